@@ -0,0 +1,127 @@
+package algorithm
+
+import (
+	"math/bits"
+)
+
+const bitsPerWord = 64
+
+// BitSet compact set of non-negative integers,
+// more memory-efficient than `map[int]struct{}` for dense ids.
+//
+// not thread-safe.
+type BitSet struct {
+	words []uint64
+}
+
+// NewBitSet create new BitSet with capacity of n bits,
+// the set will grow automatically when Set bigger index.
+func NewBitSet(n int) *BitSet {
+	if n < 0 {
+		n = 0
+	}
+
+	return &BitSet{
+		words: make([]uint64, (n+bitsPerWord-1)/bitsPerWord),
+	}
+}
+
+// Len return the number of bits the set can hold without growing
+func (b *BitSet) Len() int {
+	return len(b.words) * bitsPerWord
+}
+
+// Set set bit i to 1, grow the set if i is out of range
+//
+// panic if i is negative
+func (b *BitSet) Set(i int) {
+	if i < 0 {
+		panic("bitset: negative index")
+	}
+
+	if w := i / bitsPerWord; w >= len(b.words) {
+		b.grow(w + 1)
+	}
+
+	b.words[i/bitsPerWord] |= 1 << uint(i%bitsPerWord)
+}
+
+// Clear set bit i to 0
+func (b *BitSet) Clear(i int) {
+	if i < 0 || i/bitsPerWord >= len(b.words) {
+		return
+	}
+
+	b.words[i/bitsPerWord] &^= 1 << uint(i%bitsPerWord)
+}
+
+// Test return true if bit i is 1
+func (b *BitSet) Test(i int) bool {
+	if i < 0 || i/bitsPerWord >= len(b.words) {
+		return false
+	}
+
+	return b.words[i/bitsPerWord]&(1<<uint(i%bitsPerWord)) != 0
+}
+
+// Count return the number of bits set to 1
+func (b *BitSet) Count() (n int) {
+	for _, w := range b.words {
+		n += bits.OnesCount64(w)
+	}
+
+	return n
+}
+
+// And set b to the intersection of b and other
+func (b *BitSet) And(other *BitSet) {
+	for i := range b.words {
+		if i < len(other.words) {
+			b.words[i] &= other.words[i]
+		} else {
+			b.words[i] = 0
+		}
+	}
+}
+
+// Or set b to the union of b and other
+func (b *BitSet) Or(other *BitSet) {
+	if len(other.words) > len(b.words) {
+		b.grow(len(other.words))
+	}
+
+	for i, w := range other.words {
+		b.words[i] |= w
+	}
+}
+
+// AndNot remove all bits in other from b
+func (b *BitSet) AndNot(other *BitSet) {
+	for i := 0; i < len(b.words) && i < len(other.words); i++ {
+		b.words[i] &^= other.words[i]
+	}
+}
+
+// Clone return a copy of b
+func (b *BitSet) Clone() *BitSet {
+	return &BitSet{
+		words: append([]uint64(nil), b.words...),
+	}
+}
+
+func (b *BitSet) grow(nWords int) {
+	if nWords <= cap(b.words) {
+		b.words = b.words[:nWords]
+		return
+	}
+
+	// double the capacity to amortize growth cost
+	newCap := 2 * cap(b.words)
+	if newCap < nWords {
+		newCap = nWords
+	}
+
+	words := make([]uint64, nWords, newCap)
+	copy(words, b.words)
+	b.words = words
+}
@@ -0,0 +1,93 @@
+package algorithm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitSet(t *testing.T) {
+	b := NewBitSet(10)
+	require.Equal(t, 64, b.Len())
+	require.Zero(t, b.Count())
+
+	b.Set(0)
+	b.Set(3)
+	b.Set(63)
+	require.True(t, b.Test(0))
+	require.True(t, b.Test(3))
+	require.True(t, b.Test(63))
+	require.False(t, b.Test(1))
+	require.False(t, b.Test(-1))
+	require.False(t, b.Test(1000))
+	require.Equal(t, 3, b.Count())
+
+	// grow
+	b.Set(1000)
+	require.True(t, b.Test(1000))
+	require.GreaterOrEqual(t, b.Len(), 1001)
+	require.Equal(t, 4, b.Count())
+
+	b.Clear(3)
+	b.Clear(5000)
+	b.Clear(-1)
+	require.False(t, b.Test(3))
+	require.Equal(t, 3, b.Count())
+
+	require.Panics(t, func() { b.Set(-1) })
+}
+
+func TestBitSet_Operations(t *testing.T) {
+	newSet := func(ids ...int) *BitSet {
+		b := NewBitSet(0)
+		for _, i := range ids {
+			b.Set(i)
+		}
+
+		return b
+	}
+	members := func(b *BitSet) (ids []int) {
+		for i := 0; i < b.Len(); i++ {
+			if b.Test(i) {
+				ids = append(ids, i)
+			}
+		}
+
+		return ids
+	}
+
+	t.Run("and", func(t *testing.T) {
+		b := newSet(1, 2, 100, 200)
+		b.And(newSet(2, 100))
+		require.Equal(t, []int{2, 100}, members(b))
+	})
+
+	t.Run("or", func(t *testing.T) {
+		b := newSet(1, 2)
+		b.Or(newSet(2, 300))
+		require.Equal(t, []int{1, 2, 300}, members(b))
+	})
+
+	t.Run("and not", func(t *testing.T) {
+		b := newSet(1, 2, 100)
+		b.AndNot(newSet(2, 500))
+		require.Equal(t, []int{1, 100}, members(b))
+	})
+
+	t.Run("clone", func(t *testing.T) {
+		b := newSet(1, 2)
+		c := b.Clone()
+		c.Set(3)
+		require.Equal(t, []int{1, 2}, members(b))
+		require.Equal(t, []int{1, 2, 3}, members(c))
+	})
+}
+
+func BenchmarkBitSet(b *testing.B) {
+	set := NewBitSet(1 << 20)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		set.Set(i % (1 << 20))
+		_ = set.Test(i % (1 << 20))
+	}
+}
@@ -0,0 +1,102 @@
+package algorithm
+
+import (
+	"hash/fnv"
+	"math"
+	"sync/atomic"
+
+	"github.com/Laisky/errors/v2"
+	"github.com/cespare/xxhash"
+)
+
+// BloomFilter probabilistic set that may report false positives
+// but never false negatives.
+//
+// Add and MayContain are safe for concurrent use.
+//
+// # False positive rate
+//
+// with m bits, k hash functions and n inserted items,
+// the false positive rate is approximately
+//
+//	p = (1 - e^(-k*n/m))^k
+//
+// for expected n and p, the optimal m and k are
+//
+//	m = -n * ln(p) / (ln2)^2
+//	k = m / n * ln2
+type BloomFilter struct {
+	words []uint64
+	nBits uint64
+	nHash uint64
+}
+
+// NewBloomFilter create new BloomFilter that holds expectedN items
+// with false positive rate falsePositiveRate
+func NewBloomFilter(expectedN int, falsePositiveRate float64) (*BloomFilter, error) {
+	if expectedN <= 0 {
+		return nil, errors.Errorf("expectedN should greater than 0, got %d", expectedN)
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		return nil, errors.Errorf("falsePositiveRate should in (0, 1), got %v", falsePositiveRate)
+	}
+
+	nBits, nHash := BloomFilterOptimalParams(expectedN, falsePositiveRate)
+	return &BloomFilter{
+		words: make([]uint64, (nBits+bitsPerWord-1)/bitsPerWord),
+		nBits: uint64(nBits),
+		nHash: uint64(nHash),
+	}, nil
+}
+
+// BloomFilterOptimalParams calculate optimal bits count and hash functions count
+func BloomFilterOptimalParams(expectedN int, falsePositiveRate float64) (nBits, nHash int) {
+	n := float64(expectedN)
+	m := math.Ceil(-n * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	k := math.Round(m / n * math.Ln2)
+
+	return int(math.Max(m, 1)), int(math.Max(k, 1))
+}
+
+// Cap return the number of bits
+func (b *BloomFilter) Cap() int {
+	return int(b.nBits)
+}
+
+// HashCount return the number of hash functions
+func (b *BloomFilter) HashCount() int {
+	return int(b.nHash)
+}
+
+// hashes double hashing by xxhash and fnv-1a,
+// the i-th hash is h1 + i*h2
+func (b *BloomFilter) hashes(data []byte) (h1, h2 uint64) {
+	h1 = xxhash.Sum64(data)
+	hasher := fnv.New64a()
+	_, _ = hasher.Write(data)
+	h2 = hasher.Sum64() | 1 // make sure h2 is odd to cover all bits
+	return h1, h2
+}
+
+// Add add data to filter
+func (b *BloomFilter) Add(data []byte) {
+	h1, h2 := b.hashes(data)
+	for i := uint64(0); i < b.nHash; i++ {
+		bit := (h1 + i*h2) % b.nBits
+		atomic.OrUint64(&b.words[bit/bitsPerWord], 1<<(bit%bitsPerWord))
+	}
+}
+
+// MayContain return false if data is definitely not in filter,
+// return true if data may be in filter.
+func (b *BloomFilter) MayContain(data []byte) bool {
+	h1, h2 := b.hashes(data)
+	for i := uint64(0); i < b.nHash; i++ {
+		bit := (h1 + i*h2) % b.nBits
+		if atomic.LoadUint64(&b.words[bit/bitsPerWord])&(1<<(bit%bitsPerWord)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
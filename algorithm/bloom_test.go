@@ -0,0 +1,85 @@
+package algorithm
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBloomFilterOptimalParams(t *testing.T) {
+	// from https://hur.st/bloomfilter/?n=1000000&p=0.01
+	nBits, nHash := BloomFilterOptimalParams(1000000, 0.01)
+	require.Equal(t, 9585059, nBits)
+	require.Equal(t, 7, nHash)
+
+	nBits, nHash = BloomFilterOptimalParams(1, 0.99)
+	require.Equal(t, 1, nBits)
+	require.Equal(t, 1, nHash)
+}
+
+func TestNewBloomFilter(t *testing.T) {
+	_, err := NewBloomFilter(0, 0.01)
+	require.Error(t, err)
+	_, err = NewBloomFilter(10, 0)
+	require.Error(t, err)
+	_, err = NewBloomFilter(10, 1)
+	require.Error(t, err)
+}
+
+func TestBloomFilter_FalsePositiveRate(t *testing.T) {
+	const (
+		n      = 100000
+		fpr    = 0.01
+		nProbe = 200000
+	)
+
+	bf, err := NewBloomFilter(n, fpr)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < n; i += 8 {
+				bf.Add([]byte("member-" + strconv.Itoa(i)))
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// no false negatives
+	for i := 0; i < n; i++ {
+		require.True(t, bf.MayContain([]byte("member-"+strconv.Itoa(i))))
+	}
+
+	var falsePositive int
+	for i := 0; i < nProbe; i++ {
+		if bf.MayContain([]byte("other-" + strconv.Itoa(i))) {
+			falsePositive++
+		}
+	}
+
+	rate := float64(falsePositive) / nProbe
+	t.Logf("false positive rate: %v", rate)
+	require.Less(t, rate, fpr*1.5)
+}
+
+func BenchmarkBloomFilter(b *testing.B) {
+	bf, err := NewBloomFilter(1000000, 0.01)
+	require.NoError(b, err)
+	data := []byte("hello world")
+
+	b.Run("add", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			bf.Add(data)
+		}
+	})
+	b.Run("may contain", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			bf.MayContain(data)
+		}
+	})
+}
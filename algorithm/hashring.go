@@ -0,0 +1,118 @@
+package algorithm
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/cespare/xxhash"
+)
+
+// HashRing consistent hashing ring with virtual nodes
+//
+// it's safe for concurrent use.
+type HashRing struct {
+	mu       sync.RWMutex
+	replicas int
+	hashes   []uint64
+	owners   map[uint64]string
+	nodes    map[string]struct{}
+}
+
+// NewHashRing create new HashRing,
+// each node will be mapped to replicas virtual nodes on the ring.
+//
+// more replicas means more balanced distribution, replicas less than 1 will be set to 1.
+func NewHashRing(replicas int) *HashRing {
+	if replicas < 1 {
+		replicas = 1
+	}
+
+	return &HashRing{
+		replicas: replicas,
+		owners:   map[uint64]string{},
+		nodes:    map[string]struct{}{},
+	}
+}
+
+func (r *HashRing) vnodeHash(node string, i int) uint64 {
+	return xxhash.Sum64String(strconv.Itoa(i) + "#" + node)
+}
+
+// Add add node to ring, do nothing if node already exists
+func (r *HashRing) Add(node string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.nodes[node]; ok {
+		return
+	}
+
+	r.nodes[node] = struct{}{}
+	for i := 0; i < r.replicas; i++ {
+		h := r.vnodeHash(node, i)
+		if _, ok := r.owners[h]; ok {
+			// hash collision, keep the first owner
+			continue
+		}
+
+		r.owners[h] = node
+		r.hashes = append(r.hashes, h)
+	}
+
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+}
+
+// Remove remove node from ring
+func (r *HashRing) Remove(node string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.nodes[node]; !ok {
+		return
+	}
+
+	delete(r.nodes, node)
+	hashes := r.hashes[:0]
+	for _, h := range r.hashes {
+		if r.owners[h] == node {
+			delete(r.owners, h)
+			continue
+		}
+
+		hashes = append(hashes, h)
+	}
+	r.hashes = hashes
+}
+
+// Get get the node that key belongs to, return empty string if ring is empty
+func (r *HashRing) Get(key string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.hashes) == 0 {
+		return ""
+	}
+
+	h := xxhash.Sum64String(key)
+	idx := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if idx == len(r.hashes) {
+		idx = 0
+	}
+
+	return r.owners[r.hashes[idx]]
+}
+
+// Nodes return all nodes in ring
+func (r *HashRing) Nodes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	nodes := make([]string, 0, len(r.nodes))
+	for node := range r.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	return nodes
+}
@@ -0,0 +1,69 @@
+package algorithm
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashRing(t *testing.T) {
+	ring := NewHashRing(0)
+	require.Empty(t, ring.Get("key"))
+
+	ring.Add("node-1")
+	ring.Add("node-1")
+	require.Equal(t, "node-1", ring.Get("key"))
+	require.Equal(t, []string{"node-1"}, ring.Nodes())
+
+	ring.Add("node-2")
+	require.Equal(t, []string{"node-1", "node-2"}, ring.Nodes())
+
+	ring.Remove("node-1")
+	ring.Remove("not-exists")
+	require.Equal(t, "node-2", ring.Get("key"))
+
+	ring.Remove("node-2")
+	require.Empty(t, ring.Get("key"))
+}
+
+func TestHashRing_KeyMovement(t *testing.T) {
+	const nKeys = 100000
+	ring := NewHashRing(200)
+	for i := 0; i < 10; i++ {
+		ring.Add("node-" + strconv.Itoa(i))
+	}
+
+	before := make([]string, nKeys)
+	dist := map[string]int{}
+	for i := range before {
+		before[i] = ring.Get("key-" + strconv.Itoa(i))
+		dist[before[i]]++
+	}
+
+	// distribution should be roughly balanced
+	for node, n := range dist {
+		require.InDelta(t, nKeys/10, n, nKeys/10*0.3, node)
+	}
+
+	ring.Add("node-new")
+	var moved int
+	for i := range before {
+		got := ring.Get("key-" + strconv.Itoa(i))
+		if got != before[i] {
+			moved++
+			require.Equal(t, "node-new", got, "keys should only move to the new node")
+		}
+	}
+
+	// ideally 1/11 of keys should be moved
+	ratio := float64(moved) / nKeys
+	t.Logf("moved %.2f%% keys", ratio*100)
+	require.InDelta(t, 1.0/11, ratio, 0.03)
+
+	// remove the new node, all keys should go back
+	ring.Remove("node-new")
+	for i := range before {
+		require.Equal(t, before[i], ring.Get("key-"+strconv.Itoa(i)))
+	}
+}
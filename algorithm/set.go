@@ -0,0 +1,165 @@
+package algorithm
+
+import "sync"
+
+// Set generic set based on map
+//
+// not thread-safe, use NewSyncSet if needed.
+type Set[T comparable] struct {
+	// mu is nil if set is not thread-safe
+	mu    *sync.RWMutex
+	items map[T]struct{}
+}
+
+// NewSet create new Set with items
+func NewSet[T comparable](items ...T) *Set[T] {
+	s := &Set[T]{items: make(map[T]struct{}, len(items))}
+	for _, v := range items {
+		s.items[v] = struct{}{}
+	}
+
+	return s
+}
+
+// NewSyncSet create new thread-safe Set with items,
+// sets returned by its Union/Intersect/Difference are thread-safe too.
+func NewSyncSet[T comparable](items ...T) *Set[T] {
+	s := NewSet(items...)
+	s.mu = new(sync.RWMutex)
+	return s
+}
+
+func (s *Set[T]) lock() {
+	if s.mu != nil {
+		s.mu.Lock()
+	}
+}
+
+func (s *Set[T]) unlock() {
+	if s.mu != nil {
+		s.mu.Unlock()
+	}
+}
+
+func (s *Set[T]) rlock() {
+	if s.mu != nil {
+		s.mu.RLock()
+	}
+}
+
+func (s *Set[T]) runlock() {
+	if s.mu != nil {
+		s.mu.RUnlock()
+	}
+}
+
+// newWithSameSync create empty set that has the same thread-safety as s
+func (s *Set[T]) newWithSameSync() *Set[T] {
+	if s.mu != nil {
+		return NewSyncSet[T]()
+	}
+
+	return NewSet[T]()
+}
+
+// Add add items to set
+func (s *Set[T]) Add(items ...T) {
+	s.lock()
+	defer s.unlock()
+
+	for _, v := range items {
+		s.items[v] = struct{}{}
+	}
+}
+
+// Remove remove items from set, do nothing if item not exists
+func (s *Set[T]) Remove(items ...T) {
+	s.lock()
+	defer s.unlock()
+
+	for _, v := range items {
+		delete(s.items, v)
+	}
+}
+
+// Contains if set contains item
+func (s *Set[T]) Contains(item T) bool {
+	s.rlock()
+	defer s.runlock()
+
+	_, ok := s.items[item]
+	return ok
+}
+
+// Len return the number of items
+func (s *Set[T]) Len() int {
+	s.rlock()
+	defer s.runlock()
+
+	return len(s.items)
+}
+
+// ToSlice return items in set, order is not guaranteed
+func (s *Set[T]) ToSlice() []T {
+	s.rlock()
+	defer s.runlock()
+
+	items := make([]T, 0, len(s.items))
+	for v := range s.items {
+		items = append(items, v)
+	}
+
+	return items
+}
+
+// Union return a new set contains items in s or other
+func (s *Set[T]) Union(other *Set[T]) *Set[T] {
+	// copy other first, so only one set is locked at a time
+	otherItems := other.ToSlice()
+
+	r := s.newWithSameSync()
+	s.rlock()
+	for v := range s.items {
+		r.items[v] = struct{}{}
+	}
+	s.runlock()
+
+	for _, v := range otherItems {
+		r.items[v] = struct{}{}
+	}
+
+	return r
+}
+
+// Intersect return a new set contains items in both s and other
+func (s *Set[T]) Intersect(other *Set[T]) *Set[T] {
+	otherItems := other.ToSlice()
+
+	r := s.newWithSameSync()
+	s.rlock()
+	defer s.runlock()
+	for _, v := range otherItems {
+		if _, ok := s.items[v]; ok {
+			r.items[v] = struct{}{}
+		}
+	}
+
+	return r
+}
+
+// Difference return a new set contains items in s but not in other
+func (s *Set[T]) Difference(other *Set[T]) *Set[T] {
+	otherItems := other.ToSlice()
+
+	r := s.newWithSameSync()
+	s.rlock()
+	defer s.runlock()
+	for v := range s.items {
+		r.items[v] = struct{}{}
+	}
+	for _, v := range otherItems {
+		delete(r.items, v)
+	}
+
+	return r
+}
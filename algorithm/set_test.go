@@ -0,0 +1,67 @@
+package algorithm
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSet(t *testing.T) {
+	s := NewSet(1, 2, 2, 3)
+	require.Equal(t, 3, s.Len())
+	require.True(t, s.Contains(2))
+	require.False(t, s.Contains(4))
+
+	s.Add(4, 5)
+	s.Remove(1, 100)
+	require.ElementsMatch(t, []int{2, 3, 4, 5}, s.ToSlice())
+
+	empty := NewSet[int]()
+	require.Zero(t, empty.Len())
+	require.Empty(t, empty.ToSlice())
+}
+
+func TestSet_algebra(t *testing.T) {
+	a := NewSet(1, 2, 3)
+	b := NewSet(2, 3, 4)
+	empty := NewSet[int]()
+
+	require.ElementsMatch(t, []int{1, 2, 3, 4}, a.Union(b).ToSlice())
+	require.ElementsMatch(t, []int{2, 3}, a.Intersect(b).ToSlice())
+	require.ElementsMatch(t, []int{1}, a.Difference(b).ToSlice())
+	require.ElementsMatch(t, []int{4}, b.Difference(a).ToSlice())
+
+	require.ElementsMatch(t, a.ToSlice(), a.Union(empty).ToSlice())
+	require.Empty(t, a.Intersect(empty).ToSlice())
+	require.ElementsMatch(t, a.ToSlice(), a.Difference(empty).ToSlice())
+	require.ElementsMatch(t, a.ToSlice(), a.Union(a).ToSlice())
+	require.Empty(t, a.Difference(a).ToSlice())
+
+	// operands are not modified
+	require.ElementsMatch(t, []int{1, 2, 3}, a.ToSlice())
+	require.ElementsMatch(t, []int{2, 3, 4}, b.ToSlice())
+}
+
+func TestSyncSet(t *testing.T) {
+	s := NewSyncSet[int]()
+	other := NewSyncSet(0, 1, 2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s.Add(i)
+			_ = s.Contains(i)
+			_ = s.Union(other)
+			_ = other.Intersect(s)
+			_ = s.Difference(other)
+		}(i)
+	}
+	wg.Wait()
+
+	require.Equal(t, 100, s.Len())
+	require.NotNil(t, s.Union(other).mu)
+	require.Nil(t, NewSet[int]().Union(other).mu)
+}
@@ -0,0 +1,246 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Laisky/errors/v2"
+)
+
+type bindOption struct {
+	prefix    string
+	separator string
+}
+
+// BindOption options for BindConfig
+type BindOption func(*bindOption) error
+
+// WithBindPrefix bind keys under prefix, like `server`
+func WithBindPrefix(prefix string) BindOption {
+	return func(o *bindOption) error {
+		o.prefix = strings.Trim(prefix, ".")
+		return nil
+	}
+}
+
+// WithBindSeparator set separator to split string into slice, default is `,`
+func WithBindSeparator(sep string) BindOption {
+	return func(o *bindOption) error {
+		if sep == "" {
+			return errors.New("separator should not be empty")
+		}
+
+		o.separator = sep
+		return nil
+	}
+}
+
+var (
+	bindDurationType = reflect.TypeOf(time.Duration(0))
+	bindTimeType     = reflect.TypeOf(time.Time{})
+)
+
+// BindConfig fill exported fields of struct pointer dst by values from get.
+//
+// key of field is read from tag `cfg`, defaults to field name by ToSnakeCase,
+// nested struct's key is prefixed by its own key with `.`,
+// so field `Port` in field `Server` is read by `server.port`.
+// tag `cfg:"-"` skips the field.
+//
+// values are converted to field's type, strings are parsed as
+// int, float, bool, time.Duration, time.Time in RFC3339,
+// or split by separator into slice.
+//
+// time.Duration should have unit like `30s`, bare numbers like `30`
+// are rejected rather than guessing the unit, except `0`.
+//
+// when key is absent, tag `default` is used if set,
+// otherwise the field is left untouched, or reported if tagged `required:"true"`.
+// all missing and invalid keys are joined in returned error.
+func BindConfig(get func(key string) (any, bool), dst any, opts ...BindOption) error {
+	opt := &bindOption{separator: ","}
+	for _, f := range opts {
+		if err := f(opt); err != nil {
+			return errors.Wrap(err, "apply option")
+		}
+	}
+
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errors.Errorf("dst should be non-nil pointer to struct, got %T", dst)
+	}
+
+	var errs []error
+	bindStruct(get, opt, opt.prefix, v.Elem(), &errs)
+	return errors.Join(errs...)
+}
+
+func bindStruct(get func(key string) (any, bool), opt *bindOption,
+	prefix string, v reflect.Value, errs *[]error) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Tag.Get("cfg")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = ToSnakeCase(field.Name)
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		fv := v.Field(i)
+		if field.Type.Kind() == reflect.Struct && field.Type != bindTimeType {
+			bindStruct(get, opt, key, fv, errs)
+			continue
+		}
+
+		val, ok := get(key)
+		if !ok {
+			if val, ok = field.Tag.Lookup("default"); !ok {
+				if field.Tag.Get("required") == "true" {
+					*errs = append(*errs, errors.Errorf("missing required key %q", key))
+				}
+
+				continue
+			}
+		}
+
+		if err := bindValue(fv, val, opt); err != nil {
+			*errs = append(*errs, errors.Wrapf(err, "invalid value of key %q", key))
+		}
+	}
+}
+
+// bindValue convert val to type of v and set
+func bindValue(v reflect.Value, val any, opt *bindOption) error {
+	if val == nil {
+		return errors.New("value is nil")
+	}
+
+	rv := reflect.ValueOf(val)
+	if rv.Type().AssignableTo(v.Type()) {
+		v.Set(rv)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(v.Type().Elem())
+		if err := bindValue(elem.Elem(), val, opt); err != nil {
+			return err
+		}
+
+		v.Set(elem)
+		return nil
+	case reflect.Slice:
+		var items []any
+		switch {
+		case rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array:
+			for i := 0; i < rv.Len(); i++ {
+				items = append(items, rv.Index(i).Interface())
+			}
+		case rv.Kind() == reflect.String:
+			for _, item := range strings.Split(rv.String(), opt.separator) {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+		default:
+			return errors.Errorf("cannot convert %T to %s", val, v.Type())
+		}
+
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := bindValue(slice.Index(i), item, opt); err != nil {
+				return errors.Wrapf(err, "item %d", i)
+			}
+		}
+
+		v.Set(slice)
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		return errors.Errorf("cannot convert %T to %s", val, v.Type())
+	case reflect.Float32, reflect.Float64:
+		// avoid exponent format, numbers decoded from json are float64
+		return bindString(v, strconv.FormatFloat(rv.Float(), 'f', -1, 64))
+	}
+
+	return bindString(v, strings.TrimSpace(fmt.Sprint(val)))
+}
+
+// bindString parse s to scalar type of v and set
+func bindString(v reflect.Value, s string) error {
+	switch {
+	case v.Type() == bindDurationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			if _, nerr := strconv.ParseFloat(s, 64); nerr == nil {
+				return errors.Errorf("duration %q should have unit, like %q", s, s+"s")
+			}
+
+			return errors.Wrap(err, "parse duration")
+		}
+
+		v.SetInt(int64(d))
+		return nil
+	case v.Type() == bindTimeType:
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return errors.Wrap(err, "parse time")
+		}
+
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.Wrap(err, "parse bool")
+		}
+
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return errors.Wrap(err, "parse int")
+		}
+
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return errors.Wrap(err, "parse uint")
+		}
+
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return errors.Wrap(err, "parse float")
+		}
+
+		v.SetFloat(n)
+	default:
+		return errors.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Laisky/go-utils/v4/json"
+)
+
+// mapConfigGetter read nested map by dotted key
+func mapConfigGetter(m map[string]any) func(key string) (any, bool) {
+	return func(key string) (any, bool) {
+		var cur any = m
+		for _, part := range strings.Split(key, ".") {
+			sub, ok := cur.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+
+			if cur, ok = sub[part]; !ok {
+				return nil, false
+			}
+		}
+
+		return cur, true
+	}
+}
+
+type bindTestConfig struct {
+	Name   string `required:"true"`
+	Debug  bool
+	Server struct {
+		Host    string        `default:"127.0.0.1"`
+		Port    int           `default:"8080"`
+		Timeout time.Duration `cfg:"timeout" default:"3s"`
+		Origins []string      `cfg:"allowed-origins"`
+	}
+	DB struct {
+		DSN      string `cfg:"dsn" required:"true"`
+		MaxConns *int   `cfg:"max-conns"`
+		Ratio    float64
+		MaxIdle  int
+	} `cfg:"database"`
+	Started time.Time
+	Ignored string `cfg:"-"`
+	private string
+}
+
+func TestBindConfig(t *testing.T) {
+	t.Run("from json", func(t *testing.T) {
+		fixture := `{
+			"name": "svc",
+			"debug": true,
+			"server": {
+				"port": 9090,
+				"timeout": "1m30s",
+				"allowed-origins": ["a.com", "b.com"]
+			},
+			"database": {
+				"dsn": "postgres://localhost",
+				"max-conns": 1000000,
+				"ratio": 0.5,
+				"max_idle": 5
+			},
+			"started": "2024-01-02T03:04:05Z",
+			"ignored": "x",
+			"private": "x"
+		}`
+		var m map[string]any
+		require.NoError(t, json.Unmarshal([]byte(fixture), &m))
+
+		cfg := new(bindTestConfig)
+		require.NoError(t, BindConfig(mapConfigGetter(m), cfg))
+
+		require.Equal(t, "svc", cfg.Name)
+		require.True(t, cfg.Debug)
+		require.Equal(t, "127.0.0.1", cfg.Server.Host)
+		require.Equal(t, 9090, cfg.Server.Port)
+		require.Equal(t, 90*time.Second, cfg.Server.Timeout)
+		require.Equal(t, []string{"a.com", "b.com"}, cfg.Server.Origins)
+		require.Equal(t, "postgres://localhost", cfg.DB.DSN)
+		require.NotNil(t, cfg.DB.MaxConns)
+		require.Equal(t, 1000000, *cfg.DB.MaxConns)
+		require.Equal(t, 0.5, cfg.DB.Ratio)
+		require.Equal(t, 5, cfg.DB.MaxIdle)
+		require.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), cfg.Started)
+		require.Empty(t, cfg.Ignored)
+		require.Empty(t, cfg.private)
+	})
+
+	t.Run("from string source", func(t *testing.T) {
+		src := map[string]string{
+			"name":                   "svc",
+			"debug":                  "1",
+			"server.port":            "443",
+			"server.allowed-origins": "a.com, b.com,",
+			"database.dsn":           "mysql://localhost",
+		}
+		get := func(key string) (any, bool) {
+			v, ok := src[key]
+			return v, ok
+		}
+
+		cfg := new(bindTestConfig)
+		require.NoError(t, BindConfig(get, cfg))
+		require.True(t, cfg.Debug)
+		require.Equal(t, 443, cfg.Server.Port)
+		require.Equal(t, 3*time.Second, cfg.Server.Timeout)
+		require.Equal(t, []string{"a.com", "b.com"}, cfg.Server.Origins)
+		require.Nil(t, cfg.DB.MaxConns)
+
+		var server struct {
+			Port    int
+			Origins []string `cfg:"allowed-origins"`
+		}
+		src["server.allowed-origins"] = "a.com|b.com"
+		require.NoError(t, BindConfig(get, &server,
+			WithBindPrefix("server"), WithBindSeparator("|")))
+		require.Equal(t, 443, server.Port)
+		require.Equal(t, []string{"a.com", "b.com"}, server.Origins)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		src := map[string]any{
+			"server.port":    "http",
+			"server.timeout": "10",
+		}
+		get := func(key string) (any, bool) {
+			v, ok := src[key]
+			return v, ok
+		}
+
+		err := BindConfig(get, new(bindTestConfig))
+		require.ErrorContains(t, err, `missing required key "name"`)
+		require.ErrorContains(t, err, `missing required key "database.dsn"`)
+		require.ErrorContains(t, err, `invalid value of key "server.port"`)
+		require.ErrorContains(t, err, `invalid value of key "server.timeout"`)
+
+		require.ErrorContains(t, err, `duration "10" should have unit, like "10s"`)
+
+		require.Error(t, BindConfig(get, bindTestConfig{}))
+		require.Error(t, BindConfig(get, (*bindTestConfig)(nil)))
+		require.Error(t, BindConfig(get, new(bindTestConfig), WithBindSeparator("")))
+	})
+
+	t.Run("duration without unit", func(t *testing.T) {
+		var m map[string]any
+		require.NoError(t, json.Unmarshal([]byte(`{"timeout": 30, "zero": 0, "typed": 0}`), &m))
+		m["typed"] = 2 * time.Second
+
+		var cfg struct {
+			Timeout time.Duration
+			Zero    time.Duration
+			Typed   time.Duration
+		}
+		err := BindConfig(mapConfigGetter(m), &cfg)
+		require.ErrorContains(t, err, `invalid value of key "timeout"`)
+		require.ErrorContains(t, err, `duration "30" should have unit, like "30s"`)
+		require.Zero(t, cfg.Zero)
+		require.Equal(t, 2*time.Second, cfg.Typed)
+
+		m["timeout"] = "30s"
+		require.NoError(t, BindConfig(mapConfigGetter(m), &cfg))
+		require.Equal(t, 30*time.Second, cfg.Timeout)
+	})
+}
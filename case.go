@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// splitWords split s into words by non-alphanumeric separators and case changes,
+// acronyms are kept as one word, digits belong to the preceding word,
+// like `HTTPServer2Config` to `HTTP`, `Server2`, `Config`.
+func splitWords(s string) []string {
+	var (
+		words []string
+		cur   []rune
+	)
+	flush := func() {
+		if len(cur) != 0 {
+			words = append(words, string(cur))
+			cur = cur[:0]
+		}
+	}
+
+	runes := []rune(s)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+
+		if unicode.IsUpper(r) && len(cur) != 0 {
+			prev := cur[len(cur)-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+				(unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+
+		cur = append(cur, r)
+	}
+	flush()
+
+	return words
+}
+
+func joinLowerWords(s, sep string) string {
+	words := splitWords(s)
+	for i := range words {
+		words[i] = strings.ToLower(words[i])
+	}
+
+	return strings.Join(words, sep)
+}
+
+// titleWord upper the first rune and lower the rest
+func titleWord(word string) string {
+	r, size := utf8.DecodeRuneInString(word)
+	return string(unicode.ToUpper(r)) + strings.ToLower(word[size:])
+}
+
+// ToSnakeCase convert s to snake_case, like `HTTPServer` to `http_server`
+func ToSnakeCase(s string) string {
+	return joinLowerWords(s, "_")
+}
+
+// ToKebabCase convert s to kebab-case, like `HTTPServer` to `http-server`
+func ToKebabCase(s string) string {
+	return joinLowerWords(s, "-")
+}
+
+// ToPascalCase convert s to PascalCase, like `http_server` to `HttpServer`
+func ToPascalCase(s string) string {
+	words := splitWords(s)
+	for i := range words {
+		words[i] = titleWord(words[i])
+	}
+
+	return strings.Join(words, "")
+}
+
+// ToCamelCase convert s to camelCase, like `HTTPServer` to `httpServer`
+func ToCamelCase(s string) string {
+	words := splitWords(s)
+	for i := range words {
+		if i == 0 {
+			words[i] = strings.ToLower(words[i])
+		} else {
+			words[i] = titleWord(words[i])
+		}
+	}
+
+	return strings.Join(words, "")
+}
+
+// slugTransliterations ascii of common precomposed latin letters,
+// stands in for NFD and stripping marks without depending on x/text,
+// also covers letters that have no decomposition, like `ł`, `ø` and `ß`.
+var slugTransliterations = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'ç': "c", 'ć': "c", 'č': "c", 'ĉ': "c", 'ċ': "c",
+	'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ğ': "g", 'ģ': "g",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'į': "i", 'ı': "i",
+	'ķ': "k",
+	'ł': "l", 'ľ': "l", 'ļ': "l", 'ĺ': "l",
+	'ñ': "n", 'ń': "n", 'ň': "n", 'ņ': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o",
+	'ŕ': "r", 'ř': "r",
+	'ś': "s", 'š': "s", 'ş': "s", 'ș': "s",
+	'ť': "t", 'ţ': "t", 'ț': "t",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ý': "y", 'ÿ': "y",
+	'ź': "z", 'ż': "z", 'ž': "z",
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'þ': "th",
+}
+
+// Slugify convert s to lowercase slug joined by `-`, like `Héllo, Wörld!` to `hello-world`.
+//
+// common latin letters are transliterated to ascii, combining marks are dropped,
+// other letters and digits are kept, the rest are separators. if maxLen > 0, slug is trimmed to at most maxLen bytes
+// at word boundary, the first word is cut if it is longer than maxLen.
+func Slugify(s string, maxLen int) string {
+	var (
+		b       strings.Builder
+		pendSep bool
+	)
+	for _, r := range s {
+		r = unicode.ToLower(r)
+		if ascii, ok := slugTransliterations[r]; ok {
+			if pendSep && b.Len() != 0 {
+				b.WriteByte('-')
+			}
+			pendSep = false
+			b.WriteString(ascii)
+			continue
+		}
+
+		if unicode.Is(unicode.Mn, r) {
+			// combining mark of decomposed letter, like `e` + U+0301
+			continue
+		}
+
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			pendSep = true
+			continue
+		}
+
+		if pendSep && b.Len() != 0 {
+			b.WriteByte('-')
+		}
+		pendSep = false
+		b.WriteRune(r)
+	}
+
+	slug := b.String()
+	if maxLen <= 0 || len(slug) <= maxLen {
+		return slug
+	}
+
+	if idx := strings.LastIndexByte(slug[:maxLen+1], '-'); idx > 0 {
+		return slug[:idx]
+	}
+
+	// cut at rune boundary
+	cut := maxLen
+	for cut > 0 && !utf8.RuneStart(slug[cut]) {
+		cut--
+	}
+
+	return slug[:cut]
+}
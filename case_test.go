@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaseConversion(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []struct {
+		in, snake, kebab, camel, pascal string
+	}{
+		{"", "", "", "", ""},
+		{"_-_ .", "", "", "", ""},
+		{"foo", "foo", "foo", "foo", "Foo"},
+		{"Foo", "foo", "foo", "foo", "Foo"},
+		{"fooBar", "foo_bar", "foo-bar", "fooBar", "FooBar"},
+		{"FooBar", "foo_bar", "foo-bar", "fooBar", "FooBar"},
+		{"foo_bar", "foo_bar", "foo-bar", "fooBar", "FooBar"},
+		{"foo-bar", "foo_bar", "foo-bar", "fooBar", "FooBar"},
+		{"foo bar", "foo_bar", "foo-bar", "fooBar", "FooBar"},
+		{"  foo__bar--baz  ", "foo_bar_baz", "foo-bar-baz", "fooBarBaz", "FooBarBaz"},
+		{"server.port", "server_port", "server-port", "serverPort", "ServerPort"},
+		{"HTTPServer", "http_server", "http-server", "httpServer", "HttpServer"},
+		{"HTTP", "http", "http", "http", "Http"},
+		{"ID", "id", "id", "id", "Id"},
+		{"UserID", "user_id", "user-id", "userId", "UserId"},
+		{"userIDs", "user_i_ds", "user-i-ds", "userIDs", "UserIDs"},
+		{"XMLHTTPRequest", "xmlhttp_request", "xmlhttp-request", "xmlhttpRequest", "XmlhttpRequest"},
+		{"MaxConns", "max_conns", "max-conns", "maxConns", "MaxConns"},
+		{"base64Encode", "base64_encode", "base64-encode", "base64Encode", "Base64Encode"},
+		{"HTTP2Server", "http2_server", "http2-server", "http2Server", "Http2Server"},
+		{"Version2", "version2", "version2", "version2", "Version2"},
+		{"v2_api", "v2_api", "v2-api", "v2Api", "V2Api"},
+		{"2fa code", "2fa_code", "2fa-code", "2faCode", "2faCode"},
+		{"ÜberCool", "über_cool", "über-cool", "überCool", "ÜberCool"},
+		{"straßeName", "straße_name", "straße-name", "straßeName", "StraßeName"},
+		{"日本語テキスト", "日本語テキスト", "日本語テキスト", "日本語テキスト", "日本語テキスト"},
+		{"名前Field", "名前field", "名前field", "名前field", "名前field"},
+	} {
+		require.Equal(t, c.snake, ToSnakeCase(c.in), "snake %q", c.in)
+		require.Equal(t, c.kebab, ToKebabCase(c.in), "kebab %q", c.in)
+		require.Equal(t, c.camel, ToCamelCase(c.in), "camel %q", c.in)
+		require.Equal(t, c.pascal, ToPascalCase(c.in), "pascal %q", c.in)
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []struct {
+		in     string
+		maxLen int
+		expect string
+	}{
+		{"", 0, ""},
+		{"---", 0, ""},
+		{"  !!  ", 10, ""},
+		{"Hello World", 0, "hello-world"},
+		{"Héllo, Wörld!", 0, "hello-world"},
+		{"  multiple   spaces -- and__underscores ", 0, "multiple-spaces-and-underscores"},
+		{"Crème Brûlée", 0, "creme-brulee"},
+		{"Cre\u0300me Bru\u0302le\u0301e", 0, "creme-brulee"},
+		{"A\u030angstro\u0308m", 0, "angstrom"},
+		{"Straße", 0, "strasse"},
+		{"Łódź Ærø", 0, "lodz-aero"},
+		{"Release v2.0.1", 0, "release-v2-0-1"},
+		{"HelloWorld", 0, "helloworld"},
+		{"日本語 テキスト", 0, "日本語-テキスト"},
+		{"hello world foo", 11, "hello-world"},
+		{"hello world foo", 12, "hello-world"},
+		{"hello world foo", 10, "hello"},
+		{"hello world", 11, "hello-world"},
+		{"supercalifragilistic word", 5, "super"},
+		{"日本語", 4, "日"},
+	} {
+		require.Equal(t, c.expect, Slugify(c.in, c.maxLen), "%q %d", c.in, c.maxLen)
+	}
+}
@@ -0,0 +1,41 @@
+package utils
+
+import "context"
+
+// CMDRunner run external commands, can be replaced by FakeCMDRunner in tests
+type CMDRunner interface {
+	// Run run command and return combined stdout and stderr, same as RunCMD
+	Run(ctx context.Context, app string, args ...string) ([]byte, error)
+	// RunWithEnv same as RunCMDWithEnv
+	RunWithEnv(ctx context.Context, app string,
+		args []string, envs []string, opts ...RunCMDOption) ([]byte, error)
+	// Run2 same as RunCMD2
+	Run2(ctx context.Context, app string,
+		args []string, envs []string,
+		stdoutHandler, stderrHandler func(string),
+		opts ...RunCMDOption) error
+}
+
+// DefaultCMDRunner run commands by RunCMD, RunCMDWithEnv and RunCMD2
+var DefaultCMDRunner CMDRunner = execCMDRunner{}
+
+type execCMDRunner struct{}
+
+// Run same as RunCMD
+func (execCMDRunner) Run(ctx context.Context, app string, args ...string) ([]byte, error) {
+	return RunCMD(ctx, app, args...)
+}
+
+// RunWithEnv same as RunCMDWithEnv
+func (execCMDRunner) RunWithEnv(ctx context.Context, app string,
+	args []string, envs []string, opts ...RunCMDOption) ([]byte, error) {
+	return RunCMDWithEnv(ctx, app, args, envs, opts...)
+}
+
+// Run2 same as RunCMD2
+func (execCMDRunner) Run2(ctx context.Context, app string,
+	args []string, envs []string,
+	stdoutHandler, stderrHandler func(string),
+	opts ...RunCMDOption) error {
+	return RunCMD2(ctx, app, args, envs, stdoutHandler, stderrHandler, opts...)
+}
@@ -29,7 +29,16 @@ type Sortable interface {
 	Number | string
 }
 
+// SignedNumber is a number type that could be negative
+type SignedNumber interface {
+	int | int8 | int16 | int32 | int64 |
+		float32 | float64
+}
+
 // Min return the minimal value
+//
+// panic if vals is empty.
+// NaN is ignored unless it is the first value, in which case NaN is returned.
 func Min[T Sortable](vals ...T) T {
 	if len(vals) == 0 {
 		panic("empty vals")
@@ -46,6 +55,9 @@ func Min[T Sortable](vals ...T) T {
 }
 
 // Max return the maximal value
+//
+// panic if vals is empty.
+// NaN is ignored unless it is the first value, in which case NaN is returned.
 func Max[T Sortable](vals ...T) T {
 	if len(vals) == 0 {
 		panic("empty vals")
@@ -61,6 +73,90 @@ func Max[T Sortable](vals ...T) T {
 	return max
 }
 
+// MinMaxOf return the minimal and maximal value of s in one pass,
+// ok is false if s is empty.
+//
+// NaN is ignored unless it is the first value, same as Min and Max.
+func MinMaxOf[T Sortable](s []T) (min, max T, ok bool) {
+	if len(s) == 0 {
+		return min, max, false
+	}
+
+	min, max = s[0], s[0]
+	for _, v := range s[1:] {
+		switch {
+		case v < min:
+			min = v
+		case v > max:
+			max = v
+		}
+	}
+
+	return min, max, true
+}
+
+// Clamp limit v in [lo, hi]
+//
+// panic if lo > hi. NaN v is returned as is.
+func Clamp[T Sortable](v, lo, hi T) T {
+	if lo > hi {
+		panic("lo should not be greater than hi")
+	}
+
+	switch {
+	case v < lo:
+		return lo
+	case v > hi:
+		return hi
+	default:
+		return v
+	}
+}
+
+// Abs abs(v)
+//
+// ignore int exceeds limit error, abs(MinInt64) == MaxInt64,
+// same as AbsInt64. Abs(NaN) is NaN.
+func Abs[T SignedNumber](v T) T {
+	if v < 0 {
+		v = -v
+		if v < 0 { // -MinInt overflows to MinInt
+			v = -(v + 1)
+		}
+	}
+
+	return v
+}
+
+// SumSlice return the sum of vals, return 0 if vals is empty
+//
+// overflow is not checked.
+func SumSlice[T Number](vals []T) T {
+	var sum T
+	for _, v := range vals {
+		sum += v
+	}
+
+	return sum
+}
+
+// AvgSlice return the average of vals
+//
+// panic if vals is empty, same as Min and Max.
+// vals are summed in float64 to avoid integer overflow.
+func AvgSlice[T Number](vals []T) float64 {
+	if len(vals) == 0 {
+		panic("empty vals")
+	}
+
+	var sum float64
+	for _, v := range vals {
+		sum += float64(v)
+	}
+
+	return sum / float64(len(vals))
+}
+
 // AbsInt64 abs(v)
 //
 // ignore int exceeds limit error, abs(MinInt64) == MaxInt64
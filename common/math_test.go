@@ -204,6 +204,124 @@ func TestMax(t *testing.T) {
 	require.Panics(t, func() { Max[int]() })
 }
 
+func TestMinMaxNaN(t *testing.T) {
+	nan := math.NaN()
+	require.Equal(t, 1.0, Min(1.0, nan, 2.0))
+	require.Equal(t, 2.0, Max(1.0, nan, 2.0))
+	require.True(t, math.IsNaN(Min(nan, 1.0)))
+	require.True(t, math.IsNaN(Max(nan, 1.0)))
+}
+
+func TestMinMaxOf(t *testing.T) {
+	_, _, ok := MinMaxOf([]int(nil))
+	require.False(t, ok)
+	_, _, ok = MinMaxOf([]int{})
+	require.False(t, ok)
+
+	min, max, ok := MinMaxOf([]int{7})
+	require.True(t, ok)
+	require.Equal(t, 7, min)
+	require.Equal(t, 7, max)
+
+	min, max, ok = MinMaxOf([]int{3, -1, 4, 1, -5, 9, 2})
+	require.True(t, ok)
+	require.Equal(t, -5, min)
+	require.Equal(t, 9, max)
+
+	smin, smax, ok := MinMaxOf([]string{"b", "c", "a"})
+	require.True(t, ok)
+	require.Equal(t, "a", smin)
+	require.Equal(t, "c", smax)
+
+	nan := math.NaN()
+	fmin, fmax, ok := MinMaxOf([]float64{1, nan, 2})
+	require.True(t, ok)
+	require.Equal(t, 1.0, fmin)
+	require.Equal(t, 2.0, fmax)
+}
+
+func TestClamp(t *testing.T) {
+	for _, tt := range []struct {
+		v, lo, hi, want float64
+	}{
+		{5, 0, 10, 5},
+		{-1, 0, 10, 0},
+		{11, 0, 10, 10},
+		{0, 0, 0, 0},
+		{math.Inf(1), 0, 10, 10},
+		{math.Inf(-1), 0, 10, 0},
+	} {
+		require.Equal(t, tt.want, Clamp(tt.v, tt.lo, tt.hi), "%+v", tt)
+	}
+
+	require.Equal(t, 3, Clamp(3, 1, 5))
+	require.Equal(t, uint8(5), Clamp[uint8](200, 1, 5))
+	require.Equal(t, "b", Clamp("z", "a", "b"))
+	require.True(t, math.IsNaN(Clamp(math.NaN(), 0, 1)))
+	require.Panics(t, func() { Clamp(1, 2, 1) })
+}
+
+func TestAbs(t *testing.T) {
+	require.Equal(t, 3, Abs(-3))
+	require.Equal(t, 3, Abs(3))
+	require.Equal(t, 0, Abs(0))
+	require.Equal(t, int8(math.MaxInt8), Abs(int8(math.MinInt8)))
+	require.Equal(t, int64(math.MaxInt64), Abs(int64(math.MinInt64)))
+	require.Equal(t, 1.5, Abs(-1.5))
+	require.Equal(t, float32(1.5), Abs(float32(-1.5)))
+	require.True(t, math.IsInf(Abs(math.Inf(-1)), 1))
+	require.True(t, math.IsNaN(Abs(math.NaN())))
+}
+
+func TestSumAvgSlice(t *testing.T) {
+	require.Equal(t, 6, SumSlice([]int{1, 2, 3}))
+	require.Equal(t, 0, SumSlice([]int{}))
+	require.Equal(t, uint8(0), SumSlice[uint8](nil))
+	require.InDelta(t, 0.6, SumSlice([]float64{0.1, 0.2, 0.3}), 1e-9)
+
+	require.Equal(t, 2.0, AvgSlice([]int{1, 2, 3}))
+	require.Equal(t, 1.5, AvgSlice([]int{1, 2}))
+	require.Equal(t, float64(math.MaxInt64), AvgSlice([]int64{math.MaxInt64, math.MaxInt64}))
+	require.True(t, math.IsNaN(AvgSlice([]float64{1, math.NaN()})))
+	require.Panics(t, func() { AvgSlice([]int{}) })
+}
+
+var benchMinMaxResult int
+
+func BenchmarkMinMax(b *testing.B) {
+	x, y := 3, 5
+	b.Run("generic", func(b *testing.B) {
+		var r int
+		for i := 0; i < b.N; i++ {
+			r += Clamp(Min(x+i, y), 0, 10) + Abs(x-i)
+		}
+		benchMinMaxResult = r
+	})
+
+	b.Run("hand-written", func(b *testing.B) {
+		var r int
+		for i := 0; i < b.N; i++ {
+			v := x + i
+			if y < v {
+				v = y
+			}
+			if v < 0 {
+				v = 0
+			} else if v > 10 {
+				v = 10
+			}
+
+			d := x - i
+			if d < 0 {
+				d = -d
+			}
+
+			r += v + d
+		}
+		benchMinMaxResult = r
+	})
+}
+
 // func TestIntersectSortedChans(t *testing.T) {
 // 	nChan := 5
 
@@ -33,8 +33,26 @@ var (
 	HumanReadableByteCount = common.HumanReadableByteCount
 )
 
-// Min return the minimal value
+// SignedNumber is a number type that could be negative
+type SignedNumber common.SignedNumber
+
+// Min return the minimal value, panic if vals is empty
 func Min[T Sortable](vals ...T) T { return common.Min(vals...) }
 
-// Max return the maximal value
+// Max return the maximal value, panic if vals is empty
 func Max[T Sortable](vals ...T) T { return common.Max(vals...) }
+
+// MinMaxOf return the minimal and maximal value of s, ok is false if s is empty
+func MinMaxOf[T Sortable](s []T) (min, max T, ok bool) { return common.MinMaxOf(s) }
+
+// Clamp limit v in [lo, hi], panic if lo > hi
+func Clamp[T Sortable](v, lo, hi T) T { return common.Clamp(v, lo, hi) }
+
+// Abs abs(v), abs(MinInt64) == MaxInt64
+func Abs[T SignedNumber](v T) T { return common.Abs(v) }
+
+// SumSlice return the sum of vals, return 0 if vals is empty
+func SumSlice[T Number](vals []T) T { return common.SumSlice(vals) }
+
+// AvgSlice return the average of vals, panic if vals is empty
+func AvgSlice[T Number](vals []T) float64 { return common.AvgSlice(vals) }
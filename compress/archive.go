@@ -0,0 +1,707 @@
+package compress
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Laisky/errors/v2"
+
+	gutils "github.com/Laisky/go-utils/v4"
+	"github.com/Laisky/go-utils/v4/log"
+)
+
+// ArchiveFormat format of archive
+type ArchiveFormat string
+
+const (
+	// ArchiveFormatZip zip archive
+	ArchiveFormatZip ArchiveFormat = "zip"
+	// ArchiveFormatTarGz tar archive compressed by gzip
+	ArchiveFormatTarGz ArchiveFormat = "tar.gz"
+)
+
+const (
+	defaultExtractMaxSize  = 1 * 1024 * 1024 * 1024 // 1GB
+	maxArchiveSymlinkBytes = 4096
+)
+
+// defaultArchiveModTime mtime of all entries, zip does not support time before 1980
+var defaultArchiveModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type archiveOption struct {
+	excludes []string
+	modTime  time.Time
+}
+
+// ArchiveOption options for ArchiveDir
+type ArchiveOption func(*archiveOption) error
+
+// WithArchiveExclude exclude files or directories that match any of globs,
+// globs are matched by path.Match against both the slash-separated path
+// relative to dir and the base name.
+func WithArchiveExclude(globs ...string) ArchiveOption {
+	return func(o *archiveOption) error {
+		for _, glob := range globs {
+			if _, err := path.Match(glob, ""); err != nil {
+				return errors.Wrapf(err, "invalid glob %q", glob)
+			}
+		}
+
+		o.excludes = append(o.excludes, globs...)
+		return nil
+	}
+}
+
+// WithArchiveModTime set mtime of all entries, default is 1980-01-01 UTC
+func WithArchiveModTime(modTime time.Time) ArchiveOption {
+	return func(o *archiveOption) error {
+		if modTime.IsZero() {
+			return errors.New("mod time should not be zero")
+		}
+
+		o.modTime = modTime
+		return nil
+	}
+}
+
+func (o *archiveOption) excluded(rel string) bool {
+	for _, glob := range o.excludes {
+		if ok, _ := path.Match(glob, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(glob, path.Base(rel)); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// archiveEntry file to be archived
+type archiveEntry struct {
+	// name slash-separated path relative to dir
+	name     string
+	fpath    string
+	info     fs.FileInfo
+	linkname string
+}
+
+// ArchiveDir write all files in dir into dst as archive of format,
+// dir itself is not included.
+//
+// archive is reproducible: entries are sorted by path,
+// mtime is fixed, and owners are not recorded. file modes are kept,
+// symlinks are archived as symlinks.
+func ArchiveDir(dst io.Writer, dir string, format ArchiveFormat, opts ...ArchiveOption) error {
+	opt := &archiveOption{modTime: defaultArchiveModTime}
+	for _, f := range opts {
+		if err := f(opt); err != nil {
+			return errors.Wrap(err, "apply option")
+		}
+	}
+
+	switch format {
+	case ArchiveFormatZip, ArchiveFormatTarGz:
+	default:
+		return errors.Errorf("unsupported archive format %q", format)
+	}
+
+	var entries []archiveEntry
+	err := filepath.WalkDir(dir, func(fpath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, fpath)
+		if err != nil {
+			return errors.Wrapf(err, "get relative path of %q", fpath)
+		}
+		if rel == "." {
+			return nil
+		}
+
+		rel = filepath.ToSlash(rel)
+		if opt.excluded(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return errors.Wrapf(err, "stat %q", fpath)
+		}
+
+		entry := archiveEntry{name: rel, fpath: fpath, info: info}
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			if entry.linkname, err = os.Readlink(fpath); err != nil {
+				return errors.Wrapf(err, "read link %q", fpath)
+			}
+		case info.IsDir(), info.Mode().IsRegular():
+		default:
+			return errors.Errorf("unsupported file type %q", fpath)
+		}
+
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "walk dir %q", dir)
+	}
+
+	if format == ArchiveFormatZip {
+		return writeZipArchive(dst, entries, opt)
+	}
+
+	return writeTarGzArchive(dst, entries, opt)
+}
+
+func writeZipArchive(dst io.Writer, entries []archiveEntry, opt *archiveOption) error {
+	zw := zip.NewWriter(dst)
+	for _, entry := range entries {
+		header := &zip.FileHeader{
+			Name:     entry.name,
+			Method:   zip.Deflate,
+			Modified: opt.modTime,
+		}
+		header.SetMode(entry.info.Mode())
+		if entry.info.IsDir() {
+			header.Name += "/"
+			header.Method = zip.Store
+		}
+
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			return errors.Wrapf(err, "create entry %q", entry.name)
+		}
+
+		switch {
+		case entry.linkname != "":
+			_, err = io.WriteString(w, entry.linkname)
+		case entry.info.Mode().IsRegular():
+			err = copyFileTo(w, entry.fpath)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "write entry %q", entry.name)
+		}
+	}
+
+	return errors.Wrap(zw.Close(), "close zip writer")
+}
+
+func writeTarGzArchive(dst io.Writer, entries []archiveEntry, opt *archiveOption) error {
+	gz := gzip.NewWriter(dst)
+	tw := tar.NewWriter(gz)
+	for _, entry := range entries {
+		header := &tar.Header{
+			Name:     entry.name,
+			Mode:     int64(entry.info.Mode().Perm()),
+			ModTime:  opt.modTime,
+			Typeflag: tar.TypeReg,
+			Format:   tar.FormatPAX,
+		}
+		switch {
+		case entry.info.IsDir():
+			header.Name += "/"
+			header.Typeflag = tar.TypeDir
+		case entry.linkname != "":
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = entry.linkname
+		default:
+			header.Size = entry.info.Size()
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return errors.Wrapf(err, "write header of entry %q", entry.name)
+		}
+
+		if header.Typeflag == tar.TypeReg {
+			if err := copyFileTo(tw, entry.fpath); err != nil {
+				return errors.Wrapf(err, "write entry %q", entry.name)
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, "close tar writer")
+	}
+
+	return errors.Wrap(gz.Close(), "close gzip writer")
+}
+
+func copyFileTo(w io.Writer, fpath string) error {
+	fp, err := os.Open(fpath)
+	if err != nil {
+		return errors.Wrapf(err, "open %q", fpath)
+	}
+	defer gutils.LogErr(fp.Close, log.Shared)
+
+	_, err = io.Copy(w, fp)
+	return errors.Wrapf(err, "copy %q", fpath)
+}
+
+type extractOption struct {
+	allowSymlinks bool
+	maxSize       int64
+	maxFiles      int
+}
+
+// ExtractOption options for ExtractArchive
+type ExtractOption func(*extractOption) error
+
+// WithExtractAllowSymlinks allow symlinks pointing outside of destDir,
+// symlinks pointing inside are always allowed.
+//
+// files are never written through symlinks to outside of destDir.
+func WithExtractAllowSymlinks() ExtractOption {
+	return func(o *extractOption) error {
+		o.allowSymlinks = true
+		return nil
+	}
+}
+
+// WithExtractMaxSize total extracted bytes will not exceed this limit, default is 1GB
+func WithExtractMaxSize(totalBytes int64) ExtractOption {
+	return func(o *extractOption) error {
+		if totalBytes < 1 {
+			return errors.Errorf("max size must >= 1")
+		}
+
+		o.maxSize = totalBytes
+		return nil
+	}
+}
+
+// WithExtractMaxFiles number of entries will not exceed this limit, default/0 is unlimit
+func WithExtractMaxFiles(n int) ExtractOption {
+	return func(o *extractOption) error {
+		if n < 1 {
+			return errors.Errorf("max files must >= 1")
+		}
+
+		o.maxFiles = n
+		return nil
+	}
+}
+
+// ExtractArchive extract archive of format from src into destDir,
+// destDir will be created if not exists.
+//
+// entries escaping destDir by absolute path or `..` are rejected,
+// as well as symlinks pointing outside unless WithExtractAllowSymlinks is set.
+// file modes are kept. src of zip that is not io.ReaderAt
+// is spooled to a temp file.
+//
+// returned error names the offending entry.
+func ExtractArchive(src io.Reader, format ArchiveFormat, destDir string, opts ...ExtractOption) error {
+	opt := &extractOption{maxSize: defaultExtractMaxSize}
+	for _, f := range opts {
+		if err := f(opt); err != nil {
+			return errors.Wrap(err, "apply option")
+		}
+	}
+
+	if err := os.MkdirAll(destDir, 0o755); err != nil {
+		return errors.Wrapf(err, "create dest dir %q", destDir)
+	}
+
+	root, err := filepath.Abs(destDir)
+	if err != nil {
+		return errors.Wrapf(err, "get abs path of %q", destDir)
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return errors.Wrapf(err, "eval symlinks of %q", destDir)
+	}
+
+	ex := &extractor{opt: opt, root: root}
+	switch format {
+	case ArchiveFormatZip:
+		err = ex.extractZip(src)
+	case ArchiveFormatTarGz:
+		err = ex.extractTarGz(src)
+	default:
+		return errors.Errorf("unsupported archive format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	// set modes of dirs at last, in case of read-only dirs
+	for i := len(ex.dirs) - 1; i >= 0; i-- {
+		if err = os.Chmod(ex.dirs[i].path, ex.dirs[i].mode); err != nil {
+			return errors.Wrapf(err, "chmod dir %q", ex.dirs[i].path)
+		}
+	}
+
+	return nil
+}
+
+type extractedDir struct {
+	path string
+	mode fs.FileMode
+}
+
+type extractor struct {
+	opt   *extractOption
+	root  string
+	files int
+	size  int64
+	dirs  []extractedDir
+}
+
+// target return the path in root of entry name
+func (e *extractor) target(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if path.IsAbs(name) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", errors.Errorf("entry %q: absolute path not allowed", name)
+	}
+
+	cleaned := path.Clean(name)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", errors.Errorf("entry %q: path escapes dest dir", name)
+	}
+	if cleaned == "." {
+		return e.root, nil
+	}
+
+	return filepath.Join(e.root, filepath.FromSlash(cleaned)), nil
+}
+
+func (e *extractor) inRoot(p string) bool {
+	rel, err := filepath.Rel(e.root, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// prepare create parent dir of target, make sure it is not redirected
+// outside of root by symlinks, and remove existing symlink at target
+func (e *extractor) prepare(name, target string) error {
+	if err := e.mkdirParents(name, filepath.Dir(target)); err != nil {
+		return err
+	}
+
+	if fi, err := os.Lstat(target); err == nil && fi.Mode()&fs.ModeSymlink != 0 {
+		if err = os.Remove(target); err != nil {
+			return errors.Wrapf(err, "entry %q: remove existing symlink", name)
+		}
+	}
+
+	return nil
+}
+
+// mkdirParents create dir in root one level at a time.
+//
+// every existing component is resolved and checked before
+// anything is created beneath it, so a symlink extracted earlier
+// can not redirect directory creation outside of root.
+func (e *extractor) mkdirParents(name, dir string) error {
+	rel, err := filepath.Rel(e.root, dir)
+	if err != nil || !e.inRoot(dir) {
+		return errors.Errorf("entry %q: path escapes dest dir", name)
+	}
+	if rel == "." {
+		return nil
+	}
+
+	cur := e.root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		cur = filepath.Join(cur, part)
+		fi, err := os.Lstat(cur)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			if err = os.Mkdir(cur, 0o755); err != nil {
+				return errors.Wrapf(err, "entry %q: create parent dir", name)
+			}
+
+			continue
+		case err != nil:
+			return errors.Wrapf(err, "entry %q: stat parent dir", name)
+		case fi.Mode()&fs.ModeSymlink != 0:
+			if cur, err = filepath.EvalSymlinks(cur); err != nil {
+				return errors.Wrapf(err, "entry %q: eval symlinks", name)
+			}
+			if !e.inRoot(cur) {
+				return errors.Errorf("entry %q: path escapes dest dir through symlink", name)
+			}
+			if fi, err = os.Stat(cur); err != nil {
+				return errors.Wrapf(err, "entry %q: stat parent dir", name)
+			}
+		}
+
+		if !fi.IsDir() {
+			return errors.Errorf("entry %q: parent %q is not a dir", name, part)
+		}
+	}
+
+	return nil
+}
+
+// count check number of entries
+func (e *extractor) count(name string) error {
+	e.files++
+	if e.opt.maxFiles > 0 && e.files > e.opt.maxFiles {
+		return errors.Errorf("entry %q: exceeds max files %d", name, e.opt.maxFiles)
+	}
+
+	return nil
+}
+
+func (e *extractor) extractEntry(name string, mode fs.FileMode, declaredSize int64,
+	open func() (io.ReadCloser, error)) error {
+	if err := e.count(name); err != nil {
+		return err
+	}
+
+	target, err := e.target(name)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case mode.IsDir():
+		if err = e.prepare(name, target); err != nil {
+			return err
+		}
+		if err = os.MkdirAll(target, 0o755); err != nil {
+			return errors.Wrapf(err, "entry %q: create dir", name)
+		}
+
+		e.dirs = append(e.dirs, extractedDir{path: target, mode: mode.Perm()})
+		return nil
+	case mode&fs.ModeSymlink != 0:
+		return e.extractSymlink(name, target, open)
+	case mode.IsRegular():
+	default:
+		return errors.Errorf("entry %q: unsupported file type %s", name, mode.Type())
+	}
+
+	if target == e.root {
+		return errors.Errorf("entry %q: invalid file name", name)
+	}
+
+	remain := e.opt.maxSize - e.size
+	if declaredSize > remain {
+		return errors.Errorf("entry %q: exceeds max size %d", name, e.opt.maxSize)
+	}
+
+	if err = e.prepare(name, target); err != nil {
+		return err
+	}
+
+	r, err := open()
+	if err != nil {
+		return errors.Wrapf(err, "entry %q: open", name)
+	}
+	defer gutils.LogErr(r.Close, log.Shared)
+
+	fp, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return errors.Wrapf(err, "entry %q: create file", name)
+	}
+	defer gutils.LogErr(fp.Close, log.Shared)
+
+	// declared size may lie, so limit the actual read
+	n, err := io.Copy(fp, io.LimitReader(r, remain+1))
+	e.size += n
+	if err != nil {
+		return errors.Wrapf(err, "entry %q: write file", name)
+	}
+	if n > remain {
+		return errors.Errorf("entry %q: exceeds max size %d", name, e.opt.maxSize)
+	}
+
+	// mode in OpenFile is masked by umask and ignored for existing files
+	return errors.Wrapf(fp.Chmod(mode.Perm()), "entry %q: chmod", name)
+}
+
+func (e *extractor) extractSymlink(name, target string, open func() (io.ReadCloser, error)) error {
+	r, err := open()
+	if err != nil {
+		return errors.Wrapf(err, "entry %q: open", name)
+	}
+	defer gutils.LogErr(r.Close, log.Shared)
+
+	linkname, err := io.ReadAll(io.LimitReader(r, maxArchiveSymlinkBytes+1))
+	if err != nil {
+		return errors.Wrapf(err, "entry %q: read link", name)
+	}
+	if len(linkname) == 0 || len(linkname) > maxArchiveSymlinkBytes {
+		return errors.Errorf("entry %q: invalid link target", name)
+	}
+
+	return e.symlink(name, target, string(linkname))
+}
+
+func (e *extractor) symlink(name, target, linkname string) error {
+	if target == e.root {
+		return errors.Errorf("entry %q: invalid symlink name", name)
+	}
+
+	if err := e.prepare(name, target); err != nil {
+		return err
+	}
+
+	if !e.opt.allowSymlinks {
+		if filepath.IsAbs(linkname) || path.IsAbs(linkname) ||
+			!e.inRoot(e.resolveLink(filepath.Dir(target), linkname)) {
+			return errors.Errorf("entry %q: symlink to %q escapes dest dir", name, linkname)
+		}
+	}
+
+	if _, err := os.Lstat(target); err == nil {
+		if err = os.RemoveAll(target); err != nil {
+			return errors.Wrapf(err, "entry %q: remove existing file", name)
+		}
+	}
+
+	return errors.Wrapf(os.Symlink(linkname, target), "entry %q: create symlink", name)
+}
+
+// resolveLink return the path that linkname points to from dir,
+// symlinks created by previous entries are followed,
+// so `..` after a symlink is resolved like the OS does.
+func (e *extractor) resolveLink(dir, linkname string) string {
+	cur, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		cur = dir
+	}
+
+	for _, part := range strings.Split(filepath.ToSlash(linkname), "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			cur = filepath.Dir(cur)
+			continue
+		}
+
+		cur = filepath.Join(cur, part)
+		if fi, err := os.Lstat(cur); err == nil && fi.Mode()&fs.ModeSymlink != 0 {
+			if real, err := filepath.EvalSymlinks(cur); err == nil {
+				cur = real
+			}
+		}
+	}
+
+	return cur
+}
+
+func (e *extractor) extractZip(src io.Reader) error {
+	ra, size, cleanup, err := readerAt(src)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return errors.Wrap(err, "open zip")
+	}
+
+	for _, f := range zr.File {
+		mode := f.Mode()
+		if strings.HasSuffix(f.Name, "/") {
+			mode |= fs.ModeDir
+		}
+
+		err = e.extractEntry(f.Name, mode, int64(f.UncompressedSize64), func() (io.ReadCloser, error) {
+			return f.Open()
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *extractor) extractTarGz(src io.Reader) error {
+	gz, err := gzip.NewReader(src)
+	if err != nil {
+		return errors.Wrap(err, "open gzip")
+	}
+	defer gutils.LogErr(gz.Close, log.Shared)
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "read tar header")
+		}
+
+		perm := fs.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeXGlobalHeader:
+			continue
+		case tar.TypeDir:
+			err = e.extractEntry(header.Name, fs.ModeDir|perm, 0, nil)
+		case tar.TypeReg:
+			err = e.extractEntry(header.Name, perm, header.Size, func() (io.ReadCloser, error) {
+				return io.NopCloser(tr), nil
+			})
+		case tar.TypeSymlink:
+			if err = e.count(header.Name); err != nil {
+				return err
+			}
+
+			var target string
+			if target, err = e.target(header.Name); err != nil {
+				return err
+			}
+			err = e.symlink(header.Name, target, header.Linkname)
+		default:
+			err = errors.Errorf("entry %q: unsupported tar type %q", header.Name, header.Typeflag)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readerAt convert src to io.ReaderAt, spool to temp file if necessary
+func readerAt(src io.Reader) (ra io.ReaderAt, size int64, cleanup func(), err error) {
+	cleanup = func() {}
+	switch src := src.(type) {
+	case interface {
+		io.ReaderAt
+		Size() int64
+	}:
+		return src, src.Size(), cleanup, nil
+	case *os.File:
+		fi, err := src.Stat()
+		if err != nil {
+			return nil, 0, nil, errors.Wrap(err, "stat src")
+		}
+
+		return src, fi.Size(), cleanup, nil
+	}
+
+	fp, err := os.CreateTemp("", "gutils-archive-*")
+	if err != nil {
+		return nil, 0, nil, errors.Wrap(err, "create temp file")
+	}
+	cleanup = func() {
+		gutils.LogErr(fp.Close, log.Shared)
+		gutils.LogErr(func() error { return os.Remove(fp.Name()) }, log.Shared)
+	}
+
+	if size, err = io.Copy(fp, src); err != nil {
+		cleanup()
+		return nil, 0, nil, errors.Wrap(err, "spool src to temp file")
+	}
+
+	return fp, size, cleanup, nil
+}
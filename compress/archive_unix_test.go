@@ -0,0 +1,359 @@
+//go:build !windows
+// +build !windows
+
+package compress
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newArchiveTestTree create nested tree:
+//
+//	a.txt            0644
+//	bin/run.sh       0755
+//	sub/deep/b.txt   0600
+//	sub/link -> ../a.txt
+//	empty/
+//	skip.log
+//	cache/c.txt
+func newArchiveTestTree(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, c := range map[string]struct {
+		content string
+		mode    fs.FileMode
+	}{
+		"a.txt":          {"hello", 0o644},
+		"bin/run.sh":     {"#!/bin/sh\necho yo\n", 0o755},
+		"sub/deep/b.txt": {"secret", 0o600},
+		"skip.log":       {"log", 0o644},
+		"cache/c.txt":    {"cache", 0o644},
+	} {
+		fpath := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fpath), 0o755))
+		require.NoError(t, os.WriteFile(fpath, []byte(c.content), c.mode))
+		require.NoError(t, os.Chmod(fpath, c.mode))
+	}
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "empty"), 0o700))
+	require.NoError(t, os.Symlink("../a.txt", filepath.Join(dir, "sub", "link")))
+	return dir
+}
+
+func TestArchiveDirRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	src := newArchiveTestTree(t)
+	for _, format := range []ArchiveFormat{ArchiveFormatZip, ArchiveFormatTarGz} {
+		format := format
+		t.Run(string(format), func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			err := ArchiveDir(&buf, src, format, WithArchiveExclude("*.log", "cache"))
+			require.NoError(t, err)
+
+			// reproducible
+			var buf2 bytes.Buffer
+			err = ArchiveDir(&buf2, src, format, WithArchiveExclude("*.log", "cache"))
+			require.NoError(t, err)
+			require.Equal(t, buf.Bytes(), buf2.Bytes())
+
+			// src is not io.ReaderAt
+			dst := t.TempDir()
+			err = ExtractArchive(io.MultiReader(&buf), format, dst)
+			require.NoError(t, err)
+
+			for name, c := range map[string]struct {
+				content string
+				mode    fs.FileMode
+			}{
+				"a.txt":          {"hello", 0o644},
+				"bin/run.sh":     {"#!/bin/sh\necho yo\n", 0o755},
+				"sub/deep/b.txt": {"secret", 0o600},
+			} {
+				fpath := filepath.Join(dst, name)
+				data, err := os.ReadFile(fpath)
+				require.NoError(t, err, name)
+				require.Equal(t, c.content, string(data), name)
+
+				fi, err := os.Stat(fpath)
+				require.NoError(t, err)
+				require.Equal(t, c.mode, fi.Mode().Perm(), name)
+			}
+
+			fi, err := os.Stat(filepath.Join(dst, "empty"))
+			require.NoError(t, err)
+			require.True(t, fi.IsDir())
+			require.Equal(t, fs.FileMode(0o700), fi.Mode().Perm())
+
+			link, err := os.Readlink(filepath.Join(dst, "sub", "link"))
+			require.NoError(t, err)
+			require.Equal(t, "../a.txt", link)
+
+			for _, name := range []string{"skip.log", "cache"} {
+				_, err = os.Lstat(filepath.Join(dst, name))
+				require.ErrorIs(t, err, os.ErrNotExist, name)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+
+		require.Error(t, ArchiveDir(io.Discard, src, "rar"))
+		require.Error(t, ArchiveDir(io.Discard, src, ArchiveFormatZip, WithArchiveExclude("[")))
+		require.Error(t, ExtractArchive(bytes.NewReader(nil), "rar", t.TempDir()))
+	})
+
+	t.Run("mod time", func(t *testing.T) {
+		t.Parallel()
+
+		modTime := time.Date(2020, 1, 2, 3, 4, 6, 0, time.UTC)
+		var buf bytes.Buffer
+		require.NoError(t, ArchiveDir(&buf, src, ArchiveFormatZip, WithArchiveModTime(modTime)))
+
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+		for _, f := range zr.File {
+			require.True(t, modTime.Equal(f.Modified), f.Name)
+		}
+	})
+}
+
+type archiveTestEntry struct {
+	name     string
+	content  string
+	linkname string
+	dir      bool
+}
+
+// newMaliciousZip craft zip archive, entries are written as is
+func newMaliciousZip(t *testing.T, entries ...archiveTestEntry) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate}
+		content := entry.content
+		switch {
+		case entry.linkname != "":
+			header.SetMode(fs.ModeSymlink | 0o777)
+			content = entry.linkname
+		case entry.dir:
+			header.SetMode(fs.ModeDir | 0o755)
+		default:
+			header.SetMode(0o644)
+		}
+
+		w, err := zw.CreateHeader(header)
+		require.NoError(t, err)
+		_, err = io.WriteString(w, content)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// newMaliciousTarGz craft tar.gz archive, entries are written as is
+func newMaliciousTarGz(t *testing.T, entries ...archiveTestEntry) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0o644, Size: int64(len(entry.content))}
+		switch {
+		case entry.linkname != "":
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = entry.linkname
+			header.Size = 0
+		case entry.dir:
+			header.Typeflag = tar.TypeDir
+			header.Mode = 0o755
+		default:
+			header.Typeflag = tar.TypeReg
+		}
+
+		require.NoError(t, tw.WriteHeader(header))
+		if header.Typeflag == tar.TypeReg {
+			_, err := io.WriteString(tw, entry.content)
+			require.NoError(t, err)
+		}
+	}
+
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestExtractArchiveMalicious(t *testing.T) {
+	t.Parallel()
+
+	bomb := string(bytes.Repeat([]byte{0}, 10*1024*1024))
+	for _, c := range []struct {
+		name    string
+		entries []archiveTestEntry
+		opts    []ExtractOption
+		err     string
+	}{
+		{
+			name:    "traversal",
+			entries: []archiveTestEntry{{name: "../../evil.txt", content: "x"}},
+			err:     `entry "../../evil.txt": path escapes dest dir`,
+		},
+		{
+			name:    "nested traversal",
+			entries: []archiveTestEntry{{name: "a/../../evil.txt", content: "x"}},
+			err:     `entry "a/../../evil.txt": path escapes dest dir`,
+		},
+		{
+			name:    "absolute",
+			entries: []archiveTestEntry{{name: "/tmp/evil.txt", content: "x"}},
+			err:     `entry "/tmp/evil.txt": absolute path not allowed`,
+		},
+		{
+			name:    "symlink outside",
+			entries: []archiveTestEntry{{name: "link", linkname: "../../etc"}},
+			err:     `entry "link": symlink to "../../etc" escapes dest dir`,
+		},
+		{
+			name:    "absolute symlink",
+			entries: []archiveTestEntry{{name: "link", linkname: "/etc/passwd"}},
+			err:     `entry "link": symlink to "/etc/passwd" escapes dest dir`,
+		},
+		{
+			name: "chained symlink",
+			entries: []archiveTestEntry{
+				{name: "d", linkname: "."},
+				{name: "d/l", linkname: "../outside"},
+			},
+			err: `entry "d/l": symlink to "../outside" escapes dest dir`,
+		},
+		{
+			name: "dotdot after symlink",
+			entries: []archiveTestEntry{
+				{name: "d", linkname: "."},
+				{name: "l", linkname: "d/d/../.."},
+			},
+			err: `entry "l": symlink to "d/d/../.." escapes dest dir`,
+		},
+		{
+			name: "write through symlink",
+			entries: []archiveTestEntry{
+				{name: "link", linkname: "/tmp"},
+				{name: "link/evil.txt", content: "x"},
+			},
+			opts: []ExtractOption{WithExtractAllowSymlinks()},
+			err:  `entry "link/evil.txt": path escapes dest dir through symlink`,
+		},
+		{
+			name:    "bomb",
+			entries: []archiveTestEntry{{name: "bomb.bin", content: bomb}},
+			opts:    []ExtractOption{WithExtractMaxSize(1024 * 1024)},
+			err:     `entry "bomb.bin": exceeds max size 1048576`,
+		},
+		{
+			name: "total size",
+			entries: []archiveTestEntry{
+				{name: "a.txt", content: "12345"},
+				{name: "b.txt", content: "12345"},
+			},
+			opts: []ExtractOption{WithExtractMaxSize(8)},
+			err:  `entry "b.txt": exceeds max size 8`,
+		},
+		{
+			name: "too many files",
+			entries: []archiveTestEntry{
+				{name: "dir", dir: true},
+				{name: "dir/a.txt", content: "a"},
+				{name: "dir/b.txt", content: "b"},
+			},
+			opts: []ExtractOption{WithExtractMaxFiles(2)},
+			err:  `entry "dir/b.txt": exceeds max files 2`,
+		},
+	} {
+		c := c
+		for format, data := range map[ArchiveFormat][]byte{
+			ArchiveFormatZip:   newMaliciousZip(t, c.entries...),
+			ArchiveFormatTarGz: newMaliciousTarGz(t, c.entries...),
+		} {
+			format, data := format, data
+			t.Run(c.name+" "+string(format), func(t *testing.T) {
+				t.Parallel()
+
+				parent := t.TempDir()
+				dst := filepath.Join(parent, "a", "b")
+				err := ExtractArchive(bytes.NewReader(data), format, dst, c.opts...)
+				require.ErrorContains(t, err, c.err)
+
+				_, err = os.Lstat(filepath.Join(parent, "evil.txt"))
+				require.ErrorIs(t, err, os.ErrNotExist)
+				_, err = os.Lstat(filepath.Join(parent, "a", "evil.txt"))
+				require.ErrorIs(t, err, os.ErrNotExist)
+				_, err = os.Lstat("/tmp/evil.txt")
+				require.ErrorIs(t, err, os.ErrNotExist)
+			})
+		}
+	}
+
+	t.Run("allow symlinks", func(t *testing.T) {
+		t.Parallel()
+
+		data := newMaliciousTarGz(t, archiveTestEntry{name: "link", linkname: "/etc"})
+		dst := t.TempDir()
+		require.NoError(t, ExtractArchive(bytes.NewReader(data), ArchiveFormatTarGz, dst,
+			WithExtractAllowSymlinks()))
+
+		link, err := os.Readlink(filepath.Join(dst, "link"))
+		require.NoError(t, err)
+		require.Equal(t, "/etc", link)
+	})
+
+	t.Run("mkdir through symlink", func(t *testing.T) {
+		t.Parallel()
+
+		entries := []archiveTestEntry{
+			{name: "link", linkname: "../.."},
+			{name: "link/newdir/evil.txt", content: "x"},
+		}
+		for format, data := range map[ArchiveFormat][]byte{
+			ArchiveFormatZip:   newMaliciousZip(t, entries...),
+			ArchiveFormatTarGz: newMaliciousTarGz(t, entries...),
+		} {
+			parent := t.TempDir()
+			dst := filepath.Join(parent, "a", "b")
+			err := ExtractArchive(bytes.NewReader(data), format, dst, WithExtractAllowSymlinks())
+			require.ErrorContains(t, err,
+				`entry "link/newdir/evil.txt": path escapes dest dir through symlink`, format)
+
+			_, err = os.Lstat(filepath.Join(parent, "newdir"))
+			require.ErrorIs(t, err, os.ErrNotExist, format)
+		}
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		t.Parallel()
+
+		data := newMaliciousZip(t)
+		require.Error(t, ExtractArchive(bytes.NewReader(data), ArchiveFormatZip, t.TempDir(),
+			WithExtractMaxSize(0)))
+		require.Error(t, ExtractArchive(bytes.NewReader(data), ArchiveFormatZip, t.TempDir(),
+			WithExtractMaxFiles(0)))
+	})
+}
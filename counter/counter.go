@@ -352,3 +352,94 @@ func (c *ChildParallelCounter) CountN(n int64) (r int64) {
 
 	return c.Count()
 }
+
+// ---------------------------------------------------
+
+type slidingWindowBucket struct {
+	epoch atomic.Int64
+	n     atomic.Int64
+}
+
+// SlidingWindowCounter measure events rate over a sliding time window.
+//
+// the window is split into a ring of time buckets,
+// buckets older than the window are reset lazily on the next Incr.
+// Incr only lock when it meets an expired bucket.
+type SlidingWindowCounter struct {
+	mu             sync.Mutex
+	window         time.Duration
+	bucketDuration int64
+	buckets        []slidingWindowBucket
+	clock          gutils.SchedClock
+}
+
+// NewSlidingWindowCounter create new SlidingWindowCounter
+//
+// more buckets make the rate smoother, but cost more time to calculate rate.
+func NewSlidingWindowCounter(window time.Duration, buckets int) (*SlidingWindowCounter, error) {
+	return newSlidingWindowCounter(gutils.SystemSchedClock(), window, buckets)
+}
+
+func newSlidingWindowCounter(clock gutils.SchedClock,
+	window time.Duration, buckets int) (*SlidingWindowCounter, error) {
+	if buckets <= 0 {
+		return nil, errors.Errorf("buckets should bigger than 0, but got %d", buckets)
+	}
+	if window < time.Duration(buckets) {
+		return nil, errors.Errorf("window should not less than %dns, but got %s", buckets, window)
+	}
+
+	return &SlidingWindowCounter{
+		window:         window,
+		bucketDuration: int64(window) / int64(buckets),
+		buckets:        make([]slidingWindowBucket, buckets),
+		clock:          clock,
+	}, nil
+}
+
+// Incr record one event
+func (c *SlidingWindowCounter) Incr() {
+	c.IncrN(1)
+}
+
+// IncrN record n events
+func (c *SlidingWindowCounter) IncrN(n int64) {
+	epoch := c.clock.Now().UnixNano() / c.bucketDuration
+	b := &c.buckets[epoch%int64(len(c.buckets))]
+	if b.epoch.Load() == epoch {
+		b.n.Add(n)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// double check, and never reset bucket by stale clock
+	switch cur := b.epoch.Load(); {
+	case cur < epoch:
+		b.n.Store(0)
+		b.epoch.Store(epoch)
+	case cur > epoch:
+		// the slot already belongs to a newer time,
+		// drop events of stale clock rather than crediting the wrong slot
+		return
+	}
+
+	b.n.Add(n)
+}
+
+// Rate return events per second over the window
+func (c *SlidingWindowCounter) Rate() float64 {
+	epoch := c.clock.Now().UnixNano() / c.bucketDuration
+	oldest := epoch - int64(len(c.buckets))
+
+	var total int64
+	for i := range c.buckets {
+		b := &c.buckets[i]
+		if e := b.epoch.Load(); e > oldest && e <= epoch {
+			total += b.n.Load()
+		}
+	}
+
+	return float64(total) / c.window.Seconds()
+}
@@ -6,9 +6,12 @@ import (
 	"math/rand"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/Laisky/zap"
+	"github.com/stretchr/testify/require"
 
+	gutils "github.com/Laisky/go-utils/v4"
 	"github.com/Laisky/go-utils/v4/log"
 )
 
@@ -619,3 +622,71 @@ func BenchmarkAllCounter(b *testing.B) {
 	})
 
 }
+
+func TestSlidingWindowCounter(t *testing.T) {
+	_, err := NewSlidingWindowCounter(time.Second, 0)
+	require.Error(t, err)
+	_, err = NewSlidingWindowCounter(time.Nanosecond, 10)
+	require.Error(t, err)
+
+	clock := gutils.NewFakeSchedClock()
+	c, err := newSlidingWindowCounter(clock, 10*time.Second, 10)
+	require.NoError(t, err)
+
+	require.Zero(t, c.Rate())
+	for i := 0; i < 100; i++ {
+		c.Incr()
+	}
+	require.InDelta(t, 10, c.Rate(), 0.001)
+
+	clock.Advance(t, 5*time.Second)
+	c.IncrN(50)
+	require.InDelta(t, 15, c.Rate(), 0.001)
+
+	// the first bucket is out of the window
+	clock.Advance(t, 5*time.Second)
+	require.InDelta(t, 5, c.Rate(), 0.001)
+
+	// reuse the expired bucket
+	c.IncrN(20)
+	require.InDelta(t, 7, c.Rate(), 0.001)
+
+	// stale clock points to the slot reused by a newer bucket
+	clock.Advance(t, -10*time.Second)
+	c.IncrN(100)
+	clock.Advance(t, 10*time.Second)
+	require.InDelta(t, 7, c.Rate(), 0.001)
+
+	clock.Advance(t, time.Minute)
+	require.Zero(t, c.Rate())
+}
+
+func TestSlidingWindowCounter_Parallel(t *testing.T) {
+	c, err := NewSlidingWindowCounter(time.Minute, 60)
+	require.NoError(t, err)
+
+	var pool sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		pool.Add(1)
+		go func() {
+			defer pool.Done()
+			for j := 0; j < 1000; j++ {
+				c.Incr()
+			}
+		}()
+	}
+	pool.Wait()
+
+	require.InDelta(t, 10000.0/60, c.Rate(), 0.001)
+}
+
+func BenchmarkSlidingWindowCounter(b *testing.B) {
+	c, err := NewSlidingWindowCounter(time.Second, 10)
+	require.NoError(b, err)
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Incr()
+		}
+	})
+}
@@ -184,6 +184,9 @@ func ParseHex2Big(hex string) (b *big.Int, ok bool) {
 }
 
 // FormatBig2Base64 format big to base64 string
+//
+// it uses url-safe alphabet with padding `=`,
+// use FormatBig2Base64URL if the string is embedded in url.
 func FormatBig2Base64(b *big.Int) string {
 	return base64.URLEncoding.EncodeToString(b.Bytes())
 }
@@ -200,6 +203,23 @@ func ParseBase642Big(raw string) (*big.Int, error) {
 	return b, nil
 }
 
+// FormatBig2Base64URL format big to url-safe base64 string without padding,
+// like the encoding of JWT (RFC 7515)
+func FormatBig2Base64URL(b *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(b.Bytes())
+}
+
+// ParseBase64URL2Big parse url-safe base64 string to big,
+// trailing padding `=` is accepted, so it can parse FormatBig2Base64's output too.
+func ParseBase64URL2Big(raw string) (*big.Int, error) {
+	bb, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(raw, "="))
+	if err != nil {
+		return nil, errors.Wrap(err, "decode base64")
+	}
+
+	return new(big.Int).SetBytes(bb), nil
+}
+
 var (
 	// RSAEncrypt encrypt by RSAEncryptByPKCS1v15, for compatibility
 	//
@@ -0,0 +1,175 @@
+package crypto
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+
+	"github.com/Laisky/errors/v2"
+)
+
+const (
+	gcmBlockSize = 16
+	gcmNonceSize = 12
+	gcmTagSize   = 16
+)
+
+// gcmPrimitives block cipher primitives to build GCM (NIST SP 800-38D)
+// on top of ciphers that do not provide AEAD directly,
+// like tongsuo's `enc` command.
+type gcmPrimitives struct {
+	// ecb encrypt blocks in ECB mode without padding, len(blocks) is multiple of 16
+	ecb func(blocks []byte) ([]byte, error)
+	// ctr xor data with keystream in CTR mode,
+	// the 128-bit counter starts from counter and increases as big-endian integer
+	ctr func(counter, data []byte) ([]byte, error)
+}
+
+// gcmSeal encrypt plaintext and calculate 16 bytes tag, nonce must be 12 bytes.
+func gcmSeal(p gcmPrimitives, nonce, plaintext, aad []byte) (ciphertext, tag []byte, err error) {
+	h, ekj0, err := p.gcmInit(nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if ciphertext, err = p.gcmCTR(nonce, plaintext); err != nil {
+		return nil, nil, err
+	}
+
+	return ciphertext, gcmTag(h, ekj0, aad, ciphertext), nil
+}
+
+// gcmOpen verify tag and decrypt ciphertext, nonce must be 12 bytes.
+func gcmOpen(p gcmPrimitives, nonce, ciphertext, aad, tag []byte) (plaintext []byte, err error) {
+	if len(tag) != gcmTagSize {
+		return nil, errors.Errorf("tag should be %d bytes", gcmTagSize)
+	}
+
+	h, ekj0, err := p.gcmInit(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare(tag, gcmTag(h, ekj0, aad, ciphertext)) != 1 {
+		return nil, errors.New("message authentication failed")
+	}
+
+	return p.gcmCTR(nonce, ciphertext)
+}
+
+// gcmInit return hash subkey H = E(K, 0^128) and E(K, J0)
+func (p gcmPrimitives) gcmInit(nonce []byte) (h, ekj0 []byte, err error) {
+	if len(nonce) != gcmNonceSize {
+		return nil, nil, errors.Errorf("iv should be %d bytes", gcmNonceSize)
+	}
+
+	blocks := make([]byte, 2*gcmBlockSize)
+	copy(blocks[gcmBlockSize:], nonce)
+	blocks[2*gcmBlockSize-1] = 1 // J0 = nonce || 0^31 || 1
+
+	out, err := p.ecb(blocks)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "encrypt hash subkey")
+	}
+	if len(out) != len(blocks) {
+		return nil, nil, errors.Errorf("unexpected ecb output length %d", len(out))
+	}
+
+	return out[:gcmBlockSize], out[gcmBlockSize:], nil
+}
+
+// gcmCTR xor data with keystream starts from inc32(J0).
+//
+// inc32 only increases the low 32 bits, it equals to 128-bit increment
+// since the low 32 bits start from 2 and GCM limits plaintext to 2^32-2 blocks.
+func (p gcmPrimitives) gcmCTR(nonce, data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return []byte{}, nil
+	}
+	if uint64(len(data)) > (1<<32-2)*gcmBlockSize {
+		return nil, errors.New("data too large for gcm")
+	}
+
+	counter := make([]byte, gcmBlockSize)
+	copy(counter, nonce)
+	counter[gcmBlockSize-1] = 2
+
+	out, err := p.ctr(counter, data)
+	if err != nil {
+		return nil, errors.Wrap(err, "ctr")
+	}
+	if len(out) != len(data) {
+		return nil, errors.Errorf("unexpected ctr output length %d", len(out))
+	}
+
+	return out, nil
+}
+
+// gcmTag calculate tag = E(K, J0) xor GHASH(H, aad, ciphertext)
+func gcmTag(h, ekj0, aad, ciphertext []byte) []byte {
+	var (
+		hk = gcmFieldElement{
+			hi: binary.BigEndian.Uint64(h[:8]),
+			lo: binary.BigEndian.Uint64(h[8:]),
+		}
+		y gcmFieldElement
+	)
+
+	y = y.ghashUpdate(hk, aad)
+	y = y.ghashUpdate(hk, ciphertext)
+
+	var lens [gcmBlockSize]byte
+	binary.BigEndian.PutUint64(lens[:8], uint64(len(aad))*8)
+	binary.BigEndian.PutUint64(lens[8:], uint64(len(ciphertext))*8)
+	y = y.ghashUpdate(hk, lens[:])
+
+	tag := make([]byte, gcmTagSize)
+	binary.BigEndian.PutUint64(tag[:8], y.hi)
+	binary.BigEndian.PutUint64(tag[8:], y.lo)
+	subtle.XORBytes(tag, tag, ekj0)
+	return tag
+}
+
+// gcmFieldElement element of GF(2^128) in GCM's bit order
+type gcmFieldElement struct {
+	hi, lo uint64
+}
+
+// ghashUpdate absorb data zero-padded to multiple of 16 bytes
+func (y gcmFieldElement) ghashUpdate(h gcmFieldElement, data []byte) gcmFieldElement {
+	for len(data) > 0 {
+		var block [gcmBlockSize]byte
+		n := copy(block[:], data)
+		data = data[n:]
+
+		y.hi ^= binary.BigEndian.Uint64(block[:8])
+		y.lo ^= binary.BigEndian.Uint64(block[8:])
+		y = y.mul(h)
+	}
+
+	return y
+}
+
+// mul multiply in GF(2^128), refer to NIST SP 800-38D algorithm 1.
+//
+// branchless on bits of y and h, to not leak hash subkey by timing.
+func (y gcmFieldElement) mul(h gcmFieldElement) (z gcmFieldElement) {
+	v := h
+	for i := 0; i < 128; i++ {
+		var bit uint64
+		if i < 64 {
+			bit = (y.hi >> (63 - i)) & 1
+		} else {
+			bit = (y.lo >> (127 - i)) & 1
+		}
+		m := -bit
+		z.hi ^= v.hi & m
+		z.lo ^= v.lo & m
+
+		m = -(v.lo & 1)
+		v.lo = v.lo>>1 | v.hi<<63
+		v.hi >>= 1
+		v.hi ^= (0xe1 << 56) & m
+	}
+
+	return z
+}
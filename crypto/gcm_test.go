@@ -0,0 +1,87 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testAESGCMPrimitives build gcm primitives by aes,
+// to verify gcmSeal/gcmOpen against golang's built-in gcm
+func testAESGCMPrimitives(t *testing.T, key []byte) gcmPrimitives {
+	t.Helper()
+
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+
+	return testGCMPrimitivesByBlock(t, block)
+}
+
+// testGCMPrimitivesByBlock build gcm primitives by any 16 bytes block cipher
+func testGCMPrimitivesByBlock(t *testing.T, block cipher.Block) gcmPrimitives {
+	t.Helper()
+
+	return gcmPrimitives{
+		ecb: func(blocks []byte) ([]byte, error) {
+			out := make([]byte, len(blocks))
+			for i := 0; i < len(blocks); i += block.BlockSize() {
+				block.Encrypt(out[i:], blocks[i:])
+			}
+
+			return out, nil
+		},
+		ctr: func(counter, data []byte) ([]byte, error) {
+			out := make([]byte, len(data))
+			cipher.NewCTR(block, counter).XORKeyStream(out, data)
+			return out, nil
+		},
+	}
+}
+
+func TestGCMByPrimitives(t *testing.T) {
+	t.Parallel()
+
+	key, err := Salt(16)
+	require.NoError(t, err)
+	nonce, err := Salt(gcmNonceSize)
+	require.NoError(t, err)
+
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+
+	p := testAESGCMPrimitives(t, key)
+	for _, size := range []int{0, 1, 15, 16, 17, 100, 4096} {
+		for _, aad := range [][]byte{nil, []byte("laisky"), make([]byte, 33)} {
+			plaintext, err := Salt(size)
+			require.NoError(t, err)
+
+			ciphertext, tag, err := gcmSeal(p, nonce, plaintext, aad)
+			require.NoError(t, err)
+			require.Len(t, tag, gcmTagSize)
+
+			expect := aead.Seal(nil, nonce, plaintext, aad)
+			require.Equal(t, expect[:size], ciphertext, size)
+			require.Equal(t, expect[size:], tag, size)
+
+			decrypted, err := gcmOpen(p, nonce, ciphertext, aad, tag)
+			require.NoError(t, err)
+			require.Equal(t, plaintext, decrypted)
+
+			// tamper
+			_, err = gcmOpen(p, nonce, ciphertext, append(aad, 'a'), tag)
+			require.ErrorContains(t, err, "message authentication failed")
+			tag[0] ^= 1
+			_, err = gcmOpen(p, nonce, ciphertext, aad, tag)
+			require.ErrorContains(t, err, "message authentication failed")
+		}
+	}
+
+	_, _, err = gcmSeal(p, make([]byte, 16), []byte("yo"), nil)
+	require.ErrorContains(t, err, "iv should be 12 bytes")
+	_, err = gcmOpen(p, nonce, []byte("yo"), nil, make([]byte, 15))
+	require.ErrorContains(t, err, "tag should be 16 bytes")
+}
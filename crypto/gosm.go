@@ -0,0 +1,306 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/pem"
+
+	"github.com/Laisky/errors/v2"
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/sm3"
+	"github.com/emmansun/gmsm/sm4"
+	"github.com/emmansun/gmsm/smx509"
+)
+
+// GoSM pure go implementation of part of Tongsuo's SM2/SM3/SM4 methods
+// based on github.com/emmansun/gmsm, could be used when tongsuo binary is not available.
+//
+// GoSM covers SM2 key generation, signing and verification with default user id,
+// SM3 hash and SM4 CBC/GCM.
+type GoSM struct{}
+
+// NewGoSM new pure go SM backend
+func NewGoSM() *GoSM {
+	return new(GoSM)
+}
+
+// NewPrikey generate new sm2 private key in SEC1 PEM,
+// compatible with Tongsuo.NewPrikey
+func (s *GoSM) NewPrikey(_ context.Context) (prikeyPem []byte, err error) {
+	prikey, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "generate sm2 key")
+	}
+
+	der, err := smx509.MarshalSM2PrivateKey(prikey)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal sm2 key")
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+// Prikey2Pubkey convert private key to public key in PKIX PEM,
+// compatible with Tongsuo.Prikey2Pubkey
+func (s *GoSM) Prikey2Pubkey(_ context.Context, prikeyPem []byte) (
+	pubkeyPem []byte, err error) {
+	prikey, err := sm2ParsePrikeyPem(prikeyPem)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := smx509.MarshalPKIXPublicKey(&prikey.PublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal public key")
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// SignBySm2Sm3 sign by sm2 sm3 with default user id,
+// compatible with Tongsuo.SignBySm2Sm3
+func (s *GoSM) SignBySm2Sm3(_ context.Context,
+	prikeyPem, content []byte) (signature []byte, err error) {
+	prikey, err := sm2ParsePrikeyPem(prikeyPem)
+	if err != nil {
+		return nil, err
+	}
+
+	if signature, err = prikey.Sign(rand.Reader, content, sm2.DefaultSM2SignerOpts); err != nil {
+		return nil, errors.Wrap(err, "sign by sm2 sm3")
+	}
+
+	return signature, nil
+}
+
+// HashBySm3 hash by sm3
+func (s *GoSM) HashBySm3(_ context.Context, content []byte) (hash []byte, err error) {
+	hashed := sm3.Sum(content)
+	return hashed[:], nil
+}
+
+// VerifyBySm2Sm3 verify by sm2 sm3 with default user id,
+// compatible with Tongsuo.VerifyBySm2Sm3
+func (s *GoSM) VerifyBySm2Sm3(_ context.Context,
+	pubkeyPem, signature, content []byte) error {
+	block, _ := pem.Decode(pubkeyPem)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return errors.Errorf("cannot find public key in pem")
+	}
+
+	pub, err := smx509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return errors.Wrap(err, "parse public key")
+	}
+
+	ecPub, ok := pub.(*ecdsa.PublicKey)
+	if !ok || ecPub.Curve != sm2.P256() {
+		return errors.Errorf("public key should be sm2")
+	}
+
+	if !sm2.VerifyASN1WithSM2(ecPub, nil, content, signature) {
+		return errors.Errorf("signature not match")
+	}
+
+	return nil
+}
+
+// EncryptBySm4CbcBaisc encrypt by sm4, compatible with Tongsuo.EncryptBySm4CbcBaisc
+//
+// # Args
+//   - key: sm4 key, should be 16 bytes
+//   - plaintext: data to be encrypted
+//   - iv: sm4 iv, should be 16 bytes
+//
+// # Returns
+//   - ciphertext: sm4 encrypted data
+//   - hmac: hmac of ciphertext, 32 bytes
+func (s *GoSM) EncryptBySm4CbcBaisc(_ context.Context,
+	key, plaintext, iv []byte) (ciphertext, hmac []byte, err error) {
+	if len(iv) != 16 {
+		return nil, nil, errors.Errorf("iv should be 16 bytes")
+	}
+
+	block, err := sm4.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	padding := sm4.BlockSize - len(plaintext)%sm4.BlockSize
+	ciphertext = make([]byte, len(plaintext)+padding)
+	copy(ciphertext, plaintext)
+	copy(ciphertext[len(plaintext):], bytes.Repeat([]byte{byte(padding)}, padding))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, ciphertext)
+
+	if hmac, err = HMACSha256(key, bytes.NewReader(ciphertext)); err != nil {
+		return nil, nil, errors.Wrap(err, "calculate hmac")
+	}
+
+	return ciphertext, hmac, nil
+}
+
+// DecryptBySm4CbcBaisc decrypt by sm4, compatible with Tongsuo.DecryptBySm4CbcBaisc
+//
+// # Args
+//   - key: sm4 key
+//   - ciphertext: sm4 encrypted data
+//   - iv: sm4 iv
+//   - hmac: if not nil, will check ciphertext's integrity by hmac
+func (s *GoSM) DecryptBySm4CbcBaisc(_ context.Context,
+	key, ciphertext, iv, hmac []byte) (plaintext []byte, err error) {
+	if len(iv) != 16 {
+		return nil, errors.Errorf("iv should be 16 bytes")
+	}
+	if len(hmac) != 0 && len(hmac) != 32 {
+		return nil, errors.Errorf("hmac should be 0 or 32 bytes")
+	}
+
+	block, err := sm4.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(hmac) != 0 { // check hmac
+		if expectedHmac, err := HMACSha256(key, bytes.NewReader(ciphertext)); err != nil {
+			return nil, errors.Wrap(err, "calculate hmac")
+		} else if !bytes.Equal(hmac, expectedHmac) {
+			return nil, errors.Errorf("hmac not match")
+		}
+	}
+
+	if len(ciphertext) == 0 || len(ciphertext)%sm4.BlockSize != 0 {
+		return nil, errors.Errorf("bad decrypt, invalid ciphertext length")
+	}
+
+	plaintext = make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > sm4.BlockSize ||
+		subtle.ConstantTimeCompare(
+			plaintext[len(plaintext)-padding:],
+			bytes.Repeat([]byte{byte(padding)}, padding)) != 1 {
+		return nil, errors.Errorf("bad decrypt, invalid padding")
+	}
+
+	return plaintext[:len(plaintext)-padding], nil
+}
+
+// EncryptBySm4Cbc encrypt by sm4, compatible with Tongsuo.EncryptBySm4Cbc
+func (s *GoSM) EncryptBySm4Cbc(ctx context.Context, key, plaintext []byte) (
+	combinedCipher []byte, err error) {
+	iv, err := Salt(16)
+	if err != nil {
+		return nil, errors.Wrap(err, "generate iv")
+	}
+
+	cipher, hmac, err := s.EncryptBySm4CbcBaisc(ctx, key, plaintext, iv)
+	if err != nil {
+		return nil, errors.Wrap(err, "encrypt by sm4 basic")
+	}
+
+	combinedCipher = make([]byte, 0, len(iv)+len(cipher)+len(hmac))
+	combinedCipher = append(combinedCipher, iv...)
+	combinedCipher = append(combinedCipher, cipher...)
+	combinedCipher = append(combinedCipher, hmac...)
+
+	return combinedCipher, nil
+}
+
+// DecryptBySm4Cbc decrypt by sm4, compatible with Tongsuo.DecryptBySm4Cbc
+func (s *GoSM) DecryptBySm4Cbc(ctx context.Context, key, combinedCipher []byte) (
+	plaintext []byte, err error) {
+	if len(combinedCipher) <= 48 {
+		return nil, errors.Errorf("invalid combined cipher")
+	}
+
+	iv := combinedCipher[:16]
+	cipher := combinedCipher[16 : len(combinedCipher)-32]
+	hmac := combinedCipher[len(combinedCipher)-32:]
+
+	return s.DecryptBySm4CbcBaisc(ctx, key, cipher, iv, hmac)
+}
+
+// EncryptBySm4Gcm encrypt by sm4 in gcm mode, compatible with Tongsuo.EncryptBySm4Gcm
+func (s *GoSM) EncryptBySm4Gcm(_ context.Context,
+	key, plaintext, iv, aad []byte) (ciphertext, tag []byte, err error) {
+	aead, err := sm4NewGCM(key, iv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sealed := aead.Seal(nil, iv, plaintext, aad)
+	return sealed[:len(plaintext)], sealed[len(plaintext):], nil
+}
+
+// DecryptBySm4Gcm decrypt by sm4 in gcm mode, compatible with Tongsuo.DecryptBySm4Gcm
+func (s *GoSM) DecryptBySm4Gcm(_ context.Context,
+	key, ciphertext, iv, aad, tag []byte) (plaintext []byte, err error) {
+	if len(tag) != gcmTagSize {
+		return nil, errors.Errorf("tag should be %d bytes", gcmTagSize)
+	}
+
+	aead, err := sm4NewGCM(key, iv)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := make([]byte, 0, len(ciphertext)+len(tag))
+	sealed = append(sealed, ciphertext...)
+	sealed = append(sealed, tag...)
+	if plaintext, err = aead.Open(nil, iv, sealed, aad); err != nil {
+		return nil, errors.Wrap(errors.New("message authentication failed"), "decrypt")
+	}
+
+	return plaintext, nil
+}
+
+func sm4NewGCM(key, iv []byte) (cipher.AEAD, error) {
+	if len(iv) != gcmNonceSize {
+		return nil, errors.Errorf("iv should be %d bytes", gcmNonceSize)
+	}
+
+	block, err := sm4.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// sm2ParsePrikeyPem parse sm2 private key in SEC1 or PKCS#8 PEM,
+// other blocks like EC PARAMETERS are skipped
+func sm2ParsePrikeyPem(prikeyPem []byte) (*sm2.PrivateKey, error) {
+	for {
+		var block *pem.Block
+		if block, prikeyPem = pem.Decode(prikeyPem); block == nil {
+			return nil, errors.Errorf("cannot find private key in pem")
+		}
+
+		switch block.Type {
+		case "EC PRIVATE KEY":
+			prikey, err := smx509.ParseSM2PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, errors.Wrap(err, "parse sm2 private key")
+			}
+
+			return prikey, nil
+		case "PRIVATE KEY":
+			key, err := smx509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, errors.Wrap(err, "parse pkcs8 private key")
+			}
+
+			prikey, ok := key.(*sm2.PrivateKey)
+			if !ok {
+				return nil, errors.Errorf("private key should be sm2, got %T", key)
+			}
+
+			return prikey, nil
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/emmansun/gmsm/sm4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoSM_HashBySm3(t *testing.T) {
+	t.Parallel()
+	s := NewGoSM()
+
+	for _, tt := range []struct {
+		in   string
+		want string
+	}{
+		// GB/T 32905-2016 A.1
+		{"abc", "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0"},
+		// GB/T 32905-2016 A.2
+		{"abcdabcdabcdabcdabcdabcdabcdabcdabcdabcdabcdabcdabcdabcdabcdabcd",
+			"debe9ff92275b8a138604889c18e5a4d6fdb70e5387e5765293dcba39c0c5732"},
+	} {
+		hash, err := s.HashBySm3(context.Background(), []byte(tt.in))
+		require.NoError(t, err)
+		require.Equal(t, tt.want, hex.EncodeToString(hash), tt.in)
+	}
+}
+
+func TestGoSM_VerifyBySm2Sm3(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	s := NewGoSM()
+
+	// fixture generated by OpenSSL 3
+	pubkey, err := os.ReadFile("testdata/sm2/pubkey.pem")
+	require.NoError(t, err)
+	content, err := os.ReadFile("testdata/sm2/content.txt")
+	require.NoError(t, err)
+	sig, err := os.ReadFile("testdata/sm2/content.sig")
+	require.NoError(t, err)
+
+	require.NoError(t, s.VerifyBySm2Sm3(ctx, pubkey, sig, content))
+
+	err = s.VerifyBySm2Sm3(ctx, pubkey, sig, append(content, '!'))
+	require.Error(t, err)
+
+	tampered := bytes.Clone(sig)
+	tampered[len(tampered)-1] ^= 0xff
+	require.Error(t, s.VerifyBySm2Sm3(ctx, pubkey, tampered, content))
+
+	require.Error(t, s.VerifyBySm2Sm3(ctx, []byte("not a key"), sig, content))
+
+	t.Run("sign", func(t *testing.T) {
+		prikeyPem, err := s.NewPrikey(ctx)
+		require.NoError(t, err)
+		pubkeyPem, err := s.Prikey2Pubkey(ctx, prikeyPem)
+		require.NoError(t, err)
+
+		sig, err := s.SignBySm2Sm3(ctx, prikeyPem, content)
+		require.NoError(t, err)
+		require.NoError(t, s.VerifyBySm2Sm3(ctx, pubkeyPem, sig, content))
+		require.Error(t, s.VerifyBySm2Sm3(ctx, pubkey, sig, content))
+
+		_, err = s.SignBySm2Sm3(ctx, []byte("not a key"), content)
+		require.ErrorContains(t, err, "cannot find private key")
+	})
+}
+
+func TestGoSM_Sm4(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	s := NewGoSM()
+
+	key := bytes.Repeat([]byte("k"), 16)
+	iv := bytes.Repeat([]byte("i"), 16)
+	plaintext := []byte("hello, laisky")
+
+	t.Run("cbc", func(t *testing.T) {
+		ciphertext, hmac, err := s.EncryptBySm4CbcBaisc(ctx, key, plaintext, iv)
+		require.NoError(t, err)
+		require.Len(t, ciphertext, 16)
+		require.Len(t, hmac, 32)
+
+		got, err := s.DecryptBySm4CbcBaisc(ctx, key, ciphertext, iv, hmac)
+		require.NoError(t, err)
+		require.Equal(t, plaintext, got)
+
+		hmac[0] ^= 0xff
+		_, err = s.DecryptBySm4CbcBaisc(ctx, key, ciphertext, iv, hmac)
+		require.ErrorContains(t, err, "hmac not match")
+
+		combined, err := s.EncryptBySm4Cbc(ctx, key, plaintext)
+		require.NoError(t, err)
+		got, err = s.DecryptBySm4Cbc(ctx, key, combined)
+		require.NoError(t, err)
+		require.Equal(t, plaintext, got)
+	})
+
+	t.Run("gcm", func(t *testing.T) {
+		gcmIV := iv[:12]
+		aad := []byte("aad")
+		ciphertext, tag, err := s.EncryptBySm4Gcm(ctx, key, plaintext, gcmIV, aad)
+		require.NoError(t, err)
+		require.Len(t, ciphertext, len(plaintext))
+		require.Len(t, tag, 16)
+
+		got, err := s.DecryptBySm4Gcm(ctx, key, ciphertext, gcmIV, aad, tag)
+		require.NoError(t, err)
+		require.Equal(t, plaintext, got)
+
+		_, err = s.DecryptBySm4Gcm(ctx, key, ciphertext, gcmIV, []byte("another"), tag)
+		require.Error(t, err)
+
+		// should be the same as gcm built from primitives
+		block, err := sm4.NewCipher(key)
+		require.NoError(t, err)
+		wantCt, wantTag, err := gcmSeal(testGCMPrimitivesByBlock(t, block), gcmIV, plaintext, aad)
+		require.NoError(t, err)
+		require.Equal(t, wantCt, ciphertext)
+		require.Equal(t, wantTag, tag)
+	})
+}
+
+func TestNewTongsuo_GoSMFallback(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	_, err := NewTongsuo("/not/exists/tongsuo")
+	require.Error(t, err)
+
+	ins, err := NewTongsuo("/not/exists/tongsuo", WithTongsuoGoSMFallback())
+	require.NoError(t, err)
+	require.True(t, ins.IsGoSMFallback())
+
+	pubkey, err := os.ReadFile("testdata/sm2/pubkey.pem")
+	require.NoError(t, err)
+	content, err := os.ReadFile("testdata/sm2/content.txt")
+	require.NoError(t, err)
+	sig, err := os.ReadFile("testdata/sm2/content.sig")
+	require.NoError(t, err)
+	require.NoError(t, ins.VerifyBySm2Sm3(ctx, pubkey, sig, content))
+
+	prikeyPem, err := ins.NewPrikey(ctx)
+	require.NoError(t, err)
+	pubkeyPem, err := ins.Prikey2Pubkey(ctx, prikeyPem)
+	require.NoError(t, err)
+	sig, err = ins.SignBySm2Sm3(ctx, prikeyPem, content)
+	require.NoError(t, err)
+	require.NoError(t, ins.VerifyBySm2Sm3(ctx, pubkeyPem, sig, content))
+
+	hash, err := ins.HashBySm3(ctx, []byte("abc"))
+	require.NoError(t, err)
+	require.Equal(t, "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0",
+		hex.EncodeToString(hash))
+
+	key := bytes.Repeat([]byte("k"), 16)
+	combined, err := ins.EncryptBySm4Cbc(ctx, key, content)
+	require.NoError(t, err)
+	got, err := ins.DecryptBySm4Cbc(ctx, key, combined)
+	require.NoError(t, err)
+	require.Equal(t, content, got)
+
+	_, _, err = ins.ShowCertInfo(ctx, []byte("cert"))
+	require.ErrorContains(t, err, "not supported by go fallback")
+}
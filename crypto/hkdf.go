@@ -36,9 +36,16 @@ func HKDFWithSHA256(secret, salt, info []byte, results [][]byte) error {
 
 // Salt generate random salt with specifiec length
 func Salt(length int) ([]byte, error) {
+	return SaltWithReader(length, rand.Reader)
+}
+
+// SaltWithReader generate salt with specifiec length by reading from r,
+// pass a seeded reader to get deterministic salts in tests.
+//
+// use Salt in production, r should be cryptographically secure.
+func SaltWithReader(length int, r io.Reader) ([]byte, error) {
 	salt := make([]byte, length)
-	_, err := rand.Read(salt)
-	if err != nil {
+	if _, err := io.ReadFull(r, salt); err != nil {
 		return nil, errors.Wrap(err, "generate salt")
 	}
 
@@ -1,7 +1,9 @@
 package crypto
 
 import (
+	"bytes"
 	"crypto/rand"
+	mrand "math/rand"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -134,3 +136,21 @@ func TestDeriveKeyBySMHF(t *testing.T) {
 		})
 	}
 }
+
+func TestSaltWithReader(t *testing.T) {
+	t.Parallel()
+
+	salt1, err := SaltWithReader(32, mrand.New(mrand.NewSource(1))) //nolint:gosec
+	require.NoError(t, err)
+	salt2, err := SaltWithReader(32, mrand.New(mrand.NewSource(1))) //nolint:gosec
+	require.NoError(t, err)
+	require.Len(t, salt1, 32)
+	require.Equal(t, salt1, salt2)
+
+	_, err = SaltWithReader(32, bytes.NewReader(make([]byte, 31)))
+	require.ErrorContains(t, err, "generate salt")
+
+	salt3, err := Salt(32)
+	require.NoError(t, err)
+	require.NotEqual(t, salt1, salt3)
+}
@@ -0,0 +1,346 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+
+	"github.com/Laisky/errors/v2"
+
+	gjson "github.com/Laisky/go-utils/v4/json"
+)
+
+// JWK json web key defined in RFC 7517,
+// only contains fields of public key
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// Crv curve of ec/okp key
+	Crv string `json:"crv,omitempty"`
+	// X x coordinate of ec key, or public key of okp key
+	X string `json:"x,omitempty"`
+	// Y y coordinate of ec key
+	Y string `json:"y,omitempty"`
+	// N modulus of rsa key
+	N string `json:"n,omitempty"`
+	// E exponent of rsa key
+	E string `json:"e,omitempty"`
+}
+
+// JWKS json web key set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+var jwkBase64 = base64.RawURLEncoding
+
+// jwkCurves ecdsa curves supported by jwk, refer to RFC 7518 6.2.1.1
+var jwkCurves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+// Pubkey2JWKStruct convert public key to JWK, kid is set to its RFC 7638 thumbprint.
+//
+// only support rsa/ecdsa(P256/P384/P521)/ed25519 public key.
+func Pubkey2JWKStruct(pub crypto.PublicKey) (*JWK, error) {
+	var jwk JWK
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = jwkBase64.EncodeToString(pub.N.Bytes())
+		jwk.E = jwkBase64.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		crv := pub.Curve.Params().Name
+		if _, ok := jwkCurves[crv]; !ok {
+			return nil, errors.Errorf("not support ecdsa curve %s", crv)
+		}
+
+		ecdhPub, err := pub.ECDH()
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid ecdsa public key")
+		}
+
+		// uncompressed point, 0x04 || x || y
+		point := ecdhPub.Bytes()[1:]
+		jwk.Kty = "EC"
+		jwk.Crv = crv
+		jwk.X = jwkBase64.EncodeToString(point[:len(point)/2])
+		jwk.Y = jwkBase64.EncodeToString(point[len(point)/2:])
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = jwkBase64.EncodeToString(pub)
+	default:
+		return nil, errors.Errorf("only support rsa/ecdsa/ed25519 public key, got %T", pub)
+	}
+
+	var err error
+	if jwk.Kid, err = jwk.Thumbprint(); err != nil {
+		return nil, errors.Wrap(err, "calculate thumbprint")
+	}
+
+	return &jwk, nil
+}
+
+// Pubkey2JWK convert public key to JWK,
+// kid is set to its RFC 7638 thumbprint if empty.
+//
+// only support rsa/ecdsa(P256/P384/P521)/ed25519 public key.
+func Pubkey2JWK(pub crypto.PublicKey, kid string) (map[string]any, error) {
+	jwk, err := Pubkey2JWKStruct(pub)
+	if err != nil {
+		return nil, err
+	}
+	if kid != "" {
+		jwk.Kid = kid
+	}
+
+	data, err := gjson.Marshal(jwk)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal jwk")
+	}
+
+	var m map[string]any
+	if err = gjson.Unmarshal(data, &m); err != nil {
+		return nil, errors.Wrap(err, "unmarshal jwk")
+	}
+
+	return m, nil
+}
+
+// jwkFromMap convert JWK map to JWK, unknown members are ignored
+func jwkFromMap(m map[string]any) (*JWK, error) {
+	data, err := gjson.Marshal(m)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal jwk")
+	}
+
+	jwk := new(JWK)
+	if err = gjson.Unmarshal(data, jwk); err != nil {
+		return nil, errors.Wrap(err, "unmarshal jwk")
+	}
+
+	return jwk, nil
+}
+
+// JWK2Pubkey parse public key from JWK
+func JWK2Pubkey(jwk map[string]any) (crypto.PublicKey, error) {
+	k, err := jwkFromMap(jwk)
+	if err != nil {
+		return nil, err
+	}
+
+	return k.Pubkey()
+}
+
+// JWKThumbprint calculate RFC 7638 thumbprint of JWK, see JWK.Thumbprint
+func JWKThumbprint(jwk map[string]any) (string, error) {
+	k, err := jwkFromMap(jwk)
+	if err != nil {
+		return "", err
+	}
+
+	return k.Thumbprint()
+}
+
+// Pubkey parse public key from JWK
+func (k *JWK) Pubkey() (crypto.PublicKey, error) {
+	decode := func(name, val string) ([]byte, error) {
+		if val == "" {
+			return nil, errors.Errorf("%s is required for kty %s", name, k.Kty)
+		}
+
+		b, err := jwkBase64.DecodeString(val)
+		if err != nil {
+			return nil, errors.Wrapf(err, "decode %s", name)
+		}
+
+		return b, nil
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode("n", k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode("e", k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		eInt := new(big.Int).SetBytes(e)
+		if !eInt.IsInt64() || eInt.Int64() < 2 || eInt.Int64() > 1<<31-1 {
+			return nil, errors.Errorf("invalid rsa exponent")
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(eInt.Int64()),
+		}, nil
+	case "EC":
+		curve, ok := jwkCurves[k.Crv]
+		if !ok {
+			return nil, errors.Errorf("not support ec curve %q", k.Crv)
+		}
+
+		x, err := decode("x", k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode("y", k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		byteLen := (curve.Params().BitSize + 7) / 8
+		if len(x) != byteLen || len(y) != byteLen {
+			return nil, errors.Errorf("invalid length of coordinates for curve %s", k.Crv)
+		}
+
+		pub := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if _, err = pub.ECDH(); err != nil {
+			return nil, errors.Wrap(err, "invalid ec point")
+		}
+
+		return pub, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, errors.Errorf("not support okp curve %q", k.Crv)
+		}
+
+		x, err := decode("x", k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.Errorf("invalid length of ed25519 public key")
+		}
+
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, errors.Errorf("not support kty %q", k.Kty)
+	}
+}
+
+// Thumbprint calculate JWK thumbprint defined in RFC 7638 by sha256,
+// encoded by base64url without padding.
+func (k *JWK) Thumbprint() (string, error) {
+	// required members in lexicographic order
+	var members any
+	switch k.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{k.E, k.Kty, k.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{k.Crv, k.Kty, k.X, k.Y}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{k.Crv, k.Kty, k.X}
+	default:
+		return "", errors.Errorf("not support kty %q", k.Kty)
+	}
+
+	data, err := gjson.Marshal(members)
+	if err != nil {
+		return "", errors.Wrap(err, "marshal members")
+	}
+
+	hashed := sha256.Sum256(data)
+	return jwkBase64.EncodeToString(hashed[:]), nil
+}
+
+// NewJWKS marshal JWKs to JWK set json,
+// keys should be generated by Pubkey2JWK or parseable by JWK2Pubkey.
+func NewJWKS(keys ...map[string]any) ([]byte, error) {
+	for i, key := range keys {
+		if _, err := JWK2Pubkey(key); err != nil {
+			return nil, errors.Wrapf(err, "invalid key %d", i)
+		}
+	}
+
+	if keys == nil {
+		keys = []map[string]any{}
+	}
+
+	return gjson.Marshal(map[string]any{"keys": keys})
+}
+
+// jwkSupported whether kty and crv of k are supported by JWK.Pubkey
+func jwkSupported(k *JWK) bool {
+	switch k.Kty {
+	case "RSA":
+		return true
+	case "EC":
+		_, ok := jwkCurves[k.Crv]
+		return ok
+	case "OKP":
+		return k.Crv == "Ed25519"
+	default:
+		return false
+	}
+}
+
+// ParseJWKS parse public keys from JWK set json, keyed by kid.
+//
+// key without kid is keyed by its RFC 7638 thumbprint.
+// keys with unsupported kty or crv are ignored as RFC 7517 section 5 suggests,
+// malformed supported key and duplicated kid are errors.
+func ParseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var jwks JWKS
+	if err := gjson.Unmarshal(data, &jwks); err != nil {
+		return nil, errors.Wrap(err, "unmarshal jwks")
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for i := range jwks.Keys {
+		jwk := &jwks.Keys[i]
+		if !jwkSupported(jwk) {
+			continue
+		}
+
+		pub, err := jwk.Pubkey()
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse key %d", i)
+		}
+
+		kid := jwk.Kid
+		if kid == "" {
+			if kid, err = jwk.Thumbprint(); err != nil {
+				return nil, errors.Wrapf(err, "thumbprint of key %d", i)
+			}
+		}
+
+		if _, ok := keys[kid]; ok {
+			return nil, errors.Errorf("duplicated kid %q", kid)
+		}
+
+		keys[kid] = pub
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,229 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	gjson "github.com/Laisky/go-utils/v4/json"
+)
+
+func TestJWK(t *testing.T) {
+	t.Parallel()
+
+	rsaPrikey, err := NewRSAPrikey(RSAPrikeyBits2048)
+	require.NoError(t, err)
+	p256Prikey, err := NewECDSAPrikey(ECDSACurveP256)
+	require.NoError(t, err)
+	p384Prikey, err := NewECDSAPrikey(ECDSACurveP384)
+	require.NoError(t, err)
+	p521Prikey, err := NewECDSAPrikey(ECDSACurveP521)
+	require.NoError(t, err)
+	edPrikey, err := NewEd25519Prikey()
+	require.NoError(t, err)
+
+	var (
+		pubkeys []crypto.PublicKey
+		jwks    []map[string]any
+	)
+	for _, prikey := range []crypto.PrivateKey{
+		rsaPrikey, p256Prikey, p384Prikey, p521Prikey, edPrikey,
+	} {
+		pubkey := Prikey2Pubkey(prikey)
+		pubkeys = append(pubkeys, pubkey)
+
+		jwk, err := Pubkey2JWK(pubkey, "")
+		require.NoError(t, err)
+		jwks = append(jwks, jwk)
+
+		got, err := JWK2Pubkey(jwk)
+		require.NoError(t, err)
+		require.True(t, got.(interface{ Equal(crypto.PublicKey) bool }).Equal(pubkey))
+
+		thumbprint, err := JWKThumbprint(jwk)
+		require.NoError(t, err)
+		require.Equal(t, thumbprint, jwk["kid"])
+
+		// members are base64url without padding
+		for _, name := range []string{"n", "e", "x", "y"} {
+			if v, ok := jwk[name]; ok {
+				require.NotContains(t, v, "=")
+			}
+		}
+
+		jwk, err = Pubkey2JWK(pubkey, "my-kid")
+		require.NoError(t, err)
+		require.Equal(t, "my-kid", jwk["kid"])
+		thumbprint2, err := JWKThumbprint(jwk)
+		require.NoError(t, err)
+		require.Equal(t, thumbprint, thumbprint2)
+	}
+
+	t.Run("jwks", func(t *testing.T) {
+		data, err := NewJWKS(jwks...)
+		require.NoError(t, err)
+
+		var set JWKS
+		require.NoError(t, gjson.Unmarshal(data, &set))
+		require.Len(t, set.Keys, len(pubkeys))
+
+		parsed, err := ParseJWKS(data)
+		require.NoError(t, err)
+		require.Len(t, parsed, len(pubkeys))
+		for i := range set.Keys {
+			require.NotEmpty(t, set.Keys[i].Kid)
+
+			got := parsed[set.Keys[i].Kid]
+			require.True(t, got.(interface{ Equal(crypto.PublicKey) bool }).Equal(pubkeys[i]))
+		}
+
+		_, err = NewJWKS(jwks[0], map[string]any{"kty": "oct"})
+		require.ErrorContains(t, err, "invalid key 1")
+
+		data, err = NewJWKS()
+		require.NoError(t, err)
+		require.JSONEq(t, `{"keys":[]}`, string(data))
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		p224Prikey, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+		require.NoError(t, err)
+		_, err = Pubkey2JWK(&p224Prikey.PublicKey, "")
+		require.ErrorContains(t, err, "not support ecdsa curve P-224")
+
+		_, err = Pubkey2JWK("yo", "")
+		require.Error(t, err)
+
+		for _, data := range []string{
+			`{"kty":"oct","k":"AQAB"}`,
+			`{"kty":"EC","crv":"P-224","x":"AQAB","y":"AQAB"}`,
+			`{"kty":"EC","crv":"P-256","x":"AQAB","y":"AQAB"}`,
+			`{"kty":"EC","crv":"P-256","x":"` + jwkBase64.EncodeToString(make([]byte, 32)) +
+				`","y":"` + jwkBase64.EncodeToString(make([]byte, 32)) + `"}`,
+			`{"kty":"OKP","crv":"X25519","x":"AQAB"}`,
+			`{"kty":"OKP","crv":"Ed25519","x":"AQAB"}`,
+			`{"kty":"RSA","n":"AQAB"}`,
+			`{"kty":"RSA","n":"AQAB","e":"AQ"}`,
+			`{"kty":"RSA","n":"!!","e":"AQAB"}`,
+			`{"kty":1}`,
+		} {
+			var jwk map[string]any
+			require.NoError(t, gjson.Unmarshal([]byte(data), &jwk))
+			_, err = JWK2Pubkey(jwk)
+			require.Error(t, err, data)
+		}
+	})
+}
+
+// TestJWKThumbprint test vector from RFC 7638 3.1
+func TestJWKThumbprint(t *testing.T) {
+	t.Parallel()
+
+	jwk := JWK{
+		Kty: "RSA",
+		N:   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E:   "AQAB",
+		Alg: "RS256",
+		Kid: "2011-04-29",
+	}
+
+	thumbprint, err := jwk.Thumbprint()
+	require.NoError(t, err)
+	require.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", thumbprint)
+
+	pubkey, err := jwk.Pubkey()
+	require.NoError(t, err)
+	m, err := Pubkey2JWK(pubkey, "")
+	require.NoError(t, err)
+	require.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", m["kid"])
+
+	thumbprint, err = JWKThumbprint(map[string]any{"kty": "RSA", "n": jwk.N, "e": jwk.E})
+	require.NoError(t, err)
+	require.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", thumbprint)
+}
+
+// TestParseJWKS fixture is the public key set from RFC 7517 A.1
+func TestParseJWKS(t *testing.T) {
+	t.Parallel()
+
+	data, err := os.ReadFile("testdata/jwk/rfc7517_a1.json")
+	require.NoError(t, err)
+
+	keys, err := ParseJWKS(data)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+
+	ecPubkey, ok := keys["1"].(*ecdsa.PublicKey)
+	require.True(t, ok)
+	require.Equal(t, elliptic.P256(), ecPubkey.Curve)
+
+	rsaPubkey, ok := keys["2011-04-29"].(*rsa.PublicKey)
+	require.True(t, ok)
+	require.Equal(t, 65537, rsaPubkey.E)
+	require.Equal(t, 2048, rsaPubkey.N.BitLen())
+
+	// layout of https://www.googleapis.com/oauth2/v3/certs,
+	// keys are generated locally since the provider rotates them
+	t.Run("google certs layout", func(t *testing.T) {
+		data, err := os.ReadFile("testdata/jwk/google_certs_layout.json")
+		require.NoError(t, err)
+
+		keys, err := ParseJWKS(data)
+		require.NoError(t, err)
+		require.Len(t, keys, 2)
+		for _, kid := range []string{
+			"ee93ea19185034e5b2f18c8f423bf3dd42048bda",
+			"c46bfcd0570017b93c4363d6527ff5d952518e52",
+		} {
+			pubkey, ok := keys[kid].(*rsa.PublicKey)
+			require.True(t, ok, kid)
+			require.Equal(t, 2048, pubkey.N.BitLen())
+
+			jwk, err := Pubkey2JWK(pubkey, kid)
+			require.NoError(t, err)
+			got, err := JWK2Pubkey(jwk)
+			require.NoError(t, err)
+			require.True(t, pubkey.Equal(got))
+		}
+	})
+
+	t.Run("kid by thumbprint", func(t *testing.T) {
+		keys, err := ParseJWKS([]byte(`{"keys":[{"kty":"RSA","e":"AQAB","n":"` +
+			"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw" +
+			`"},{"kty":"oct","k":"AQAB"}]}`))
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		require.Contains(t, keys, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs")
+	})
+
+	t.Run("skip unsupported", func(t *testing.T) {
+		x := jwkBase64.EncodeToString(make([]byte, 32))
+		keys, err := ParseJWKS([]byte(`{"keys":[` +
+			`{"kty":"EC","crv":"secp256k1","kid":"k1","x":"` + x + `","y":"` + x + `"},` +
+			`{"kty":"OKP","crv":"X25519","kid":"x25519","x":"` + x + `"},` +
+			`{"kty":"oct","kid":"oct","k":"AQAB"},` +
+			`{"kty":"OKP","crv":"Ed25519","kid":"ed","x":"` + x + `"}]}`))
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		require.Contains(t, keys, "ed")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, data := range []string{
+			`not json`,
+			`{"keys":[{"kty":"RSA","n":"AQAB"}]}`,
+			`{"keys":[{"kty":"EC","crv":"P-256","x":"AQAB","y":"AQAB"}]}`,
+			`{"keys":[{"kty":"OKP","crv":"Ed25519","kid":"a","x":"` + jwkBase64.EncodeToString(make([]byte, 32)) + `"},` +
+				`{"kty":"OKP","crv":"Ed25519","kid":"a","x":"` + jwkBase64.EncodeToString(make([]byte, 32)) + `"}]}`,
+		} {
+			_, err := ParseJWKS([]byte(data))
+			require.Error(t, err, data)
+		}
+	})
+}
@@ -0,0 +1,331 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Laisky/errors/v2"
+
+	gjson "github.com/Laisky/go-utils/v4/json"
+)
+
+// DirManifestVersion version of dir manifest format
+const DirManifestVersion = 1
+
+// DirManifest signed manifest of files in a directory tree
+type DirManifest struct {
+	Version int `json:"version"`
+	// Exclude glob patterns of excluded files
+	Exclude []string `json:"exclude,omitempty"`
+	// FollowSymlinks whether symlinks are followed,
+	// if false, symlinks are recorded by their target
+	FollowSymlinks bool `json:"follow_symlinks"`
+	// Files sorted by path
+	Files []DirManifestFile `json:"files"`
+	// Signature base64 encoded ed25519 signature
+	// of the canonical json of manifest without signature
+	Signature string `json:"signature,omitempty"`
+}
+
+// DirManifestFile one file in dir manifest
+type DirManifestFile struct {
+	// Path slash-separated path relative to the root dir
+	Path string `json:"path"`
+	Size int64  `json:"size,omitempty"`
+	// SHA256 hex encoded sha256 of file content
+	SHA256 string `json:"sha256,omitempty"`
+	// ModTime RFC3339 modtime, only recorded if WithManifestModTime is set
+	ModTime string `json:"modtime,omitempty"`
+	// Link target of symlink, only recorded if symlinks are not followed
+	Link string `json:"link,omitempty"`
+}
+
+type manifestOption struct {
+	exclude        []string
+	followSymlinks bool
+	modTime        bool
+}
+
+// ManifestOption options for SignDirManifest
+type ManifestOption func(*manifestOption) error
+
+// WithManifestExclude exclude files match any of globs.
+//
+// glob contains `/` is matched against the slash-separated relative path,
+// otherwise it is matched against the base name of each file or dir.
+// excluded dir will be skipped entirely.
+func WithManifestExclude(globs ...string) ManifestOption {
+	return func(opt *manifestOption) error {
+		for _, glob := range globs {
+			if _, err := path.Match(glob, ""); err != nil {
+				return errors.Wrapf(err, "invalid glob %q", glob)
+			}
+		}
+
+		opt.exclude = append(opt.exclude, globs...)
+		return nil
+	}
+}
+
+// WithManifestFollowSymlinks whether to follow symlinks, default to false.
+//
+// if not follow, symlinks are recorded by their target without hashing.
+func WithManifestFollowSymlinks(follow bool) ManifestOption {
+	return func(opt *manifestOption) error {
+		opt.followSymlinks = follow
+		return nil
+	}
+}
+
+// WithManifestModTime record files' modtime in manifest,
+// and VerifyDirManifest will check modtime too.
+func WithManifestModTime() ManifestOption {
+	return func(opt *manifestOption) error {
+		opt.modTime = true
+		return nil
+	}
+}
+
+// SignDirManifest generate manifest of all files in dir and sign it by ed25519.
+//
+// returns canonical json of DirManifest, with signature embedded.
+func SignDirManifest(prikey ed25519.PrivateKey, dir string, opts ...ManifestOption) ([]byte, error) {
+	if len(prikey) != ed25519.PrivateKeySize {
+		return nil, errors.Errorf("invalid ed25519 private key size %d", len(prikey))
+	}
+
+	opt := new(manifestOption)
+	for _, f := range opts {
+		if err := f(opt); err != nil {
+			return nil, err
+		}
+	}
+
+	files, err := collectDirManifestFiles(dir, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &DirManifest{
+		Version:        DirManifestVersion,
+		Exclude:        opt.exclude,
+		FollowSymlinks: opt.followSymlinks,
+		Files:          files,
+	}
+
+	payload, err := gjson.MarshalCanonical(manifest)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal manifest")
+	}
+
+	manifest.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(prikey, payload))
+	return gjson.MarshalCanonical(manifest)
+}
+
+// VerifyDirManifest verify manifest's signature, then re-hash files in dir.
+//
+// exclude globs and symlink policy are read from the signed manifest.
+// every mismatched, missing or extra file is reported in the returned error.
+func VerifyDirManifest(pubkey ed25519.PublicKey, dir string, manifestBytes []byte) error {
+	if len(pubkey) != ed25519.PublicKeySize {
+		return errors.Errorf("invalid ed25519 public key size %d", len(pubkey))
+	}
+
+	manifest := new(DirManifest)
+	if err := gjson.Unmarshal(manifestBytes, manifest); err != nil {
+		return errors.Wrap(err, "unmarshal manifest")
+	}
+	if manifest.Version != DirManifestVersion {
+		return errors.Errorf("unsupported manifest version %d", manifest.Version)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(manifest.Signature)
+	if err != nil {
+		return errors.Wrap(err, "decode signature")
+	}
+
+	manifest.Signature = ""
+	payload, err := gjson.MarshalCanonical(manifest)
+	if err != nil {
+		return errors.Wrap(err, "marshal manifest")
+	}
+	if !ed25519.Verify(pubkey, payload, sig) {
+		return errors.New("invalid manifest signature")
+	}
+
+	opt := &manifestOption{
+		exclude:        manifest.Exclude,
+		followSymlinks: manifest.FollowSymlinks,
+		modTime:        true,
+	}
+	files, err := collectDirManifestFiles(dir, opt)
+	if err != nil {
+		return err
+	}
+
+	actual := make(map[string]DirManifestFile, len(files))
+	for _, f := range files {
+		actual[f.Path] = f
+	}
+
+	var errs []error
+	for _, expect := range manifest.Files {
+		got, ok := actual[expect.Path]
+		if !ok {
+			errs = append(errs, errors.Errorf("file %q missing", expect.Path))
+			continue
+		}
+		delete(actual, expect.Path)
+
+		if expect.ModTime == "" {
+			got.ModTime = ""
+		}
+		if got != expect {
+			errs = append(errs, errors.Errorf("file %q mismatch", expect.Path))
+		}
+	}
+
+	extras := make([]string, 0, len(actual))
+	for p := range actual {
+		extras = append(extras, p)
+	}
+	sort.Strings(extras)
+	for _, p := range extras {
+		errs = append(errs, errors.Errorf("file %q not in manifest", p))
+	}
+
+	return errors.Join(errs...)
+}
+
+// collectDirManifestFiles walk dir and return files sorted by path
+func collectDirManifestFiles(dir string, opt *manifestOption) ([]DirManifestFile, error) {
+	var (
+		files []DirManifestFile
+		// visited real paths of dirs in current walking stack, to detect symlink loop
+		visited = map[string]bool{}
+		walk    func(absDir, relDir string) error
+	)
+	walk = func(absDir, relDir string) error {
+		if opt.followSymlinks {
+			realDir, err := filepath.EvalSymlinks(absDir)
+			if err != nil {
+				return errors.Wrapf(err, "eval symlinks %q", relDir)
+			}
+			if visited[realDir] {
+				return errors.Errorf("symlink loop at %q", relDir)
+			}
+
+			visited[realDir] = true
+			defer delete(visited, realDir)
+		}
+
+		entries, err := os.ReadDir(absDir)
+		if err != nil {
+			return errors.Wrapf(err, "read dir %q", absDir)
+		}
+
+		for _, entry := range entries {
+			absPath := filepath.Join(absDir, entry.Name())
+			relPath := path.Join(relDir, entry.Name())
+			if opt.excluded(relPath) {
+				continue
+			}
+
+			info, err := os.Lstat(absPath)
+			if err != nil {
+				return errors.Wrapf(err, "stat %q", relPath)
+			}
+
+			if info.Mode()&os.ModeSymlink != 0 {
+				if !opt.followSymlinks {
+					target, err := os.Readlink(absPath)
+					if err != nil {
+						return errors.Wrapf(err, "read link %q", relPath)
+					}
+
+					files = append(files, DirManifestFile{Path: relPath, Link: target})
+					continue
+				}
+
+				if info, err = os.Stat(absPath); err != nil {
+					return errors.Wrapf(err, "stat %q", relPath)
+				}
+			}
+
+			switch {
+			case info.IsDir():
+				if err = walk(absPath, relPath); err != nil {
+					return err
+				}
+			case info.Mode().IsRegular():
+				f, err := hashDirManifestFile(absPath, relPath)
+				if err != nil {
+					return err
+				}
+				if opt.modTime {
+					f.ModTime = info.ModTime().UTC().Format(time.RFC3339)
+				}
+
+				files = append(files, f)
+			default:
+				return errors.Errorf("unsupported file type %s of %q", info.Mode().Type(), relPath)
+			}
+		}
+
+		return nil
+	}
+
+	if err := walk(dir, ""); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files, nil
+}
+
+func hashDirManifestFile(absPath, relPath string) (DirManifestFile, error) {
+	fp, err := os.Open(absPath)
+	if err != nil {
+		return DirManifestFile{}, errors.Wrapf(err, "open %q", relPath)
+	}
+	defer fp.Close() // nolint: errcheck
+
+	hasher := sha256.New()
+	n, err := io.Copy(hasher, fp)
+	if err != nil {
+		return DirManifestFile{}, errors.Wrapf(err, "hash %q", relPath)
+	}
+
+	return DirManifestFile{
+		Path:   relPath,
+		Size:   n,
+		SHA256: hex.EncodeToString(hasher.Sum(nil)),
+	}, nil
+}
+
+// excluded whether relPath match any exclude glob
+func (o *manifestOption) excluded(relPath string) bool {
+	for _, glob := range o.exclude {
+		name := path.Base(relPath)
+		if strings.Contains(glob, "/") {
+			name = relPath
+		}
+
+		if ok, _ := path.Match(glob, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
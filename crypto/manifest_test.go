@@ -0,0 +1,172 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	gjson "github.com/Laisky/go-utils/v4/json"
+)
+
+func testPrepareManifestDir(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"plugin.toml":         "name = 'laisky'",
+		"bin/run":             "#!/bin/sh\necho yo",
+		"lib/a/b.txt":         "bbb",
+		"lib/a/c.txt":         "ccc",
+		".git/HEAD":           "ref: refs/heads/master",
+		"lib/cache/tmp.cache": "tmp",
+	} {
+		fpath := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(fpath), 0700))
+		require.NoError(t, os.WriteFile(fpath, []byte(content), 0600))
+	}
+
+	return dir
+}
+
+func TestSignDirManifest(t *testing.T) {
+	t.Parallel()
+
+	prikey, err := NewEd25519Prikey()
+	require.NoError(t, err)
+	pubkey := prikey.Public().(ed25519.PublicKey)
+	otherPrikey, err := NewEd25519Prikey()
+	require.NoError(t, err)
+
+	t.Run("verify", func(t *testing.T) {
+		t.Parallel()
+		dir := testPrepareManifestDir(t)
+
+		manifestBytes, err := SignDirManifest(prikey, dir,
+			WithManifestExclude(".git", "lib/cache"),
+			WithManifestModTime(),
+		)
+		require.NoError(t, err)
+
+		manifest := new(DirManifest)
+		require.NoError(t, gjson.Unmarshal(manifestBytes, manifest))
+		canonical, err := gjson.MarshalCanonical(manifest)
+		require.NoError(t, err)
+		require.Equal(t, string(canonical), string(manifestBytes))
+
+		var paths []string
+		for _, f := range manifest.Files {
+			paths = append(paths, f.Path)
+		}
+		require.Equal(t, []string{"bin/run", "lib/a/b.txt", "lib/a/c.txt", "plugin.toml"}, paths)
+		require.Equal(t, int64(3), manifest.Files[1].Size)
+		require.NotEmpty(t, manifest.Files[1].ModTime)
+
+		require.NoError(t, VerifyDirManifest(pubkey, dir, manifestBytes))
+
+		// excluded files could be changed freely
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("yo"), 0600))
+		require.NoError(t, VerifyDirManifest(pubkey, dir, manifestBytes))
+
+		err = VerifyDirManifest(otherPrikey.Public().(ed25519.PublicKey), dir, manifestBytes)
+		require.ErrorContains(t, err, "invalid manifest signature")
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		t.Parallel()
+		dir := testPrepareManifestDir(t)
+
+		manifestBytes, err := SignDirManifest(prikey, dir)
+		require.NoError(t, err)
+
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "lib", "a", "b.txt"), []byte("bbB"), 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "lib", "extra"), []byte("extra"), 0600))
+		require.NoError(t, os.Remove(filepath.Join(dir, "plugin.toml")))
+
+		err = VerifyDirManifest(pubkey, dir, manifestBytes)
+		require.ErrorContains(t, err, `file "lib/a/b.txt" mismatch`)
+		require.ErrorContains(t, err, `file "lib/extra" not in manifest`)
+		require.ErrorContains(t, err, `file "plugin.toml" missing`)
+	})
+
+	t.Run("tampered manifest", func(t *testing.T) {
+		t.Parallel()
+		dir := testPrepareManifestDir(t)
+
+		manifestBytes, err := SignDirManifest(prikey, dir)
+		require.NoError(t, err)
+
+		manifest := new(DirManifest)
+		require.NoError(t, gjson.Unmarshal(manifestBytes, manifest))
+		manifest.Exclude = append(manifest.Exclude, "*.txt")
+		manifestBytes, err = gjson.Marshal(manifest)
+		require.NoError(t, err)
+
+		err = VerifyDirManifest(pubkey, dir, manifestBytes)
+		require.ErrorContains(t, err, "invalid manifest signature")
+	})
+
+	t.Run("symlink", func(t *testing.T) {
+		t.Parallel()
+		dir := testPrepareManifestDir(t)
+		require.NoError(t, os.Symlink(filepath.Join("a", "b.txt"), filepath.Join(dir, "lib", "link")))
+
+		// not follow
+		manifestBytes, err := SignDirManifest(prikey, dir)
+		require.NoError(t, err)
+		manifest := new(DirManifest)
+		require.NoError(t, gjson.Unmarshal(manifestBytes, manifest))
+		var linkFile *DirManifestFile
+		for i := range manifest.Files {
+			if manifest.Files[i].Path == "lib/link" {
+				linkFile = &manifest.Files[i]
+			}
+		}
+		require.NotNil(t, linkFile)
+		require.Equal(t, "a/b.txt", linkFile.Link)
+		require.Empty(t, linkFile.SHA256)
+		require.NoError(t, VerifyDirManifest(pubkey, dir, manifestBytes))
+
+		// follow
+		followed, err := SignDirManifest(prikey, dir, WithManifestFollowSymlinks(true))
+		require.NoError(t, err)
+		require.NoError(t, VerifyDirManifest(pubkey, dir, followed))
+
+		// retarget symlink
+		require.NoError(t, os.Remove(filepath.Join(dir, "lib", "link")))
+		require.NoError(t, os.Symlink(filepath.Join("a", "c.txt"), filepath.Join(dir, "lib", "link")))
+		require.ErrorContains(t, VerifyDirManifest(pubkey, dir, manifestBytes), `file "lib/link" mismatch`)
+		require.ErrorContains(t, VerifyDirManifest(pubkey, dir, followed), `file "lib/link" mismatch`)
+
+		// loop
+		require.NoError(t, os.Symlink("..", filepath.Join(dir, "lib", "loop")))
+		_, err = SignDirManifest(prikey, dir, WithManifestFollowSymlinks(true))
+		require.ErrorContains(t, err, "symlink loop")
+	})
+
+	t.Run("invalid glob", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := SignDirManifest(prikey, t.TempDir(), WithManifestExclude("["))
+		require.ErrorContains(t, err, "invalid glob")
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		t.Parallel()
+		dir := testPrepareManifestDir(t)
+
+		_, err := SignDirManifest(nil, dir)
+		require.ErrorContains(t, err, "invalid ed25519 private key size 0")
+		_, err = SignDirManifest(prikey[:10], dir)
+		require.ErrorContains(t, err, "invalid ed25519 private key size 10")
+
+		manifestBytes, err := SignDirManifest(prikey, dir)
+		require.NoError(t, err)
+		err = VerifyDirManifest(nil, dir, manifestBytes)
+		require.ErrorContains(t, err, "invalid ed25519 public key size 0")
+		err = VerifyDirManifest(pubkey[:10], dir, manifestBytes)
+		require.ErrorContains(t, err, "invalid ed25519 public key size 10")
+	})
+}
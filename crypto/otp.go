@@ -1,8 +1,14 @@
 package crypto
 
 import (
+	"crypto/rand"
 	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/base32"
+	"hash"
+	"math"
 	"net/url"
 	"strconv"
 	"strings"
@@ -30,8 +36,26 @@ type OTPAlgorithm string
 const (
 	// OTPAlgorithmSHA1 sha1
 	OTPAlgorithmSHA1 OTPAlgorithm = "sha1"
+	// OTPAlgorithmSHA256 sha256
+	OTPAlgorithmSHA256 OTPAlgorithm = "sha256"
+	// OTPAlgorithmSHA512 sha512
+	OTPAlgorithmSHA512 OTPAlgorithm = "sha512"
 )
 
+// hashFunc return the hash constructor of algorithm, default to sha1
+func (a OTPAlgorithm) hashFunc() (func() hash.Hash, error) {
+	switch OTPAlgorithm(strings.ToLower(string(a))) {
+	case "", OTPAlgorithmSHA1:
+		return sha1.New, nil
+	case OTPAlgorithmSHA256:
+		return sha256.New, nil
+	case OTPAlgorithmSHA512:
+		return sha512.New, nil
+	default:
+		return nil, errors.Errorf("unsupport hasher %q", a)
+	}
+}
+
 // Base32Secret generate base32 encoded secret
 func Base32Secret(key []byte) string {
 	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(key)
@@ -71,15 +95,20 @@ type OTPArgs struct {
 
 // Hasher get hasher from argument
 func (a OTPArgs) Hasher() (*gotp.Hasher, error) {
-	switch strings.ToLower(string(a.Algorithm)) {
-	case "", string(OTPAlgorithmSHA1):
-		return &gotp.Hasher{
-			HashName: string(OTPAlgorithmSHA1),
-			Digest:   sha1.New,
-		}, nil
-	default:
-		return nil, errors.Errorf("unsupport hasher %q", a.Algorithm)
+	digest, err := a.Algorithm.hashFunc()
+	if err != nil {
+		return nil, err
 	}
+
+	name := strings.ToLower(string(a.Algorithm))
+	if name == "" {
+		name = string(OTPAlgorithmSHA1)
+	}
+
+	return &gotp.Hasher{
+		HashName: name,
+		Digest:   digest,
+	}, nil
 }
 
 // TOTP time-based OTP
@@ -99,16 +128,15 @@ type TOTPInterface interface {
 }
 
 // NewTOTP new TOTP
-func NewTOTP(arg OTPArgs) (*TOTP, error) {
+//
+// secret with spaces, lowercase or padding is accepted,
+// as Google Authenticator exports them.
+func NewTOTP(arg OTPArgs, opts ...OTPOption) (*TOTP, error) {
 	arg.OtpType = OTPTypeTOTP
-	if len(arg.Base32Secret) == 0 {
-		return nil, errors.Errorf("secret shoule not be empty")
+	if err := arg.prepare(opts...); err != nil {
+		return nil, err
 	}
 
-	arg.Algorithm = gutils.OptionalVal(&arg.Algorithm, OTPAlgorithmSHA1)
-	arg.Digits = gutils.OptionalVal(&arg.Digits, 6)
-	arg.PeriodSecs = gutils.OptionalVal(&arg.PeriodSecs, 30)
-
 	hasher, err := arg.Hasher()
 	if err != nil {
 		return nil, err
@@ -135,6 +163,27 @@ func (t *TOTP) KeyAt(at time.Time) string {
 	return t.engine.AtTime(at)
 }
 
+// Validate check code at t, allow skewSteps periods before and after t
+// to tolerate clock drift.
+//
+// code is compared in constant time.
+func (t *TOTP) Validate(code string, at time.Time, skewSteps int) bool {
+	step := at.Unix() / int64(t.arg.PeriodSecs)
+	var matched int
+	for i := -skewSteps; i <= skewSteps; i++ {
+		if step+int64(i) < 0 {
+			continue
+		}
+
+		expect := t.engine.At((step + int64(i)) * int64(t.arg.PeriodSecs))
+
+		// check all steps to avoid leaking which one matched by timing
+		matched |= subtle.ConstantTimeCompare([]byte(expect), []byte(code))
+	}
+
+	return matched == 1
+}
+
 // URI build uri for otp arguments
 func (t *TOTP) URI() string {
 	return gotp.BuildUri(
@@ -221,3 +270,220 @@ func ParseOTPUri(uri string) (arg OTPArgs, err error) {
 
 	return arg, nil
 }
+
+// HOTP hash-based OTP
+type HOTP struct {
+	arg    OTPArgs
+	engine *gotp.HOTP
+}
+
+// NewHOTP new HOTP
+//
+// secret with spaces, lowercase or padding is accepted,
+// as Google Authenticator exports them.
+func NewHOTP(arg OTPArgs, opts ...OTPOption) (*HOTP, error) {
+	arg.OtpType = OTPTypeHOTP
+	if err := arg.prepare(opts...); err != nil {
+		return nil, err
+	}
+	if arg.InitialCount < 0 {
+		return nil, errors.Errorf("initial count should not be negative, got %d", arg.InitialCount)
+	}
+
+	hasher, err := arg.Hasher()
+	if err != nil {
+		return nil, err
+	}
+
+	return &HOTP{
+		engine: gotp.NewHOTP(arg.Base32Secret, int(arg.Digits), hasher),
+		arg:    arg,
+	}, nil
+}
+
+// KeyAt generate key by hotp at counter
+func (h *HOTP) KeyAt(counter uint64) (string, error) {
+	if counter > math.MaxInt64 {
+		return "", errors.Errorf("counter should not exceed %d", int64(math.MaxInt64))
+	}
+
+	return h.engine.At(int(counter)), nil
+}
+
+// Validate check code against counters in [counter, counter+lookAhead],
+// return the next counter to store if matched.
+//
+// code is compared in constant time.
+func (h *HOTP) Validate(code string, counter uint64, lookAhead int) (next uint64, ok bool) {
+	for i := 0; i <= lookAhead; i++ {
+		expect, err := h.KeyAt(counter + uint64(i))
+		if err != nil {
+			return counter, false
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expect), []byte(code)) == 1 {
+			return counter + uint64(i) + 1, true
+		}
+	}
+
+	return counter, false
+}
+
+// URI build uri for otp arguments
+func (h *HOTP) URI() string {
+	return gotp.BuildUri(
+		string(h.arg.OtpType),
+		h.arg.Base32Secret,
+		h.arg.AccountName,
+		h.arg.IssuerName,
+		string(h.arg.Algorithm),
+		h.arg.InitialCount,
+		int(h.arg.Digits),
+		0,
+	)
+}
+
+// OTPOption options for NewTOTP/NewHOTP, override fields of OTPArgs
+type OTPOption func(*OTPArgs) error
+
+// WithOTPPeriod set the number of seconds each totp code is valid for,
+// default to 30
+func WithOTPPeriod(secs uint) OTPOption {
+	return func(o *OTPArgs) error {
+		if secs == 0 {
+			return errors.New("period should be positive")
+		}
+
+		o.PeriodSecs = secs
+		return nil
+	}
+}
+
+// WithOTPDigits set the length of otp code, should in [6, 10], default to 6
+func WithOTPDigits(digits uint) OTPOption {
+	return func(o *OTPArgs) error {
+		if digits < 6 || digits > 10 {
+			return errors.Errorf("digits should in [6, 10], got %d", digits)
+		}
+
+		o.Digits = digits
+		return nil
+	}
+}
+
+// WithOTPAlgorithm set hash algorithm, default to sha1
+func WithOTPAlgorithm(algorithm OTPAlgorithm) OTPOption {
+	return func(o *OTPArgs) error {
+		if _, err := algorithm.hashFunc(); err != nil {
+			return err
+		}
+
+		o.Algorithm = algorithm
+		return nil
+	}
+}
+
+// prepare apply options, fill default values and normalize secret
+func (a *OTPArgs) prepare(opts ...OTPOption) error {
+	for _, f := range opts {
+		if err := f(a); err != nil {
+			return errors.Wrap(err, "apply options")
+		}
+	}
+
+	a.Algorithm = gutils.OptionalVal(&a.Algorithm, OTPAlgorithmSHA1)
+	a.Digits = gutils.OptionalVal(&a.Digits, 6)
+	a.PeriodSecs = gutils.OptionalVal(&a.PeriodSecs, 30)
+
+	// gotp panics on invalid secret
+	key, err := decodeOTPSecret(a.Base32Secret)
+	if err != nil {
+		return err
+	}
+
+	a.Base32Secret = Base32Secret(key)
+	return nil
+}
+
+// decodeOTPSecret decode base32 secret, spaces, lowercase and
+// padding are accepted as Google Authenticator exports them
+func decodeOTPSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	secret = strings.TrimRight(secret, "=")
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode base32 secret")
+	}
+	if len(key) == 0 {
+		return nil, errors.New("secret should not be empty")
+	}
+
+	return key, nil
+}
+
+// NewTOTPSecret generate a random base32 secret and
+// its otpauth:// provisioning uri for QR codes
+func NewTOTPSecret(issuer, account string) (secret string, provisioningURI string, err error) {
+	key := make([]byte, 20)
+	if _, err = rand.Read(key); err != nil {
+		return "", "", errors.Wrap(err, "generate secret")
+	}
+
+	secret = Base32Secret(key)
+	totp, err := NewTOTP(OTPArgs{
+		Base32Secret: secret,
+		AccountName:  account,
+		IssuerName:   issuer,
+	})
+	if err != nil {
+		return "", "", errors.Wrap(err, "new totp")
+	}
+
+	return secret, totp.URI(), nil
+}
+
+// GenerateHOTP generate hotp code by counter, see RFC 4226
+func GenerateHOTP(secret string, counter uint64, opts ...OTPOption) (string, error) {
+	hotp, err := NewHOTP(OTPArgs{Base32Secret: secret}, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	return hotp.KeyAt(counter)
+}
+
+// ValidateHOTP check code against counters in [counter, counter+lookAhead],
+// return the next counter to store if matched.
+//
+// code is compared in constant time.
+func ValidateHOTP(secret, code string, counter uint64, lookAhead int, opts ...OTPOption) (next uint64, ok bool) {
+	hotp, err := NewHOTP(OTPArgs{Base32Secret: secret}, opts...)
+	if err != nil {
+		return counter, false
+	}
+
+	return hotp.Validate(code, counter, lookAhead)
+}
+
+// GenerateTOTP generate totp code at t, see RFC 6238
+func GenerateTOTP(secret string, t time.Time, opts ...OTPOption) (string, error) {
+	totp, err := NewTOTP(OTPArgs{Base32Secret: secret}, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	return totp.KeyAt(t), nil
+}
+
+// ValidateTOTP check code at t, allow skewSteps periods before and after t
+// to tolerate clock drift.
+//
+// code is compared in constant time.
+func ValidateTOTP(secret, code string, t time.Time, skewSteps int, opts ...OTPOption) bool {
+	totp, err := NewTOTP(OTPArgs{Base32Secret: secret}, opts...)
+	if err != nil {
+		return false
+	}
+
+	return totp.Validate(code, t, skewSteps)
+}
@@ -1,6 +1,7 @@
 package crypto
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -50,3 +51,170 @@ func TestTOTP_Key(t *testing.T) {
 	require.NoError(t, err)
 	testTOTP(t, tt)
 }
+
+func TestGenerateTOTP_RFC6238(t *testing.T) {
+	t.Parallel()
+
+	seeds := map[OTPAlgorithm]string{
+		OTPAlgorithmSHA1:   Base32Secret([]byte("12345678901234567890")),
+		OTPAlgorithmSHA256: Base32Secret([]byte("12345678901234567890123456789012")),
+		OTPAlgorithmSHA512: Base32Secret([]byte(
+			"1234567890123456789012345678901234567890123456789012345678901234")),
+	}
+
+	for _, tc := range []struct {
+		ts                   int64
+		sha1, sha256, sha512 string
+	}{
+		{59, "94287082", "46119246", "90693936"},
+		{1111111109, "07081804", "68084774", "25091201"},
+		{1111111111, "14050471", "67062674", "99943326"},
+		{1234567890, "89005924", "91819424", "93441116"},
+		{2000000000, "69279037", "90698825", "38618901"},
+		{20000000000, "65353130", "77737706", "47863826"},
+	} {
+		at := time.Unix(tc.ts, 0)
+		for alg, expect := range map[OTPAlgorithm]string{
+			OTPAlgorithmSHA1:   tc.sha1,
+			OTPAlgorithmSHA256: tc.sha256,
+			OTPAlgorithmSHA512: tc.sha512,
+		} {
+			code, err := GenerateTOTP(seeds[alg], at, WithOTPDigits(8), WithOTPAlgorithm(alg))
+			require.NoError(t, err)
+			require.Equal(t, expect, code, "alg %s at %d", alg, tc.ts)
+			require.True(t, ValidateTOTP(seeds[alg], code, at, 0,
+				WithOTPDigits(8), WithOTPAlgorithm(alg)))
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	t.Parallel()
+
+	secret, uri, err := NewTOTPSecret("laisky-corp", "admin")
+	require.NoError(t, err)
+	require.Len(t, secret, 32)
+	require.Contains(t, uri, "otpauth://totp/")
+	require.Contains(t, uri, "secret="+secret)
+
+	arg, err := ParseOTPUri(uri)
+	require.NoError(t, err)
+	require.Equal(t, secret, arg.Base32Secret)
+	require.Equal(t, "laisky-corp", arg.IssuerName)
+
+	now := time.Unix(1700000000, 0)
+	code, err := GenerateTOTP(secret, now)
+	require.NoError(t, err)
+	require.Len(t, code, 6)
+
+	// compatible with TOTP
+	totp, err := NewTOTP(arg)
+	require.NoError(t, err)
+	require.Equal(t, totp.KeyAt(now), code)
+	require.True(t, totp.Validate(code, now.Add(30*time.Second), 1))
+	require.False(t, totp.Validate(code, now.Add(30*time.Second), 0))
+
+	_, err = NewTOTP(OTPArgs{Base32Secret: secret}, WithOTPDigits(4))
+	require.Error(t, err)
+
+	require.True(t, ValidateTOTP(secret, code, now, 0))
+	require.False(t, ValidateTOTP(secret, code, now.Add(30*time.Second), 0))
+	require.True(t, ValidateTOTP(secret, code, now.Add(30*time.Second), 1))
+	require.True(t, ValidateTOTP(secret, code, now.Add(-30*time.Second), 1))
+	require.False(t, ValidateTOTP(secret, code, now.Add(90*time.Second), 1))
+	wrong := []byte(code)
+	wrong[0] = '0' + (wrong[0]-'0'+1)%10
+	require.False(t, ValidateTOTP(secret, string(wrong), now, 1))
+	require.False(t, ValidateTOTP(secret, code[:5], now, 0))
+
+	t.Run("google authenticator secret", func(t *testing.T) {
+		var grouped []string
+		for i := 0; i < len(secret); i += 4 {
+			grouped = append(grouped, strings.ToLower(secret[i:i+4]))
+		}
+
+		require.True(t, ValidateTOTP(strings.Join(grouped, " "), code, now, 0))
+	})
+
+	t.Run("period", func(t *testing.T) {
+		start := time.Unix(1700000000/60*60, 0)
+		code, err := GenerateTOTP(secret, start, WithOTPPeriod(60))
+		require.NoError(t, err)
+		require.True(t, ValidateTOTP(secret, code, start.Add(59*time.Second), 0, WithOTPPeriod(60)))
+		require.False(t, ValidateTOTP(secret, code, start.Add(60*time.Second), 0, WithOTPPeriod(60)))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := GenerateTOTP("not base32!", now)
+		require.Error(t, err)
+		_, err = GenerateTOTP("", now)
+		require.Error(t, err)
+		_, err = GenerateTOTP(secret, now, WithOTPDigits(4))
+		require.Error(t, err)
+		_, err = GenerateTOTP(secret, now, WithOTPAlgorithm("md5"))
+		require.Error(t, err)
+		require.False(t, ValidateTOTP("not base32!", code, now, 1))
+	})
+}
+
+func TestHOTP_RFC4226(t *testing.T) {
+	t.Parallel()
+
+	secret := Base32Secret([]byte("12345678901234567890"))
+	expects := []string{
+		"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489",
+	}
+	for i, expect := range expects {
+		code, err := GenerateHOTP(secret, uint64(i))
+		require.NoError(t, err)
+		require.Equal(t, expect, code, "counter %d", i)
+	}
+
+	next, ok := ValidateHOTP(secret, expects[0], 0, 0)
+	require.True(t, ok)
+	require.EqualValues(t, 1, next)
+
+	// counter drift within look ahead window
+	next, ok = ValidateHOTP(secret, expects[3], 1, 2)
+	require.True(t, ok)
+	require.EqualValues(t, 4, next)
+
+	next, ok = ValidateHOTP(secret, expects[5], 1, 2)
+	require.False(t, ok)
+	require.EqualValues(t, 1, next)
+
+	// used code can not be replayed
+	_, ok = ValidateHOTP(secret, expects[0], 1, 5)
+	require.False(t, ok)
+
+	t.Run("type", func(t *testing.T) {
+		hotp, err := NewHOTP(OTPArgs{
+			Base32Secret: strings.ToLower(secret) + "====",
+			AccountName:  "admin",
+			IssuerName:   "laisky-corp",
+			InitialCount: 3,
+		})
+		require.NoError(t, err)
+
+		code, err := hotp.KeyAt(3)
+		require.NoError(t, err)
+		require.Equal(t, expects[3], code)
+		next, ok := hotp.Validate(expects[4], 3, 1)
+		require.True(t, ok)
+		require.EqualValues(t, 5, next)
+
+		arg, err := ParseOTPUri(hotp.URI())
+		require.NoError(t, err)
+		require.Equal(t, OTPTypeHOTP, arg.OtpType)
+		require.Equal(t, secret, arg.Base32Secret)
+		require.Equal(t, 3, arg.InitialCount)
+
+		_, err = hotp.KeyAt(1 << 63)
+		require.Error(t, err)
+		_, err = NewHOTP(OTPArgs{Base32Secret: "not base32!"})
+		require.Error(t, err)
+		_, err = NewHOTP(OTPArgs{Base32Secret: secret, InitialCount: -1})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,159 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/Laisky/errors/v2"
+)
+
+// spkiPinPrefix prefix of SPKI pin, like curl's `--pinnedpubkey`
+const spkiPinPrefix = "sha256//"
+
+// SPKIPin return the pin of cert's public key like `sha256//<base64>`,
+// which is the base64 encoded sha256 of SubjectPublicKeyInfo, as HPKP does.
+//
+// can be generated by openssl:
+//
+//	openssl x509 -in cert.pem -pubkey -noout | \
+//	  openssl pkey -pubin -outform der | \
+//	  openssl dgst -sha256 -binary | base64
+func SPKIPin(cert *x509.Certificate) string {
+	hashed := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return spkiPinPrefix + base64.StdEncoding.EncodeToString(hashed[:])
+}
+
+type pinOption struct {
+	leafOnly     bool
+	allowExpired bool
+}
+
+// PinOption options for NewCertPinVerifier
+type PinOption func(*pinOption) error
+
+// WithPinLeafOnly only match pins against the leaf certificate,
+// default is any certificate in the presented chain.
+func WithPinLeafOnly() PinOption {
+	return func(o *pinOption) error {
+		o.leafOnly = true
+		return nil
+	}
+}
+
+// WithPinAllowExpired do not check the validity period of certificates,
+// should only be used in lab environments.
+func WithPinAllowExpired() PinOption {
+	return func(o *pinOption) error {
+		o.allowExpired = true
+		return nil
+	}
+}
+
+// NewCertPinVerifier new verifier for tls.Config.VerifyPeerCertificate,
+// accept the connection only if the presented certificates match any of pins.
+//
+// pins should be like `sha256//<base64>`, see SPKIPin.
+// invalid pins or options are reported by the returned verifier,
+// so every connection will be rejected.
+//
+// the verifier does not trust any roots, so it can be used
+// with tls.Config.InsecureSkipVerify to replace the system roots.
+// when a non-leaf certificate is pinned, the certificates below it
+// must be signed in chain, so a pinned CA can not be simply appended.
+func NewCertPinVerifier(pins []string, opts ...PinOption) func(
+	rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	opt := new(pinOption)
+	pinSet, err := parseCertPins(pins)
+	if err == nil {
+		for _, f := range opts {
+			if err = f(opt); err != nil {
+				err = errors.Wrap(err, "apply option")
+				break
+			}
+		}
+	}
+
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if err != nil {
+			return errors.Wrap(err, "invalid cert pin verifier")
+		}
+		if len(rawCerts) == 0 {
+			return errors.New("no certificate presented")
+		}
+
+		certs := make([]*x509.Certificate, 0, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return errors.Wrapf(err, "parse certificate %d", i)
+			}
+
+			certs = append(certs, cert)
+		}
+
+		if !opt.allowExpired {
+			now := time.Now()
+			if now.Before(certs[0].NotBefore) || now.After(certs[0].NotAfter) {
+				return errors.Errorf("leaf certificate is not valid at %s, valid period is [%s, %s]",
+					now.Format(time.RFC3339),
+					certs[0].NotBefore.Format(time.RFC3339),
+					certs[0].NotAfter.Format(time.RFC3339))
+			}
+		}
+
+		candidates := certs
+		if opt.leafOnly {
+			candidates = certs[:1]
+		}
+
+		presented := make([]string, 0, len(candidates))
+		for i, cert := range candidates {
+			// the pinned cert should sign the cert below it
+			if i > 0 {
+				if err := certs[i-1].CheckSignatureFrom(cert); err != nil {
+					break
+				}
+			}
+
+			pin := SPKIPin(cert)
+			if _, ok := pinSet[pin]; ok {
+				return nil
+			}
+
+			presented = append(presented, pin)
+		}
+
+		return errors.Errorf("certificate pins mismatch, presented [%s]",
+			strings.Join(presented, ", "))
+	}
+}
+
+// parseCertPins check pins and return them as a set
+func parseCertPins(pins []string) (map[string]struct{}, error) {
+	if len(pins) == 0 {
+		return nil, errors.New("pins should not be empty")
+	}
+
+	pinSet := make(map[string]struct{}, len(pins))
+	for _, pin := range pins {
+		b64, ok := strings.CutPrefix(pin, spkiPinPrefix)
+		if !ok {
+			return nil, errors.Errorf("pin %q should start with %q", pin, spkiPinPrefix)
+		}
+
+		hashed, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "decode pin %q", pin)
+		}
+		if len(hashed) != sha256.Size {
+			return nil, errors.Errorf("pin %q should be sha256", pin)
+		}
+
+		pinSet[pin] = struct{}{}
+	}
+
+	return pinSet, nil
+}
@@ -0,0 +1,148 @@
+package crypto
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPinServer(t *testing.T, certsDer [][]byte, prikeyPem []byte) *httptest.Server {
+	t.Helper()
+
+	prikey, err := Pem2Prikey(prikeyPem)
+	require.NoError(t, err)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	ts.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: certsDer,
+			PrivateKey:  prikey,
+		}},
+	}
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func requestWithPins(url string, pins []string, opts ...PinOption) error {
+	cli := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify:    true, //nolint:gosec // verified by pins
+				VerifyPeerCertificate: NewCertPinVerifier(pins, opts...),
+			},
+		},
+	}
+
+	resp, err := cli.Get(url)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+func TestNewCertPinVerifier(t *testing.T) {
+	t.Parallel()
+
+	prikeyPem1, certDer1, err := NewRSAPrikeyAndCert(RSAPrikeyBits2048,
+		WithX509CertCommonName("pinned"))
+	require.NoError(t, err)
+	_, certDer2, err := NewRSAPrikeyAndCert(RSAPrikeyBits2048,
+		WithX509CertCommonName("other"))
+	require.NoError(t, err)
+
+	cert1, err := Der2Cert(certDer1)
+	require.NoError(t, err)
+	cert2, err := Der2Cert(certDer2)
+	require.NoError(t, err)
+
+	ts := newTestPinServer(t, [][]byte{certDer1}, prikeyPem1)
+
+	t.Run("accept", func(t *testing.T) {
+		require.NoError(t, requestWithPins(ts.URL, []string{SPKIPin(cert2), SPKIPin(cert1)}))
+		require.NoError(t, requestWithPins(ts.URL, []string{SPKIPin(cert1)}, WithPinLeafOnly()))
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		err := requestWithPins(ts.URL, []string{SPKIPin(cert2)})
+		require.ErrorContains(t, err, "certificate pins mismatch")
+		require.ErrorContains(t, err, SPKIPin(cert1))
+	})
+
+	t.Run("invalid pins", func(t *testing.T) {
+		raws := [][]byte{certDer1}
+		require.Error(t, NewCertPinVerifier(nil)(raws, nil))
+		require.ErrorContains(t, NewCertPinVerifier([]string{"sha1//abcd"})(raws, nil),
+			"should start with")
+		require.Error(t, NewCertPinVerifier([]string{"sha256//!!!"})(raws, nil))
+		require.ErrorContains(t, NewCertPinVerifier([]string{"sha256//YWJj"})(raws, nil),
+			"should be sha256")
+
+		err := requestWithPins(ts.URL, []string{"sha256//YWJj"})
+		require.ErrorContains(t, err, "invalid cert pin verifier")
+	})
+}
+
+func TestNewCertPinVerifier_chain(t *testing.T) {
+	t.Parallel()
+
+	caPrikeyPem, caDer, err := NewRSAPrikeyAndCert(RSAPrikeyBits2048,
+		WithX509CertCommonName("ca"), WithX509CertIsCA())
+	require.NoError(t, err)
+	caPrikey, err := Pem2Prikey(caPrikeyPem)
+	require.NoError(t, err)
+	ca, err := Der2Cert(caDer)
+	require.NoError(t, err)
+
+	leafPrikey, err := NewRSAPrikey(RSAPrikeyBits2048)
+	require.NoError(t, err)
+	leafPrikeyPem, err := Prikey2Pem(leafPrikey)
+	require.NoError(t, err)
+	leafDer, err := NewX509Cert(caPrikey,
+		WithX509CertCommonName("leaf"),
+		WithX509CertParent(ca),
+		WithX509CertPubkey(&leafPrikey.PublicKey))
+	require.NoError(t, err)
+
+	ts := newTestPinServer(t, [][]byte{leafDer, caDer}, leafPrikeyPem)
+
+	require.NoError(t, requestWithPins(ts.URL, []string{SPKIPin(ca)}))
+	require.ErrorContains(t, requestWithPins(ts.URL, []string{SPKIPin(ca)}, WithPinLeafOnly()),
+		"certificate pins mismatch")
+
+	t.Run("pinned ca appended to unrelated leaf", func(t *testing.T) {
+		prikeyPem, certDer, err := NewRSAPrikeyAndCert(RSAPrikeyBits2048,
+			WithX509CertCommonName("attacker"))
+		require.NoError(t, err)
+
+		ts := newTestPinServer(t, [][]byte{certDer, caDer}, prikeyPem)
+		require.ErrorContains(t, requestWithPins(ts.URL, []string{SPKIPin(ca)}),
+			"certificate pins mismatch")
+	})
+}
+
+func TestNewCertPinVerifier_expired(t *testing.T) {
+	t.Parallel()
+
+	prikeyPem, certDer, err := NewRSAPrikeyAndCert(RSAPrikeyBits2048,
+		WithX509CertCommonName("expired"),
+		WithX509CertNotBefore(time.Now().Add(-2*time.Hour)),
+		WithX509CertNotAfter(time.Now().Add(-time.Hour)))
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(certDer)
+	require.NoError(t, err)
+
+	ts := newTestPinServer(t, [][]byte{certDer}, prikeyPem)
+
+	require.ErrorContains(t, requestWithPins(ts.URL, []string{SPKIPin(cert)}), "not valid")
+	require.NoError(t, requestWithPins(ts.URL, []string{SPKIPin(cert)}, WithPinAllowExpired()))
+}
@@ -0,0 +1,78 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"slices"
+	"strings"
+
+	"github.com/Laisky/errors/v2"
+)
+
+// KeyPolicy constraints of keys, used by ValidateKeyPolicy
+type KeyPolicy struct {
+	// MinRSABits minimum bits of rsa key, 0 means no limit
+	MinRSABits int
+	// AllowedCurves allowed curves of ecdsa key,
+	// empty means ECDSACurveP256, ECDSACurveP384 and ECDSACurveP521
+	AllowedCurves []ECDSACurve
+	// AllowEd25519 whether ed25519 key is permitted
+	AllowEd25519 bool
+}
+
+// ecdsaCurveName convert curve name like `P-256` to ECDSACurve
+func ecdsaCurveName(name string) ECDSACurve {
+	return ECDSACurve(strings.ReplaceAll(name, "-", ""))
+}
+
+// ValidateKeyPolicy check whether private key meets policy,
+// return error describes the violation
+func ValidateKeyPolicy(key crypto.PrivateKey, policy KeyPolicy) error {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return errors.Errorf("key type %T not permitted by policy", key)
+	}
+
+	return validatePubkeyPolicy(signer.Public(), policy)
+}
+
+func validatePubkeyPolicy(pubkey crypto.PublicKey, policy KeyPolicy) error {
+	switch pubkey := pubkey.(type) {
+	case *rsa.PublicKey:
+		if bits := pubkey.N.BitLen(); bits < policy.MinRSABits {
+			return errors.Errorf("rsa key has %d bits, policy requires at least %d",
+				bits, policy.MinRSABits)
+		}
+	case *ecdsa.PublicKey:
+		allowed := policy.AllowedCurves
+		if len(allowed) == 0 {
+			allowed = []ECDSACurve{ECDSACurveP256, ECDSACurveP384, ECDSACurveP521}
+		}
+
+		curve := pubkey.Curve.Params().Name
+		if !slices.Contains(allowed, ecdsaCurveName(curve)) {
+			return errors.Errorf("ecdsa curve %s not permitted by policy, allowed %v",
+				curve, allowed)
+		}
+	case ed25519.PublicKey:
+		if !policy.AllowEd25519 {
+			return errors.New("ed25519 key not permitted by policy")
+		}
+	default:
+		return errors.Errorf("key type %T not permitted by policy", pubkey)
+	}
+
+	return nil
+}
+
+// NewRSAPrikeyWithPolicy new rsa private key, return error if bits violates policy
+func NewRSAPrikeyWithPolicy(bits RSAPrikeyBits, policy KeyPolicy) (*rsa.PrivateKey, error) {
+	if int(bits) < policy.MinRSABits {
+		return nil, errors.Errorf("rsa key has %d bits, policy requires at least %d",
+			bits, policy.MinRSABits)
+	}
+
+	return NewRSAPrikey(bits)
+}
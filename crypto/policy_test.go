@@ -0,0 +1,101 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateKeyPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy := KeyPolicy{MinRSABits: 3072}
+
+	rsa2048, err := NewRSAPrikey(RSAPrikeyBits2048)
+	require.NoError(t, err)
+	err = ValidateKeyPolicy(rsa2048, policy)
+	require.ErrorContains(t, err, "rsa key has 2048 bits, policy requires at least 3072")
+	require.NoError(t, ValidateKeyPolicy(rsa2048, KeyPolicy{}))
+
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	require.NoError(t, err)
+	require.ErrorContains(t, ValidateKeyPolicy(p224, policy), "ecdsa curve P-224 not permitted")
+
+	p384, err := NewECDSAPrikey(ECDSACurveP384)
+	require.NoError(t, err)
+	require.NoError(t, ValidateKeyPolicy(p384, policy))
+	err = ValidateKeyPolicy(p384, KeyPolicy{AllowedCurves: []ECDSACurve{ECDSACurveP256}})
+	require.ErrorContains(t, err, "ecdsa curve P-384 not permitted")
+
+	edkey, err := NewEd25519Prikey()
+	require.NoError(t, err)
+	require.ErrorContains(t, ValidateKeyPolicy(edkey, policy), "ed25519 key not permitted")
+	require.NoError(t, ValidateKeyPolicy(edkey, KeyPolicy{AllowEd25519: true}))
+
+	require.ErrorContains(t, ValidateKeyPolicy("key", policy), "not permitted")
+
+	_, err = NewRSAPrikeyWithPolicy(RSAPrikeyBits2048, policy)
+	require.ErrorContains(t, err, "policy requires at least 3072")
+	key, err := NewRSAPrikeyWithPolicy(RSAPrikeyBits3072, policy)
+	require.NoError(t, err)
+	require.Equal(t, 3072, key.N.BitLen())
+}
+
+func TestWithX509SignCSRKeyPolicy(t *testing.T) {
+	t.Parallel()
+
+	caPrikeyPem, caDer, err := NewECDSAPrikeyAndCert(ECDSACurveP256,
+		WithX509CertCommonName("ca"),
+		WithX509CertIsCA(),
+	)
+	require.NoError(t, err)
+	caPrikey, err := Pem2Prikey(caPrikeyPem)
+	require.NoError(t, err)
+	ca, err := Der2Cert(caDer)
+	require.NoError(t, err)
+
+	policy := KeyPolicy{MinRSABits: 3072}
+
+	t.Run("rsa 2048", func(t *testing.T) {
+		t.Parallel()
+
+		prikey, err := NewRSAPrikey(RSAPrikeyBits2048)
+		require.NoError(t, err)
+		csrDer, err := NewX509CSR(prikey, WithX509CSRCommonName("leaf"))
+		require.NoError(t, err)
+
+		_, err = NewX509CertByCSR(ca, caPrikey, csrDer, WithX509SignCSRKeyPolicy(policy))
+		require.ErrorContains(t, err, "rsa key has 2048 bits, policy requires at least 3072")
+
+		// no policy
+		_, err = NewX509CertByCSR(ca, caPrikey, csrDer)
+		require.NoError(t, err)
+	})
+
+	t.Run("ecdsa p224", func(t *testing.T) {
+		t.Parallel()
+
+		prikey, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+		require.NoError(t, err)
+		csrDer, err := NewX509CSR(prikey, WithX509CSRCommonName("leaf"))
+		require.NoError(t, err)
+
+		_, err = NewX509CertByCSR(ca, caPrikey, csrDer, WithX509SignCSRKeyPolicy(policy))
+		require.ErrorContains(t, err, "ecdsa curve P-224 not permitted")
+	})
+
+	t.Run("rsa 3072", func(t *testing.T) {
+		t.Parallel()
+
+		prikey, err := NewRSAPrikey(RSAPrikeyBits3072)
+		require.NoError(t, err)
+		csrDer, err := NewX509CSR(prikey, WithX509CSRCommonName("leaf"))
+		require.NoError(t, err)
+
+		_, err = NewX509CertByCSR(ca, caPrikey, csrDer, WithX509SignCSRKeyPolicy(policy))
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,471 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"math"
+	"math/big"
+
+	"github.com/Laisky/errors/v2"
+)
+
+const (
+	recipientMagic   = "GUENC"
+	recipientVersion = 1
+
+	recipientFileKeySize     = 32
+	recipientFingerprintSize = 16
+
+	// recipientStanzaRSAOAEP file key is wrapped by RSA-OAEP with sha256
+	recipientStanzaRSAOAEP byte = 1
+	// recipientStanzaECDH file key is wrapped by AES-GCM with the key
+	// derived from ephemeral ECDH by HKDF
+	recipientStanzaECDH byte = 2
+
+	recipientRSALabel      = "gutils recipient rsa"
+	recipientECDHInfo      = "gutils recipient ecdh"
+	recipientHeaderInfo    = "gutils recipient header"
+	recipientPayloadInfo   = "gutils recipient payload"
+	recipientWrappedKeyLen = recipientFileKeySize + 16
+)
+
+// recipientCurves curve id used in ECDH stanza
+var recipientCurves = []struct {
+	id    byte
+	curve ecdh.Curve
+}{
+	{1, ecdh.P256()},
+	{2, ecdh.P384()},
+	{3, ecdh.P521()},
+	{4, ecdh.X25519()},
+}
+
+// recipientFingerprint sha256 of pkix der of public key, truncated to 16 bytes
+func recipientFingerprint(pubkey crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pubkey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "marshal public key %T", pubkey)
+	}
+
+	sum := sha256.Sum256(der)
+	return sum[:recipientFingerprintSize], nil
+}
+
+// ed25519PubkeyToX25519 convert ed25519 public key to x25519 by
+// the birational map u = (1 + y) / (1 - y), see RFC 7748
+func ed25519PubkeyToX25519(pubkey ed25519.PublicKey) (*ecdh.PublicKey, error) {
+	if len(pubkey) != ed25519.PublicKeySize {
+		return nil, errors.Errorf("invalid ed25519 public key size %d", len(pubkey))
+	}
+
+	p := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+
+	// little endian y without the sign bit of x
+	le := bytes.Clone(pubkey)
+	le[31] &= 0x7f
+	y := new(big.Int).SetBytes(reverseBytes(le))
+	if y.Cmp(p) >= 0 {
+		return nil, errors.New("invalid ed25519 public key")
+	}
+
+	one := big.NewInt(1)
+	denominator := new(big.Int).Sub(one, y)
+	denominator.Mod(denominator, p)
+	if denominator.Sign() == 0 {
+		return nil, errors.New("invalid ed25519 public key")
+	}
+
+	u := new(big.Int).Add(one, y)
+	u.Mul(u, denominator.ModInverse(denominator, p))
+	u.Mod(u, p)
+
+	return ecdh.X25519().NewPublicKey(reverseBytes(u.FillBytes(make([]byte, 32))))
+}
+
+// ed25519PrikeyToX25519 convert ed25519 private key to x25519,
+// the scalar is the first half of sha512(seed), same as ed25519 signing
+func ed25519PrikeyToX25519(prikey ed25519.PrivateKey) (*ecdh.PrivateKey, error) {
+	if len(prikey) != ed25519.PrivateKeySize {
+		return nil, errors.Errorf("invalid ed25519 private key size %d", len(prikey))
+	}
+
+	h := sha512.Sum512(prikey.Seed())
+	return ecdh.X25519().NewPrivateKey(h[:32])
+}
+
+func reverseBytes(b []byte) []byte {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+
+	return b
+}
+
+// newRecipientGCM derive key from secret by HKDF, and return AES-256-GCM
+func newRecipientGCM(secret, salt []byte, info string) (cipher.AEAD, error) {
+	key := make([]byte, 32)
+	if err := HKDFWithSHA256(secret, salt, []byte(info), [][]byte{key}); err != nil {
+		return nil, errors.Wrap(err, "derive key")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "new aes cipher")
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// recipientHeaderMAC hmac of header by key derived from file key
+func recipientHeaderMAC(fileKey, header []byte) ([]byte, error) {
+	key := make([]byte, 32)
+	if err := HKDFWithSHA256(fileKey, nil, []byte(recipientHeaderInfo), [][]byte{key}); err != nil {
+		return nil, errors.Wrap(err, "derive header key")
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(header)
+	return mac.Sum(nil), nil
+}
+
+// wrapFileKeyByECDH wrap file key by ephemeral ECDH,
+// return curve id | ephemeral public key | wrapped key
+func wrapFileKeyByECDH(pubkey *ecdh.PublicKey, fileKey []byte) ([]byte, error) {
+	var curveID byte
+	for _, c := range recipientCurves {
+		if c.curve == pubkey.Curve() {
+			curveID = c.id
+		}
+	}
+	if curveID == 0 {
+		return nil, errors.Errorf("unsupport curve %s", pubkey.Curve())
+	}
+
+	ephemeral, err := pubkey.Curve().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "generate ephemeral key")
+	}
+
+	shared, err := ephemeral.ECDH(pubkey)
+	if err != nil {
+		return nil, errors.Wrap(err, "ecdh")
+	}
+
+	ephemeralPub := ephemeral.PublicKey().Bytes()
+	aead, err := newRecipientGCM(shared,
+		append(bytes.Clone(ephemeralPub), pubkey.Bytes()...), recipientECDHInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	// wrap key is unique, so zero nonce is safe
+	body := append([]byte{curveID}, ephemeralPub...)
+	return aead.Seal(body, make([]byte, aead.NonceSize()), fileKey, nil), nil
+}
+
+// unwrapFileKeyByECDH unwrap file key wrapped by wrapFileKeyByECDH
+func unwrapFileKeyByECDH(prikey *ecdh.PrivateKey, body []byte) ([]byte, error) {
+	if len(body) <= 1+recipientWrappedKeyLen {
+		return nil, errors.New("invalid ecdh stanza")
+	}
+
+	var curve ecdh.Curve
+	for _, c := range recipientCurves {
+		if c.id == body[0] {
+			curve = c.curve
+		}
+	}
+	if curve != prikey.Curve() {
+		return nil, errors.Errorf("curve %d of stanza does not match private key", body[0])
+	}
+
+	ephemeralPubBytes := body[1 : len(body)-recipientWrappedKeyLen]
+	ephemeralPub, err := curve.NewPublicKey(ephemeralPubBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse ephemeral public key")
+	}
+
+	shared, err := prikey.ECDH(ephemeralPub)
+	if err != nil {
+		return nil, errors.Wrap(err, "ecdh")
+	}
+
+	aead, err := newRecipientGCM(shared,
+		append(bytes.Clone(ephemeralPubBytes), prikey.PublicKey().Bytes()...), recipientECDHInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	fileKey, err := aead.Open(nil, make([]byte, aead.NonceSize()),
+		body[len(body)-recipientWrappedKeyLen:], nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unwrap file key")
+	}
+
+	return fileKey, nil
+}
+
+// wrapFileKey return stanza type, fingerprint and body of recipient
+func wrapFileKey(recipient crypto.PublicKey, fileKey []byte) (
+	typ byte, fingerprint, body []byte, err error) {
+	if fingerprint, err = recipientFingerprint(recipient); err != nil {
+		return 0, nil, nil, err
+	}
+
+	var ecdhPub *ecdh.PublicKey
+	switch pub := recipient.(type) {
+	case *rsa.PublicKey:
+		body, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, fileKey, []byte(recipientRSALabel))
+		if err != nil {
+			return 0, nil, nil, errors.Wrap(err, "encrypt file key by rsa-oaep")
+		}
+
+		return recipientStanzaRSAOAEP, fingerprint, body, nil
+	case *ecdsa.PublicKey:
+		ecdhPub, err = pub.ECDH()
+	case *ecdh.PublicKey:
+		ecdhPub = pub
+	case ed25519.PublicKey:
+		ecdhPub, err = ed25519PubkeyToX25519(pub)
+	default:
+		return 0, nil, nil, errors.Errorf("unsupport recipient type %T", recipient)
+	}
+	if err != nil {
+		return 0, nil, nil, errors.Wrap(err, "convert to ecdh public key")
+	}
+
+	if body, err = wrapFileKeyByECDH(ecdhPub, fileKey); err != nil {
+		return 0, nil, nil, err
+	}
+
+	return recipientStanzaECDH, fingerprint, body, nil
+}
+
+// EncryptFileToRecipient encrypt src to dst, only the holders of
+// private keys of recipients can decrypt it by DecryptFileFromRecipient.
+//
+// recipients could be *rsa.PublicKey, *ecdsa.PublicKey,
+// *ecdh.PublicKey or ed25519.PublicKey.
+//
+// a random file key is wrapped for each recipient, by RSA-OAEP for rsa keys,
+// or by ephemeral ECDH and HKDF for other keys, ed25519 keys are converted to x25519.
+// the header is authenticated by HMAC of the file key,
+// and the payload is encrypted by chunked AES-256-GCM, see StreamEncryptor.
+func EncryptFileToRecipient(dst io.Writer, src io.Reader, recipients []crypto.PublicKey) error {
+	if len(recipients) == 0 {
+		return errors.New("recipients should not be empty")
+	}
+	if len(recipients) > math.MaxUint16 {
+		return errors.Errorf("too many recipients %d", len(recipients))
+	}
+
+	fileKey := make([]byte, recipientFileKeySize)
+	if _, err := rand.Read(fileKey); err != nil {
+		return errors.Wrap(err, "generate file key")
+	}
+
+	header := bytes.NewBufferString(recipientMagic)
+	header.WriteByte(recipientVersion)
+	_ = binary.Write(header, binary.BigEndian, uint16(len(recipients)))
+	for i, recipient := range recipients {
+		typ, fingerprint, body, err := wrapFileKey(recipient, fileKey)
+		if err != nil {
+			return errors.Wrapf(err, "wrap file key for recipient %d", i)
+		}
+
+		header.WriteByte(typ)
+		header.Write(fingerprint)
+		_ = binary.Write(header, binary.BigEndian, uint16(len(body)))
+		header.Write(body)
+	}
+
+	mac, err := recipientHeaderMAC(fileKey, header.Bytes())
+	if err != nil {
+		return err
+	}
+	header.Write(mac)
+
+	if _, err = dst.Write(header.Bytes()); err != nil {
+		return errors.Wrap(err, "write header")
+	}
+
+	aead, err := newRecipientGCM(fileKey, mac, recipientPayloadInfo)
+	if err != nil {
+		return err
+	}
+
+	enc, err := newStreamEncryptor(dst, aead)
+	if err != nil {
+		return errors.Wrap(err, "new stream encryptor")
+	}
+
+	if _, err = io.Copy(enc, src); err != nil {
+		return errors.Wrap(err, "encrypt payload")
+	}
+
+	return enc.Close()
+}
+
+type recipientStanza struct {
+	typ  byte
+	body []byte
+}
+
+// unwrap file key from stanza by private key
+func (s recipientStanza) unwrap(prikey crypto.PrivateKey) (fileKey []byte, err error) {
+	var ecdhPri *ecdh.PrivateKey
+	switch pri := prikey.(type) {
+	case *rsa.PrivateKey:
+		if s.typ != recipientStanzaRSAOAEP {
+			return nil, errors.Errorf("stanza type %d does not match rsa private key", s.typ)
+		}
+
+		fileKey, err = rsa.DecryptOAEP(sha256.New(), nil, pri, s.body, []byte(recipientRSALabel))
+		if err != nil {
+			return nil, errors.Wrap(err, "decrypt file key by rsa-oaep")
+		}
+
+		return fileKey, nil
+	case *ecdsa.PrivateKey:
+		ecdhPri, err = pri.ECDH()
+	case *ecdh.PrivateKey:
+		ecdhPri = pri
+	case ed25519.PrivateKey:
+		ecdhPri, err = ed25519PrikeyToX25519(pri)
+	default:
+		return nil, errors.Errorf("unsupport private key type %T", prikey)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "convert to ecdh private key")
+	}
+
+	if s.typ != recipientStanzaECDH {
+		return nil, errors.Errorf("stanza type %d does not match ecdh private key", s.typ)
+	}
+
+	return unwrapFileKeyByECDH(ecdhPri, s.body)
+}
+
+// DecryptFileFromRecipient decrypt src encrypted by EncryptFileToRecipient to dst,
+// the wrapped file key is located by fingerprint of prikey's public key.
+//
+// the header is verified before any plaintext is written,
+// and each chunk is only written after it is authenticated,
+// so a truncated or tampered payload returns error after
+// writing the chunks before the broken one.
+func DecryptFileFromRecipient(dst io.Writer, src io.Reader, prikey crypto.PrivateKey) error {
+	// all supported private keys have Public method, include *ecdh.PrivateKey
+	pri, ok := prikey.(interface{ Public() crypto.PublicKey })
+	if !ok {
+		return errors.Errorf("unsupport private key type %T", prikey)
+	}
+
+	return decryptFileFromRecipient(dst, src, prikey, pri.Public())
+}
+
+func decryptFileFromRecipient(dst io.Writer, src io.Reader,
+	prikey crypto.PrivateKey, pubkey crypto.PublicKey) error {
+	fingerprint, err := recipientFingerprint(pubkey)
+	if err != nil {
+		return err
+	}
+
+	// keep raw header to verify mac
+	header := new(bytes.Buffer)
+	r := io.TeeReader(src, header)
+
+	prefix := make([]byte, len(recipientMagic)+1+2)
+	if _, err = io.ReadFull(r, prefix); err != nil {
+		return errors.Wrap(err, "read header")
+	}
+	if string(prefix[:len(recipientMagic)]) != recipientMagic {
+		return errors.New("not a recipient encrypted file")
+	}
+	if prefix[len(recipientMagic)] != recipientVersion {
+		return errors.Errorf("unsupport version %d", prefix[len(recipientMagic)])
+	}
+
+	count := binary.BigEndian.Uint16(prefix[len(recipientMagic)+1:])
+	if count == 0 {
+		return errors.New("no recipient in header")
+	}
+
+	var matched []recipientStanza
+	stanzaHead := make([]byte, 1+recipientFingerprintSize+2)
+	for i := 0; i < int(count); i++ {
+		if _, err = io.ReadFull(r, stanzaHead); err != nil {
+			return errors.Wrapf(err, "read stanza %d", i)
+		}
+
+		body := make([]byte, binary.BigEndian.Uint16(stanzaHead[1+recipientFingerprintSize:]))
+		if _, err = io.ReadFull(r, body); err != nil {
+			return errors.Wrapf(err, "read stanza %d", i)
+		}
+
+		if hmac.Equal(stanzaHead[1:1+recipientFingerprintSize], fingerprint) {
+			matched = append(matched, recipientStanza{typ: stanzaHead[0], body: body})
+		}
+	}
+
+	headerLen := header.Len()
+	mac := make([]byte, sha256.Size)
+	if _, err = io.ReadFull(src, mac); err != nil {
+		return errors.Wrap(err, "read header mac")
+	}
+
+	if len(matched) == 0 {
+		return errors.New("no recipient matches the private key")
+	}
+
+	var (
+		fileKey []byte
+		errs    []error
+	)
+	for _, stanza := range matched {
+		if fileKey, err = stanza.unwrap(prikey); err == nil {
+			break
+		}
+
+		errs = append(errs, err)
+	}
+	if fileKey == nil {
+		return errors.Wrap(errors.Join(errs...), "unwrap file key")
+	}
+
+	expectMAC, err := recipientHeaderMAC(fileKey, header.Bytes()[:headerLen])
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(mac, expectMAC) {
+		return errors.New("header mac mismatch, header may be tampered")
+	}
+
+	aead, err := newRecipientGCM(fileKey, mac, recipientPayloadInfo)
+	if err != nil {
+		return err
+	}
+
+	dec, err := newStreamDecryptor(src, aead)
+	if err != nil {
+		return errors.Wrap(err, "new stream decryptor")
+	}
+
+	if _, err = io.Copy(dst, dec); err != nil {
+		return errors.Wrap(err, "decrypt payload")
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEd25519ToX25519(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		pub, pri, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+
+		xpub, err := ed25519PubkeyToX25519(pub)
+		require.NoError(t, err)
+		xpri, err := ed25519PrikeyToX25519(pri)
+		require.NoError(t, err)
+		require.True(t, xpri.PublicKey().Equal(xpub))
+	}
+}
+
+func TestEncryptFileToRecipient(t *testing.T) {
+	rsaPri, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecdsaPri, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	edPub, edPri, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	xPri, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	recipients := []crypto.PublicKey{&rsaPri.PublicKey, &ecdsaPri.PublicKey, edPub, xPri.PublicKey()}
+	prikeys := []crypto.PrivateKey{rsaPri, ecdsaPri, edPri, xPri}
+
+	for _, size := range []int{0, 1, StreamChunkSize, 3*StreamChunkSize + 7} {
+		plaintext := make([]byte, size)
+		_, err = rand.Read(plaintext)
+		require.NoError(t, err)
+
+		cipher := new(bytes.Buffer)
+		err = EncryptFileToRecipient(cipher, bytes.NewReader(plaintext), recipients)
+		require.NoError(t, err)
+
+		for _, prikey := range prikeys {
+			got := new(bytes.Buffer)
+			err = DecryptFileFromRecipient(got, bytes.NewReader(cipher.Bytes()), prikey)
+			require.NoError(t, err, "%T", prikey)
+			require.True(t, bytes.Equal(plaintext, got.Bytes()), "%T", prikey)
+		}
+	}
+
+	plaintext := bytes.Repeat([]byte("hello"), StreamChunkSize/2)
+	cipher := new(bytes.Buffer)
+	err = EncryptFileToRecipient(cipher, bytes.NewReader(plaintext), recipients[:2])
+	require.NoError(t, err)
+
+	t.Run("wrong key", func(t *testing.T) {
+		otherPri, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		require.NoError(t, err)
+
+		for _, prikey := range []crypto.PrivateKey{otherPri, edPri, xPri} {
+			err = DecryptFileFromRecipient(new(bytes.Buffer), bytes.NewReader(cipher.Bytes()), prikey)
+			require.ErrorContains(t, err, "no recipient matches")
+		}
+	})
+
+	t.Run("tampered header", func(t *testing.T) {
+		tampered := bytes.Clone(cipher.Bytes())
+		// flip a byte in the rsa stanza body
+		tampered[len(recipientMagic)+3+1+recipientFingerprintSize+2+10] ^= 1
+		err = DecryptFileFromRecipient(new(bytes.Buffer), bytes.NewReader(tampered), ecdsaPri)
+		require.ErrorContains(t, err, "header may be tampered")
+	})
+
+	t.Run("tampered payload", func(t *testing.T) {
+		tampered := bytes.Clone(cipher.Bytes())
+		tampered[len(tampered)-1] ^= 1
+		err = DecryptFileFromRecipient(new(bytes.Buffer), bytes.NewReader(tampered), rsaPri)
+		require.Error(t, err)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		for _, n := range []int{3, 40, cipher.Len() - StreamChunkSize, cipher.Len() - 1} {
+			got := new(bytes.Buffer)
+			err = DecryptFileFromRecipient(got, bytes.NewReader(cipher.Bytes()[:n]), rsaPri)
+			require.Error(t, err, n)
+			require.Less(t, got.Len(), len(plaintext))
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		err = EncryptFileToRecipient(new(bytes.Buffer), bytes.NewReader(plaintext), nil)
+		require.Error(t, err)
+
+		err = EncryptFileToRecipient(new(bytes.Buffer), bytes.NewReader(plaintext),
+			[]crypto.PublicKey{"not a key"})
+		require.Error(t, err)
+	})
+}
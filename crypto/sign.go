@@ -1,14 +1,18 @@
 package crypto
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/sha512"
+	"encoding/asn1"
+	"hash"
 	"io"
 	"math/big"
 	"strings"
@@ -17,6 +21,8 @@ import (
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/sign/schnorr"
 	dediskey "go.dedis.ch/kyber/v3/util/key"
+
+	gutils "github.com/Laisky/go-utils/v4"
 )
 
 // // EncodeRSAPrivateKey encode rsa private key to pem bytes
@@ -111,13 +117,29 @@ func VerifyByRSAPSSWithSHA256(pubKey *rsa.PublicKey, content []byte, sig []byte)
 }
 
 // SignReaderByRSAWithSHA256 generate signature by rsa private key use sha256
-func SignReaderByRSAWithSHA256(prikey *rsa.PrivateKey, reader io.Reader) (sig []byte, err error) {
+//
+// return detached signature by default, use WithSignatureEnvelope to get envelope.
+func SignReaderByRSAWithSHA256(prikey *rsa.PrivateKey, reader io.Reader,
+	opts ...SignOption) (sig []byte, err error) {
+	opt, err := new(signOption).applyOpts(opts...)
+	if err != nil {
+		return nil, err
+	}
+
 	hasher := sha256.New()
 	if _, err = io.Copy(hasher, reader); err != nil {
 		return nil, errors.Wrap(err, "read content")
 	}
 
-	return rsa.SignPKCS1v15(rand.Reader, prikey, crypto.SHA256, hasher.Sum(nil))
+	if sig, err = rsa.SignPKCS1v15(rand.Reader, prikey, crypto.SHA256, hasher.Sum(nil)); err != nil {
+		return nil, errors.Wrap(err, "sign")
+	}
+
+	if opt.envelope {
+		return NewSignatureEnvelope(SignatureAlgorithmRSAPKCS1v15, SignatureHashSHA256, sig)
+	}
+
+	return sig, nil
 }
 
 // VerifyReaderByRSAWithSHA256 verify signature by rsa public key use sha256
@@ -166,6 +188,136 @@ func VerifyByECDSAWithSHA256(pubKey *ecdsa.PublicKey, content []byte, r, s *big.
 	return ecdsa.Verify(pubKey, hash[:], r, s)
 }
 
+// SignByECDSAWithSHA256Deterministic generate signature by ecdsa private key use sha256,
+// nonce is derived from private key and content by RFC 6979,
+// so the same key and content always produce the same signature.
+//
+// s is not normalized, use NormalizeECDSALowS if low-S form is required.
+// it is not constant-time, do not use it where timing side channel matters.
+func SignByECDSAWithSHA256Deterministic(prikey *ecdsa.PrivateKey,
+	content []byte) (r, s *big.Int, err error) {
+	if prikey == nil || prikey.D == nil {
+		return nil, nil, errors.New("private key should not be empty")
+	}
+
+	hashed := sha256.Sum256(content)
+	curve := prikey.Curve
+	n := curve.Params().N
+	e := rfc6979Bits2Int(hashed[:], n.BitLen())
+
+	nonces := newRFC6979Nonces(sha256.New, prikey.D, n, hashed[:])
+	for i := 0; i < 100; i++ {
+		k := nonces.next()
+
+		x, _ := curve.ScalarBaseMult(k.FillBytes(make([]byte, (n.BitLen()+7)/8)))
+		r = new(big.Int).Mod(x, n)
+		if r.Sign() == 0 {
+			continue
+		}
+
+		// s = k^-1 * (e + r*d) mod n
+		s = new(big.Int).Mul(r, prikey.D)
+		s.Add(s, e)
+		s.Mul(s, new(big.Int).ModInverse(k, n))
+		s.Mod(s, n)
+		if s.Sign() != 0 {
+			return r, s, nil
+		}
+	}
+
+	return nil, nil, errors.New("cannot find valid nonce")
+}
+
+// NormalizeECDSALowS return n - s if s > n/2, otherwise return copy of s,
+// both forms are accepted by VerifyByECDSAWithSHA256.
+func NormalizeECDSALowS(curve elliptic.Curve, s *big.Int) *big.Int {
+	n := curve.Params().N
+	if s.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		return new(big.Int).Sub(n, s)
+	}
+
+	return new(big.Int).Set(s)
+}
+
+// rfc6979Bits2Int convert bytes to int, keep the leftmost qlen bits
+func rfc6979Bits2Int(b []byte, qlen int) *big.Int {
+	v := new(big.Int).SetBytes(b)
+	if blen := len(b) * 8; blen > qlen {
+		v.Rsh(v, uint(blen-qlen))
+	}
+
+	return v
+}
+
+// rfc6979Nonces generate nonce candidates by RFC 6979 section 3.2
+type rfc6979Nonces struct {
+	q    *big.Int
+	rlen int
+	k, v []byte
+	hash func() hash.Hash
+	// started whether the first candidate has been returned
+	started bool
+}
+
+func newRFC6979Nonces(h func() hash.Hash, x, q *big.Int, hashed []byte) *rfc6979Nonces {
+	rlen := (q.BitLen() + 7) / 8
+	hlen := h().Size()
+
+	// bits2octets(h1)
+	z := rfc6979Bits2Int(hashed, q.BitLen())
+	if z.Cmp(q) >= 0 {
+		z.Sub(z, q)
+	}
+
+	g := &rfc6979Nonces{
+		q:    q,
+		rlen: rlen,
+		k:    make([]byte, hlen),
+		v:    bytes.Repeat([]byte{0x01}, hlen),
+		hash: h,
+	}
+
+	xOctets := x.FillBytes(make([]byte, rlen))
+	zOctets := z.FillBytes(make([]byte, rlen))
+	for _, sep := range []byte{0x00, 0x01} {
+		g.k = g.mac(g.k, g.v, []byte{sep}, xOctets, zOctets)
+		g.v = g.mac(g.k, g.v)
+	}
+
+	return g
+}
+
+func (g *rfc6979Nonces) mac(key []byte, data ...[]byte) []byte {
+	m := hmac.New(g.hash, key)
+	for _, d := range data {
+		m.Write(d)
+	}
+
+	return m.Sum(nil)
+}
+
+// next return the next nonce in [1, q-1]
+func (g *rfc6979Nonces) next() *big.Int {
+	for {
+		if g.started {
+			g.k = g.mac(g.k, g.v, []byte{0x00})
+			g.v = g.mac(g.k, g.v)
+		}
+		g.started = true
+
+		var t []byte
+		for len(t) < g.rlen {
+			g.v = g.mac(g.k, g.v)
+			t = append(t, g.v...)
+		}
+
+		k := rfc6979Bits2Int(t[:g.rlen], g.q.BitLen())
+		if k.Sign() > 0 && k.Cmp(g.q) < 0 {
+			return k
+		}
+	}
+}
+
 // SignByECDSAWithSHA256AndBase64 generate signature by ecdsa private key use sha256
 func SignByECDSAWithSHA256AndBase64(prikey *ecdsa.PrivateKey, content []byte) (signature string, err error) {
 	hash := sha256.Sum256(content)
@@ -213,7 +365,15 @@ const (
 )
 
 // SignReaderByEd25519WithSHA256 generate signature by ecdsa private key use sha256
-func SignReaderByEd25519WithSHA256(prikey ed25519.PrivateKey, reader io.Reader) (sig []byte, err error) {
+//
+// return detached signature by default, use WithSignatureEnvelope to get envelope.
+func SignReaderByEd25519WithSHA256(prikey ed25519.PrivateKey, reader io.Reader,
+	opts ...SignOption) (sig []byte, err error) {
+	opt, err := new(signOption).applyOpts(opts...)
+	if err != nil {
+		return nil, err
+	}
+
 	hasher := sha256.New()
 	chunk := make([]byte, streamChunkSize)
 	for {
@@ -231,7 +391,15 @@ func SignReaderByEd25519WithSHA256(prikey ed25519.PrivateKey, reader io.Reader)
 		}
 	}
 
-	return prikey.Sign(rand.Reader, hasher.Sum(nil), crypto.Hash(0))
+	if sig, err = prikey.Sign(rand.Reader, hasher.Sum(nil), crypto.Hash(0)); err != nil {
+		return nil, errors.Wrap(err, "sign")
+	}
+
+	if opt.envelope {
+		return NewSignatureEnvelope(SignatureAlgorithmEd25519, SignatureHashSHA256, sig)
+	}
+
+	return sig, nil
 }
 
 // VerifyReaderByEd25519WithSHA256 verify signature by ecdsa public key use sha256
@@ -292,7 +460,7 @@ func EncodeES256SignByBase64(r, s *big.Int) string {
 func DecodeES256SignByBase64(sign string) (r, s *big.Int, err error) {
 	ss := strings.Split(sign, ecdsaSignDelimiter)
 	if len(ss) != 2 {
-		return nil, nil, errors.Wrapf(err, "unknown format of signature `%s`, expect is `xxxx.xxxx`", sign)
+		return nil, nil, errors.Errorf("unknown format of signature `%s`, expect is `xxxx.xxxx`", sign)
 	}
 
 	if r, err = ParseBase642Big(ss[0]); err != nil {
@@ -306,6 +474,61 @@ func DecodeES256SignByBase64(sign string) (r, s *big.Int, err error) {
 	return
 }
 
+// EncodeES256SignByBase64URL format ecdsa signature to url-safe stirng without padding
+func EncodeES256SignByBase64URL(r, s *big.Int) string {
+	return FormatBig2Base64URL(r) + ecdsaSignDelimiter + FormatBig2Base64URL(s)
+}
+
+// DecodeES256SignByBase64URL parse ecdsa signature string to two *big.Int,
+// accept the output of both EncodeES256SignByBase64URL and EncodeES256SignByBase64
+func DecodeES256SignByBase64URL(sign string) (r, s *big.Int, err error) {
+	ss := strings.Split(sign, ecdsaSignDelimiter)
+	if len(ss) != 2 {
+		return nil, nil, errors.Errorf("unknown format of signature `%s`, expect is `xxxx.xxxx`", sign)
+	}
+
+	if r, err = ParseBase64URL2Big(ss[0]); err != nil {
+		return nil, nil, errors.Wrapf(err, "`%s` is not validate base64", ss[0])
+	}
+
+	if s, err = ParseBase64URL2Big(ss[1]); err != nil {
+		return nil, nil, errors.Wrapf(err, "`%s` is not validate base64", ss[1])
+	}
+
+	return r, s, nil
+}
+
+// ecdsaSignature ASN.1 structure of ecdsa signature
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// EncodeES256SignByDER format ecdsa signature to ASN.1 DER `SEQUENCE{r, s}`,
+// which is the format used by OpenSSL and ecdsa.SignASN1
+func EncodeES256SignByDER(r, s *big.Int) ([]byte, error) {
+	if r == nil || s == nil || r.Sign() <= 0 || s.Sign() <= 0 {
+		return nil, errors.New("r and s should be positive")
+	}
+
+	return asn1.Marshal(ecdsaSignature{R: r, S: s})
+}
+
+// DecodeES256SignByDER parse ASN.1 DER `SEQUENCE{r, s}` to two *big.Int
+func DecodeES256SignByDER(sig []byte) (r, s *big.Int, err error) {
+	var parsed ecdsaSignature
+	rest, err := asn1.Unmarshal(sig, &parsed)
+	switch {
+	case err != nil:
+		return nil, nil, errors.Wrap(err, "unmarshal asn1")
+	case len(rest) != 0:
+		return nil, nil, errors.New("trailing data after signature")
+	case parsed.R.Sign() <= 0 || parsed.S.Sign() <= 0:
+		return nil, nil, errors.New("r and s should be positive")
+	}
+
+	return parsed.R, parsed.S, nil
+}
+
 // HMACSha256 calculate HMAC by sha256
 //
 // The main difference between HMAC and SHA is that
@@ -326,3 +549,35 @@ func HMACSha256(key []byte, data io.Reader) ([]byte, error) {
 
 	return h.Sum(nil), nil
 }
+
+// HMACSha512 calculate HMAC by sha512, see HMACSha256
+//
+// # Returns:
+//   - hmac: HMAC result, 64 bytes
+func HMACSha512(key []byte, data io.Reader) ([]byte, error) {
+	h := hmac.New(sha512.New, key)
+	if _, err := io.Copy(h, data); err != nil {
+		return nil, errors.Wrap(err, "write data")
+	}
+
+	return h.Sum(nil), nil
+}
+
+// NewHMAC new HMAC by hash type, like gutils.HashTypeSha512.
+//
+// the returned hash.Hash can be reused across messages by Reset.
+// xxhash is not a cryptographic hash, so it is not supported.
+func NewHMAC(hashType gutils.HashTypeInterface, key []byte) (hash.Hash, error) {
+	if hashType.String() == gutils.HashTypeXxhash.String() {
+		return nil, errors.Errorf("hash %q is not supported by hmac", hashType.String())
+	}
+
+	if _, err := hashType.Hasher(); err != nil {
+		return nil, errors.Wrap(err, "new hasher")
+	}
+
+	return hmac.New(func() hash.Hash {
+		h, _ := hashType.Hasher() // nolint: errcheck // already checked
+		return h
+	}, key), nil
+}
@@ -0,0 +1,135 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/binary"
+	"io"
+
+	"github.com/Laisky/errors/v2"
+)
+
+// SignatureAlgorithm signature algorithm recorded in signature envelope
+type SignatureAlgorithm uint8
+
+const (
+	// SignatureAlgorithmEd25519 ed25519 over the digest of content
+	SignatureAlgorithmEd25519 SignatureAlgorithm = 1
+	// SignatureAlgorithmRSAPKCS1v15 rsa pkcs#1 v1.5
+	SignatureAlgorithmRSAPKCS1v15 SignatureAlgorithm = 2
+)
+
+// SignatureHash hash algorithm recorded in signature envelope
+type SignatureHash uint8
+
+const (
+	// SignatureHashSHA256 sha256
+	SignatureHashSHA256 SignatureHash = 1
+)
+
+// signatureEnvelopeMagic prefix of signature envelope
+var signatureEnvelopeMagic = []byte("GUSIG")
+
+const (
+	signatureEnvelopeVersion = 1
+	// magic | version | algorithm | hash | signature length (uint16 big endian)
+	signatureEnvelopeHeaderLen = 5 + 1 + 1 + 1 + 2
+)
+
+type signOption struct {
+	envelope bool
+}
+
+// SignOption options for signing
+type SignOption func(*signOption) error
+
+func (o *signOption) applyOpts(opts ...SignOption) (*signOption, error) {
+	for _, f := range opts {
+		if err := f(o); err != nil {
+			return nil, err
+		}
+	}
+
+	return o, nil
+}
+
+// WithSignatureEnvelope return a self-describing signature blob
+// that contains algorithm and hash id, verify it by VerifyEnvelope.
+func WithSignatureEnvelope() SignOption {
+	return func(opt *signOption) error {
+		opt.envelope = true
+		return nil
+	}
+}
+
+// NewSignatureEnvelope wrap detached signature with algorithm and hash id
+//
+// layout: "GUSIG" | version(1B) | algorithm(1B) | hash(1B) | len(sig)(2B, big endian) | sig
+func NewSignatureEnvelope(alg SignatureAlgorithm, hash SignatureHash, sig []byte) ([]byte, error) {
+	if len(sig) > 0xffff {
+		return nil, errors.Errorf("signature too long, got %d bytes", len(sig))
+	}
+
+	envelope := make([]byte, 0, signatureEnvelopeHeaderLen+len(sig))
+	envelope = append(envelope, signatureEnvelopeMagic...)
+	envelope = append(envelope, signatureEnvelopeVersion, byte(alg), byte(hash))
+	envelope = binary.BigEndian.AppendUint16(envelope, uint16(len(sig)))
+	return append(envelope, sig...), nil
+}
+
+// ParseSignatureEnvelope parse envelope created by NewSignatureEnvelope
+func ParseSignatureEnvelope(envelope []byte) (
+	alg SignatureAlgorithm, hash SignatureHash, sig []byte, err error) {
+	if len(envelope) < signatureEnvelopeHeaderLen ||
+		!bytes.HasPrefix(envelope, signatureEnvelopeMagic) {
+		return 0, 0, nil, errors.New("not a signature envelope")
+	}
+
+	header := envelope[len(signatureEnvelopeMagic):signatureEnvelopeHeaderLen]
+	if header[0] != signatureEnvelopeVersion {
+		return 0, 0, nil, errors.Errorf("unsupported envelope version %d", header[0])
+	}
+
+	sig = envelope[signatureEnvelopeHeaderLen:]
+	if int(binary.BigEndian.Uint16(header[3:])) != len(sig) {
+		return 0, 0, nil, errors.Errorf("signature length mismatch")
+	}
+
+	return SignatureAlgorithm(header[1]), SignatureHash(header[2]), sig, nil
+}
+
+// VerifyEnvelope verify content in reader by signature envelope.
+//
+// algorithm and hash are read from envelope,
+// and algorithm must match the type of pubkey to avoid algorithm confusion.
+func VerifyEnvelope(pubkey crypto.PublicKey, reader io.Reader, envelope []byte) error {
+	alg, hash, sig, err := ParseSignatureEnvelope(envelope)
+	if err != nil {
+		return errors.Wrap(err, "parse envelope")
+	}
+
+	if hash != SignatureHashSHA256 {
+		return errors.Errorf("unsupported hash %d", hash)
+	}
+
+	switch alg {
+	case SignatureAlgorithmEd25519:
+		pub, ok := pubkey.(ed25519.PublicKey)
+		if !ok {
+			return errors.Errorf("envelope algorithm ed25519 mismatch pubkey %T", pubkey)
+		}
+
+		return VerifyReaderByEd25519WithSHA256(pub, reader, sig)
+	case SignatureAlgorithmRSAPKCS1v15:
+		pub, ok := pubkey.(*rsa.PublicKey)
+		if !ok {
+			return errors.Errorf("envelope algorithm rsa mismatch pubkey %T", pubkey)
+		}
+
+		return VerifyReaderByRSAWithSHA256(pub, reader, sig)
+	default:
+		return errors.Errorf("unsupported algorithm %d", alg)
+	}
+}
@@ -0,0 +1,77 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignatureEnvelope(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("hello, laisky")
+	edPrikey, err := NewEd25519Prikey()
+	require.NoError(t, err)
+	rsaPrikey, err := NewRSAPrikey(RSAPrikeyBits2048)
+	require.NoError(t, err)
+
+	edEnvelope, err := SignReaderByEd25519WithSHA256(edPrikey, bytes.NewReader(content), WithSignatureEnvelope())
+	require.NoError(t, err)
+	rsaEnvelope, err := SignReaderByRSAWithSHA256(rsaPrikey, bytes.NewReader(content), WithSignatureEnvelope())
+	require.NoError(t, err)
+
+	t.Run("verify", func(t *testing.T) {
+		for _, tt := range []struct {
+			pubkey   crypto.PublicKey
+			envelope []byte
+		}{
+			{Prikey2Pubkey(edPrikey), edEnvelope},
+			{Prikey2Pubkey(rsaPrikey), rsaEnvelope},
+		} {
+			require.NoError(t, VerifyEnvelope(tt.pubkey, bytes.NewReader(content), tt.envelope))
+			require.Error(t, VerifyEnvelope(tt.pubkey, bytes.NewReader([]byte("another")), tt.envelope))
+		}
+	})
+
+	t.Run("detached signature in envelope", func(t *testing.T) {
+		alg, hash, sig, err := ParseSignatureEnvelope(edEnvelope)
+		require.NoError(t, err)
+		require.Equal(t, SignatureAlgorithmEd25519, alg)
+		require.Equal(t, SignatureHashSHA256, hash)
+		require.Len(t, sig, ed25519.SignatureSize)
+
+		pubkey := Prikey2Pubkey(edPrikey).(ed25519.PublicKey) //nolint:forcetypeassert
+		require.NoError(t, VerifyReaderByEd25519WithSHA256(pubkey, bytes.NewReader(content), sig))
+	})
+
+	t.Run("algorithm confusion", func(t *testing.T) {
+		err := VerifyEnvelope(Prikey2Pubkey(rsaPrikey), bytes.NewReader(content), edEnvelope)
+		require.ErrorContains(t, err, "mismatch pubkey")
+
+		err = VerifyEnvelope(Prikey2Pubkey(edPrikey), bytes.NewReader(content), rsaEnvelope)
+		require.ErrorContains(t, err, "mismatch pubkey")
+	})
+
+	t.Run("invalid envelope", func(t *testing.T) {
+		pubkey := Prikey2Pubkey(edPrikey)
+		_, _, sig, err := ParseSignatureEnvelope(edEnvelope)
+		require.NoError(t, err)
+
+		for _, envelope := range [][]byte{
+			nil,
+			sig,
+			edEnvelope[:len(edEnvelope)-1],
+			append([]byte("GUSIG\x02"), edEnvelope[6:]...),
+			append([]byte("GUSIG\x01\x09"), edEnvelope[7:]...),
+			append([]byte("GUSIG\x01\x01\x09"), edEnvelope[8:]...),
+		} {
+			require.Error(t, VerifyEnvelope(pubkey, bytes.NewReader(content), envelope))
+		}
+
+		_, err = NewSignatureEnvelope(SignatureAlgorithmEd25519, SignatureHashSHA256, make([]byte, 0x10000))
+		require.ErrorContains(t, err, "signature too long")
+	})
+}
@@ -9,8 +9,11 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/hex"
 	"fmt"
 	"math/big"
+	"os"
 	"testing"
 
 	"github.com/Laisky/zap"
@@ -18,6 +21,7 @@ import (
 	"go.dedis.ch/kyber/v3/group/edwards25519"
 	dediskey "go.dedis.ch/kyber/v3/util/key"
 
+	gutils "github.com/Laisky/go-utils/v4"
 	"github.com/Laisky/go-utils/v4/log"
 )
 
@@ -384,6 +388,118 @@ func TestECDSASignFormatAndParseByBase64(t *testing.T) {
 	require.Equal(t, 0, b2.Cmp(b))
 }
 
+func TestFormatBig2Base64URL(t *testing.T) {
+	t.Parallel()
+
+	b := new(big.Int).SetBytes([]byte{0xfb, 0xef, 0xff, 0xfe})
+	require.Equal(t, "--___g==", FormatBig2Base64(b))
+	require.Equal(t, "--___g", FormatBig2Base64URL(b))
+
+	for _, raw := range []string{"--___g", "--___g=="} {
+		got, err := ParseBase64URL2Big(raw)
+		require.NoError(t, err)
+		require.Equal(t, 0, got.Cmp(b), raw)
+	}
+
+	for _, raw := range []string{"++///g", "--___", "yo!"} {
+		_, err := ParseBase64URL2Big(raw)
+		require.Error(t, err, raw)
+	}
+}
+
+func TestECDSASignFormatAndParseByBase64URL(t *testing.T) {
+	t.Parallel()
+
+	prikey, err := NewECDSAPrikey(ECDSACurveP256)
+	require.NoError(t, err)
+	content := []byte("hello, laisky")
+
+	r, s, err := SignByECDSAWithSHA256(prikey, content)
+	require.NoError(t, err)
+
+	encoded := EncodeES256SignByBase64URL(r, s)
+	require.NotContains(t, encoded, "=")
+	require.NotContains(t, encoded, "+")
+	require.NotContains(t, encoded, "/")
+
+	r2, s2, err := DecodeES256SignByBase64URL(encoded)
+	require.NoError(t, err)
+	require.True(t, VerifyByECDSAWithSHA256(&prikey.PublicKey, content, r2, s2))
+
+	// compatible with padded encoding
+	r2, s2, err = DecodeES256SignByBase64URL(EncodeES256SignByBase64(r, s))
+	require.NoError(t, err)
+	require.Equal(t, 0, r2.Cmp(r))
+	require.Equal(t, 0, s2.Cmp(s))
+
+	for _, sign := range []string{"", "abc", "a.b.c", "!.abc", "abc.!"} {
+		_, _, err = DecodeES256SignByBase64URL(sign)
+		require.Error(t, err, sign)
+		_, _, err = DecodeES256SignByBase64(sign)
+		require.Error(t, err, sign)
+	}
+}
+
+func TestECDSASignFormatAndParseByDER(t *testing.T) {
+	t.Parallel()
+
+	t.Run("openssl", func(t *testing.T) {
+		pubkeyPem, err := os.ReadFile("testdata/ecdsa/pubkey.pem")
+		require.NoError(t, err)
+		content, err := os.ReadFile("testdata/ecdsa/content.txt")
+		require.NoError(t, err)
+		sig, err := os.ReadFile("testdata/ecdsa/content.sig")
+		require.NoError(t, err)
+
+		pubkey, err := Pem2Pubkey(pubkeyPem)
+		require.NoError(t, err)
+
+		r, s, err := DecodeES256SignByDER(sig)
+		require.NoError(t, err)
+		require.True(t, VerifyByECDSAWithSHA256(pubkey.(*ecdsa.PublicKey), content, r, s)) //nolint:forcetypeassert
+
+		encoded, err := EncodeES256SignByDER(r, s)
+		require.NoError(t, err)
+		require.Equal(t, sig, encoded)
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		prikey, err := NewECDSAPrikey(ECDSACurveP256)
+		require.NoError(t, err)
+		content := []byte("hello, laisky")
+
+		r, s, err := SignByECDSAWithSHA256(prikey, content)
+		require.NoError(t, err)
+		encoded, err := EncodeES256SignByDER(r, s)
+		require.NoError(t, err)
+
+		hashed := sha256.Sum256(content)
+		require.True(t, ecdsa.VerifyASN1(&prikey.PublicKey, hashed[:], encoded))
+
+		r2, s2, err := DecodeES256SignByDER(encoded)
+		require.NoError(t, err)
+		require.Equal(t, 0, r.Cmp(r2))
+		require.Equal(t, 0, s.Cmp(s2))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := EncodeES256SignByDER(nil, big.NewInt(1))
+		require.Error(t, err)
+		_, err = EncodeES256SignByDER(big.NewInt(0), big.NewInt(1))
+		require.Error(t, err)
+
+		valid, err := EncodeES256SignByDER(big.NewInt(1), big.NewInt(2))
+		require.NoError(t, err)
+		negative, err := asn1.Marshal(ecdsaSignature{R: big.NewInt(-1), S: big.NewInt(2)})
+		require.NoError(t, err)
+
+		for _, sig := range [][]byte{nil, []byte("yo"), append(bytes.Clone(valid), 0), negative} {
+			_, _, err = DecodeES256SignByDER(sig)
+			require.Error(t, err, sig)
+		}
+	})
+}
+
 // func Test_expandAesSecret(t *testing.T) {
 // 	type args struct {
 // 		secret []byte
@@ -692,3 +808,126 @@ func TestHMAC(t *testing.T) {
 		})
 	}
 }
+
+// TestHMACSha512 test case 2 from RFC 4231
+func TestHMACSha512(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("Jefe")
+	data := []byte("what do ya want for nothing?")
+	want256 := "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	want512 := "164b7a7bfcf819e2e395fbe73b56e0a387bd64222e831fd610270cd7ea250554" +
+		"9758bf75c05a994a6d034f65f8f0e6fdcaeab1a34d4a6b4b636e070a38bce737"
+
+	got, err := HMACSha512(key, bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, want512, hex.EncodeToString(got))
+
+	got, err = HMACSha256(key, bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, want256, hex.EncodeToString(got))
+
+	t.Run("NewHMAC", func(t *testing.T) {
+		for hashType, want := range map[gutils.HashType]string{
+			gutils.HashTypeSha256: want256,
+			gutils.HashTypeSha512: want512,
+		} {
+			mac, err := NewHMAC(hashType, key)
+			require.NoError(t, err)
+
+			// reuse across messages
+			for i := 0; i < 2; i++ {
+				mac.Reset()
+				_, err = mac.Write(data[:5])
+				require.NoError(t, err)
+				_, err = mac.Write(data[5:])
+				require.NoError(t, err)
+				require.Equal(t, want, hex.EncodeToString(mac.Sum(nil)), hashType)
+			}
+		}
+
+		_, err := NewHMAC(gutils.HashTypeXxhash, key)
+		require.ErrorContains(t, err, "not supported by hmac")
+		_, err = NewHMAC(gutils.HashType("yo"), key)
+		require.Error(t, err)
+	})
+}
+
+func TestSignByECDSAWithSHA256Deterministic(t *testing.T) {
+	t.Parallel()
+
+	hexInt := func(s string) *big.Int {
+		v, ok := new(big.Int).SetString(s, 16)
+		require.True(t, ok, s)
+		return v
+	}
+
+	// RFC 6979 A.2.5, ECDSA 256 bits (prime field)
+	prikey := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     hexInt("60FED4BA255A9D31C961EB74C6356D68C049B8923B61FA6CE669622E60F29FB6"),
+			Y:     hexInt("7903FE1008B8BC99A41AE9E95628BC64F2F1B20C2D7E9F5177A3C294D4462299"),
+		},
+		D: hexInt("C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721"),
+	}
+
+	t.Run("rfc6979 vectors", func(t *testing.T) {
+		t.Parallel()
+
+		for _, c := range []struct {
+			msg, r, s string
+		}{
+			{
+				msg: "sample",
+				r:   "EFD48B2AACB6A8FD1140DD9CD45E81D69D2C877B56AAF991C34D0EA84EAF3716",
+				s:   "F7CB1C942D657C41D436C7A1B6E29F65F3E900DBB9AFF4064DC4AB2F843ACDA8",
+			},
+			{
+				msg: "test",
+				r:   "F1ABB023518351CD71D881567B1EA663ED3EFCF6C5132B354F28D3B0B7D38367",
+				s:   "019F4113742A2B14BD25926B49C649155F267E60D3814B4C0CC84250E46F0083",
+			},
+		} {
+			r, s, err := SignByECDSAWithSHA256Deterministic(prikey, []byte(c.msg))
+			require.NoError(t, err)
+			require.Equal(t, hexInt(c.r), r, c.msg)
+			require.Equal(t, hexInt(c.s), s, c.msg)
+			require.True(t, VerifyByECDSAWithSHA256(&prikey.PublicKey, []byte(c.msg), r, s))
+
+			// low-S form is also valid
+			lowS := NormalizeECDSALowS(prikey.Curve, s)
+			require.True(t, VerifyByECDSAWithSHA256(&prikey.PublicKey, []byte(c.msg), r, lowS))
+			halfN := new(big.Int).Rsh(prikey.Curve.Params().N, 1)
+			require.LessOrEqual(t, lowS.Cmp(halfN), 0)
+			require.Equal(t, lowS, NormalizeECDSALowS(prikey.Curve, lowS))
+		}
+	})
+
+	t.Run("deterministic", func(t *testing.T) {
+		t.Parallel()
+
+		for _, curve := range []ECDSACurve{ECDSACurveP256, ECDSACurveP384, ECDSACurveP521} {
+			key, err := NewECDSAPrikey(curve)
+			require.NoError(t, err)
+
+			content := []byte("hello, world")
+			r1, s1, err := SignByECDSAWithSHA256Deterministic(key, content)
+			require.NoError(t, err)
+			r2, s2, err := SignByECDSAWithSHA256Deterministic(key, content)
+			require.NoError(t, err)
+			require.Equal(t, r1, r2, curve)
+			require.Equal(t, s1, s2, curve)
+			require.True(t, VerifyByECDSAWithSHA256(&key.PublicKey, content, r1, s1), curve)
+
+			r3, s3, err := SignByECDSAWithSHA256Deterministic(key, []byte("hello, world!"))
+			require.NoError(t, err)
+			require.NotEqual(t, r1, r3)
+			require.NotEqual(t, s1, s3)
+			require.False(t, VerifyByECDSAWithSHA256(&key.PublicKey, content, r3, s3))
+		}
+	})
+
+	_, _, err := SignByECDSAWithSHA256Deterministic(nil, []byte("yo"))
+	require.Error(t, err)
+}
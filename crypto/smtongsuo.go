@@ -32,6 +32,65 @@ import (
 type Tongsuo struct {
 	exePath         string
 	serialGenerator *DefaultX509CertSerialNumGenerator
+	// gosm is not nil if tongsuo binary is not found and go fallback is enabled
+	gosm *GoSM
+	// sem semaphore that limits the number of running tongsuo subprocesses,
+	// nil means unlimited
+	sem    chan struct{}
+	runner gutils.CMDRunner
+}
+
+type tongsuoOption struct {
+	goSMFallback bool
+	concurrency  int
+	runner       gutils.CMDRunner
+}
+
+// TongsuoOption options for NewTongsuo
+type TongsuoOption func(*tongsuoOption) error
+
+// WithTongsuoGoSMFallback use pure go implementation GoSM
+// if tongsuo executable binary is not found.
+//
+// in fallback mode, only these methods are available:
+// NewPrikey, Prikey2Pubkey, SignBySm2Sm3, VerifyBySm2Sm3, HashBySm3,
+// EncryptBySm4Cbc(Baisc), DecryptBySm4Cbc(Baisc), EncryptBySm4Gcm, DecryptBySm4Gcm,
+// other methods will return error.
+func WithTongsuoGoSMFallback() TongsuoOption {
+	return func(opt *tongsuoOption) error {
+		opt.goSMFallback = true
+		return nil
+	}
+}
+
+// WithTongsuoConcurrency set the max number of tongsuo subprocesses
+// running at the same time, default is unlimited.
+//
+// it's a concurrency limit, not a process pool, every call still spawns
+// a new subprocess. extra calls will wait for a running call to finish or ctx done.
+func WithTongsuoConcurrency(n int) TongsuoOption {
+	return func(opt *tongsuoOption) error {
+		if n <= 0 {
+			return errors.Errorf("concurrency should be greater than 0, got %d", n)
+		}
+
+		opt.concurrency = n
+		return nil
+	}
+}
+
+// WithTongsuoRunner run tongsuo by runner, default is gutils.DefaultCMDRunner.
+//
+// use gutils.FakeCMDRunner to test without tongsuo executable binary.
+func WithTongsuoRunner(runner gutils.CMDRunner) TongsuoOption {
+	return func(opt *tongsuoOption) error {
+		if runner == nil {
+			return errors.New("runner should not be nil")
+		}
+
+		opt.runner = runner
+		return nil
+	}
 }
 
 // NewTongsuo new tongsuo wrapper
@@ -42,12 +101,41 @@ type Tongsuo struct {
 //
 // #Args
 //   - exePath: path of tongsuo executable binary
-func NewTongsuo(exePath string) (ins *Tongsuo, err error) {
+func NewTongsuo(exePath string, opts ...TongsuoOption) (ins *Tongsuo, err error) {
+	opt := &tongsuoOption{
+		runner: gutils.DefaultCMDRunner,
+	}
+	for _, f := range opts {
+		if err = f(opt); err != nil {
+			return nil, err
+		}
+	}
+
+	ins = &Tongsuo{
+		exePath: exePath,
+		runner:  opt.runner,
+	}
+	if opt.concurrency > 0 {
+		ins.sem = make(chan struct{}, opt.concurrency)
+	}
+
+	// new serial number generator
+	if ins.serialGenerator, err = NewDefaultX509CertSerialNumGenerator(); err != nil {
+		return nil, errors.Wrap(err, "new serial number generator")
+	}
+
+	if opt.goSMFallback {
+		if _, err = exec.LookPath(exePath); err != nil {
+			glog.Shared.Warn("tongsuo executable binary not found, fallback to pure go implementation",
+				zap.String("path", exePath), zap.Error(err))
+			ins.gosm = NewGoSM()
+			return ins, nil
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 
-	ins = &Tongsuo{exePath: exePath}
-
 	// check tongsuo executable binary
 	if out, err := ins.runCMD(ctx, []string{"version"}, nil); err != nil {
 		return nil, errors.Wrapf(err, "run `%s version` failed", exePath)
@@ -55,31 +143,47 @@ func NewTongsuo(exePath string) (ins *Tongsuo, err error) {
 		return nil, errors.Errorf("only support Tongsuo")
 	}
 
-	// new serial number generator
-	if ins.serialGenerator, err = NewDefaultX509CertSerialNumGenerator(); err != nil {
-		return nil, errors.Wrap(err, "new serial number generator")
-	}
-
 	return ins, nil
 }
 
+// IsGoSMFallback whether tongsuo is running in pure go fallback mode,
+// see WithTongsuoGoSMFallback
+func (t *Tongsuo) IsGoSMFallback() bool {
+	return t.gosm != nil
+}
+
 func (t *Tongsuo) runCMD(ctx context.Context, args []string, stdin []byte) (
 	output []byte, err error) {
+	if t.gosm != nil {
+		return nil, errors.Errorf("tongsuo executable binary is not available, "+
+			"`%s` is not supported by go fallback", strings.Join(args, " "))
+	}
+
 	if args, err = gutils.SanitizeCMDArgs(args); err != nil {
 		return nil, errors.Wrap(err, "sanitize cmd args")
 	}
 
-	//nolint: gosec
-	// G204: Subprocess launched with a potential tainted input or cmd arguments
-	cmd := exec.CommandContext(ctx, t.exePath, args...)
-	if len(stdin) != 0 {
-		var stdinBuf bytes.Buffer
-		stdinBuf.Write(stdin)
-		cmd.Stdin = &stdinBuf
+	// every call spawns a new subprocess, tongsuo has no batch mode
+	// that can reuse one process for arbitrary stdin and args,
+	// so bound the number of running processes instead.
+	if t.sem != nil {
+		select {
+		case t.sem <- struct{}{}:
+			defer func() { <-t.sem }()
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err(), "wait for tongsuo concurrency limit")
+		}
 	}
 
-	if output, err = cmd.CombinedOutput(); err != nil {
-		return nil, errors.Wrapf(err, "run cmd failed, got %s", output)
+	runner := t.runner
+	if runner == nil {
+		runner = gutils.DefaultCMDRunner
+	}
+
+	output, err = runner.RunWithEnv(ctx, t.exePath, args, nil, gutils.WithCMDStdin(stdin))
+	if err != nil {
+		// runner may not put output into err, keep it for diagnostic
+		return nil, errors.Wrapf(err, "run cmd failed, got %s", bytes.TrimSpace(output))
 	}
 
 	return output, nil
@@ -394,6 +498,10 @@ func (t *Tongsuo) ShowCsrInfo(ctx context.Context, csrDer []byte) (
 //
 //	tongsuo ecparam -genkey -name SM2 -out rootca.key
 func (t *Tongsuo) NewPrikey(ctx context.Context) (prikeyPem []byte, err error) {
+	if t.gosm != nil {
+		return t.gosm.NewPrikey(ctx)
+	}
+
 	prikeyPem, err = t.runCMD(ctx, []string{
 		"ecparam", "-genkey", "-name", "SM2",
 	}, nil)
@@ -436,6 +544,10 @@ func (t *Tongsuo) removeAll(path string) {
 // Prikey2Pubkey convert private key to public key
 func (t *Tongsuo) Prikey2Pubkey(ctx context.Context, prikeyPem []byte) (
 	pubkeyPem []byte, err error) {
+	if t.gosm != nil {
+		return t.gosm.Prikey2Pubkey(ctx, prikeyPem)
+	}
+
 	dir, err := os.MkdirTemp("", "tongsuo*")
 	if err != nil {
 		return nil, errors.Wrap(err, "generate temp dir")
@@ -653,6 +765,10 @@ func (t *Tongsuo) EncryptBySm4CbcBaisc(ctx context.Context,
 		return nil, nil, errors.Errorf("hmac should be 0 or 32 bytes")
 	}
 
+	if t.gosm != nil {
+		return t.gosm.EncryptBySm4CbcBaisc(ctx, key, plaintext, iv)
+	}
+
 	dir, err := os.MkdirTemp("", "tongsuo*")
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "generate temp dir")
@@ -698,6 +814,10 @@ func (t *Tongsuo) DecryptBySm4CbcBaisc(ctx context.Context,
 		return nil, errors.Errorf("hmac should be 0 or 32 bytes")
 	}
 
+	if t.gosm != nil {
+		return t.gosm.DecryptBySm4CbcBaisc(ctx, key, ciphertext, iv, hmac)
+	}
+
 	if len(hmac) != 0 { // check hmac
 		if expectedHmac, err := HMACSha256(key, bytes.NewReader(ciphertext)); err != nil {
 			return nil, errors.Wrap(err, "calculate hmac")
@@ -763,6 +883,112 @@ func (t *Tongsuo) DecryptBySm4Cbc(ctx context.Context, key, combinedCipher []byt
 	return t.DecryptBySm4CbcBaisc(ctx, key, cipher, iv, hmac)
 }
 
+// sm4GcmPrimitives build gcm on top of tongsuo's sm4-ecb and sm4-ctr,
+// since `enc` command does not support AEAD ciphers like `-sm4-gcm`.
+func (t *Tongsuo) sm4GcmPrimitives(ctx context.Context, key []byte) (gcmPrimitives, func(), error) {
+	dir, err := os.MkdirTemp("", "tongsuo*")
+	if err != nil {
+		return gcmPrimitives{}, nil, errors.Wrap(err, "generate temp dir")
+	}
+
+	outPath := filepath.Join(dir, "out")
+	run := func(args []string, data []byte) ([]byte, error) {
+		args = append(args,
+			"-in", "/dev/stdin", "-out", outPath,
+			"-K", hex.EncodeToString(key),
+		)
+		if _, err := t.runCMD(ctx, args, data); err != nil {
+			return nil, err
+		}
+
+		return os.ReadFile(outPath)
+	}
+
+	return gcmPrimitives{
+		ecb: func(blocks []byte) ([]byte, error) {
+			return run([]string{"enc", "-sm4-ecb", "-e", "-nopad"}, blocks)
+		},
+		ctr: func(counter, data []byte) ([]byte, error) {
+			return run([]string{"enc", "-sm4-ctr", "-e", "-iv", hex.EncodeToString(counter)}, data)
+		},
+	}, func() { t.removeAll(dir) }, nil
+}
+
+// EncryptBySm4Gcm encrypt by sm4 in gcm mode
+//
+// # Args
+//   - key: sm4 key, should be 16 bytes
+//   - plaintext: data to be encrypted
+//   - iv: gcm nonce, should be 12 bytes, must not be reused with the same key
+//   - aad: additional authenticated data, could be nil
+//
+// # Returns
+//   - ciphertext: sm4 encrypted data, has the same length as plaintext
+//   - tag: authentication tag, 16 bytes
+func (t *Tongsuo) EncryptBySm4Gcm(ctx context.Context,
+	key, plaintext, iv, aad []byte) (ciphertext, tag []byte, err error) {
+	if t.gosm != nil {
+		return t.gosm.EncryptBySm4Gcm(ctx, key, plaintext, iv, aad)
+	}
+
+	if len(key) != 16 {
+		return nil, nil, errors.Errorf("key should be 16 bytes")
+	}
+	if len(iv) != gcmNonceSize {
+		return nil, nil, errors.Errorf("iv should be %d bytes", gcmNonceSize)
+	}
+
+	p, cleanup, err := t.sm4GcmPrimitives(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cleanup()
+
+	if ciphertext, tag, err = gcmSeal(p, iv, plaintext, aad); err != nil {
+		return nil, nil, errors.Wrap(err, "encrypt")
+	}
+
+	return ciphertext, tag, nil
+}
+
+// DecryptBySm4Gcm decrypt by sm4 in gcm mode,
+// return error if ciphertext, iv, aad or tag has been tampered.
+//
+// # Args
+//   - key: sm4 key, should be 16 bytes
+//   - ciphertext: sm4 encrypted data
+//   - iv: gcm nonce, should be 12 bytes
+//   - aad: additional authenticated data, could be nil
+//   - tag: authentication tag, 16 bytes
+func (t *Tongsuo) DecryptBySm4Gcm(ctx context.Context,
+	key, ciphertext, iv, aad, tag []byte) (plaintext []byte, err error) {
+	if t.gosm != nil {
+		return t.gosm.DecryptBySm4Gcm(ctx, key, ciphertext, iv, aad, tag)
+	}
+
+	if len(key) != 16 {
+		return nil, errors.Errorf("key should be 16 bytes")
+	}
+	if len(iv) != gcmNonceSize {
+		return nil, errors.Errorf("iv should be %d bytes", gcmNonceSize)
+	}
+	if len(tag) != gcmTagSize {
+		return nil, errors.Errorf("tag should be %d bytes", gcmTagSize)
+	}
+
+	p, cleanup, err := t.sm4GcmPrimitives(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	if plaintext, err = gcmOpen(p, iv, ciphertext, aad, tag); err != nil {
+		return nil, errors.Wrap(err, "decrypt")
+	}
+
+	return plaintext, nil
+}
+
 var (
 	reX509Subject = regexp.MustCompile(`(?s)Subject: ([\S ]+)`)
 	reX509Sans    = regexp.MustCompile(`(?m)X509v3 Subject Alternative Name: ?\n +(.+)\b`)
@@ -849,6 +1075,10 @@ func (t *Tongsuo) CloneX509Csr(ctx context.Context,
 // https://www.yuque.com/tsdoc/ts/ewh6xg7qlddxlec2#rehkK
 func (t *Tongsuo) SignBySm2Sm3(ctx context.Context,
 	parentPrikeyPem []byte, content []byte) (signature []byte, err error) {
+	if t.gosm != nil {
+		return t.gosm.SignBySm2Sm3(ctx, parentPrikeyPem, content)
+	}
+
 	dir, err := os.MkdirTemp("", "tongsuo*")
 	if err != nil {
 		return nil, errors.Wrap(err, "generate temp dir")
@@ -941,6 +1171,10 @@ func (t *Tongsuo) VerifyCertsChain(ctx context.Context,
 // https://www.yuque.com/tsdoc/ts/ewh6xg7qlddxlec2#rehkK
 func (t *Tongsuo) VerifyBySm2Sm3(ctx context.Context,
 	pubkeyPem, signature, content []byte) error {
+	if t.gosm != nil {
+		return t.gosm.VerifyBySm2Sm3(ctx, pubkeyPem, signature, content)
+	}
+
 	dir, err := os.MkdirTemp("", "tongsuo*")
 	if err != nil {
 		return errors.Wrap(err, "generate temp dir")
@@ -979,6 +1213,10 @@ func (t *Tongsuo) VerifyBySm2Sm3(ctx context.Context,
 
 // HashBySm3 hash by sm3
 func (t *Tongsuo) HashBySm3(ctx context.Context, content []byte) (hash []byte, err error) {
+	if t.gosm != nil {
+		return t.gosm.HashBySm3(ctx, content)
+	}
+
 	dir, err := os.MkdirTemp("", "tongsuo*")
 	if err != nil {
 		return nil, errors.Wrap(err, "generate temp dir")
@@ -1140,6 +1378,151 @@ func (t *Tongsuo) SignX509CRL(ctx context.Context,
 	return signedCrlDer, nil
 }
 
+// NewX509CRL generate new x509 crl signed by ca
+//
+//	tongsuo ca -gencrl -config ca.cnf \
+//	    -cert ca.pem -keyfile ca.key \
+//	    -crlsec 2592000 -md sm3 -batch \
+//	    -out crl.pem
+//
+// # Args
+//   - caCertDer: ca certificate
+//   - caPrikeyPem: ca private key
+//   - crlNumber: crl number, should be monotonically increasing
+//   - revoked: revoked certificates, only SerialNumber and RevocationTime are used
+//   - opts: only WithX509CRLNextUpdate is supported,
+//     this update is always the time when tongsuo signs the crl
+func (t *Tongsuo) NewX509CRL(ctx context.Context,
+	caCertDer []byte,
+	caPrikeyPem []byte,
+	crlNumber *big.Int,
+	revoked []pkix.RevokedCertificate,
+	opts ...X509CRLOption,
+) (crlDer []byte, err error) {
+	if crlNumber == nil || crlNumber.Sign() < 0 {
+		return nil, errors.Errorf("crlNumber should be non-negative")
+	}
+
+	opt, err := new(x509CRLOption).applyOpts(opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "apply options")
+	}
+
+	crlSec := int(time.Until(opt.nextUpdate) / time.Second)
+	if crlSec <= 0 {
+		return nil, errors.Errorf("nextUpdate should be in the future")
+	}
+
+	index, err := tongsuoCRLIndex(revoked)
+	if err != nil {
+		return nil, errors.Wrap(err, "generate index")
+	}
+
+	digestAlgo := "sha256"
+	if certinfo, _, err := t.ShowCertInfo(ctx, caCertDer); err != nil {
+		return nil, errors.Wrap(err, "show ca cert info")
+	} else if strings.Contains(certinfo, "ASN1 OID: SM2") {
+		digestAlgo = "sm3"
+	}
+
+	dir, err := os.MkdirTemp("", "tongsuo*")
+	if err != nil {
+		return nil, errors.Wrap(err, "generate temp dir")
+	}
+	defer t.removeAll(dir)
+
+	indexPath := filepath.Join(dir, "index.txt")
+	crlNumberPath := filepath.Join(dir, "crlnumber")
+	confPath := filepath.Join(dir, "ca.cnf")
+	caCertPath := filepath.Join(dir, "ca.pem")
+	outCrlPath := filepath.Join(dir, "crl.pem")
+	for fpath, content := range map[string][]byte{
+		indexPath:     index,
+		crlNumberPath: []byte(tongsuoSerialHex(crlNumber) + "\n"),
+		caCertPath:    CertDer2Pem(caCertDer),
+		confPath: []byte(strings.Join([]string{
+			"[ ca ]",
+			"default_ca = CA_default",
+			"[ CA_default ]",
+			"database = " + indexPath,
+			"crlnumber = " + crlNumberPath,
+			"unique_subject = no",
+			"crl_extensions = crl_ext",
+			"[ crl_ext ]",
+			"authorityKeyIdentifier = keyid",
+		}, "\n") + "\n"),
+	} {
+		if err = os.WriteFile(fpath, content, 0600); err != nil {
+			return nil, errors.Wrapf(err, "write %s", filepath.Base(fpath))
+		}
+	}
+
+	if _, err = t.runCMD(ctx, []string{
+		"ca", "-gencrl", "-config", confPath,
+		"-cert", caCertPath, "-keyfile", "/dev/stdin",
+		"-crlsec", strconv.Itoa(crlSec),
+		"-md", digestAlgo, "-batch",
+		"-out", outCrlPath,
+	}, caPrikeyPem); err != nil {
+		return nil, errors.Wrap(err, "generate crl")
+	}
+
+	crlPem, err := os.ReadFile(outCrlPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "read crl")
+	}
+
+	if crlDer, err = Pem2Der(crlPem); err != nil {
+		return nil, errors.Wrap(err, "Pem2Der")
+	}
+
+	return crlDer, nil
+}
+
+// tongsuoCRLIndex generate openssl ca database (index.txt) with revoked certs.
+//
+// each line is tab separated: status, expiry, revocation time, serial, filename, subject.
+// expiry and subject are not used by `ca -gencrl`, so placeholders are used.
+func tongsuoCRLIndex(revoked []pkix.RevokedCertificate) ([]byte, error) {
+	var buf bytes.Buffer
+	for i := range revoked {
+		if revoked[i].SerialNumber == nil || revoked[i].SerialNumber.Sign() < 0 {
+			return nil, errors.Errorf("revoked[%d] has invalid serial number", i)
+		}
+
+		revokedAt := tongsuoIndexTime(revoked[i].RevocationTime)
+		buf.WriteString(strings.Join([]string{
+			"R", revokedAt, revokedAt,
+			tongsuoSerialHex(revoked[i].SerialNumber),
+			"unknown", "/CN=revoked",
+		}, "\t"))
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes(), nil
+}
+
+// tongsuoIndexTime format time as ASN.1 UTCTime or GeneralizedTime
+// in the same way as openssl ca database
+func tongsuoIndexTime(t time.Time) string {
+	t = t.UTC()
+	if t.Year() >= 1950 && t.Year() < 2050 {
+		return t.Format("060102150405Z")
+	}
+
+	return t.Format("20060102150405Z")
+}
+
+// tongsuoSerialHex format serial as uppercase hex with even length
+func tongsuoSerialHex(serial *big.Int) string {
+	h := strings.ToUpper(serial.Text(16))
+	if len(h)%2 != 0 {
+		h = "0" + h
+	}
+
+	return h
+}
+
 // PrivateKey get private key
 func (t *Tongsuo) PrivateKey(prikeyPem []byte) (crypto.PrivateKey, error) {
 	return &TongsuoPriKey{ts: t, pem: prikeyPem}, nil
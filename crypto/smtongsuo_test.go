@@ -1,16 +1,24 @@
 package crypto
 
 import (
+	"bytes"
 	"context"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/asn1"
+	"encoding/hex"
 	"math/big"
 	"math/rand"
 	"os/exec"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/Laisky/errors/v2"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+
+	gutils "github.com/Laisky/go-utils/v4"
 )
 
 func TestSm2CrossAlgorithmSign(t *testing.T) {
@@ -527,6 +535,57 @@ func TestTongsuo_DecryptBySm4(t *testing.T) {
 	require.Equal(t, plaintext, gotPlain)
 }
 
+func TestTongsuo_EncryptBySm4Gcm(t *testing.T) {
+	t.Parallel()
+	if testSkipSmTongsuo(t) {
+		return
+	}
+
+	ctx := context.Background()
+	ins, err := NewTongsuo("/usr/local/bin/tongsuo")
+	require.NoError(t, err)
+
+	key, err := Salt(16)
+	require.NoError(t, err)
+	incorrectKey, err := Salt(16)
+	require.NoError(t, err)
+	iv, err := Salt(12)
+	require.NoError(t, err)
+	aad := []byte("laisky")
+
+	for _, plaintext := range [][]byte{
+		{},
+		[]byte("Hello, World!"),
+		bytes.Repeat([]byte("a"), 1000),
+	} {
+		ciphertext, tag, err := ins.EncryptBySm4Gcm(ctx, key, plaintext, iv, aad)
+		require.NoError(t, err)
+		require.Len(t, ciphertext, len(plaintext))
+		require.Len(t, tag, 16)
+
+		decrypted, err := ins.DecryptBySm4Gcm(ctx, key, ciphertext, iv, aad, tag)
+		require.NoError(t, err)
+		require.Equal(t, plaintext, decrypted)
+
+		_, err = ins.DecryptBySm4Gcm(ctx, incorrectKey, ciphertext, iv, aad, tag)
+		require.ErrorContains(t, err, "message authentication failed")
+		_, err = ins.DecryptBySm4Gcm(ctx, key, ciphertext, iv, []byte("yo"), tag)
+		require.ErrorContains(t, err, "message authentication failed")
+
+		incorrectTag := append([]byte{}, tag...)
+		incorrectTag[0] ^= 1
+		_, err = ins.DecryptBySm4Gcm(ctx, key, ciphertext, iv, aad, incorrectTag)
+		require.ErrorContains(t, err, "message authentication failed")
+	}
+
+	_, _, err = ins.EncryptBySm4Gcm(ctx, append(key, 'a'), []byte("yo"), iv, nil)
+	require.ErrorContains(t, err, "key should be 16 bytes")
+	_, _, err = ins.EncryptBySm4Gcm(ctx, key, []byte("yo"), append(iv, 'a'), nil)
+	require.ErrorContains(t, err, "iv should be 12 bytes")
+	_, err = ins.DecryptBySm4Gcm(ctx, key, []byte("yo"), iv, nil, make([]byte, 15))
+	require.ErrorContains(t, err, "tag should be 16 bytes")
+}
+
 func TestTongsuo_NewPrikeyWithPassword(t *testing.T) {
 	t.Parallel()
 	if testSkipSmTongsuo(t) {
@@ -818,55 +877,326 @@ func TestTongsuo_EncryptBySm2(t *testing.T) {
 	})
 }
 
-// func TestTongsuo_NewX509CRL(t *testing.T) {
-// 	t.Parallel()
-// 	if testSkipSmTongsuo(t) {
-// 		return
-// 	}
-
-// 	ctx := context.Background()
-// 	ins, err := NewTongsuo("/usr/local/bin/tongsuo")
-// 	require.NoError(t, err)
-
-// 	// Generate a CA certificate
-// 	caPrikeyPem, caCertDer, err := ins.NewPrikeyAndCert(ctx,
-// 		WithX509CertCommonName("test-ca"),
-// 		WithX509CertIsCA(),
-// 	)
-// 	require.NoError(t, err)
-
-// 	// Generate a revoked certificate
-// 	certPrikeyPem, err := ins.NewPrikey(ctx)
-// 	require.NoError(t, err)
-
-// 	certCsrDer, err := ins.NewX509CSR(ctx, certPrikeyPem,
-// 		WithX509CSRCommonName("test-cert"),
-// 	)
-// 	require.NoError(t, err)
-
-// 	certDer, err := ins.NewX509CertByCSR(ctx, caCertDer, caPrikeyPem, certCsrDer)
-// 	require.NoError(t, err)
-
-// 	certInfo, err := ins.ShowCertInfo(ctx, certDer)
-// 	require.NoError(t, err,certinfo)
-
-// 	// Generate the CRL
-// 	revokedCert := pkix.RevokedCertificate{
-// 		SerialNumber:   certInfo.SerialNumber,
-// 		RevocationTime: time.Now(),
-// 	}
-// 	revokeCerts := []pkix.RevokedCertificate{revokedCert}
-
-// 	// Generate the CRL
-// 	crlNo := big.NewInt(1)
-// 	crlDer, err := ins.NewX509CRL(ctx, caCertDer, caPrikeyPem, crlNo, revokeCerts)
-// 	require.NoError(t, err)
-// 	require.NotNil(t, crlDer)
-
-// 	// Verify the generated CRL
-// 	crl, err := x509.ParseRevocationList(crlDer)
-// 	require.NoError(t, err)
-// 	require.Equal(t, crlNo, crl.Number)
-// 	require.Len(t, crl.RevokedCertificateEntries, 1)
-// 	require.Equal(t, certInfo.SerialNumber, crl.RevokedCertificateEntries[0].SerialNumber)
-// }
+func TestTongsuo_NewX509CRL(t *testing.T) {
+	t.Parallel()
+	if testSkipSmTongsuo(t) {
+		return
+	}
+
+	ctx := context.Background()
+	ins, err := NewTongsuo("/usr/local/bin/tongsuo")
+	require.NoError(t, err)
+
+	// Generate a CA certificate
+	caPrikeyPem, caCertDer, err := ins.NewPrikeyAndCert(ctx,
+		WithX509CertCommonName("test-ca"),
+		WithX509CertIsCA(),
+	)
+	require.NoError(t, err)
+
+	// Generate a revoked certificate
+	certPrikeyPem, err := ins.NewPrikey(ctx)
+	require.NoError(t, err)
+
+	certCsrDer, err := ins.NewX509CSR(ctx, certPrikeyPem,
+		WithX509CSRCommonName("test-cert"),
+	)
+	require.NoError(t, err)
+
+	certDer, err := ins.NewX509CertByCSR(ctx, caCertDer, caPrikeyPem, certCsrDer)
+	require.NoError(t, err)
+
+	_, cert, err := ins.ShowCertInfo(ctx, certDer)
+	require.NoError(t, err)
+
+	// Generate the CRL
+	revokeCerts := []pkix.RevokedCertificate{
+		{
+			SerialNumber:   cert.SerialNumber,
+			RevocationTime: time.Now().Add(-time.Hour),
+		},
+		{
+			SerialNumber:   big.NewInt(15),
+			RevocationTime: time.Now().Add(-time.Minute),
+		},
+	}
+
+	crlNo := big.NewInt(1234)
+	nextUpdate := time.Now().Add(7 * 24 * time.Hour)
+	crlDer, err := ins.NewX509CRL(ctx, caCertDer, caPrikeyPem, crlNo, revokeCerts,
+		WithX509CRLNextUpdate(nextUpdate))
+	require.NoError(t, err)
+	require.NotNil(t, crlDer)
+
+	// Verify the generated CRL
+	crl, err := x509.ParseRevocationList(crlDer)
+	require.NoError(t, err)
+	require.Equal(t, 0, crlNo.Cmp(crl.Number))
+	require.WithinDuration(t, nextUpdate, crl.NextUpdate, time.Minute)
+	require.Len(t, crl.RevokedCertificateEntries, len(revokeCerts))
+	for i := range revokeCerts {
+		require.Equal(t, 0, revokeCerts[i].SerialNumber.Cmp(crl.RevokedCertificateEntries[i].SerialNumber))
+		require.WithinDuration(t, revokeCerts[i].RevocationTime,
+			crl.RevokedCertificateEntries[i].RevocationTime, time.Second)
+	}
+
+	t.Run("empty revoked", func(t *testing.T) {
+		crlDer, err := ins.NewX509CRL(ctx, caCertDer, caPrikeyPem, big.NewInt(1), nil)
+		require.NoError(t, err)
+
+		crl, err := x509.ParseRevocationList(crlDer)
+		require.NoError(t, err)
+		require.Empty(t, crl.RevokedCertificateEntries)
+	})
+
+	t.Run("invalid args", func(t *testing.T) {
+		_, err := ins.NewX509CRL(ctx, caCertDer, caPrikeyPem, nil, nil)
+		require.ErrorContains(t, err, "crlNumber should be non-negative")
+
+		_, err = ins.NewX509CRL(ctx, caCertDer, caPrikeyPem, big.NewInt(1), nil,
+			WithX509CRLNextUpdate(time.Now().Add(-time.Hour)))
+		require.ErrorContains(t, err, "nextUpdate should be in the future")
+	})
+}
+
+func Test_tongsuoCRLIndex(t *testing.T) {
+	t.Parallel()
+
+	index, err := tongsuoCRLIndex([]pkix.RevokedCertificate{
+		{
+			SerialNumber:   big.NewInt(0xabc),
+			RevocationTime: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			SerialNumber:   big.NewInt(0x10),
+			RevocationTime: time.Date(2051, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t,
+		"R\t240102030405Z\t240102030405Z\t0ABC\tunknown\t/CN=revoked\n"+
+			"R\t20510102030405Z\t20510102030405Z\t10\tunknown\t/CN=revoked\n",
+		string(index))
+
+	_, err = tongsuoCRLIndex([]pkix.RevokedCertificate{{SerialNumber: big.NewInt(-1)}})
+	require.ErrorContains(t, err, "invalid serial number")
+}
+
+func TestWithTongsuoConcurrency(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewTongsuo("/not/exists/tongsuo", WithTongsuoConcurrency(0))
+	require.ErrorContains(t, err, "concurrency should be greater than 0")
+
+	// unlimited by default
+	ins, _ := testNewFakeTongsuo(t)
+	require.Nil(t, ins.sem)
+
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep not found")
+	}
+
+	ins = &Tongsuo{exePath: "sleep", sem: make(chan struct{}, 1)}
+	ctx := context.Background()
+
+	t.Run("limit", func(t *testing.T) {
+		start := time.Now()
+		var pool errgroup.Group
+		for i := 0; i < 2; i++ {
+			pool.Go(func() error {
+				_, err := ins.runCMD(ctx, []string{"0.2"}, nil)
+				return err
+			})
+		}
+		require.NoError(t, pool.Wait())
+		require.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+	})
+
+	t.Run("ctx done while waiting", func(t *testing.T) {
+		ins.sem <- struct{}{}
+		defer func() { <-ins.sem }()
+
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		_, err := ins.runCMD(ctx, []string{"0"}, nil)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+// BenchmarkTongsuo_HashBySm3 compare serial calls with concurrent calls
+// limited by WithTongsuoConcurrency, both spawn one subprocess per call
+func BenchmarkTongsuo_HashBySm3(b *testing.B) {
+	if _, err := exec.LookPath("tongsuo"); err != nil {
+		b.Skip("tongsuo not found")
+	}
+
+	ctx := context.Background()
+	content := []byte("hello, laisky")
+
+	b.Run("serial", func(b *testing.B) {
+		ins, err := NewTongsuo("/usr/local/bin/tongsuo", WithTongsuoConcurrency(1))
+		require.NoError(b, err)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := ins.HashBySm3(ctx, content); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("concurrent", func(b *testing.B) {
+		ins, err := NewTongsuo("/usr/local/bin/tongsuo")
+		require.NoError(b, err)
+
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := ins.HashBySm3(ctx, content); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+}
+
+const testTongsuoCertInfo = `Certificate:
+    Data:
+        Version: 3 (0x2)
+        Serial Number: 17108345756590001 (0x3cc7f327841fb1)
+        Signature Algorithm: SM2-with-SM3
+        Issuer: CN = test-common-name, O = test org
+        Validity
+            Not Before: Mar 19 07:49:35 2024 GMT
+            Not After : Mar 25 07:49:35 2024 GMT
+        Subject: CN = test-common-name, O = test org
+        Subject Public Key Info:
+            Public Key Algorithm: id-ecPublicKey
+                Public-Key: (256 bit)
+                ASN1 OID: SM2
+        X509v3 extensions:
+            X509v3 Basic Constraints: critical
+                CA:TRUE
+            X509v3 Key Usage: critical
+                Digital Signature, Certificate Sign, CRL Sign
+            X509v3 Extended Key Usage:
+                TLS Web Server Authentication, TLS Web Client Authentication
+            X509v3 Subject Key Identifier:
+                AF:9A:33:37:3F:DE:3E:DD:77:61:A1:C8:3F:D5:0C:39:F0:D6:A6:7B
+            X509v3 Authority Key Identifier:
+                AF:9A:33:37:3F:DE:3E:DD:77:61:A1:C8:3F:D5:0C:39:F0:D6:A6:7C
+            X509v3 Certificate Policies:
+                Policy: 1.3.6.1.4.1.59936.1.1.3
+    Signature Algorithm: SM2-with-SM3
+`
+
+func testNewFakeTongsuo(t *testing.T) (*Tongsuo, *gutils.FakeCMDRunner) {
+	t.Helper()
+
+	runner := gutils.NewFakeCMDRunner()
+	require.NoError(t, runner.On(`^tongsuo version$`, gutils.FakeCMDResult{
+		Stdout: []byte("Tongsuo 8.4.0-pre3"),
+	}))
+
+	ins, err := NewTongsuo("tongsuo", WithTongsuoRunner(runner))
+	require.NoError(t, err)
+	return ins, runner
+}
+
+// testBareErrRunner fail every RunWithEnv without putting output into error
+type testBareErrRunner struct {
+	*gutils.FakeCMDRunner
+	output []byte
+}
+
+func (r testBareErrRunner) RunWithEnv(context.Context, string,
+	[]string, []string, ...gutils.RunCMDOption) ([]byte, error) {
+	return r.output, errors.New("exit status 1")
+}
+
+func TestTongsuo_ShowCertInfo_FakeRunner(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("parse", func(t *testing.T) {
+		ins, runner := testNewFakeTongsuo(t)
+		require.NoError(t, runner.On(`^tongsuo x509 -inform DER -text$`, gutils.FakeCMDResult{
+			Stdout: []byte(testTongsuoCertInfo),
+		}))
+
+		certDer := []byte("fake cert der")
+		info, cert, err := ins.ShowCertInfo(ctx, certDer)
+		require.NoError(t, err)
+		require.Contains(t, info, "SM2-with-SM3")
+
+		calls := runner.Calls()
+		require.Len(t, calls, 2)
+		require.Equal(t, []string{"x509", "-inform", "DER", "-text"}, calls[1].Args)
+		require.Equal(t, certDer, calls[1].Stdin)
+
+		require.Equal(t, certDer, cert.Raw)
+		require.Equal(t, big.NewInt(17108345756590001), cert.SerialNumber)
+		require.Equal(t, time.Date(2024, 3, 19, 7, 49, 35, 0, time.UTC), cert.NotBefore.UTC())
+		require.Equal(t, time.Date(2024, 3, 25, 7, 49, 35, 0, time.UTC), cert.NotAfter.UTC())
+		require.True(t, cert.IsCA)
+		require.Equal(t, "test-common-name", cert.Subject.CommonName)
+		require.Equal(t, x509.ECDSA, cert.PublicKeyAlgorithm)
+		require.Equal(t, "af9a33373fde3edd7761a1c83fd50c39f0d6a67b", hex.EncodeToString(cert.SubjectKeyId))
+		require.Equal(t, "af9a33373fde3edd7761a1c83fd50c39f0d6a67c", hex.EncodeToString(cert.AuthorityKeyId))
+		require.Equal(t, x509.KeyUsageDigitalSignature|x509.KeyUsageCertSign|x509.KeyUsageCRLSign,
+			cert.KeyUsage)
+		require.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+			cert.ExtKeyUsage)
+		require.Len(t, cert.Policies, 1)
+		require.Equal(t, "1.3.6.1.4.1.59936.1.1.3", cert.Policies[0].String())
+	})
+
+	t.Run("hex serial number", func(t *testing.T) {
+		ins, runner := testNewFakeTongsuo(t)
+		output := strings.Replace(testTongsuoCertInfo,
+			"Serial Number: 17108345756590001 (0x3cc7f327841fb1)",
+			"Serial Number:\n            51:f5:46:8b", 1)
+		require.NoError(t, runner.On(`x509`, gutils.FakeCMDResult{Stdout: []byte(output)}))
+
+		_, cert, err := ins.ShowCertInfo(ctx, []byte("der"))
+		require.NoError(t, err)
+		require.Equal(t, big.NewInt(0x51f5468b), cert.SerialNumber)
+	})
+
+	t.Run("missing subject key identifier", func(t *testing.T) {
+		ins, runner := testNewFakeTongsuo(t)
+		output := strings.Replace(testTongsuoCertInfo, "X509v3 Subject Key Identifier", "X", 1)
+		require.NoError(t, runner.On(`x509`, gutils.FakeCMDResult{Stdout: []byte(output)}))
+
+		_, _, err := ins.ShowCertInfo(ctx, []byte("der"))
+		require.ErrorContains(t, err, "subject key identifier")
+	})
+
+	t.Run("cmd failed", func(t *testing.T) {
+		ins, runner := testNewFakeTongsuo(t)
+		require.NoError(t, runner.On(`x509`, gutils.FakeCMDResult{
+			Stderr:   []byte("unable to load certificate"),
+			ExitCode: 1,
+		}))
+
+		_, _, err := ins.ShowCertInfo(ctx, []byte("der"))
+		require.ErrorContains(t, err, "unable to load certificate")
+	})
+
+	t.Run("cmd failed by custom runner", func(t *testing.T) {
+		ins, runner := testNewFakeTongsuo(t)
+		ins.runner = testBareErrRunner{FakeCMDRunner: runner,
+			output: []byte("  unable to load certificate\n")}
+
+		_, _, err := ins.ShowCertInfo(ctx, []byte("der"))
+		require.ErrorContains(t, err, "run cmd failed, got unable to load certificate")
+	})
+
+	t.Run("not tongsuo", func(t *testing.T) {
+		runner := gutils.NewFakeCMDRunner()
+		require.NoError(t, runner.On(`version`, gutils.FakeCMDResult{Stdout: []byte("OpenSSL 3.0")}))
+
+		_, err := NewTongsuo("tongsuo", WithTongsuoRunner(runner))
+		require.ErrorContains(t, err, "only support Tongsuo")
+	})
+}
@@ -0,0 +1,126 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/pem"
+
+	"github.com/Laisky/errors/v2"
+	"golang.org/x/crypto/ssh"
+)
+
+// checkSSHKeyCurve make sure ecdsa key's curve is supported by ssh
+func checkSSHKeyCurve(curve elliptic.Curve) error {
+	switch curve {
+	case elliptic.P256(), elliptic.P384(), elliptic.P521():
+		return nil
+	default:
+		return errors.Errorf("ssh not support ecdsa curve %s", curve.Params().Name)
+	}
+}
+
+// Pubkey2SSHAuthorizedKey marshal public key to one line of authorized_keys,
+// like `ssh-ed25519 AAAA... comment`, tailing with line break.
+//
+// only support rsa/ecdsa(P256/P384/P521)/ed25519 public key.
+func Pubkey2SSHAuthorizedKey(pub crypto.PublicKey, comment string) ([]byte, error) {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey, ed25519.PublicKey:
+	case *ecdsa.PublicKey:
+		if err := checkSSHKeyCurve(pub.Curve); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("only support rsa/ecdsa/ed25519 public key, got %T", pub)
+	}
+
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return nil, errors.Wrap(err, "convert to ssh public key")
+	}
+
+	line := ssh.MarshalAuthorizedKey(sshPub)
+	if comment != "" {
+		line = append(bytes.TrimRight(line, "\n"), ' ')
+		line = append(line, comment...)
+		line = append(line, '\n')
+	}
+
+	return line, nil
+}
+
+// SSHAuthorizedKey2Pubkey parse public key and comment from one line of authorized_keys
+func SSHAuthorizedKey2Pubkey(line []byte) (pub crypto.PublicKey, comment string, err error) {
+	sshPub, comment, _, _, err := ssh.ParseAuthorizedKey(line)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "parse authorized key")
+	}
+
+	cryptoPub, ok := sshPub.(ssh.CryptoPublicKey)
+	if !ok {
+		return nil, "", errors.Errorf("not support ssh key type %s", sshPub.Type())
+	}
+
+	return cryptoPub.CryptoPublicKey(), comment, nil
+}
+
+// Prikey2OpenSSHPem marshal private key to pem in "OPENSSH PRIVATE KEY" format,
+// the same format as `ssh-keygen` generates.
+//
+// private key will be encrypted if passphrase is not empty.
+// only support rsa/ecdsa(P256/P384/P521)/ed25519 private key.
+func Prikey2OpenSSHPem(pri crypto.PrivateKey, passphrase []byte) ([]byte, error) {
+	switch pri := pri.(type) {
+	case *rsa.PrivateKey, ed25519.PrivateKey:
+	case *ecdsa.PrivateKey:
+		if err := checkSSHKeyCurve(pri.Curve); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("only support rsa/ecdsa/ed25519 private key, got %T", pri)
+	}
+
+	var (
+		block *pem.Block
+		err   error
+	)
+	if len(passphrase) == 0 {
+		block, err = ssh.MarshalPrivateKey(pri, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(pri, "", passphrase)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal openssh private key")
+	}
+
+	return pem.EncodeToMemory(block), nil
+}
+
+// OpenSSHPem2Prikey parse private key from pem in "OPENSSH PRIVATE KEY" format,
+// passphrase is required if the key is encrypted.
+//
+// ed25519 private key is returned as ed25519.PrivateKey rather than pointer.
+func OpenSSHPem2Prikey(pemBytes []byte, passphrase []byte) (crypto.PrivateKey, error) {
+	var (
+		pri any
+		err error
+	)
+	if len(passphrase) == 0 {
+		pri, err = ssh.ParseRawPrivateKey(pemBytes)
+	} else {
+		pri, err = ssh.ParseRawPrivateKeyWithPassphrase(pemBytes, passphrase)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "parse openssh private key")
+	}
+
+	if pri, ok := pri.(*ed25519.PrivateKey); ok {
+		return *pri, nil
+	}
+
+	return pri, nil
+}
@@ -0,0 +1,139 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSSHKeyRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	rsaPrikey, err := NewRSAPrikey(RSAPrikeyBits2048)
+	require.NoError(t, err)
+	p256Prikey, err := NewECDSAPrikey(ECDSACurveP256)
+	require.NoError(t, err)
+	p384Prikey, err := NewECDSAPrikey(ECDSACurveP384)
+	require.NoError(t, err)
+	edPrikey, err := NewEd25519Prikey()
+	require.NoError(t, err)
+
+	for name, prikey := range map[string]crypto.PrivateKey{
+		"rsa":     rsaPrikey,
+		"p256":    p256Prikey,
+		"p384":    p384Prikey,
+		"ed25519": edPrikey,
+	} {
+		prikey := prikey
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			pubkey := Prikey2Pubkey(prikey)
+
+			// authorized_keys
+			line, err := Pubkey2SSHAuthorizedKey(pubkey, "laisky@test")
+			require.NoError(t, err)
+			sshPub, comment, _, _, err := ssh.ParseAuthorizedKey(line)
+			require.NoError(t, err)
+			require.Equal(t, "laisky@test", comment)
+
+			gotPub, gotComment, err := SSHAuthorizedKey2Pubkey(line)
+			require.NoError(t, err)
+			require.Equal(t, "laisky@test", gotComment)
+			require.True(t, gotPub.(interface{ Equal(crypto.PublicKey) bool }).Equal(pubkey))
+
+			// private key
+			for _, passphrase := range [][]byte{nil, []byte("laisky")} {
+				pemBytes, err := Prikey2OpenSSHPem(prikey, passphrase)
+				require.NoError(t, err)
+				require.Contains(t, string(pemBytes), "OPENSSH PRIVATE KEY")
+
+				var signer ssh.Signer
+				if passphrase == nil {
+					signer, err = ssh.ParsePrivateKey(pemBytes)
+				} else {
+					signer, err = ssh.ParsePrivateKeyWithPassphrase(pemBytes, passphrase)
+				}
+				require.NoError(t, err)
+				require.Equal(t, sshPub.Marshal(), signer.PublicKey().Marshal())
+
+				gotPri, err := OpenSSHPem2Prikey(pemBytes, passphrase)
+				require.NoError(t, err)
+				require.True(t, gotPri.(interface{ Equal(crypto.PrivateKey) bool }).Equal(prikey))
+			}
+		})
+	}
+
+	t.Run("wrong passphrase", func(t *testing.T) {
+		pemBytes, err := Prikey2OpenSSHPem(edPrikey, []byte("laisky"))
+		require.NoError(t, err)
+
+		_, err = OpenSSHPem2Prikey(pemBytes, nil)
+		require.Error(t, err)
+		_, err = OpenSSHPem2Prikey(pemBytes, []byte("wrong"))
+		require.Error(t, err)
+	})
+}
+
+func TestSSHKeyUnsupported(t *testing.T) {
+	t.Parallel()
+
+	p224Prikey, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	require.NoError(t, err)
+
+	_, err = Pubkey2SSHAuthorizedKey(&p224Prikey.PublicKey, "")
+	require.ErrorContains(t, err, "not support ecdsa curve P-224")
+	_, err = Prikey2OpenSSHPem(p224Prikey, nil)
+	require.ErrorContains(t, err, "not support ecdsa curve P-224")
+
+	_, err = Pubkey2SSHAuthorizedKey("yo", "")
+	require.ErrorContains(t, err, "only support rsa/ecdsa/ed25519 public key")
+	_, err = Prikey2OpenSSHPem("yo", nil)
+	require.ErrorContains(t, err, "only support rsa/ecdsa/ed25519 private key")
+}
+
+// fixtures are generated by:
+//
+//	ssh-keygen -t ed25519 -N laisky -C "laisky@ed25519" -f id_ed25519
+//	ssh-keygen -t ecdsa -b 256 -N "" -C "laisky@ecdsa" -f id_ecdsa
+//	ssh-keygen -t rsa -b 2048 -N "" -C "laisky@rsa" -f id_rsa
+func TestSSHKeyFixtures(t *testing.T) {
+	t.Parallel()
+
+	for name, passphrase := range map[string][]byte{
+		"id_ed25519": []byte("laisky"),
+		"id_ecdsa":   nil,
+		"id_rsa":     nil,
+	} {
+		priPem, err := os.ReadFile(filepath.Join("testdata", "ssh", name))
+		require.NoError(t, err)
+		pubLine, err := os.ReadFile(filepath.Join("testdata", "ssh", name+".pub"))
+		require.NoError(t, err)
+
+		prikey, err := OpenSSHPem2Prikey(priPem, passphrase)
+		require.NoError(t, err, name)
+		switch prikey.(type) {
+		case ed25519.PrivateKey, *ecdsa.PrivateKey, *rsa.PrivateKey:
+		default:
+			t.Fatalf("unexpected key type %T", prikey)
+		}
+
+		pubkey, comment, err := SSHAuthorizedKey2Pubkey(pubLine)
+		require.NoError(t, err, name)
+		require.Equal(t, "laisky@"+name[len("id_"):], comment)
+		require.True(t, pubkey.(interface{ Equal(crypto.PublicKey) bool }).Equal(Prikey2Pubkey(prikey)), name)
+
+		// marshal again should be identical to ssh-keygen's output
+		line, err := Pubkey2SSHAuthorizedKey(pubkey, comment)
+		require.NoError(t, err)
+		require.Equal(t, string(pubLine), string(line))
+	}
+}
@@ -0,0 +1,227 @@
+package crypto
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/Laisky/errors/v2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// StreamChunkSize plaintext size of each chunk in stream encryption
+const StreamChunkSize = 64 * 1024
+
+const (
+	// streamNoncePrefixSize random prefix of nonce of XChaCha20-Poly1305 stream,
+	// written as stream header
+	streamNoncePrefixSize = chacha20poly1305.NonceSizeX - 8 - 1
+	streamSealedChunkSize = StreamChunkSize + chacha20poly1305.Overhead
+)
+
+// streamNonce nonce of chunk: prefix | counter(8B, big endian) | last flag(1B),
+// prefix is 15B for XChaCha20-Poly1305 and 3B for AES-GCM.
+func streamNonce(prefix []byte, counter uint64, last bool) []byte {
+	nonce := make([]byte, len(prefix)+8+1)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint64(nonce[len(prefix):], counter)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+
+	return nonce
+}
+
+// StreamEncryptor encrypt stream by XChaCha20-Poly1305 in chunks,
+// should call Close to write the final chunk.
+//
+// each chunk has a unique nonce derived from a random prefix and its counter,
+// the final chunk is marked in nonce, so reordering, truncation and
+// appending can be detected by StreamDecryptor.
+type StreamEncryptor struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint64
+	buf     []byte
+	closed  bool
+}
+
+// NewStreamEncryptor new stream encryptor, key should be 32 bytes.
+//
+// it writes a random nonce prefix to w immediately.
+func NewStreamEncryptor(w io.Writer, key []byte) (*StreamEncryptor, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "new xchacha20-poly1305")
+	}
+
+	return newStreamEncryptor(w, aead)
+}
+
+// newStreamEncryptor new stream encryptor by aead,
+// nonce size of aead should be greater than 9 bytes.
+func newStreamEncryptor(w io.Writer, aead cipher.AEAD) (*StreamEncryptor, error) {
+	prefix := make([]byte, aead.NonceSize()-8-1)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, errors.Wrap(err, "generate nonce prefix")
+	}
+
+	if _, err := w.Write(prefix); err != nil {
+		return nil, errors.Wrap(err, "write header")
+	}
+
+	return &StreamEncryptor{
+		w:      w,
+		aead:   aead,
+		prefix: prefix,
+		buf:    make([]byte, 0, StreamChunkSize+aead.Overhead()),
+	}, nil
+}
+
+// Write encrypt p, data is buffered until a full chunk is available
+func (e *StreamEncryptor) Write(p []byte) (n int, err error) {
+	if e.closed {
+		return 0, errors.New("write to closed stream encryptor")
+	}
+
+	for len(p) > 0 {
+		// only seal the buffered chunk when more data comes,
+		// because the final chunk should be sealed with last flag
+		if len(e.buf) == StreamChunkSize {
+			if err = e.flush(false); err != nil {
+				return n, err
+			}
+		}
+
+		c := copy(e.buf[len(e.buf):StreamChunkSize], p)
+		e.buf = e.buf[:len(e.buf)+c]
+		p = p[c:]
+		n += c
+	}
+
+	return n, nil
+}
+
+func (e *StreamEncryptor) flush(last bool) error {
+	if e.counter == math.MaxUint64 {
+		return errors.New("too many chunks")
+	}
+
+	nonce := streamNonce(e.prefix, e.counter, last)
+	e.buf = e.aead.Seal(e.buf[:0], nonce, e.buf, nil)
+	if _, err := e.w.Write(e.buf); err != nil {
+		return errors.Wrap(err, "write chunk")
+	}
+
+	e.counter++
+	e.buf = e.buf[:0]
+	return nil
+}
+
+// Close write the final chunk, the underlying writer will not be closed
+func (e *StreamEncryptor) Close() error {
+	if e.closed {
+		return nil
+	}
+
+	e.closed = true
+	return e.flush(true)
+}
+
+// StreamDecryptor decrypt stream encrypted by StreamEncryptor,
+// Read only returns authenticated plaintext.
+type StreamDecryptor struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint64
+	buf     []byte
+	// plain unread plaintext in buf
+	plain []byte
+	done  bool
+	// err is sticky, stream can not be recovered after tampered
+	err error
+}
+
+// NewStreamDecryptor new stream decryptor, key should be 32 bytes
+func NewStreamDecryptor(r io.Reader, key []byte) (*StreamDecryptor, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "new xchacha20-poly1305")
+	}
+
+	return newStreamDecryptor(r, aead)
+}
+
+// newStreamDecryptor new stream decryptor by aead, same as newStreamEncryptor
+func newStreamDecryptor(r io.Reader, aead cipher.AEAD) (*StreamDecryptor, error) {
+	prefix := make([]byte, aead.NonceSize()-8-1)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, errors.Wrap(err, "read header")
+	}
+
+	sealedChunkSize := StreamChunkSize + aead.Overhead()
+	return &StreamDecryptor{
+		r:      bufio.NewReaderSize(r, sealedChunkSize),
+		aead:   aead,
+		prefix: prefix,
+		buf:    make([]byte, sealedChunkSize),
+	}, nil
+}
+
+// Read decrypt data, return error if stream is tampered or truncated
+func (d *StreamDecryptor) Read(p []byte) (n int, err error) {
+	for len(d.plain) == 0 {
+		switch {
+		case d.err != nil:
+			return 0, d.err
+		case d.done:
+			return 0, io.EOF
+		}
+
+		d.err = d.next()
+	}
+
+	n = copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// next read and decrypt next chunk
+func (d *StreamDecryptor) next() error {
+	n, err := io.ReadFull(d.r, d.buf)
+	switch {
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		// short chunk must be the final one
+		d.done = true
+	case err != nil:
+		return errors.Wrap(err, "read chunk")
+	default:
+		// full chunk is the final one if nothing follows
+		if _, err = d.r.Peek(1); errors.Is(err, io.EOF) {
+			d.done = true
+		} else if err != nil {
+			return errors.Wrap(err, "read chunk")
+		}
+	}
+
+	if d.counter == math.MaxUint64 {
+		return errors.New("too many chunks")
+	}
+
+	nonce := streamNonce(d.prefix, d.counter, d.done)
+	if d.plain, err = d.aead.Open(d.buf[:0], nonce, d.buf[:n], nil); err != nil {
+		if d.done {
+			return errors.Wrap(err, "decrypt final chunk, stream may be truncated")
+		}
+
+		return errors.Wrapf(err, "decrypt chunk %d", d.counter)
+	}
+
+	d.counter++
+	return nil
+}
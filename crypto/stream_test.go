@@ -0,0 +1,185 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testStreamEncrypt(t *testing.T, key, plaintext []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	enc, err := NewStreamEncryptor(&buf, key)
+	require.NoError(t, err)
+
+	// write in odd-sized pieces
+	for p := plaintext; len(p) > 0; {
+		n := rand.Intn(3*StreamChunkSize/2) + 1 //nolint:gosec
+		if n > len(p) {
+			n = len(p)
+		}
+
+		_, err = enc.Write(p[:n])
+		require.NoError(t, err)
+		p = p[n:]
+	}
+	require.NoError(t, enc.Close())
+	require.NoError(t, enc.Close())
+
+	_, err = enc.Write([]byte("yo"))
+	require.Error(t, err)
+
+	return buf.Bytes()
+}
+
+func testStreamDecrypt(key, ciphertext []byte) ([]byte, error) {
+	dec, err := NewStreamDecryptor(bytes.NewReader(ciphertext), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(dec)
+}
+
+func TestStreamEncryptor(t *testing.T) {
+	t.Parallel()
+
+	key, err := Salt(32)
+	require.NoError(t, err)
+
+	for _, size := range []int{
+		0, 1, StreamChunkSize - 1, StreamChunkSize, StreamChunkSize + 1, 3*StreamChunkSize + 7,
+	} {
+		plaintext, err := Salt(size)
+		require.NoError(t, err)
+
+		ciphertext := testStreamEncrypt(t, key, plaintext)
+		got, err := testStreamDecrypt(key, ciphertext)
+		require.NoError(t, err, size)
+		require.Equal(t, plaintext, got, size)
+	}
+
+	plaintext, err := Salt(3 * StreamChunkSize)
+	require.NoError(t, err)
+	ciphertext := testStreamEncrypt(t, key, plaintext)
+	headerLen := streamNoncePrefixSize
+
+	t.Run("wrong key", func(t *testing.T) {
+		anotherKey, err := Salt(32)
+		require.NoError(t, err)
+		_, err = testStreamDecrypt(anotherKey, ciphertext)
+		require.Error(t, err)
+
+		_, err = NewStreamEncryptor(io.Discard, key[:16])
+		require.Error(t, err)
+		_, err = NewStreamDecryptor(bytes.NewReader(ciphertext), key[:16])
+		require.Error(t, err)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		for _, l := range []int{
+			0,
+			headerLen - 1,
+			headerLen,
+			headerLen + streamSealedChunkSize,   // drop at chunk boundary
+			headerLen + 2*streamSealedChunkSize, // drop final chunk
+			len(ciphertext) - 1,
+		} {
+			_, err := testStreamDecrypt(key, ciphertext[:l])
+			require.Error(t, err, l)
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		tampered := bytes.Clone(ciphertext)
+		tampered[headerLen+streamSealedChunkSize+10] ^= 0x01
+
+		dec, err := NewStreamDecryptor(bytes.NewReader(tampered), key)
+		require.NoError(t, err)
+		got, err := io.ReadAll(dec)
+		require.ErrorContains(t, err, "decrypt chunk 1")
+		// only authenticated plaintext is returned
+		require.Equal(t, plaintext[:StreamChunkSize], got)
+
+		// error is sticky
+		_, err = dec.Read(make([]byte, 10))
+		require.Error(t, err)
+	})
+
+	t.Run("reordered", func(t *testing.T) {
+		chunk0 := ciphertext[headerLen : headerLen+streamSealedChunkSize]
+		chunk1 := ciphertext[headerLen+streamSealedChunkSize : headerLen+2*streamSealedChunkSize]
+		reordered := append(bytes.Clone(ciphertext[:headerLen]), chunk1...)
+		reordered = append(reordered, chunk0...)
+		reordered = append(reordered, ciphertext[headerLen+2*streamSealedChunkSize:]...)
+
+		_, err := testStreamDecrypt(key, reordered)
+		require.Error(t, err)
+	})
+
+	t.Run("appended", func(t *testing.T) {
+		_, err := testStreamDecrypt(key, append(bytes.Clone(ciphertext), 0))
+		require.Error(t, err)
+	})
+}
+
+// cappedReader generate size bytes of pseudo random data
+type cappedReader struct {
+	rnd  *rand.Rand
+	left int64
+}
+
+func (r *cappedReader) Read(p []byte) (int, error) {
+	if r.left <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.left {
+		p = p[:r.left]
+	}
+
+	n, _ := r.rnd.Read(p)
+	r.left -= int64(n)
+	return n, nil
+}
+
+func TestStreamEncryptor_MultiGB(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip multi-GB stream test in short mode")
+	}
+	t.Parallel()
+
+	const size = 2<<30 + 12345
+	key, err := Salt(32)
+	require.NoError(t, err)
+
+	pr, pw := io.Pipe()
+	srcHasher := sha256.New()
+	go func() {
+		enc, err := NewStreamEncryptor(pw, key)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+
+		src := io.TeeReader(&cappedReader{rnd: rand.New(rand.NewSource(1)), left: size}, srcHasher) //nolint:gosec
+		if _, err = io.Copy(enc, src); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+
+		pw.CloseWithError(enc.Close())
+	}()
+
+	dec, err := NewStreamDecryptor(pr, key)
+	require.NoError(t, err)
+	dstHasher := sha256.New()
+	n, err := io.Copy(dstHasher, dec)
+	require.NoError(t, err)
+	require.EqualValues(t, size, n)
+	require.Equal(t, srcHasher.Sum(nil), dstHasher.Sum(nil))
+}
@@ -15,6 +15,7 @@ import (
 	"net"
 	"net/mail"
 	"net/url"
+	"regexp"
 	"strings"
 	"time"
 
@@ -88,11 +89,37 @@ func WithX509CSRExtension(_ pkix.Extension) X509CSROption {
 // field is not populated when parsing certificates, see Extensions.
 func WithX509CSRExtraExtension(ext pkix.Extension) X509CSROption {
 	return func(o *x509CSROption) error {
+		if err := validX509Extension(ext); err != nil {
+			return err
+		}
+
 		o.extraExtensions = append(o.extraExtensions, ext)
 		return nil
 	}
 }
 
+// validX509Extension check extension's oid format refer to X.660
+func validX509Extension(ext pkix.Extension) error {
+	if len(ext.Id) < 2 {
+		return errors.Errorf("invalid extension oid %q, should contain at least 2 arcs", ext.Id)
+	}
+
+	for _, arc := range ext.Id {
+		if arc < 0 {
+			return errors.Errorf("invalid extension oid %q, arc should not be negative", ext.Id)
+		}
+	}
+
+	switch {
+	case ext.Id[0] > 2:
+		return errors.Errorf("invalid extension oid %q, first arc should be 0, 1 or 2", ext.Id)
+	case ext.Id[0] < 2 && ext.Id[1] >= 40:
+		return errors.Errorf("invalid extension oid %q, second arc should less than 40", ext.Id)
+	}
+
+	return nil
+}
+
 // WithX509CSRAttribute set attribute
 //
 // Deprecated: Use Extensions and ExtraExtensions instead for parsing and
@@ -349,47 +376,25 @@ func (g *DefaultX509CertSerialNumGenerator) SerialNum() int64 {
 	return time.Now().UnixMilli()*10000 + g.counter.Count()
 }
 
-// NewX509CertTemplate new tls template with common default values
-// func NewX509CertTemplate(opts ...X509CertOption) (tpl *x509.Certificate, err error) {
-// 	opt, err := new(x509V3CertOption).fillDefault().applyOpts(opts...)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-
-// 	notAfter := opt.validFrom.Add(opt.validFor)
-// 	tpl = &x509.Certificate{
-// 		SignatureAlgorithm: opt.signatureAlgorithm,
-// 		SerialNumber:       opt.serialNumber,
-// 		Subject: pkix.Name{
-// 			CommonName:         opt.commonName,
-// 			Organization:       opt.organization,
-// 			OrganizationalUnit: opt.organizationUnit,
-// 			Locality:           opt.locality,
-// 			Country:            opt.country,
-// 			Province:           opt.province,
-// 			StreetAddress:      opt.streetAddrs,
-// 			PostalCode:         opt.PostalCode,
-// 		},
-// 		NotBefore: opt.validFrom,
-// 		NotAfter:  notAfter,
-
-// 		KeyUsage:              opt.keyUsage,
-// 		ExtKeyUsage:           opt.extKeyUsage,
-// 		BasicConstraintsValid: true,
-// 		IsCA:                  opt.isCA,
-// 		PolicyIdentifiers:     opt.policies,
-// 		CRLDistributionPoints: opt.crls,
-// 		OCSPServer:            opt.ocsps,
-// 	}
-
-// 	sansTpl := parseSans(opt.sans)
-// 	tpl.DNSNames = sansTpl.DNSNames
-// 	tpl.EmailAddresses = sansTpl.EmailAddresses
-// 	tpl.IPAddresses = sansTpl.IPAddresses
-// 	tpl.URIs = sansTpl.URIs
-
-// 	return tpl, nil
-// }
+// NewX509CertTemplate new x509 certificate template from options without signing it,
+// so you can inspect or tweak the template before calling x509.CreateCertificate.
+//
+// subject key id is filled if WithX509CertPubkey is set and the cert is not CA,
+// x509.CreateCertificate will generate it for CA.
+func NewX509CertTemplate(opts ...X509CertOption) (*x509.Certificate, error) {
+	opt, tpl, err := x509CertOption2Template(opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "convert options to template")
+	}
+
+	if opt.pubkey != nil && !opt.isCA {
+		if tpl.SubjectKeyId, err = X509CertSubjectKeyID(opt.pubkey); err != nil {
+			return nil, errors.Wrap(err, "generate cert subject key id")
+		}
+	}
+
+	return tpl, nil
+}
 
 type sansTemp struct {
 	DNSNames       []string
@@ -414,6 +419,80 @@ func parseSans(sans []string) (tpl sansTemp) {
 	return tpl
 }
 
+var regexpSANLooksLikeIPv4 = regexp.MustCompile(`^[0-9.]+$`)
+
+// parseSansStrict parse sans like parseSans,
+// but return error for entries that look like ip/email/uri but fail to parse.
+//
+//   - uri: contains `/`, must have scheme and host,
+//     or opaque uri like `urn:uuid:...`, has scheme in urn/mailto/tel/did and no `/`
+//   - email: contains `@`, must be a bare address without display name
+//   - ip: contains `:`, or only digits and dots
+//   - others are treated as dns names
+func parseSansStrict(sans []string) (tpl sansTemp, err error) {
+	for _, san := range sans {
+		switch {
+		case san == "":
+			return tpl, errors.New("empty san")
+		case strings.Contains(san, "/"):
+			uri, err := url.ParseRequestURI(san)
+			if err != nil {
+				return tpl, errors.Wrapf(err, "malformed uri san %q", san)
+			}
+			if uri.Scheme == "" || uri.Host == "" {
+				return tpl, errors.Errorf("malformed uri san %q, scheme and host are required", san)
+			}
+
+			tpl.URIs = append(tpl.URIs, uri)
+		case sanIsOpaqueURI(san):
+			uri, _ := url.Parse(san)
+			tpl.URIs = append(tpl.URIs, uri)
+		case strings.Contains(san, "@"):
+			email, err := mail.ParseAddress(san)
+			if err != nil {
+				return tpl, errors.Wrapf(err, "malformed email san %q", san)
+			}
+			if email.Address != san {
+				return tpl, errors.Errorf("malformed email san %q, should be bare address", san)
+			}
+
+			tpl.EmailAddresses = append(tpl.EmailAddresses, email.Address)
+		case strings.Contains(san, ":") ||
+			regexpSANLooksLikeIPv4.MatchString(san):
+			ip := net.ParseIP(san)
+			if ip == nil {
+				return tpl, errors.Errorf("malformed ip san %q", san)
+			}
+
+			tpl.IPAddresses = append(tpl.IPAddresses, ip)
+		default:
+			tpl.DNSNames = append(tpl.DNSNames, san)
+		}
+	}
+
+	return tpl, nil
+}
+
+// sanOpaqueURISchemes schemes of opaque uri accepted as san in strict mode
+var sanOpaqueURISchemes = map[string]bool{
+	"urn":    true,
+	"mailto": true,
+	"tel":    true,
+	"did":    true,
+}
+
+// sanIsOpaqueURI whether san is uri with known scheme and opaque part,
+// like `urn:uuid:...`.
+//
+// only schemes in sanOpaqueURISchemes are accepted,
+// so `host:port` like `laisky.com:443` is not mistaken as uri.
+func sanIsOpaqueURI(san string) bool {
+	uri, err := url.Parse(san)
+	return err == nil &&
+		sanOpaqueURISchemes[strings.ToLower(uri.Scheme)] &&
+		uri.Opaque != ""
+}
+
 type signCSROption struct {
 	notBefore    time.Time
 	notAfter     time.Time
@@ -442,6 +521,8 @@ type signCSROption struct {
 	serialNumGenerator X509CertSerialNumberGenerator
 	// maxPathLen set CA path length constraint
 	maxPathLen *int
+	// keyPolicy reject csr whose public key violates policy
+	keyPolicy *KeyPolicy
 }
 
 func (o *signCSROption) applyOpts(
@@ -557,6 +638,12 @@ func WithX509SignCSRExtenstions(_ ...pkix.Extension) SignCSROption {
 // see Extensions instead.
 func WithX509SignCSRExtraExtenstions(exts ...pkix.Extension) SignCSROption {
 	return func(o *signCSROption) error {
+		for i := range exts {
+			if err := validX509Extension(exts[i]); err != nil {
+				return err
+			}
+		}
+
 		o.extraExtensions = append(o.extraExtensions, exts...)
 		return nil
 	}
@@ -679,6 +766,14 @@ func WithX509SignCSRIsCRLCA() SignCSROption {
 	}
 }
 
+// WithX509SignCSRKeyPolicy reject csr whose public key violates policy
+func WithX509SignCSRKeyPolicy(policy KeyPolicy) SignCSROption {
+	return func(o *signCSROption) error {
+		o.keyPolicy = &policy
+		return nil
+	}
+}
+
 // NewX509CertByCSR sign CSR to certificate
 //
 // Depends on RFC-5280 4.2.1.12, empty ext key usage is as same as any key usage.
@@ -708,6 +803,12 @@ func NewX509CertByCSR(
 		return nil, errors.Wrap(err, "apply options")
 	}
 
+	if opt.keyPolicy != nil {
+		if err = validatePubkeyPolicy(csr.PublicKey, *opt.keyPolicy); err != nil {
+			return nil, errors.Wrap(err, "csr key violates policy")
+		}
+	}
+
 	if !parent.IsCA || (parent.KeyUsage&x509.KeyUsageCertSign) == x509.KeyUsage(0) {
 		return nil, errors.Errorf("parent certificate does not have CA flag or key usage")
 	}
@@ -790,11 +891,26 @@ func WithX509CertExtentions(_ ...pkix.Extension) X509CertOption {
 // WithX509CertExtraExtensions set extra extensions
 func WithX509CertExtraExtensions(exts ...pkix.Extension) X509CertOption {
 	return func(o *x509V3CertOption) error {
+		for i := range exts {
+			if err := validX509Extension(exts[i]); err != nil {
+				return err
+			}
+		}
+
 		o.signCSROption.extraExtensions = append(o.signCSROption.extraExtensions, exts...)
 		return nil
 	}
 }
 
+// WithX509CertExtraExtension add custom extension, like SCT, CT poison or vendor's oid
+//
+// ExtraExtensions contains extensions to be copied, raw, into any
+// marshaled certificates. Values override any extensions that would
+// otherwise be produced based on the other fields.
+func WithX509CertExtraExtension(ext pkix.Extension) X509CertOption {
+	return WithX509CertExtraExtensions(ext)
+}
+
 // WithX509CertParent set issuer
 func WithX509CertParent(parent *x509.Certificate) X509CertOption {
 	return func(o *x509V3CertOption) error {
@@ -985,6 +1101,27 @@ func WithX509CertSANS(sans ...string) X509CertOption {
 	}
 }
 
+// WithX509CertSANSStrict set certificate SANs like WithX509CertSANS,
+// but return error for entries that look like ip/email/uri but fail to parse,
+// instead of treating them as dns names.
+//
+// refer to RFC-5280 4.2.1.6
+func WithX509CertSANSStrict(sans ...string) X509CertOption {
+	return func(o *x509V3CertOption) error {
+		parsedSANs, err := parseSansStrict(sans)
+		if err != nil {
+			return errors.Wrap(err, "parse sans")
+		}
+
+		o.dnsNames = append(o.dnsNames, parsedSANs.DNSNames...)
+		o.emailAddresses = append(o.emailAddresses, parsedSANs.EmailAddresses...)
+		o.uris = append(o.uris, parsedSANs.URIs...)
+		o.ipAddresses = append(o.ipAddresses, parsedSANs.IPAddresses...)
+
+		return nil
+	}
+}
+
 // WithX509CertDNSNames set dns sans
 func WithX509CertDNSNames(dnsNames ...string) X509CertOption {
 	return func(o *x509V3CertOption) error {
@@ -1403,7 +1540,7 @@ func OIDContains(oids []asn1.ObjectIdentifier,
 			return true
 		}
 
-		if opt.prefix && strings.HasPrefix(oids[i].String(), oid.String()) {
+		if opt.prefix && oidHasPrefix(oids[i], oid) {
 			return true
 		}
 	}
@@ -1411,6 +1548,22 @@ func OIDContains(oids []asn1.ObjectIdentifier,
 	return false
 }
 
+// oidHasPrefix check whether prefix is the leading arcs of oid,
+// compare arc by arc, so `1.2.3` is not a prefix of `1.2.30`
+func oidHasPrefix(oid, prefix asn1.ObjectIdentifier) bool {
+	if len(prefix) > len(oid) {
+		return false
+	}
+
+	for i := range prefix {
+		if oid[i] != prefix[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
 // ReadableX509Cert convert x509 certificate to readable jsonable map
 func ReadableX509Cert(cert *x509.Certificate) (map[string]any, error) {
 	pubkey, err := Pubkey2Pem(cert.PublicKey)
@@ -2,12 +2,14 @@ package crypto
 
 import (
 	"crypto/ecdsa"
+	"crypto/rand"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
 	"math/big"
 	"net"
 	"net/url"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -588,6 +590,24 @@ func Test_OIDs(t *testing.T) {
 	require.True(t, OIDContains(ca.PolicyIdentifiers, asn1.ObjectIdentifier{1, 2}, MatchPrefix()))
 }
 
+func TestOIDContains(t *testing.T) {
+	t.Parallel()
+
+	oids := []asn1.ObjectIdentifier{{1, 2, 3, 4}}
+	require.True(t, OIDContains(oids, asn1.ObjectIdentifier{1, 2, 3, 4}))
+	require.False(t, OIDContains(oids, asn1.ObjectIdentifier{1, 2, 3}))
+	require.True(t, OIDContains(oids, asn1.ObjectIdentifier{1, 2, 3}, MatchPrefix()))
+	require.True(t, OIDContains(oids, asn1.ObjectIdentifier{1}, MatchPrefix()))
+	require.False(t, OIDContains(oids, asn1.ObjectIdentifier{1, 2, 3, 4, 5}, MatchPrefix()))
+	require.False(t, OIDContains(oids, asn1.ObjectIdentifier{1, 3}, MatchPrefix()))
+
+	// multi-digit arcs
+	oids = []asn1.ObjectIdentifier{{1, 2, 30}, {1, 22, 3}}
+	require.False(t, OIDContains(oids, asn1.ObjectIdentifier{1, 2, 3}, MatchPrefix()))
+	require.False(t, OIDContains([]asn1.ObjectIdentifier{{1, 22, 3}}, asn1.ObjectIdentifier{1, 2}, MatchPrefix()))
+	require.True(t, OIDContains([]asn1.ObjectIdentifier{{1, 2, 30}}, asn1.ObjectIdentifier{1, 2}, MatchPrefix()))
+}
+
 func TestNewRSAPrikeyAndCert(t *testing.T) {
 	t.Parallel()
 
@@ -675,6 +695,221 @@ func TestNewRSAPrikeyAndCert(t *testing.T) {
 	})
 }
 
+func TestNewX509CertTemplate(t *testing.T) {
+	t.Parallel()
+
+	prikey, err := NewECDSAPrikey(ECDSACurveP256)
+	require.NoError(t, err)
+
+	tpl, err := NewX509CertTemplate(
+		WithX509CertCommonName("laisky"),
+		WithX509CertSANS("laisky.com"),
+		WithX509CertSeriaNumber(big.NewInt(123)),
+		WithX509CertPubkey(Prikey2Pubkey(prikey)),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "laisky", tpl.Subject.CommonName)
+	require.Equal(t, []string{"laisky.com"}, tpl.DNSNames)
+	require.Equal(t, int64(123), tpl.SerialNumber.Int64())
+	require.NotEmpty(t, tpl.SubjectKeyId)
+	require.False(t, tpl.IsCA)
+
+	// add custom extension that not covered by options
+	ext := pkix.Extension{Id: asn1.ObjectIdentifier{1, 2, 3, 4, 5}, Value: []byte{0x05, 0x00}}
+	tpl.ExtraExtensions = append(tpl.ExtraExtensions, ext)
+
+	certDer, err := x509.CreateCertificate(rand.Reader, tpl, tpl, Prikey2Pubkey(prikey), prikey)
+	require.NoError(t, err)
+	cert, err := Der2Cert(certDer)
+	require.NoError(t, err)
+	require.Equal(t, "laisky", cert.Subject.CommonName)
+
+	var found bool
+	for _, e := range cert.Extensions {
+		if e.Id.Equal(ext.Id) {
+			found = true
+			require.Equal(t, ext.Value, e.Value)
+		}
+	}
+	require.True(t, found)
+
+	_, err = NewX509CertTemplate()
+	require.ErrorContains(t, err, "common name must be set")
+}
+
+func TestWithX509CertExtraExtension(t *testing.T) {
+	t.Parallel()
+
+	// CT poison, RFC 6962 3.1
+	poison := pkix.Extension{
+		Id:       asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3},
+		Critical: true,
+		Value:    []byte{0x05, 0x00},
+	}
+	vendor := pkix.Extension{
+		Id:    asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 55555, 1},
+		Value: []byte("laisky"),
+	}
+
+	_, certDer, err := NewRSAPrikeyAndCert(RSAPrikeyBits2048,
+		WithX509CertCommonName("laisky"),
+		WithX509CertExtraExtension(poison),
+		WithX509CertExtraExtension(vendor),
+	)
+	require.NoError(t, err)
+
+	cert, err := Der2Cert(certDer)
+	require.NoError(t, err)
+	require.Contains(t, cert.Extensions, poison)
+	require.Contains(t, cert.Extensions, vendor)
+
+	t.Run("csr", func(t *testing.T) {
+		prikey, err := NewRSAPrikey(RSAPrikeyBits2048)
+		require.NoError(t, err)
+
+		csrDer, err := NewX509CSR(prikey,
+			WithX509CSRCommonName("laisky"),
+			WithX509CSRExtraExtension(vendor),
+		)
+		require.NoError(t, err)
+
+		csr, err := Der2CSR(csrDer)
+		require.NoError(t, err)
+		require.Contains(t, csr.Extensions, vendor)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, ext := range []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{1}, Value: []byte{0x05, 0x00}},
+			{Id: asn1.ObjectIdentifier{3, 1}, Value: []byte{0x05, 0x00}},
+			{Id: asn1.ObjectIdentifier{1, 40}, Value: []byte{0x05, 0x00}},
+			{Id: asn1.ObjectIdentifier{1, 2, -1}, Value: []byte{0x05, 0x00}},
+		} {
+			_, _, err := NewRSAPrikeyAndCert(RSAPrikeyBits2048,
+				WithX509CertCommonName("laisky"),
+				WithX509CertExtraExtension(ext),
+			)
+			require.Error(t, err, ext.Id)
+
+			_, err = X509CsrOption2Template(
+				WithX509CSRCommonName("laisky"),
+				WithX509CSRExtraExtension(ext),
+			)
+			require.Error(t, err, ext.Id)
+		}
+
+		// 2.999 is valid
+		_, err := X509CsrOption2Template(
+			WithX509CSRCommonName("laisky"),
+			WithX509CSRExtraExtension(pkix.Extension{
+				Id: asn1.ObjectIdentifier{2, 999, 1}, Value: []byte{0x05, 0x00}}),
+		)
+		require.NoError(t, err)
+
+		// empty value is not checked
+		_, err = X509CsrOption2Template(
+			WithX509CSRCommonName("laisky"),
+			WithX509CSRExtraExtension(pkix.Extension{Id: asn1.ObjectIdentifier{1, 2, 3}}),
+		)
+		require.NoError(t, err)
+	})
+}
+
+func Test_parseSansStrict(t *testing.T) {
+	t.Parallel()
+
+	tpl, err := parseSansStrict([]string{
+		"laisky.com",
+		"*.laisky.com",
+		"1.2.3.4",
+		"::1",
+		"fe80::1",
+		"https://laisky.com/path",
+		"spiffe://laisky.com/ns/default",
+		"urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6",
+		"i@laisky.com",
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"laisky.com", "*.laisky.com"}, tpl.DNSNames)
+	require.Equal(t, []string{"i@laisky.com"}, tpl.EmailAddresses)
+	require.Len(t, tpl.IPAddresses, 3)
+	require.True(t, tpl.IPAddresses[0].Equal(net.ParseIP("1.2.3.4")))
+	require.True(t, tpl.IPAddresses[1].Equal(net.IPv6loopback))
+	require.True(t, tpl.IPAddresses[2].Equal(net.ParseIP("fe80::1")))
+	require.Len(t, tpl.URIs, 3)
+	require.Equal(t, "laisky.com", tpl.URIs[0].Host)
+	require.Equal(t, "urn", tpl.URIs[2].Scheme)
+	require.Equal(t, "urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6", tpl.URIs[2].String())
+
+	for _, san := range []string{
+		"",
+		// ip
+		"1.2.3",
+		"1.2.3.256",
+		"1.2.3.4.",
+		"::g",
+		"2001:db8::1::1",
+		// uri
+		"https://",
+		"https//laisky.com",
+		"://laisky.com",
+		"https://laisky.com/%zz",
+		// email
+		"i@",
+		"@laisky.com",
+		"i@@laisky.com",
+		"Laisky <i@laisky.com>",
+	} {
+		_, err := parseSansStrict([]string{san})
+		require.Error(t, err, san)
+
+		// non-strict mode treats them as dns names
+		if san != "" && !strings.Contains(san, "/") && !strings.Contains(san, "@") {
+			require.Equal(t, []string{san}, parseSans([]string{san}).DNSNames, san)
+		}
+	}
+
+	// host:port typos are not opaque uris
+	for _, san := range []string{
+		"example.com:443",
+		"localhost:8080",
+		"Localhost:8080",
+		"foo:bar",
+		"urn:",
+	} {
+		_, err := parseSansStrict([]string{san})
+		require.ErrorContains(t, err, "malformed ip san", san)
+	}
+
+	tpl, err = parseSansStrict([]string{"mailto:i@laisky.com", "URN:ISBN:0451450523"})
+	require.NoError(t, err)
+	require.Len(t, tpl.URIs, 2)
+	require.Empty(t, tpl.EmailAddresses)
+}
+
+func TestWithX509CertSANSStrict(t *testing.T) {
+	t.Parallel()
+
+	_, certder, err := NewRSAPrikeyAndCert(RSAPrikeyBits2048,
+		WithX509CertCommonName("laisky"),
+		WithX509CertSANSStrict("laisky.com", "1.2.3.4", "i@laisky.com", "https://laisky.com"),
+	)
+	require.NoError(t, err)
+
+	cert, err := Der2Cert(certder)
+	require.NoError(t, err)
+	require.Equal(t, []string{"laisky.com"}, cert.DNSNames)
+	require.Equal(t, []string{"i@laisky.com"}, cert.EmailAddresses)
+	require.Len(t, cert.IPAddresses, 1)
+	require.Len(t, cert.URIs, 1)
+
+	_, _, err = NewRSAPrikeyAndCert(RSAPrikeyBits2048,
+		WithX509CertCommonName("laisky"),
+		WithX509CertSANSStrict("i@laisky@com"),
+	)
+	require.ErrorContains(t, err, "malformed email san")
+}
+
 func TestReadableX509Cert(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Laisky/errors/v2"
+)
+
+// deduplicatorBuckets number of time buckets in window
+const deduplicatorBuckets = 10
+
+type deduplicatorBucket[K comparable] struct {
+	epoch int64
+	keys  map[K]struct{}
+}
+
+// DeduplicatorStats statistics of Deduplicator
+type DeduplicatorStats struct {
+	// Size number of keys in window
+	Size int
+	// Evictions number of keys evicted before expiry due to maxEntries
+	Evictions uint64
+}
+
+// Deduplicator best-effort deduplicator of keys within a sliding time window,
+// create by NewDeduplicator.
+//
+// the window is split into a ring of time buckets, keys expire with their bucket,
+// so keys are kept for at least window minus the duration of one bucket.
+type Deduplicator[K comparable] struct {
+	mu             sync.Mutex
+	bucketDuration int64
+	buckets        []deduplicatorBucket[K]
+	maxEntries     int
+	lastEpoch      int64
+	size           int
+	evictions      uint64
+	clock          SchedClock
+}
+
+// NewDeduplicator create new Deduplicator,
+// at most maxEntries keys are kept, the oldest bucket is evicted when full.
+func NewDeduplicator[K comparable](window time.Duration, maxEntries int) (*Deduplicator[K], error) {
+	return newDeduplicator[K](realSchedClock{}, window, maxEntries)
+}
+
+func newDeduplicator[K comparable](clock SchedClock,
+	window time.Duration, maxEntries int) (*Deduplicator[K], error) {
+	if window < deduplicatorBuckets {
+		return nil, errors.Errorf("window should not less than %dns, but got %s",
+			deduplicatorBuckets, window)
+	}
+	if maxEntries <= 0 {
+		return nil, errors.Errorf("maxEntries should bigger than 0, but got %d", maxEntries)
+	}
+
+	d := &Deduplicator[K]{
+		bucketDuration: int64(window) / deduplicatorBuckets,
+		buckets:        make([]deduplicatorBucket[K], deduplicatorBuckets),
+		maxEntries:     maxEntries,
+		clock:          clock,
+	}
+	for i := range d.buckets {
+		d.buckets[i].keys = map[K]struct{}{}
+	}
+
+	return d, nil
+}
+
+func (d *Deduplicator[K]) resetBucket(b *deduplicatorBucket[K], epoch int64) {
+	d.size -= len(b.keys)
+	b.epoch = epoch
+	if len(b.keys) != 0 {
+		// release memory of flooded map
+		b.keys = map[K]struct{}{}
+	}
+}
+
+// expire reset buckets out of window, return current epoch,
+// should be called with lock held
+func (d *Deduplicator[K]) expire() int64 {
+	// never go back by stale clock
+	epoch := max(d.clock.Now().UnixNano()/d.bucketDuration, d.lastEpoch)
+	if epoch == d.lastEpoch {
+		// buckets only expire when epoch moves
+		return epoch
+	}
+	d.lastEpoch = epoch
+
+	oldest := epoch - int64(len(d.buckets))
+	for i := range d.buckets {
+		if b := &d.buckets[i]; b.epoch <= oldest && len(b.keys) != 0 {
+			d.resetBucket(b, b.epoch)
+		}
+	}
+
+	return epoch
+}
+
+// Seen record key, return true if key has been seen within window
+func (d *Deduplicator[K]) Seen(key K) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	epoch := d.expire()
+	oldest := epoch - int64(len(d.buckets))
+	for i := range d.buckets {
+		b := &d.buckets[i]
+		if b.epoch <= oldest {
+			continue
+		}
+
+		if _, ok := b.keys[key]; ok {
+			return true
+		}
+	}
+
+	// evict the oldest buckets until there is room
+	for d.size >= d.maxEntries {
+		var oldestBucket *deduplicatorBucket[K]
+		for i := range d.buckets {
+			b := &d.buckets[i]
+			if len(b.keys) != 0 && (oldestBucket == nil || b.epoch < oldestBucket.epoch) {
+				oldestBucket = b
+			}
+		}
+
+		d.evictions += uint64(len(oldestBucket.keys))
+		d.resetBucket(oldestBucket, oldestBucket.epoch)
+	}
+
+	b := &d.buckets[epoch%int64(len(d.buckets))]
+	if b.epoch != epoch {
+		d.resetBucket(b, epoch)
+	}
+
+	b.keys[key] = struct{}{}
+	d.size++
+	return false
+}
+
+// Stats return statistics
+func (d *Deduplicator[K]) Stats() DeduplicatorStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.expire()
+	return DeduplicatorStats{
+		Size:      d.size,
+		Evictions: d.evictions,
+	}
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeduplicator(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewDeduplicator[string](time.Nanosecond, 10)
+		require.Error(t, err)
+		_, err = NewDeduplicator[string](time.Second, 0)
+		require.Error(t, err)
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		clock := NewFakeSchedClock()
+		d, err := newDeduplicator[string](clock, 10*time.Second, 100)
+		require.NoError(t, err)
+
+		require.False(t, d.Seen("a"))
+		require.True(t, d.Seen("a"))
+
+		clock.Advance(t, 5*time.Second)
+		require.True(t, d.Seen("a"))
+		require.False(t, d.Seen("b"))
+		require.Equal(t, DeduplicatorStats{Size: 2}, d.Stats())
+
+		// still in window
+		clock.Advance(t, 4*time.Second)
+		require.True(t, d.Seen("a"))
+
+		// a expired, b not
+		clock.Advance(t, 2*time.Second)
+		require.Equal(t, DeduplicatorStats{Size: 1}, d.Stats())
+		require.True(t, d.Seen("b"))
+		require.False(t, d.Seen("a"))
+
+		// all expired
+		clock.Advance(t, time.Hour)
+		require.Equal(t, DeduplicatorStats{}, d.Stats())
+		require.False(t, d.Seen("a"))
+		require.False(t, d.Seen("b"))
+
+		// stale clock
+		clock.Advance(t, -time.Hour)
+		require.True(t, d.Seen("a"))
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		d, err := NewDeduplicator[int](time.Minute, 1000)
+		require.NoError(t, err)
+
+		for key := 0; key < 10; key++ {
+			var (
+				firsts atomic.Int64
+				wg     sync.WaitGroup
+			)
+			for i := 0; i < 100; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if !d.Seen(key) {
+						firsts.Add(1)
+					}
+				}()
+			}
+			wg.Wait()
+
+			require.EqualValues(t, 1, firsts.Load())
+		}
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		clock := NewFakeSchedClock()
+		d, err := newDeduplicator[string](clock, 10*time.Second, 100)
+		require.NoError(t, err)
+
+		for i := 0; i < 10000; i++ {
+			if i%100 == 0 {
+				clock.Advance(t, 100*time.Millisecond)
+			}
+
+			require.False(t, d.Seen(fmt.Sprintf("key-%d", i)))
+			require.LessOrEqual(t, d.Stats().Size, 100)
+		}
+
+		stats := d.Stats()
+		require.Positive(t, stats.Size)
+		require.EqualValues(t, 10000, uint64(stats.Size)+stats.Evictions)
+
+		// the latest keys are kept
+		require.True(t, d.Seen("key-9999"))
+	})
+}
+
+func BenchmarkDeduplicator(b *testing.B) {
+	d, err := NewDeduplicator[int](time.Minute, 100000)
+	require.NoError(b, err)
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			d.Seen(i % 200000)
+			i++
+		}
+	})
+}
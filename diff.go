@@ -0,0 +1,267 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/Laisky/errors/v2"
+)
+
+// FieldDiff one changed field found by DiffStructs
+type FieldDiff struct {
+	// Path like `Meta.Labels[env]` or `Items[2].Name`,
+	// `<path>.len` means the length of slice is changed.
+	Path string
+	// Old value in old, nil if not exists
+	Old any
+	// New value in new, nil if not exists
+	New any
+}
+
+type diffOption struct {
+	ignorePaths []string
+	maxDepth    int
+}
+
+// DiffOption options for DiffStructs
+type DiffOption func(*diffOption) error
+
+// WithDiffIgnorePaths ignore paths and their children, like `Meta.UpdatedAt`
+func WithDiffIgnorePaths(paths ...string) DiffOption {
+	return func(o *diffOption) error {
+		o.ignorePaths = append(o.ignorePaths, paths...)
+		return nil
+	}
+}
+
+// WithDiffMaxDepth stop walking deeper than n levels,
+// values at the max depth are compared as a whole. default is 32.
+//
+// pointer and interface indirections count as levels too.
+func WithDiffMaxDepth(n int) DiffOption {
+	return func(o *diffOption) error {
+		if n <= 0 {
+			return errors.Errorf("max depth should be positive, got %d", n)
+		}
+
+		o.maxDepth = n
+		return nil
+	}
+}
+
+func (o *diffOption) ignored(path string) bool {
+	for _, p := range o.ignorePaths {
+		if path == p ||
+			strings.HasPrefix(path, p+".") ||
+			strings.HasPrefix(path, p+"[") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DiffStructs return the changed fields between old and new, sorted by path.
+//
+// exported fields of structs are walked recursively, including maps,
+// slices (index-wise) and pointers. structs without exported fields,
+// like time.Time, are compared as a whole by their Equal method if any.
+//
+// old and new should be the same type.
+func DiffStructs(old, new any, opts ...DiffOption) ([]FieldDiff, error) {
+	opt := &diffOption{maxDepth: 32}
+	for _, f := range opts {
+		if err := f(opt); err != nil {
+			return nil, errors.Wrap(err, "apply option")
+		}
+	}
+
+	oldV, newV := reflect.ValueOf(old), reflect.ValueOf(new)
+	if oldV.IsValid() && newV.IsValid() && oldV.Type() != newV.Type() {
+		return nil, errors.Errorf("type mismatch, old is %s but new is %s", oldV.Type(), newV.Type())
+	}
+
+	var diffs []FieldDiff
+	diffValue(opt, &diffs, "", 0, oldV, newV)
+	slices.SortStableFunc(diffs, func(a, b FieldDiff) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	return diffs, nil
+}
+
+// diffInterface return the value of v, nil if v is invalid
+func diffInterface(v reflect.Value) any {
+	if !v.IsValid() {
+		return nil
+	}
+
+	return v.Interface()
+}
+
+func diffJoinField(path, field string) string {
+	if path == "" {
+		return field
+	}
+
+	return path + "." + field
+}
+
+func diffValue(opt *diffOption, diffs *[]FieldDiff, path string, depth int, oldV, newV reflect.Value) {
+	if opt.ignored(path) {
+		return
+	}
+
+	addDiff := func() {
+		*diffs = append(*diffs, FieldDiff{
+			Path: path,
+			Old:  diffInterface(oldV),
+			New:  diffInterface(newV),
+		})
+	}
+
+	switch {
+	case !oldV.IsValid() && !newV.IsValid():
+		return
+	case !oldV.IsValid() || !newV.IsValid() || oldV.Type() != newV.Type():
+		addDiff()
+		return
+	case depth >= opt.maxDepth:
+		if !diffLeafEqual(oldV, newV) {
+			addDiff()
+		}
+
+		return
+	}
+
+	switch oldV.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		switch {
+		case oldV.IsNil() && newV.IsNil():
+		case oldV.IsNil() || newV.IsNil():
+			addDiff()
+		default:
+			// count indirection as a level, so self-referential values
+			// like `x = &x` stop at max depth
+			diffValue(opt, diffs, path, depth+1, oldV.Elem(), newV.Elem())
+		}
+	case reflect.Struct:
+		typ := oldV.Type()
+		var walked bool
+		for i := 0; i < typ.NumField(); i++ {
+			if !typ.Field(i).IsExported() {
+				continue
+			}
+
+			walked = true
+			diffValue(opt, diffs, diffJoinField(path, typ.Field(i).Name), depth+1,
+				oldV.Field(i), newV.Field(i))
+		}
+
+		if !walked && !diffLeafEqual(oldV, newV) {
+			addDiff()
+		}
+	case reflect.Slice, reflect.Array:
+		oldLen, newLen := oldV.Len(), newV.Len()
+		if oldLen != newLen && !opt.ignored(path+".len") {
+			*diffs = append(*diffs, FieldDiff{Path: path + ".len", Old: oldLen, New: newLen})
+		}
+
+		for i := 0; i < max(oldLen, newLen); i++ {
+			var oldItem, newItem reflect.Value
+			if i < oldLen {
+				oldItem = oldV.Index(i)
+			}
+			if i < newLen {
+				newItem = newV.Index(i)
+			}
+
+			diffValue(opt, diffs, fmt.Sprintf("%s[%d]", path, i), depth+1, oldItem, newItem)
+		}
+	case reflect.Map:
+		for _, key := range oldV.MapKeys() {
+			diffValue(opt, diffs, fmt.Sprintf("%s[%v]", path, key.Interface()), depth+1,
+				oldV.MapIndex(key), newV.MapIndex(key))
+		}
+		for _, key := range newV.MapKeys() {
+			if oldV.MapIndex(key).IsValid() {
+				continue
+			}
+
+			diffValue(opt, diffs, fmt.Sprintf("%s[%v]", path, key.Interface()), depth+1,
+				reflect.Value{}, newV.MapIndex(key))
+		}
+	default:
+		if !diffLeafEqual(oldV, newV) {
+			addDiff()
+		}
+	}
+}
+
+// diffLeafEqual compare by `Equal(T) bool` method if any, like time.Time,
+// otherwise by reflect.DeepEqual
+func diffLeafEqual(oldV, newV reflect.Value) bool {
+	if m := oldV.MethodByName("Equal"); m.IsValid() {
+		mt := m.Type()
+		if mt.NumIn() == 1 && mt.In(0) == newV.Type() &&
+			mt.NumOut() == 1 && mt.Out(0).Kind() == reflect.Bool {
+			return m.Call([]reflect.Value{newV})[0].Bool()
+		}
+	}
+
+	return reflect.DeepEqual(oldV.Interface(), newV.Interface())
+}
+
+// DiffMap return the changed keys between old and new by dotted path,
+// nested map[string]any are walked like FlattenMap with delimiter `.`.
+//
+// values in changed are FieldDiff with old and new values.
+// if the type of a key is changed between map and others,
+// it is reported in changed as a whole, rather than added and removed leaves.
+func DiffMap(old, new map[string]any) (added, removed, changed map[string]any) {
+	added, removed, changed = map[string]any{}, map[string]any{}, map[string]any{}
+	diffMap(added, removed, changed, "", old, new)
+	return added, removed, changed
+}
+
+func diffMap(added, removed, changed map[string]any, prefix string, old, new map[string]any) {
+	for k, oldVal := range old {
+		path := prefix + k
+		newVal, ok := new[k]
+		if !ok {
+			diffMapLeaves(removed, path, oldVal)
+			continue
+		}
+
+		oldMap, oldIsMap := oldVal.(map[string]any)
+		newMap, newIsMap := newVal.(map[string]any)
+		switch {
+		case oldIsMap && newIsMap:
+			diffMap(added, removed, changed, path+".", oldMap, newMap)
+		case !reflect.DeepEqual(oldVal, newVal):
+			changed[path] = FieldDiff{Path: path, Old: oldVal, New: newVal}
+		}
+	}
+
+	for k, newVal := range new {
+		if _, ok := old[k]; !ok {
+			diffMapLeaves(added, prefix+k, newVal)
+		}
+	}
+}
+
+// diffMapLeaves set all leaves of val into dst by dotted path,
+// empty map is a leaf itself
+func diffMapLeaves(dst map[string]any, path string, val any) {
+	m, ok := val.(map[string]any)
+	if !ok || len(m) == 0 {
+		dst[path] = val
+		return
+	}
+
+	for k, v := range m {
+		diffMapLeaves(dst, path+"."+k, v)
+	}
+}
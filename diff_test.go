@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testDiffMeta struct {
+	UpdatedAt time.Time
+	Labels    map[string]string
+}
+
+type testDiffSettings struct {
+	Name    string
+	Port    int
+	Tags    []string
+	Meta    testDiffMeta
+	Backup  *testDiffMeta
+	Any     any
+	private int
+}
+
+func TestDiffStructs(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	newSettings := func() testDiffSettings {
+		return testDiffSettings{
+			Name: "srv",
+			Port: 80,
+			Tags: []string{"a", "b"},
+			Meta: testDiffMeta{
+				UpdatedAt: now,
+				Labels:    map[string]string{"env": "prod", "zone": "us"},
+			},
+			Any: 1,
+		}
+	}
+
+	t.Run("equal", func(t *testing.T) {
+		old, new := newSettings(), newSettings()
+		new.private = 2
+		// same instant in another location
+		new.Meta.UpdatedAt = now.UTC()
+
+		diffs, err := DiffStructs(old, new)
+		require.NoError(t, err)
+		require.Empty(t, diffs)
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		old, new := newSettings(), newSettings()
+		new.Port = 8080
+		new.Tags = []string{"a", "c", "d"}
+		new.Meta.UpdatedAt = now.Add(time.Second)
+		new.Meta.Labels = map[string]string{"env": "test", "region": "eu"}
+		new.Backup = &testDiffMeta{}
+		new.Any = "1"
+
+		diffs, err := DiffStructs(&old, &new)
+		require.NoError(t, err)
+		require.Equal(t, []FieldDiff{
+			{Path: "Any", Old: 1, New: "1"},
+			{Path: "Backup", Old: (*testDiffMeta)(nil), New: new.Backup},
+			{Path: "Meta.Labels[env]", Old: "prod", New: "test"},
+			{Path: "Meta.Labels[region]", Old: nil, New: "eu"},
+			{Path: "Meta.Labels[zone]", Old: "us", New: nil},
+			{Path: "Meta.UpdatedAt", Old: now, New: new.Meta.UpdatedAt},
+			{Path: "Port", Old: 80, New: 8080},
+			{Path: "Tags.len", Old: 2, New: 3},
+			{Path: "Tags[1]", Old: "b", New: "c"},
+			{Path: "Tags[2]", Old: nil, New: "d"},
+		}, diffs)
+	})
+
+	t.Run("pointer values", func(t *testing.T) {
+		old, new := newSettings(), newSettings()
+		old.Backup = &testDiffMeta{Labels: map[string]string{"a": "1"}}
+		new.Backup = &testDiffMeta{Labels: map[string]string{"a": "2"}}
+
+		diffs, err := DiffStructs(old, new)
+		require.NoError(t, err)
+		require.Equal(t, []FieldDiff{{Path: "Backup.Labels[a]", Old: "1", New: "2"}}, diffs)
+	})
+
+	t.Run("ignore paths", func(t *testing.T) {
+		old, new := newSettings(), newSettings()
+		new.Port = 8080
+		new.Tags = nil
+		new.Meta.UpdatedAt = now.Add(time.Second)
+		new.Meta.Labels["env"] = "test"
+
+		diffs, err := DiffStructs(old, new,
+			WithDiffIgnorePaths("Meta.UpdatedAt", "Tags", "Meta.Labels[env]"))
+		require.NoError(t, err)
+		require.Equal(t, []FieldDiff{{Path: "Port", Old: 80, New: 8080}}, diffs)
+	})
+
+	t.Run("max depth", func(t *testing.T) {
+		old, new := newSettings(), newSettings()
+		new.Meta.Labels = map[string]string{"env": "test"}
+
+		diffs, err := DiffStructs(old, new, WithDiffMaxDepth(1))
+		require.NoError(t, err)
+		require.Len(t, diffs, 1)
+		require.Equal(t, "Meta", diffs[0].Path)
+
+		_, err = DiffStructs(old, new, WithDiffMaxDepth(0))
+		require.Error(t, err)
+	})
+
+	t.Run("self reference", func(t *testing.T) {
+		var x, y any
+		x, y = &x, &y
+
+		diffs, err := DiffStructs(x, y)
+		require.NoError(t, err)
+		require.Empty(t, diffs)
+	})
+
+	t.Run("type mismatch", func(t *testing.T) {
+		_, err := DiffStructs(newSettings(), testDiffMeta{})
+		require.ErrorContains(t, err, "type mismatch")
+	})
+}
+
+func TestDiffMap(t *testing.T) {
+	old := map[string]any{
+		"name": "svc",
+		"port": 80,
+		"db": map[string]any{
+			"host": "127.0.0.1",
+			"pool": map[string]any{"size": 10, "idle": 2},
+		},
+		"tls":    map[string]any{"cert": "a.pem", "key": "a.key"},
+		"labels": []string{"a"},
+		"debug":  true,
+	}
+	new := map[string]any{
+		"name": "svc",
+		"port": "80",
+		"db": map[string]any{
+			"host": "10.0.0.1",
+			"pool": map[string]any{"size": 10, "max": 20},
+		},
+		"tls":    false,
+		"labels": []string{"a"},
+		"log":    map[string]any{"level": "info"},
+	}
+
+	added, removed, changed := DiffMap(old, new)
+	require.Equal(t, map[string]any{
+		"db.pool.max": 20,
+		"log.level":   "info",
+	}, added)
+	require.Equal(t, map[string]any{
+		"db.pool.idle": 2,
+		"debug":        true,
+	}, removed)
+	require.Equal(t, map[string]any{
+		"port":    FieldDiff{Path: "port", Old: 80, New: "80"},
+		"db.host": FieldDiff{Path: "db.host", Old: "127.0.0.1", New: "10.0.0.1"},
+		"tls": FieldDiff{Path: "tls",
+			Old: map[string]any{"cert": "a.pem", "key": "a.key"}, New: false},
+	}, changed)
+
+	added, removed, changed = DiffMap(nil, old)
+	require.Len(t, added, 9)
+	require.Equal(t, 10, added["db.pool.size"])
+	require.Empty(t, removed)
+	require.Empty(t, changed)
+
+	// empty nested map
+	added, removed, changed = DiffMap(map[string]any{"a": map[string]any{}}, nil)
+	require.Empty(t, added)
+	require.Equal(t, map[string]any{"a": map[string]any{}}, removed)
+	require.Empty(t, changed)
+
+	added, removed, changed = DiffMap(
+		map[string]any{"a": map[string]any{}},
+		map[string]any{"a": map[string]any{"b": map[string]any{}}},
+	)
+	require.Equal(t, map[string]any{"a.b": map[string]any{}}, added)
+	require.Empty(t, removed)
+	require.Empty(t, changed)
+}
@@ -0,0 +1,425 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Laisky/errors/v2"
+)
+
+// ResolveWithTimeout resolve host to ips by net.DefaultResolver,
+// resolving is canceled if it takes longer than timeout.
+//
+// timeout <= 0 means no extra timeout except ctx.
+func ResolveWithTimeout(ctx context.Context, host string, timeout time.Duration) ([]net.IP, error) {
+	return resolveWithTimeout(ctx, net.DefaultResolver, host, timeout)
+}
+
+func resolveWithTimeout(ctx context.Context, resolver *net.Resolver,
+	host string, timeout time.Duration) ([]net.IP, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	ips, err := resolver.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return nil, errors.Wrapf(err, "resolve %q", host)
+	}
+
+	return ips, nil
+}
+
+type roundRobinDialerOption struct {
+	cooldown time.Duration
+	timeout  time.Duration
+	resolver *net.Resolver
+}
+
+// RoundRobinDialerOption options for NewRoundRobinDialer
+type RoundRobinDialerOption func(*roundRobinDialerOption) error
+
+// WithRoundRobinDialerCooldown skip address for d after it failed, default to 10s
+func WithRoundRobinDialerCooldown(d time.Duration) RoundRobinDialerOption {
+	return func(o *roundRobinDialerOption) error {
+		if d < 0 {
+			return errors.Errorf("cooldown should not be negative, got %s", d)
+		}
+
+		o.cooldown = d
+		return nil
+	}
+}
+
+// WithRoundRobinDialerTimeout set timeout for each resolving and dialing attempt,
+// default to 3s
+func WithRoundRobinDialerTimeout(d time.Duration) RoundRobinDialerOption {
+	return func(o *roundRobinDialerOption) error {
+		if d <= 0 {
+			return errors.Errorf("timeout should be positive, got %s", d)
+		}
+
+		o.timeout = d
+		return nil
+	}
+}
+
+// WithRoundRobinDialerResolver set resolver, default to net.DefaultResolver
+func WithRoundRobinDialerResolver(resolver *net.Resolver) RoundRobinDialerOption {
+	return func(o *roundRobinDialerOption) error {
+		if resolver == nil {
+			return errors.New("resolver should not be nil")
+		}
+
+		o.resolver = resolver
+		return nil
+	}
+}
+
+// NewRoundRobinDialer return a DialContext func that dials addrs in turn.
+//
+// addrs are like `host:port`, hosts are resolved on each dial,
+// and the connection is tried on resolved addresses one by one,
+// starting from the next one of the last dial.
+// address failed recently is skipped during cooldown,
+// unless all addresses are cooling down.
+//
+// the addr argument of DialContext is ignored, so it can be used as
+// http.Transport.DialContext to balance requests between replicas.
+// return the joined error of each attempt if all addresses failed.
+func NewRoundRobinDialer(addrs []string, opts ...RoundRobinDialerOption) (
+	func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("addrs should not be empty")
+	}
+	for _, addr := range addrs {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, errors.Wrapf(err, "invalid addr %q", addr)
+		}
+	}
+
+	opt := &roundRobinDialerOption{
+		cooldown: 10 * time.Second,
+		timeout:  3 * time.Second,
+		resolver: net.DefaultResolver,
+	}
+	for _, f := range opts {
+		if err := f(opt); err != nil {
+			return nil, errors.Wrap(err, "apply option")
+		}
+	}
+
+	var (
+		next     atomic.Uint64
+		mu       sync.Mutex
+		failedAt = map[string]time.Time{}
+	)
+	dialer := &net.Dialer{Timeout: opt.timeout}
+
+	return func(ctx context.Context, network, _ string) (net.Conn, error) {
+		var (
+			candidates []string
+			errs       []error
+		)
+		for _, addr := range addrs {
+			host, port, _ := net.SplitHostPort(addr)
+			ips, err := resolveWithTimeout(ctx, opt.resolver, host, opt.timeout)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			for _, ip := range ips {
+				candidates = append(candidates, net.JoinHostPort(ip.String(), port))
+			}
+		}
+
+		if len(candidates) == 0 {
+			return nil, errors.Wrap(errors.Join(errs...), "no address resolved")
+		}
+
+		// rotate candidates, then move the cooling ones to the end
+		start := int((next.Add(1) - 1) % uint64(len(candidates)))
+		candidates = append(candidates[start:len(candidates):len(candidates)], candidates[:start]...)
+		now := time.Now()
+		mu.Lock()
+		var ready, cooling []string
+		for _, c := range candidates {
+			if t, ok := failedAt[c]; ok && now.Sub(t) < opt.cooldown {
+				cooling = append(cooling, c)
+			} else {
+				ready = append(ready, c)
+			}
+		}
+		mu.Unlock()
+		if len(ready) != 0 {
+			cooling = nil
+		}
+
+		for _, c := range append(ready, cooling...) {
+			conn, err := dialer.DialContext(ctx, network, c)
+			mu.Lock()
+			if err != nil {
+				failedAt[c] = time.Now()
+			} else {
+				delete(failedAt, c)
+			}
+			mu.Unlock()
+
+			if err == nil {
+				return conn, nil
+			}
+
+			errs = append(errs, errors.Wrapf(err, "dial %q", c))
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		return nil, errors.Join(errs...)
+	}, nil
+}
+
+// NewStaticResolver return a resolver that resolves names in overrides
+// to the given ips, like /etc/hosts, other names are resolved by the
+// nameservers of the system.
+//
+//	resolver, _ := NewStaticResolver(map[string]string{"config-server.internal": "127.0.0.1"})
+//	ips, _ := resolver.LookupIP(ctx, "ip", "config-server.internal")
+//
+// A and AAAA queries are answered in process by the Dial hook of net.Resolver,
+// so names in /etc/hosts take precedence.
+func NewStaticResolver(overrides map[string]string) (*net.Resolver, error) {
+	records := make(map[string]net.IP, len(overrides))
+	for name, addr := range overrides {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return nil, errors.Errorf("invalid ip %q for %q", addr, name)
+		}
+
+		records[normalizeDNSName(name)] = ip
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return &staticDNSConn{
+				ctx:      ctx,
+				upstream: address,
+				records:  records,
+			}, nil
+		},
+	}, nil
+}
+
+func normalizeDNSName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+const (
+	dnsTypeA    = 1
+	dnsTypeAAAA = 28
+	dnsClassIN  = 1
+	// dnsHeaderLen length of dns message header
+	dnsHeaderLen = 12
+	// dnsStaticTTL ttl of static records
+	dnsStaticTTL = 60
+)
+
+// staticDNSConn in-process dns server in TCP framing.
+//
+// it does not implement net.PacketConn,
+// so net.Resolver talks to it by length-prefixed messages.
+type staticDNSConn struct {
+	ctx      context.Context
+	upstream string
+	records  map[string]net.IP
+
+	mu       sync.Mutex
+	req      bytes.Buffer
+	resp     bytes.Buffer
+	deadline time.Time
+}
+
+func (c *staticDNSConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.resp.Len() == 0 {
+		return 0, io.EOF
+	}
+
+	return c.resp.Read(b)
+}
+
+func (c *staticDNSConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.req.Write(b)
+	for c.req.Len() >= 2 {
+		msgLen := int(binary.BigEndian.Uint16(c.req.Bytes()))
+		if c.req.Len() < 2+msgLen {
+			break
+		}
+
+		msg := make([]byte, msgLen)
+		c.req.Next(2)
+		_, _ = c.req.Read(msg)
+
+		resp, err := c.answer(msg)
+		if err != nil {
+			return 0, err
+		}
+
+		_ = binary.Write(&c.resp, binary.BigEndian, uint16(len(resp)))
+		c.resp.Write(resp)
+	}
+
+	return len(b), nil
+}
+
+// answer build response for query msg, forward to upstream if name not in records
+func (c *staticDNSConn) answer(msg []byte) ([]byte, error) {
+	name, qtype, qclass, questionEnd, err := parseDNSQuestion(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	ip, ok := c.records[normalizeDNSName(name)]
+	if !ok {
+		return c.forward(msg)
+	}
+
+	var rdata []byte
+	switch {
+	case qclass != dnsClassIN:
+	case qtype == dnsTypeA && ip.To4() != nil:
+		rdata = ip.To4()
+	case qtype == dnsTypeAAAA && ip.To4() == nil:
+		rdata = ip.To16()
+	}
+
+	resp := make([]byte, 0, questionEnd+16+len(rdata))
+	resp = append(resp, msg[:2]...) // id
+	// QR, AA, copy RD, RA, NOERROR
+	resp = append(resp, 0x84|msg[2]&0x01, 0x80)
+	resp = binary.BigEndian.AppendUint16(resp, 1) // qdcount
+	if rdata == nil {
+		resp = binary.BigEndian.AppendUint16(resp, 0)
+	} else {
+		resp = binary.BigEndian.AppendUint16(resp, 1)
+	}
+	resp = append(resp, 0, 0, 0, 0) // nscount, arcount
+	resp = append(resp, msg[dnsHeaderLen:questionEnd]...)
+	if rdata != nil {
+		resp = append(resp, 0xc0, dnsHeaderLen) // pointer to question name
+		resp = binary.BigEndian.AppendUint16(resp, qtype)
+		resp = binary.BigEndian.AppendUint16(resp, dnsClassIN)
+		resp = binary.BigEndian.AppendUint32(resp, dnsStaticTTL)
+		resp = binary.BigEndian.AppendUint16(resp, uint16(len(rdata)))
+		resp = append(resp, rdata...)
+	}
+
+	return resp, nil
+}
+
+// forward send msg to upstream nameserver by tcp
+func (c *staticDNSConn) forward(msg []byte) ([]byte, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(c.ctx, "tcp", c.upstream)
+	if err != nil {
+		return nil, errors.Wrapf(err, "dial upstream %q", c.upstream)
+	}
+	defer SilentClose(conn)
+
+	if !c.deadline.IsZero() {
+		if err = conn.SetDeadline(c.deadline); err != nil {
+			return nil, errors.Wrap(err, "set deadline")
+		}
+	}
+
+	req := binary.BigEndian.AppendUint16(nil, uint16(len(msg)))
+	if _, err = conn.Write(append(req, msg...)); err != nil {
+		return nil, errors.Wrap(err, "write to upstream")
+	}
+
+	var respLen uint16
+	if err = binary.Read(conn, binary.BigEndian, &respLen); err != nil {
+		return nil, errors.Wrap(err, "read from upstream")
+	}
+
+	resp := make([]byte, respLen)
+	if _, err = io.ReadFull(conn, resp); err != nil {
+		return nil, errors.Wrap(err, "read from upstream")
+	}
+
+	return resp, nil
+}
+
+func (c *staticDNSConn) Close() error { return nil }
+
+func (c *staticDNSConn) LocalAddr() net.Addr { return staticDNSAddr{} }
+
+func (c *staticDNSConn) RemoteAddr() net.Addr { return staticDNSAddr{} }
+
+func (c *staticDNSConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.deadline = t
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *staticDNSConn) SetReadDeadline(t time.Time) error { return nil }
+
+func (c *staticDNSConn) SetWriteDeadline(t time.Time) error { return c.SetDeadline(t) }
+
+type staticDNSAddr struct{}
+
+func (staticDNSAddr) Network() string { return "static" }
+
+func (staticDNSAddr) String() string { return "static" }
+
+// parseDNSQuestion parse the first question of dns query,
+// questionEnd is the offset after the question.
+func parseDNSQuestion(msg []byte) (name string, qtype, qclass uint16, questionEnd int, err error) {
+	if len(msg) < dnsHeaderLen || binary.BigEndian.Uint16(msg[4:]) != 1 {
+		return "", 0, 0, 0, errors.New("dns query should have exactly one question")
+	}
+
+	var labels []string
+	off := dnsHeaderLen
+	for {
+		if off >= len(msg) {
+			return "", 0, 0, 0, errors.New("invalid dns question")
+		}
+
+		l := int(msg[off])
+		off++
+		if l == 0 {
+			break
+		}
+		if l&0xc0 != 0 || off+l > len(msg) {
+			return "", 0, 0, 0, errors.New("invalid dns question name")
+		}
+
+		labels = append(labels, string(msg[off:off+l]))
+		off += l
+	}
+
+	if off+4 > len(msg) {
+		return "", 0, 0, 0, errors.New("invalid dns question")
+	}
+
+	qtype = binary.BigEndian.Uint16(msg[off:])
+	qclass = binary.BigEndian.Uint16(msg[off+2:])
+	return strings.Join(labels, "."), qtype, qclass, off + 4, nil
+}
@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveWithTimeout(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	ips, err := ResolveWithTimeout(ctx, "127.0.0.1", time.Second)
+	require.NoError(t, err)
+	require.Len(t, ips, 1)
+	require.True(t, ips[0].Equal(net.ParseIP("127.0.0.1")))
+
+	ips, err = ResolveWithTimeout(ctx, "localhost", time.Second)
+	require.NoError(t, err)
+	require.NotEmpty(t, ips)
+
+	_, err = ResolveWithTimeout(ctx, "resolve-timeout.invalid", time.Nanosecond)
+	require.Error(t, err)
+}
+
+func TestNewStaticResolver(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	_, err := NewStaticResolver(map[string]string{"a.internal": "not-ip"})
+	require.ErrorContains(t, err, "invalid ip")
+
+	resolver, err := NewStaticResolver(map[string]string{
+		"config-server.internal": "127.0.0.1",
+		"V6.Internal.":           "::1",
+	})
+	require.NoError(t, err)
+
+	ips, err := resolver.LookupIP(ctx, "ip", "config-server.internal")
+	require.NoError(t, err)
+	require.Len(t, ips, 1)
+	require.True(t, ips[0].Equal(net.ParseIP("127.0.0.1")))
+
+	ips, err = resolver.LookupIP(ctx, "ip6", "v6.internal")
+	require.NoError(t, err)
+	require.Len(t, ips, 1)
+	require.True(t, ips[0].Equal(net.ParseIP("::1")))
+
+	// no AAAA record for ipv4 override
+	_, err = resolver.LookupIP(ctx, "ip6", "config-server.internal")
+	require.Error(t, err)
+
+	addrs, err := resolver.LookupHost(ctx, "CONFIG-SERVER.internal")
+	require.NoError(t, err)
+	require.Equal(t, []string{"127.0.0.1"}, addrs)
+}
+
+// newTestDNSListener listen on 127.0.0.1 and count accepted connections
+func newTestDNSListener(t *testing.T, addr string) (net.Listener, chan struct{}) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", addr)
+	require.NoError(t, err)
+
+	accepted := make(chan struct{}, 100)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			_ = conn.Close()
+			accepted <- struct{}{}
+		}
+	}()
+
+	return ln, accepted
+}
+
+func TestNewRoundRobinDialer(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	_, err := NewRoundRobinDialer(nil)
+	require.Error(t, err)
+	_, err = NewRoundRobinDialer([]string{"no-port"})
+	require.Error(t, err)
+	_, err = NewRoundRobinDialer([]string{"127.0.0.1:1"}, WithRoundRobinDialerTimeout(0))
+	require.Error(t, err)
+
+	ln1, _ := newTestDNSListener(t, "127.0.0.1:0")
+	defer ln1.Close()
+	ln2, _ := newTestDNSListener(t, "127.0.0.1:0")
+	addr2 := ln2.Addr().String()
+
+	// ln2 is resolved by static resolver
+	_, port2, err := net.SplitHostPort(addr2)
+	require.NoError(t, err)
+	resolver, err := NewStaticResolver(map[string]string{"replica2.internal": "127.0.0.1"})
+	require.NoError(t, err)
+
+	dial, err := NewRoundRobinDialer(
+		[]string{ln1.Addr().String(), net.JoinHostPort("replica2.internal", port2)},
+		WithRoundRobinDialerResolver(resolver),
+		WithRoundRobinDialerCooldown(300*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	dialTo := func() string {
+		conn, err := dial(ctx, "tcp", "ignored:80")
+		require.NoError(t, err)
+		defer conn.Close()
+		return conn.RemoteAddr().String()
+	}
+
+	t.Run("rotation", func(t *testing.T) {
+		var got []string
+		for i := 0; i < 4; i++ {
+			got = append(got, dialTo())
+		}
+
+		require.Equal(t, []string{
+			ln1.Addr().String(), addr2, ln1.Addr().String(), addr2,
+		}, got)
+	})
+
+	t.Run("cooldown", func(t *testing.T) {
+		require.NoError(t, ln2.Close())
+
+		// ln2 fails and falls back to ln1
+		for i := 0; i < 4; i++ {
+			require.Equal(t, ln1.Addr().String(), dialTo())
+		}
+
+		// ln2 is back, but still in cooldown
+		ln2, accepted2 := newTestDNSListener(t, addr2)
+		defer ln2.Close()
+		for i := 0; i < 4; i++ {
+			require.Equal(t, ln1.Addr().String(), dialTo())
+		}
+		require.Empty(t, accepted2)
+
+		time.Sleep(350 * time.Millisecond)
+		var got []string
+		for i := 0; i < 2; i++ {
+			got = append(got, dialTo())
+		}
+		require.Contains(t, got, addr2)
+	})
+}
+
+func TestNewRoundRobinDialer_allFailed(t *testing.T) {
+	t.Parallel()
+
+	var addrs []string
+	for i := 0; i < 2; i++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addrs = append(addrs, ln.Addr().String())
+		require.NoError(t, ln.Close())
+	}
+
+	dial, err := NewRoundRobinDialer(addrs)
+	require.NoError(t, err)
+
+	_, err = dial(context.Background(), "tcp", "")
+	require.Error(t, err)
+	for _, addr := range addrs {
+		require.ErrorContains(t, err, addr)
+	}
+
+	// all addresses are cooling down, but still tried
+	_, err = dial(context.Background(), "tcp", "")
+	require.Error(t, err)
+	for _, addr := range addrs {
+		require.ErrorContains(t, err, addr)
+	}
+}
@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"encoding/base32"
+	"strings"
+
+	"github.com/Laisky/errors/v2"
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var base58Index = func() (idx [256]int8) {
+	for i := range idx {
+		idx[i] = -1
+	}
+	for i := 0; i < len(base58Alphabet); i++ {
+		idx[base58Alphabet[i]] = int8(i)
+	}
+
+	return idx
+}()
+
+// EncodeByBase58 encode bytes to string by base58 with bitcoin alphabet,
+// each leading zero byte is encoded as `1`.
+func EncodeByBase58(raw []byte) string {
+	zeros := 0
+	for zeros < len(raw) && raw[zeros] == 0 {
+		zeros++
+	}
+
+	// log(256) / log(58) ≈ 1.37
+	digits := make([]byte, 0, (len(raw)-zeros)*138/100+1)
+	for _, b := range raw[zeros:] {
+		carry := int(b)
+		for i := range digits {
+			carry += int(digits[i]) << 8
+			digits[i] = byte(carry % 58)
+			carry /= 58
+		}
+		for carry > 0 {
+			digits = append(digits, byte(carry%58))
+			carry /= 58
+		}
+	}
+
+	out := make([]byte, zeros+len(digits))
+	for i := 0; i < zeros; i++ {
+		out[i] = '1'
+	}
+	for i, d := range digits {
+		out[len(out)-1-i] = base58Alphabet[d]
+	}
+
+	return string(out)
+}
+
+// DecodeByBase58 decode base58 string encoded by EncodeByBase58
+func DecodeByBase58(s string) ([]byte, error) {
+	zeros := 0
+	for zeros < len(s) && s[zeros] == '1' {
+		zeros++
+	}
+
+	// log(58) / log(256) ≈ 0.733
+	bs := make([]byte, 0, (len(s)-zeros)*733/1000+1)
+	for i := zeros; i < len(s); i++ {
+		v := base58Index[s[i]]
+		if v < 0 {
+			return nil, errors.Errorf("invalid base58 character %q at %d", s[i], i)
+		}
+
+		carry := int(v)
+		for j := range bs {
+			carry += int(bs[j]) * 58
+			bs[j] = byte(carry)
+			carry >>= 8
+		}
+		for carry > 0 {
+			bs = append(bs, byte(carry))
+			carry >>= 8
+		}
+	}
+
+	out := make([]byte, zeros+len(bs))
+	for i, b := range bs {
+		out[len(out)-1-i] = b
+	}
+
+	return out, nil
+}
+
+const base32CrockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var base32Crockford = base32.NewEncoding(base32CrockfordAlphabet).WithPadding(base32.NoPadding)
+
+// EncodeByBase32Crockford encode bytes to string by Crockford's base32
+// in upper case without padding, see https://www.crockford.com/base32.html
+func EncodeByBase32Crockford(raw []byte) string {
+	return base32Crockford.EncodeToString(raw)
+}
+
+// DecodeByBase32Crockford decode Crockford's base32 string.
+//
+// it is case-insensitive, `O` is read as `0`, `I` and `L` are read as `1`,
+// and hyphens are ignored.
+func DecodeByBase32Crockford(s string) ([]byte, error) {
+	var normalized strings.Builder
+	normalized.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+
+		switch c {
+		case '-':
+			continue
+		case 'O':
+			c = '0'
+		case 'I', 'L':
+			c = '1'
+		}
+
+		if strings.IndexByte(base32CrockfordAlphabet, c) < 0 {
+			return nil, errors.Errorf("invalid base32 character %q at %d", s[i], i)
+		}
+
+		normalized.WriteByte(c)
+	}
+
+	// 1, 3 or 6 trailing symbols can not form whole bytes
+	switch normalized.Len() % 8 {
+	case 1, 3, 6:
+		return nil, errors.Errorf("invalid base32 length %d", normalized.Len())
+	}
+
+	raw, err := base32Crockford.DecodeString(normalized.String())
+	if err != nil {
+		return nil, errors.Wrapf(err, "decode %q", s)
+	}
+
+	return raw, nil
+}
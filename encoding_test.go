@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"encoding/hex"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBase58(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		raw     string
+		encoded string
+	}{
+		{"", ""},
+		{"\x00", "1"},
+		{"Hello World!", "2NEpo7TZRRrLZSi2U"},
+		{"The quick brown fox jumps over the lazy dog.",
+			"USm3fpXnKG5EUBx2ndxBDMPVciP5hGey2Jh4NDv6gmeo1LkMeiKrLJUUBk6Z"},
+		{"\x00\x00\x28\x7f\xb4\xcd", "11233QC4"},
+	} {
+		require.Equal(t, tt.encoded, EncodeByBase58([]byte(tt.raw)), tt.raw)
+
+		got, err := DecodeByBase58(tt.encoded)
+		require.NoError(t, err, tt.encoded)
+		require.Equal(t, []byte(tt.raw), got, tt.encoded)
+	}
+
+	for _, s := range []string{"0", "O", "I", "l", "2NEpo7TZRRrL+Si2U"} {
+		_, err := DecodeByBase58(s)
+		require.ErrorContains(t, err, "invalid base58 character", s)
+	}
+
+	_, err := DecodeByBase58("2NEpo7TZRRrL+Si2U")
+	require.ErrorContains(t, err, "at 12")
+}
+
+func TestBase32Crockford(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		raw     string
+		encoded string
+	}{
+		{"", ""},
+		{"f", "CR"},
+		{"fo", "CSQG"},
+		{"foo", "CSQPY"},
+		{"foob", "CSQPYRG"},
+		{"fooba", "CSQPYRK1"},
+		{"foobar", "CSQPYRK1E8"},
+		{"\x00\x00\xff", "000FY"},
+	} {
+		require.Equal(t, tt.encoded, EncodeByBase32Crockford([]byte(tt.raw)), tt.raw)
+
+		got, err := DecodeByBase32Crockford(tt.encoded)
+		require.NoError(t, err, tt.encoded)
+		require.Equal(t, []byte(tt.raw), got, tt.encoded)
+	}
+
+	t.Run("read aloud", func(t *testing.T) {
+		for _, s := range []string{"csqpyrk1e8", "CSQP-YRKI-E8", "csqpyrkle8"} {
+			got, err := DecodeByBase32Crockford(s)
+			require.NoError(t, err, s)
+			require.Equal(t, "foobar", string(got), s)
+		}
+
+		got, err := DecodeByBase32Crockford("ooofy")
+		require.NoError(t, err)
+		require.Equal(t, []byte{0, 0, 0xff}, got)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := DecodeByBase32Crockford("CSQPU")
+		require.ErrorContains(t, err, `invalid base32 character 'U' at 4`)
+		_, err = DecodeByBase32Crockford("CSQ=")
+		require.ErrorContains(t, err, "at 3")
+		_, err = DecodeByBase32Crockford("C")
+		require.Error(t, err)
+	})
+}
+
+func TestEncodingRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	rnd := rand.New(rand.NewSource(1)) //nolint:gosec
+	for i := 0; i < 1000; i++ {
+		raw := make([]byte, rnd.Intn(64))
+		rnd.Read(raw)
+		// leading zeros
+		for j := 0; j < len(raw) && j < rnd.Intn(4); j++ {
+			raw[j] = 0
+		}
+
+		got, err := DecodeByBase58(EncodeByBase58(raw))
+		require.NoError(t, err)
+		require.Equal(t, raw, got, hex.EncodeToString(raw))
+
+		got, err = DecodeByBase32Crockford(EncodeByBase32Crockford(raw))
+		require.NoError(t, err)
+		require.Equal(t, raw, got, hex.EncodeToString(raw))
+	}
+}
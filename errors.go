@@ -1,8 +1,47 @@
 package utils
 
-import "fmt"
+import (
+	"fmt"
+	"runtime/debug"
+)
 
 // Wrap wrap error without stack
 func Wrap(err error, msg string) error {
 	return fmt.Errorf("%s: %w", msg, err)
 }
+
+// PanicError error of recovered panic, returned by IsPanic2
+type PanicError struct {
+	// Value the recovered value
+	Value any
+	// Stack stack of the panicking goroutine, captured by debug.Stack
+	Stack []byte
+}
+
+// newPanicError should be called in the deferred func that recovers
+func newPanicError(val any) *PanicError {
+	return &PanicError{Value: val, Stack: debug.Stack()}
+}
+
+// Error return `panic: <value>`, without stack
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap return the recovered value if it is an error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Format print stack with `%+v`
+func (e *PanicError) Format(s fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && s.Flag('+'):
+		fmt.Fprintf(s, "%s\n%s", e.Error(), e.Stack)
+	case verb == 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	default:
+		fmt.Fprint(s, e.Error())
+	}
+}
@@ -0,0 +1,233 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/Laisky/errors/v2"
+)
+
+// ErrEventBusClosed event bus is closed
+var ErrEventBusClosed = errors.New("event bus closed")
+
+// EventDropPolicy what to drop when the buffer of subscriber is full
+type EventDropPolicy uint8
+
+const (
+	// EventDropNew drop the event being published, keep the buffered ones
+	EventDropNew EventDropPolicy = iota
+	// EventDropOldest drop the oldest buffered event to make room for the new one
+	EventDropOldest
+)
+
+type eventSubscribeOption struct {
+	policy EventDropPolicy
+}
+
+// EventSubscribeOption options for Subscribe
+type EventSubscribeOption func(*eventSubscribeOption)
+
+// WithEventDropPolicy set drop policy when buffer is full, default is EventDropNew
+func WithEventDropPolicy(policy EventDropPolicy) EventSubscribeOption {
+	return func(o *eventSubscribeOption) {
+		o.policy = policy
+	}
+}
+
+type eventSubscriber interface {
+	close()
+}
+
+type eventSub[T any] struct {
+	// mu guards closed and sending to ch
+	mu     sync.Mutex
+	closed bool
+	ch     chan T
+	policy EventDropPolicy
+}
+
+// deliver send ev to ch without blocking
+func (s *eventSub[T]) deliver(ev T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.ch <- ev:
+		return
+	default:
+	}
+
+	if s.policy != EventDropOldest {
+		return
+	}
+
+	// only this goroutine sends under lock, so there is room after one receive
+	select {
+	case <-s.ch:
+	default:
+	}
+	select {
+	case s.ch <- ev:
+	default:
+	}
+}
+
+func (s *eventSub[T]) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+type eventTopic struct {
+	typ    reflect.Type
+	nextID uint64
+	subs   map[uint64]eventSubscriber
+}
+
+// EventBus in-process pub/sub by topics, create by NewEventBus.
+//
+// each topic carries events of one type, decided by the first Subscribe
+// on the topic. topic is removed when its last subscriber leaves,
+// so it can carry another type after that.
+type EventBus struct {
+	mu     sync.Mutex
+	closed bool
+	topics map[string]*eventTopic
+}
+
+// NewEventBus new event bus
+func NewEventBus() *EventBus {
+	return &EventBus{
+		topics: map[string]*eventTopic{},
+	}
+}
+
+// topicLocked get or create topic with type typ, should be called with lock held.
+// only Subscribe creates topics.
+func (b *EventBus) topicLocked(name string, typ reflect.Type) (*eventTopic, error) {
+	topic, ok := b.topics[name]
+	if !ok {
+		topic = &eventTopic{
+			typ:  typ,
+			subs: map[uint64]eventSubscriber{},
+		}
+		b.topics[name] = topic
+	}
+
+	if topic.typ != typ {
+		return nil, errors.Errorf("topic %q carries events of %s, got %s", name, topic.typ, typ)
+	}
+
+	return topic, nil
+}
+
+// Subscribe subscribe events of topic, return the channel of events
+// and the func to unsubscribe, which closes the channel and is safe to call multiple times.
+//
+// the channel has buffer size buffer, at least 1, events are dropped
+// by policy when the buffer is full, so a slow subscriber never blocks publishers.
+// the channel is closed by unsubscribe or bus.Close.
+//
+// panic if topic carries events of another type.
+func Subscribe[T any](bus *EventBus, topic string, buffer int,
+	opts ...EventSubscribeOption) (<-chan T, func()) {
+	opt := new(eventSubscribeOption)
+	for _, f := range opts {
+		f(opt)
+	}
+
+	sub := &eventSub[T]{
+		ch:     make(chan T, max(buffer, 1)),
+		policy: opt.policy,
+	}
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	if bus.closed {
+		sub.close()
+		return sub.ch, func() {}
+	}
+
+	t, err := bus.topicLocked(topic, reflect.TypeFor[T]())
+	if err != nil {
+		panic(fmt.Sprintf("subscribe: %v", err))
+	}
+
+	id := t.nextID
+	t.nextID++
+	t.subs[id] = sub
+
+	return sub.ch, func() {
+		bus.mu.Lock()
+		delete(t.subs, id)
+		if len(t.subs) == 0 && bus.topics[topic] == t {
+			delete(bus.topics, topic)
+		}
+		bus.mu.Unlock()
+
+		sub.close()
+	}
+}
+
+// Publish send ev to all subscribers of topic without blocking,
+// topic without subscribers is a no-op.
+//
+// return error if topic carries events of another type, or bus is closed.
+func Publish[T any](bus *EventBus, topic string, ev T) error {
+	bus.mu.Lock()
+	if bus.closed {
+		bus.mu.Unlock()
+		return ErrEventBusClosed
+	}
+
+	t, ok := bus.topics[topic]
+	if !ok {
+		bus.mu.Unlock()
+		return nil
+	}
+	if typ := reflect.TypeFor[T](); t.typ != typ {
+		bus.mu.Unlock()
+		return errors.Errorf("publish: topic %q carries events of %s, got %s", topic, t.typ, typ)
+	}
+
+	subs := make([]*eventSub[T], 0, len(t.subs))
+	for _, sub := range t.subs {
+		subs = append(subs, sub.(*eventSub[T]))
+	}
+	bus.mu.Unlock()
+
+	// closed subscribers are skipped by deliver
+	for _, sub := range subs {
+		sub.deliver(ev)
+	}
+
+	return nil
+}
+
+// Close close all subscriptions, later Publish returns ErrEventBusClosed
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.closed = true
+	for _, topic := range b.topics {
+		for _, sub := range topic.subs {
+			sub.close()
+		}
+	}
+	b.topics = nil
+}
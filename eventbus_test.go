@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBus(t *testing.T) {
+	t.Parallel()
+
+	t.Run("publish", func(t *testing.T) {
+		bus := NewEventBus()
+		defer bus.Close()
+
+		ch1, unsub1 := Subscribe[int](bus, "num", 10)
+		defer unsub1()
+		ch2, unsub2 := Subscribe[int](bus, "num", 10)
+		defer unsub2()
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, Publish(bus, "num", i))
+		}
+		for i := 0; i < 3; i++ {
+			require.Equal(t, i, <-ch1)
+			require.Equal(t, i, <-ch2)
+		}
+
+		// topic without subscribers
+		require.NoError(t, Publish(bus, "other", "hello"))
+	})
+
+	t.Run("type mismatch", func(t *testing.T) {
+		bus := NewEventBus()
+		defer bus.Close()
+
+		_, unsub := Subscribe[int](bus, "num", 1)
+		defer unsub()
+
+		err := Publish(bus, "num", "1")
+		require.ErrorContains(t, err, `topic "num" carries events of int, got string`)
+
+		require.PanicsWithValue(t,
+			`subscribe: topic "num" carries events of int, got string`,
+			func() { Subscribe[string](bus, "num", 1) })
+
+		// topic is removed after all subscribers left
+		unsub()
+		require.NoError(t, Publish(bus, "num", int64(1)))
+		_, unsub = Subscribe[string](bus, "num", 1)
+		unsub()
+	})
+
+	t.Run("no leaked topics", func(t *testing.T) {
+		bus := NewEventBus()
+		defer bus.Close()
+
+		for i := 0; i < 100; i++ {
+			require.NoError(t, Publish(bus, fmt.Sprintf("topic-%d", i), i))
+		}
+		require.Empty(t, bus.topics)
+
+		_, unsub1 := Subscribe[int](bus, "num", 1)
+		_, unsub2 := Subscribe[int](bus, "num", 1)
+		unsub1()
+		require.Len(t, bus.topics, 1)
+		unsub2()
+		unsub1()
+		require.Empty(t, bus.topics)
+	})
+
+	t.Run("drop new", func(t *testing.T) {
+		bus := NewEventBus()
+		defer bus.Close()
+
+		ch, unsub := Subscribe[int](bus, "num", 2)
+		defer unsub()
+		for i := 0; i < 5; i++ {
+			require.NoError(t, Publish(bus, "num", i))
+		}
+
+		require.Equal(t, 0, <-ch)
+		require.Equal(t, 1, <-ch)
+		require.Empty(t, ch)
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		bus := NewEventBus()
+		defer bus.Close()
+
+		ch, unsub := Subscribe[int](bus, "num", 2, WithEventDropPolicy(EventDropOldest))
+		defer unsub()
+		for i := 0; i < 5; i++ {
+			require.NoError(t, Publish(bus, "num", i))
+		}
+
+		require.Equal(t, 3, <-ch)
+		require.Equal(t, 4, <-ch)
+		require.Empty(t, ch)
+	})
+
+	t.Run("slow subscriber", func(t *testing.T) {
+		bus := NewEventBus()
+		defer bus.Close()
+
+		slow, unsubSlow := Subscribe[int](bus, "num", 1)
+		defer unsubSlow()
+		fast, unsubFast := Subscribe[int](bus, "num", 1000)
+		defer unsubFast()
+
+		// never read from slow, publish should not block
+		for i := 0; i < 1000; i++ {
+			require.NoError(t, Publish(bus, "num", i))
+		}
+
+		require.Len(t, slow, 1)
+		require.Len(t, fast, 1000)
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		bus := NewEventBus()
+		defer bus.Close()
+
+		ch, unsub := Subscribe[int](bus, "num", 1)
+		unsub()
+		unsub()
+
+		_, ok := <-ch
+		require.False(t, ok)
+		require.NoError(t, Publish(bus, "num", 1))
+	})
+
+	t.Run("close", func(t *testing.T) {
+		bus := NewEventBus()
+		ch, unsub := Subscribe[int](bus, "num", 1)
+
+		bus.Close()
+		bus.Close()
+		unsub()
+
+		_, ok := <-ch
+		require.False(t, ok)
+		require.ErrorIs(t, Publish(bus, "num", 1), ErrEventBusClosed)
+
+		ch, unsub = Subscribe[int](bus, "num", 1)
+		unsub()
+		_, ok = <-ch
+		require.False(t, ok)
+	})
+}
+
+func TestEventBusRace(t *testing.T) {
+	t.Parallel()
+
+	bus := NewEventBus()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if err := Publish(bus, "num", j); err != nil {
+					require.ErrorIs(t, err, ErrEventBusClosed)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				ch, unsub := Subscribe[int](bus, "num", 4,
+					WithEventDropPolicy(EventDropPolicy(i%2)))
+				select {
+				case <-ch:
+				default:
+				}
+				unsub()
+			}
+		}(i)
+	}
+
+	// subscriber that stays until bus closed
+	ch, _ := Subscribe[int](bus, "num", 16)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range ch {
+		}
+	}()
+
+	bus.Close()
+	wg.Wait()
+}
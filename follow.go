@@ -0,0 +1,300 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Laisky/errors/v2"
+	"github.com/Laisky/zap"
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/Laisky/go-utils/v4/log"
+)
+
+const (
+	defaultFollowFileDebounce = 50 * time.Millisecond
+	// defaultFollowFilePollInterval used when fsnotify is not available,
+	// and as a safety net when it is, in case of missed events
+	defaultFollowFilePollInterval = time.Second
+	followFileReadSize            = 32 * 1024
+)
+
+type followFileOption struct {
+	pollInterval time.Duration
+	debounce     time.Duration
+}
+
+// FollowFileOption options for FollowFile
+type FollowFileOption func(*followFileOption) error
+
+// WithFollowFilePolling check file every interval instead of watching by fsnotify
+func WithFollowFilePolling(interval time.Duration) FollowFileOption {
+	return func(o *followFileOption) error {
+		if interval <= 0 {
+			return errors.Errorf("interval should greater than 0, got %s", interval)
+		}
+
+		o.pollInterval = interval
+		return nil
+	}
+}
+
+// WithFollowFileDebounce wait d after the first fsnotify event before reading,
+// events during d are merged into one read. default is 50ms.
+func WithFollowFileDebounce(d time.Duration) FollowFileOption {
+	return func(o *followFileOption) error {
+		if d < 0 {
+			return errors.Errorf("debounce should not be negative, got %s", d)
+		}
+
+		o.debounce = d
+		return nil
+	}
+}
+
+// FollowFile call handler with every new line appended to path, like `tail -F`,
+// lines are without line endings. it blocks until ctx is done, then returns nil.
+//
+// if fromEnd is true, existing content is skipped. if path does not exist,
+// it waits for path to be created and reads from start.
+//
+// file is watched by fsnotify on its directory, falls back to polling
+// if fsnotify is not available or WithFollowFilePolling is set.
+// when file is truncated, it reads from start again;
+// when file is renamed or removed and recreated, like by logrotate,
+// the rest of the old file is read before switching to the new file from start.
+func FollowFile(ctx context.Context, path string, fromEnd bool,
+	handler func(line string), opts ...FollowFileOption) error {
+	opt := &followFileOption{debounce: defaultFollowFileDebounce}
+	for _, f := range opts {
+		if err := f(opt); err != nil {
+			return errors.Wrap(err, "apply option")
+		}
+	}
+
+	if handler == nil {
+		return errors.New("handler should not be nil")
+	}
+
+	path = filepath.Clean(path)
+	follower := &fileFollower{
+		path:    path,
+		handler: handler,
+		buf:     make([]byte, followFileReadSize),
+	}
+	defer follower.closeFile()
+
+	if err := follower.open(fromEnd); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	var (
+		events    <-chan fsnotify.Event
+		watchErrs <-chan error
+	)
+	pollInterval := opt.pollInterval
+	if pollInterval == 0 {
+		pollInterval = defaultFollowFilePollInterval
+
+		watcher, err := fsnotify.NewWatcher()
+		if err == nil {
+			if err = watcher.Add(filepath.Dir(path)); err != nil {
+				LogErr(watcher.Close, log.Shared)
+			}
+		}
+
+		if err != nil {
+			log.Shared.Warn("fsnotify not available, fallback to polling",
+				zap.String("file", path), zap.Error(err))
+		} else {
+			defer LogErr(watcher.Close, log.Shared)
+			events, watchErrs = watcher.Events, watcher.Errors
+		}
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	var (
+		timer   *time.Timer
+		pending <-chan time.Time
+	)
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	if err := follower.check(); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if filepath.Clean(ev.Name) != path || pending != nil {
+				continue
+			}
+
+			timer = time.NewTimer(opt.debounce)
+			pending = timer.C
+			continue
+		case err, ok := <-watchErrs:
+			if !ok {
+				watchErrs = nil
+				continue
+			}
+
+			log.Shared.Warn("watch file", zap.String("file", path), zap.Error(err))
+			continue
+		case <-pending:
+			pending = nil
+		case <-ticker.C:
+		}
+
+		if err := follower.check(); err != nil {
+			return err
+		}
+	}
+}
+
+// fileFollower read new lines of file, used by FollowFile
+type fileFollower struct {
+	path    string
+	handler func(line string)
+	buf     []byte
+
+	fp     *os.File
+	info   os.FileInfo
+	offset int64
+	// partial incomplete line at the end of file
+	partial []byte
+}
+
+func (f *fileFollower) open(fromEnd bool) error {
+	fp, err := os.Open(f.path)
+	if err != nil {
+		return errors.Wrapf(err, "open file %q", f.path)
+	}
+
+	info, err := fp.Stat()
+	if err != nil {
+		LogErr(fp.Close, log.Shared)
+		return errors.Wrapf(err, "stat file %q", f.path)
+	}
+
+	var offset int64
+	if fromEnd {
+		if offset, err = fp.Seek(0, io.SeekEnd); err != nil {
+			LogErr(fp.Close, log.Shared)
+			return errors.Wrapf(err, "seek file %q", f.path)
+		}
+	}
+
+	f.fp, f.info, f.offset, f.partial = fp, info, offset, f.partial[:0]
+	return nil
+}
+
+func (f *fileFollower) closeFile() {
+	if f.fp != nil {
+		LogErr(f.fp.Close, log.Shared)
+		f.fp = nil
+	}
+}
+
+// drain read to the end of current file
+func (f *fileFollower) drain() error {
+	if f.fp == nil {
+		return nil
+	}
+
+	for {
+		n, err := f.fp.Read(f.buf)
+		if n > 0 {
+			f.offset += int64(n)
+			f.emit(f.buf[:n])
+		}
+
+		if errors.Is(err, io.EOF) || (err == nil && n == 0) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "read file %q", f.path)
+		}
+	}
+}
+
+// emit call handler with complete lines in data, keep the incomplete one
+func (f *fileFollower) emit(data []byte) {
+	f.partial = append(f.partial, data...)
+
+	rest := f.partial
+	for {
+		idx := bytes.IndexByte(rest, '\n')
+		if idx < 0 {
+			break
+		}
+
+		f.handler(string(bytes.TrimSuffix(rest[:idx], []byte("\r"))))
+		rest = rest[idx+1:]
+	}
+
+	f.partial = append(f.partial[:0], rest...)
+}
+
+// check detect truncation and rotation, then read new lines
+func (f *fileFollower) check() error {
+	info, err := os.Stat(f.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		// removed or renamed, and not recreated yet
+		return f.drain()
+	case err != nil:
+		return errors.Wrapf(err, "stat file %q", f.path)
+	}
+
+	if f.fp == nil || !os.SameFile(f.info, info) {
+		if err = f.drain(); err != nil {
+			return err
+		}
+
+		// the last line of rotated file may not end with line break,
+		// clear it before reopen, which may fail and be retried later
+		if len(f.partial) != 0 {
+			f.handler(string(bytes.TrimSuffix(f.partial, []byte("\r"))))
+			f.partial = f.partial[:0]
+		}
+
+		f.closeFile()
+		if err = f.open(false); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+
+		return f.drain()
+	}
+
+	if info.Size() < f.offset {
+		// truncated, incomplete line before truncation is dropped
+		if _, err = f.fp.Seek(0, io.SeekStart); err != nil {
+			return errors.Wrapf(err, "seek file %q", f.path)
+		}
+
+		f.offset, f.partial = 0, f.partial[:0]
+	}
+
+	return f.drain()
+}
@@ -0,0 +1,152 @@
+//go:build !windows
+// +build !windows
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type followTestLines struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *followTestLines) add(line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, line)
+}
+
+func (l *followTestLines) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.lines...)
+}
+
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	fp, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	require.NoError(t, err)
+	_, err = fp.WriteString(content)
+	require.NoError(t, err)
+	require.NoError(t, fp.Close())
+}
+
+func TestFollowFile(t *testing.T) {
+	t.Parallel()
+
+	for name, opts := range map[string][]FollowFileOption{
+		"fsnotify": {WithFollowFileDebounce(10 * time.Millisecond)},
+		"polling":  {WithFollowFilePolling(10 * time.Millisecond)},
+	} {
+		opts := opts
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			path := filepath.Join(dir, "app.log")
+			appendFile(t, path, "existing\n")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			got := new(followTestLines)
+			done := make(chan error)
+			go func() {
+				done <- FollowFile(ctx, path, true, got.add, opts...)
+			}()
+
+			var expect []string
+			waitLines := func() {
+				t.Helper()
+				require.Eventually(t, func() bool {
+					return len(got.get()) >= len(expect)
+				}, 5*time.Second, time.Millisecond)
+				require.Equal(t, expect, got.get())
+			}
+
+			// wait for follower to start
+			time.Sleep(50 * time.Millisecond)
+
+			// append with burst and partial lines
+			for i := 0; i < 1000; i++ {
+				appendFile(t, path, fmt.Sprintf("line-%d\r\n", i))
+				expect = append(expect, fmt.Sprintf("line-%d", i))
+			}
+			appendFile(t, path, "par")
+			time.Sleep(30 * time.Millisecond)
+			appendFile(t, path, "tial\n")
+			expect = append(expect, "partial")
+			waitLines()
+
+			// truncate
+			require.NoError(t, os.Truncate(path, 0))
+			appendFile(t, path, "after-truncate\n")
+			expect = append(expect, "after-truncate")
+			waitLines()
+
+			// rename and recreate, write to old file after rename
+			old, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+			require.NoError(t, err)
+			require.NoError(t, os.Rename(path, path+".1"))
+			_, err = old.WriteString("old-after-rename\n")
+			require.NoError(t, err)
+			require.NoError(t, old.Close())
+			expect = append(expect, "old-after-rename")
+			waitLines()
+
+			appendFile(t, path, "new-1\nnew-2\n")
+			expect = append(expect, "new-1", "new-2")
+			waitLines()
+
+			// remove and recreate
+			require.NoError(t, os.Remove(path))
+			time.Sleep(50 * time.Millisecond)
+			appendFile(t, path, "recreated\n")
+			expect = append(expect, "recreated")
+			waitLines()
+
+			cancel()
+			require.NoError(t, <-done)
+			require.Equal(t, expect, got.get())
+		})
+	}
+}
+
+func TestFollowFileNotExist(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	got := new(followTestLines)
+	done := make(chan error)
+	go func() {
+		done <- FollowFile(ctx, path, true, got.add, WithFollowFilePolling(10*time.Millisecond))
+	}()
+
+	time.Sleep(30 * time.Millisecond)
+	appendFile(t, path, "first\nsecond\n")
+	require.Eventually(t, func() bool { return len(got.get()) == 2 },
+		5*time.Second, time.Millisecond)
+	require.Equal(t, []string{"first", "second"}, got.get())
+
+	cancel()
+	require.NoError(t, <-done)
+
+	ctx = context.Background()
+	require.Error(t, FollowFile(ctx, path, true, nil))
+	require.Error(t, FollowFile(ctx, path, true, got.add, WithFollowFilePolling(0)))
+	require.Error(t, FollowFile(ctx, path, true, got.add, WithFollowFileDebounce(-1)))
+}
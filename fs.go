@@ -9,11 +9,15 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/Laisky/errors/v2"
 	"github.com/Laisky/zap"
@@ -457,6 +461,177 @@ func NewTmpFile(reader io.Reader) (*os.File, error) {
 	return tmpFile, nil
 }
 
+type tmpFileOption struct {
+	dir     string
+	pattern string
+	content []byte
+	perm    os.FileMode
+}
+
+// TmpFileOption options for NewTmpFilePath and NewTmpDir
+type TmpFileOption func(*tmpFileOption) error
+
+// WithTmpDir create in dir, default is os.TempDir()
+func WithTmpDir(dir string) TmpFileOption {
+	return func(o *tmpFileOption) error {
+		o.dir = dir
+		return nil
+	}
+}
+
+// WithTmpPattern name pattern like `cfg-*.yaml`, the last `*` is replaced
+// by random string, so the extension is preserved. see os.CreateTemp
+func WithTmpPattern(pattern string) TmpFileOption {
+	return func(o *tmpFileOption) error {
+		if strings.ContainsRune(pattern, os.PathSeparator) {
+			return errors.Errorf("pattern %q should not contain path separator", pattern)
+		}
+
+		o.pattern = pattern
+		return nil
+	}
+}
+
+// WithTmpContent write content to tmp file, only for NewTmpFilePath
+func WithTmpContent(content []byte) TmpFileOption {
+	return func(o *tmpFileOption) error {
+		o.content = content
+		return nil
+	}
+}
+
+// WithTmpPerm set permission, default is 0600 for file and 0700 for dir
+func WithTmpPerm(perm os.FileMode) TmpFileOption {
+	return func(o *tmpFileOption) error {
+		if perm&^os.ModePerm != 0 {
+			return errors.Errorf("invalid permission %s", perm)
+		}
+
+		o.perm = perm
+		return nil
+	}
+}
+
+func newTmpFileOption(defaultPerm os.FileMode, opts ...TmpFileOption) (*tmpFileOption, error) {
+	opt := &tmpFileOption{perm: defaultPerm}
+	for _, f := range opts {
+		if err := f(opt); err != nil {
+			return nil, errors.Wrap(err, "apply option")
+		}
+	}
+
+	return opt, nil
+}
+
+// tmpCleanup return idempotent cleanup that removes path recursively
+func tmpCleanup(path string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if err := os.RemoveAll(path); err != nil {
+				log.Shared.Warn("remove tmp path", zap.String("path", path), zap.Error(err))
+			}
+		})
+	}
+}
+
+// NewTmpFilePath create tmp file and return its path,
+// cleanup removes the file and is safe to call multiple times.
+//
+// the permission is set by chmod after creation, so it is not affected by umask.
+func NewTmpFilePath(opts ...TmpFileOption) (path string, cleanup func(), err error) {
+	opt, err := newTmpFileOption(0600, opts...)
+	if err != nil {
+		return "", nil, err
+	}
+
+	fp, err := os.CreateTemp(opt.dir, opt.pattern)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "create tmp file")
+	}
+	path = fp.Name()
+	cleanup = tmpCleanup(path)
+
+	if err = func() error {
+		defer LogErr(fp.Close, log.Shared)
+		if err := fp.Chmod(opt.perm); err != nil {
+			return errors.Wrapf(err, "chmod %q", path)
+		}
+
+		if _, err := fp.Write(opt.content); err != nil {
+			return errors.Wrapf(err, "write to %q", path)
+		}
+
+		return nil
+	}(); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return path, cleanup, nil
+}
+
+// NewTmpDir create tmp dir and return its path,
+// cleanup removes the dir recursively and is safe to call multiple times.
+//
+// the permission is set by chmod after creation, so it is not affected by umask.
+func NewTmpDir(opts ...TmpFileOption) (dir string, cleanup func(), err error) {
+	opt, err := newTmpFileOption(0700, opts...)
+	if err != nil {
+		return "", nil, err
+	}
+	if opt.content != nil {
+		return "", nil, errors.New("content is not supported for tmp dir")
+	}
+
+	if dir, err = os.MkdirTemp(opt.dir, opt.pattern); err != nil {
+		return "", nil, errors.Wrap(err, "create tmp dir")
+	}
+	cleanup = tmpCleanup(dir)
+
+	if err = os.Chmod(dir, opt.perm); err != nil {
+		cleanup()
+		return "", nil, errors.Wrapf(err, "chmod %q", dir)
+	}
+
+	return dir, cleanup, nil
+}
+
+// WriteFileAtomicTemp write data to a new hidden tmp file with 0600 in dir,
+// and fsync it before return, so the caller can rename it to the final path.
+//
+// the tmp file is removed if any error occurs.
+func WriteFileAtomicTemp(dir string, data []byte) (path string, err error) {
+	fp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return "", errors.Wrap(err, "create tmp file")
+	}
+	path = fp.Name()
+
+	err = func() error {
+		if err := fp.Chmod(0600); err != nil {
+			return errors.Wrapf(err, "chmod %q", path)
+		}
+		if _, err := fp.Write(data); err != nil {
+			return errors.Wrapf(err, "write to %q", path)
+		}
+		if err := fp.Sync(); err != nil {
+			return errors.Wrapf(err, "sync %q", path)
+		}
+
+		return nil
+	}()
+	if closeErr := fp.Close(); err == nil && closeErr != nil {
+		err = errors.Wrapf(closeErr, "close %q", path)
+	}
+	if err != nil {
+		LogErr(func() error { return os.Remove(path) }, log.Shared)
+		return "", err
+	}
+
+	return path, nil
+}
+
 // WatchFileChanging watch file changing
 //
 // when file changed, callback will be called,
@@ -529,3 +704,219 @@ func RenderTemplateFile(tplFile string, args any) ([]byte, error) {
 
 	return RenderTemplate(string(cnt), args)
 }
+
+const sniffLen = 512
+
+type magicMIME struct {
+	offset    int
+	hasPrefix func([]byte) bool
+	mime      string
+	// riff is true if the file must be a RIFF container
+	riff bool
+}
+
+var hasRIFFPrefix = NewHasPrefixWithMagic([]byte("RIFF"))
+
+// magicMIMEs types that not (or not precisely) recognized by http.DetectContentType
+var magicMIMEs = []magicMIME{
+	{offset: 0, hasPrefix: NewHasPrefixWithMagic([]byte("PK\x03\x04")), mime: "application/zip"},
+	{offset: 0, hasPrefix: NewHasPrefixWithMagic([]byte("PK\x05\x06")), mime: "application/zip"}, // empty archive
+	{offset: 0, hasPrefix: NewHasPrefixWithMagic([]byte("\x1f\x8b")), mime: "application/gzip"},
+	{offset: 0, hasPrefix: NewHasPrefixWithMagic([]byte("%PDF-")), mime: "application/pdf"},
+	{offset: 8, hasPrefix: NewHasPrefixWithMagic([]byte("WEBP")), mime: "image/webp", riff: true},
+	{offset: 4, hasPrefix: NewHasPrefixWithMagic([]byte("ftypheic")), mime: "image/heic"},
+	{offset: 4, hasPrefix: NewHasPrefixWithMagic([]byte("ftypheix")), mime: "image/heic"},
+	{offset: 4, hasPrefix: NewHasPrefixWithMagic([]byte("ftyphevc")), mime: "image/heic-sequence"},
+	{offset: 4, hasPrefix: NewHasPrefixWithMagic([]byte("ftyphevx")), mime: "image/heic-sequence"},
+	{offset: 4, hasPrefix: NewHasPrefixWithMagic([]byte("ftypmif1")), mime: "image/heif"},
+	{offset: 4, hasPrefix: NewHasPrefixWithMagic([]byte("ftypmsf1")), mime: "image/heif-sequence"},
+}
+
+// DetectContentType detect mime type by the first 512 bytes of r,
+// r will be seeked back to the original offset.
+//
+// besides the types recognized by http.DetectContentType,
+// zip, gzip, pdf, webp and heic/heif are recognized by magic numbers.
+func DetectContentType(r io.ReadSeeker) (mime string, err error) {
+	offset, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", errors.Wrap(err, "get current offset")
+	}
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", errors.Wrap(err, "read head")
+	}
+	head = head[:n]
+
+	if _, err = r.Seek(offset, io.SeekStart); err != nil {
+		return "", errors.Wrap(err, "seek back")
+	}
+
+	for _, m := range magicMIMEs {
+		if len(head) <= m.offset ||
+			(m.riff && !hasRIFFPrefix(head)) ||
+			!m.hasPrefix(head[m.offset:]) {
+			continue
+		}
+
+		return m.mime, nil
+	}
+
+	return http.DetectContentType(head), nil
+}
+
+// FilenameNonASCIIMode how to deal with non-ASCII characters in SanitizeFilename
+type FilenameNonASCIIMode uint
+
+const (
+	// FilenameNonASCIIKeep keep non-ASCII characters
+	FilenameNonASCIIKeep FilenameNonASCIIMode = iota
+	// FilenameNonASCIIStrip remove all non-ASCII characters
+	FilenameNonASCIIStrip
+	// FilenameNonASCIITransliterate replace latin letters with diacritics
+	// by their ASCII equivalents, remove other non-ASCII characters
+	FilenameNonASCIITransliterate
+)
+
+const defaultSanitizeFilenameMaxLen = 255
+
+type sanitizeFilenameOption struct {
+	maxLen   int
+	nonASCII FilenameNonASCIIMode
+}
+
+// SanitizeFilenameOption options for SanitizeFilename
+type SanitizeFilenameOption func(*sanitizeFilenameOption) error
+
+// WithSanitizeFilenameMaxLen set max length in bytes of filename, default is 255
+func WithSanitizeFilenameMaxLen(maxLen int) SanitizeFilenameOption {
+	return func(o *sanitizeFilenameOption) error {
+		if maxLen <= 0 {
+			return errors.Errorf("max length should greater than 0, got %d", maxLen)
+		}
+
+		o.maxLen = maxLen
+		return nil
+	}
+}
+
+// WithSanitizeFilenameNonASCII set how to deal with non-ASCII characters,
+// default is FilenameNonASCIIKeep
+func WithSanitizeFilenameNonASCII(mode FilenameNonASCIIMode) SanitizeFilenameOption {
+	return func(o *sanitizeFilenameOption) error {
+		switch mode {
+		case FilenameNonASCIIKeep, FilenameNonASCIIStrip, FilenameNonASCIITransliterate:
+		default:
+			return errors.Errorf("unknown non-ASCII mode %d", mode)
+		}
+
+		o.nonASCII = mode
+		return nil
+	}
+}
+
+var (
+	// windowsReservedFilenames can not be used as file name on windows,
+	// no matter what extension is
+	windowsReservedFilenames = map[string]struct{}{
+		"CON": {}, "PRN": {}, "AUX": {}, "NUL": {},
+		"COM1": {}, "COM2": {}, "COM3": {}, "COM4": {}, "COM5": {},
+		"COM6": {}, "COM7": {}, "COM8": {}, "COM9": {},
+		"LPT1": {}, "LPT2": {}, "LPT3": {}, "LPT4": {}, "LPT5": {},
+		"LPT6": {}, "LPT7": {}, "LPT8": {}, "LPT9": {},
+	}
+
+	transliterateTable = map[rune]string{
+		'À': "A", 'Á': "A", 'Â': "A", 'Ã': "A", 'Ä': "A", 'Å': "A", 'Æ': "AE",
+		'Ç': "C", 'È': "E", 'É': "E", 'Ê': "E", 'Ë': "E",
+		'Ì': "I", 'Í': "I", 'Î': "I", 'Ï': "I", 'Ð': "D", 'Ñ': "N",
+		'Ò': "O", 'Ó': "O", 'Ô': "O", 'Õ': "O", 'Ö': "O", 'Ø': "O",
+		'Ù': "U", 'Ú': "U", 'Û': "U", 'Ü': "U", 'Ý': "Y", 'Þ': "TH", 'ß': "ss",
+		'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'æ': "ae",
+		'ç': "c", 'è': "e", 'é': "e", 'ê': "e", 'ë': "e",
+		'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ð': "d", 'ñ': "n",
+		'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o",
+		'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ý': "y", 'þ': "th", 'ÿ': "y",
+		'Ł': "L", 'ł': "l", 'Œ': "OE", 'œ': "oe", 'Š': "S", 'š': "s",
+		'Ž': "Z", 'ž': "z", 'Č': "C", 'č': "c", 'Ř': "R", 'ř': "r",
+		'Ğ': "G", 'ğ': "g", 'İ': "I", 'ı': "i", 'Ş': "S", 'ş': "s",
+	}
+)
+
+// SanitizeFilename make user-supplied name safe to be used as a file name
+//
+//   - remove path separators, control characters and `<>:"|?*`
+//   - remove leading dots and trailing dots/spaces
+//   - prefix windows reserved names (like `CON.txt`) with `_`
+//   - truncate to max length, the extension is preserved
+//
+// return "_" if nothing left.
+func SanitizeFilename(name string, opts ...SanitizeFilenameOption) (string, error) {
+	opt := &sanitizeFilenameOption{
+		maxLen: defaultSanitizeFilenameMaxLen,
+	}
+	for _, optf := range opts {
+		if err := optf(opt); err != nil {
+			return "", errors.Wrap(err, "apply option")
+		}
+	}
+
+	var sb strings.Builder
+	for _, r := range strings.ToValidUTF8(name, "") {
+		switch {
+		case r == '/' || r == '\\' || unicode.IsControl(r) ||
+			strings.ContainsRune(`<>:"|?*`, r):
+			continue
+		case r >= utf8.RuneSelf:
+			switch opt.nonASCII {
+			case FilenameNonASCIIStrip:
+				continue
+			case FilenameNonASCIITransliterate:
+				sb.WriteString(transliterateTable[r])
+				continue
+			}
+		}
+
+		sb.WriteRune(r)
+	}
+
+	name = strings.TrimRight(strings.TrimLeft(sb.String(), "."), ". ")
+	if name == "" {
+		return "_", nil
+	}
+
+	// windows reserves the name whatever extensions follow, like `CON.tar.gz`
+	stem, _, _ := strings.Cut(name, ".")
+	if _, ok := windowsReservedFilenames[strings.ToUpper(strings.TrimRight(stem, " "))]; ok {
+		name = "_" + name
+	}
+
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	if len(base)+len(ext) > opt.maxLen {
+		if len(ext) >= opt.maxLen {
+			// extension is too long to be preserved
+			base, ext = base+ext, ""
+		}
+
+		base = truncateUTF8(base, opt.maxLen-len(ext))
+	}
+
+	return base + ext, nil
+}
+
+// truncateUTF8 truncate s to at most n bytes without breaking runes
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
@@ -5,8 +5,10 @@ import (
 	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -583,3 +585,98 @@ func TestFilepathJoin(t *testing.T) {
 		})
 	}
 }
+
+func TestDetectContentType(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name   string
+		prefix []byte
+		expect string
+	}{
+		{"zip", []byte("PK\x03\x04\x14\x00\x00\x00\x08\x00"), "application/zip"},
+		{"empty zip", []byte("PK\x05\x06\x00\x00\x00\x00"), "application/zip"},
+		{"gzip", []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\x03"), "application/gzip"},
+		{"pdf", []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3"), "application/pdf"},
+		{"webp", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), "image/webp"},
+		{"wav is not webp", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), "audio/wave"},
+		{"heic", []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic"), "image/heic"},
+		{"heif", []byte("\x00\x00\x00\x18ftypmif1\x00\x00\x00\x00mif1heic"), "image/heif"},
+		{"png", []byte("\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0dIHDR"), "image/png"},
+		{"jpeg", []byte("\xFF\xD8\xFF\xE0\x00\x10JFIF"), "image/jpeg"},
+		{"html", []byte("<!DOCTYPE html><html></html>"), "text/html; charset=utf-8"},
+		{"text", []byte("hello world"), "text/plain; charset=utf-8"},
+		{"empty", []byte{}, "text/plain; charset=utf-8"},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// prepend some bytes to make sure the offset is respected
+			r := bytes.NewReader(append([]byte("skip"), tt.prefix...))
+			_, err := r.Seek(4, io.SeekStart)
+			require.NoError(t, err)
+
+			got, err := DetectContentType(r)
+			require.NoError(t, err)
+			require.Equal(t, tt.expect, got)
+
+			offset, err := r.Seek(0, io.SeekCurrent)
+			require.NoError(t, err)
+			require.Equal(t, int64(4), offset)
+		})
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name   string
+		opts   []SanitizeFilenameOption
+		expect string
+	}{
+		{"hello.txt", nil, "hello.txt"},
+		{"../../etc/passwd", nil, "etcpasswd"},
+		{`..\..\windows\system32`, nil, "windowssystem32"},
+		{".bashrc", nil, "bashrc"},
+		{"CON.txt", nil, "_CON.txt"},
+		{"con", nil, "_con"},
+		{"lpt1.tar.gz", nil, "_lpt1.tar.gz"},
+		{"CON.tar.gz", nil, "_CON.tar.gz"},
+		{"nul.x.y", nil, "_nul.x.y"},
+		{"aux .txt", nil, "_aux .txt"},
+		{"console.txt", nil, "console.txt"},
+		{"a\x00b\nc\x7f.txt", nil, "abc.txt"},
+		{`what?<is>:"this"|*.md`, nil, "whatisthis.md"},
+		{"trailing. . ", nil, "trailing"},
+		{"...", nil, "_"},
+		{"", nil, "_"},
+		{"日本語.txt", nil, "日本語.txt"},
+		{"日本語.txt", []SanitizeFilenameOption{WithSanitizeFilenameNonASCII(FilenameNonASCIIStrip)}, "txt"},
+		{"café-Ünïcode.txt", []SanitizeFilenameOption{WithSanitizeFilenameNonASCII(FilenameNonASCIITransliterate)}, "cafe-Unicode.txt"},
+		{"café-日本.txt", []SanitizeFilenameOption{WithSanitizeFilenameNonASCII(FilenameNonASCIIStrip)}, "caf-.txt"},
+		{"abcdefghij.txt", []SanitizeFilenameOption{WithSanitizeFilenameMaxLen(8)}, "abcd.txt"},
+		{"日本語日本語.txt", []SanitizeFilenameOption{WithSanitizeFilenameMaxLen(11)}, "日本.txt"},
+		{"abc.verylongext", []SanitizeFilenameOption{WithSanitizeFilenameMaxLen(5)}, "abc.v"},
+	} {
+		got, err := SanitizeFilename(tt.name, tt.opts...)
+		require.NoError(t, err, tt.name)
+		require.Equal(t, tt.expect, got, tt.name)
+	}
+
+	t.Run("long name", func(t *testing.T) {
+		name := strings.Repeat("a", 300) + ".jpeg"
+		got, err := SanitizeFilename(name)
+		require.NoError(t, err)
+		require.Len(t, got, 255)
+		require.True(t, strings.HasSuffix(got, ".jpeg"))
+	})
+
+	t.Run("invalid option", func(t *testing.T) {
+		_, err := SanitizeFilename("a", WithSanitizeFilenameMaxLen(0))
+		require.Error(t, err)
+		_, err = SanitizeFilename("a", WithSanitizeFilenameNonASCII(100))
+		require.Error(t, err)
+	})
+}
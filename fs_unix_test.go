@@ -0,0 +1,117 @@
+//go:build !windows
+// +build !windows
+
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testWithUmask run f with a permissive umask, to make sure modes do not depend on it
+func testWithUmask(mask int, f func()) {
+	old := syscall.Umask(mask)
+	defer syscall.Umask(old)
+	f()
+}
+
+func TestNewTmpFilePath(t *testing.T) {
+	dir := t.TempDir()
+
+	testWithUmask(0, func() {
+		path, cleanup, err := NewTmpFilePath(
+			WithTmpDir(dir),
+			WithTmpPattern("cfg-*.yaml"),
+			WithTmpContent([]byte("a: 1")),
+		)
+		require.NoError(t, err)
+		require.Equal(t, dir, filepath.Dir(path))
+		require.True(t, strings.HasPrefix(filepath.Base(path), "cfg-"))
+		require.Equal(t, ".yaml", filepath.Ext(path))
+
+		stat, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0600), stat.Mode().Perm())
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "a: 1", string(content))
+
+		cleanup()
+		cleanup()
+		_, err = os.Stat(path)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	testWithUmask(0077, func() {
+		path, cleanup, err := NewTmpFilePath(WithTmpDir(dir), WithTmpPerm(0644))
+		require.NoError(t, err)
+		defer cleanup()
+
+		stat, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0644), stat.Mode().Perm())
+	})
+
+	_, _, err := NewTmpFilePath(WithTmpPattern("a/b-*"))
+	require.Error(t, err)
+	_, _, err = NewTmpFilePath(WithTmpPerm(os.ModeDir | 0700))
+	require.Error(t, err)
+	_, _, err = NewTmpFilePath(WithTmpDir(filepath.Join(dir, "not-exists")))
+	require.Error(t, err)
+}
+
+func TestNewTmpDir(t *testing.T) {
+	parent := t.TempDir()
+
+	testWithUmask(0, func() {
+		dir, cleanup, err := NewTmpDir(WithTmpDir(parent), WithTmpPattern("work-*"))
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(filepath.Base(dir), "work-"))
+
+		stat, err := os.Stat(dir)
+		require.NoError(t, err)
+		require.True(t, stat.IsDir())
+		require.Equal(t, os.FileMode(0700), stat.Mode().Perm())
+
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "a", "b"), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "a", "b", "c"), []byte("c"), 0600))
+
+		cleanup()
+		cleanup()
+		_, err = os.Stat(dir)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	_, _, err := NewTmpDir(WithTmpContent([]byte("x")))
+	require.Error(t, err)
+}
+
+func TestWriteFileAtomicTemp(t *testing.T) {
+	dir := t.TempDir()
+
+	testWithUmask(0, func() {
+		path, err := WriteFileAtomicTemp(dir, []byte("hello"))
+		require.NoError(t, err)
+		require.Equal(t, dir, filepath.Dir(path))
+		require.True(t, strings.HasPrefix(filepath.Base(path), ".tmp-"))
+
+		stat, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0600), stat.Mode().Perm())
+
+		dst := filepath.Join(dir, "final")
+		require.NoError(t, os.Rename(path, dst))
+		content, err := os.ReadFile(dst)
+		require.NoError(t, err)
+		require.Equal(t, "hello", string(content))
+	})
+
+	_, err := WriteFileAtomicTemp(filepath.Join(dir, "not-exists"), []byte("x"))
+	require.Error(t, err)
+}
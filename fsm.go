@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Laisky/errors/v2"
+)
+
+// ErrFSMStateChanged state of FSM is changed by others while guard is running
+var ErrFSMStateChanged = errors.New("fsm state changed during guard")
+
+// ErrInvalidTransition transition is not registered in FSM
+type ErrInvalidTransition[S comparable] struct {
+	From, To S
+}
+
+func (e *ErrInvalidTransition[S]) Error() string {
+	return fmt.Sprintf("invalid transition from %v to %v", e.From, e.To)
+}
+
+type fsmOption struct {
+	threadSafe bool
+}
+
+// FSMOption options for NewFSM
+type FSMOption func(*fsmOption)
+
+// WithFSMThreadSafe protect FSM by mutex,
+// so Transition/Current/AddTransition/OnEnter can be called concurrently.
+func WithFSMThreadSafe() FSMOption {
+	return func(o *fsmOption) {
+		o.threadSafe = true
+	}
+}
+
+type fsmTransition[S comparable] struct {
+	from, to S
+}
+
+// FSM finite state machine that only allows registered transitions
+type FSM[S comparable] struct {
+	opt         *fsmOption
+	mu          sync.Mutex
+	current     S
+	transitions map[fsmTransition[S]]func() error
+	hooks       map[S][]func(from S)
+}
+
+// NewFSM create new FSM with initial state
+//
+// FSM is not thread-safe by default, use WithFSMThreadSafe if needed.
+func NewFSM[S comparable](initial S, opts ...FSMOption) *FSM[S] {
+	opt := new(fsmOption)
+	for _, optf := range opts {
+		optf(opt)
+	}
+
+	return &FSM[S]{
+		opt:         opt,
+		current:     initial,
+		transitions: make(map[fsmTransition[S]]func() error),
+		hooks:       make(map[S][]func(from S)),
+	}
+}
+
+func (f *FSM[S]) lock() {
+	if f.opt.threadSafe {
+		f.mu.Lock()
+	}
+}
+
+func (f *FSM[S]) unlock() {
+	if f.opt.threadSafe {
+		f.mu.Unlock()
+	}
+}
+
+// AddTransition allow transition from `from` to `to`,
+// guard can be nil, if guard returns error, the transition will be rejected.
+//
+// return error if the transition is already registered.
+func (f *FSM[S]) AddTransition(from, to S, guard func() error) error {
+	f.lock()
+	defer f.unlock()
+
+	key := fsmTransition[S]{from: from, to: to}
+	if _, ok := f.transitions[key]; ok {
+		return errors.Errorf("transition from %v to %v already exists", from, to)
+	}
+
+	f.transitions[key] = guard
+	return nil
+}
+
+// MustAddTransitions allow transitions of pairs `[from, to]` without guard,
+// panic if any transition is already registered.
+func (f *FSM[S]) MustAddTransitions(pairs ...[2]S) {
+	for _, pair := range pairs {
+		if err := f.AddTransition(pair[0], pair[1], nil); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// OnEnter register hook that will be called after entering state,
+// hooks are called in registration order with the previous state.
+//
+// hooks are called outside the lock, so they can call Current safely.
+func (f *FSM[S]) OnEnter(state S, hook func(from S)) {
+	f.lock()
+	defer f.unlock()
+
+	f.hooks[state] = append(f.hooks[state], hook)
+}
+
+// Current return current state
+func (f *FSM[S]) Current() S {
+	f.lock()
+	defer f.unlock()
+
+	return f.current
+}
+
+// Transition change current state to `to`.
+//
+// return *ErrInvalidTransition if transition is not registered,
+// or the error returned by guard, state is not changed in both cases.
+// self transition should be registered explicitly.
+//
+// guard is called outside the lock, so it can call Current safely,
+// if state is changed by others during guard, return ErrFSMStateChanged.
+func (f *FSM[S]) Transition(to S) error {
+	f.lock()
+	from := f.current
+	guard, ok := f.transitions[fsmTransition[S]{from: from, to: to}]
+	if !ok {
+		f.unlock()
+		return &ErrInvalidTransition[S]{From: from, To: to}
+	}
+
+	if guard != nil {
+		f.unlock()
+		if err := guard(); err != nil {
+			return errors.Wrapf(err, "guard of transition from %v to %v", from, to)
+		}
+
+		f.lock()
+		if f.current != from {
+			current := f.current
+			f.unlock()
+			return errors.Wrapf(ErrFSMStateChanged, "transition from %v to %v, current is %v",
+				from, to, current)
+		}
+	}
+
+	f.current = to
+	hooks := f.hooks[to]
+	f.unlock()
+
+	for _, hook := range hooks {
+		hook(from)
+	}
+
+	return nil
+}
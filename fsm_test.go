@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Laisky/errors/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type testCertState string
+
+const (
+	testCertIssued  testCertState = "issued"
+	testCertRevoked testCertState = "revoked"
+	testCertExpired testCertState = "expired"
+)
+
+func TestFSM(t *testing.T) {
+	t.Parallel()
+
+	t.Run("allowed and denied", func(t *testing.T) {
+		fsm := NewFSM(testCertIssued)
+		fsm.MustAddTransitions(
+			[2]testCertState{testCertIssued, testCertRevoked},
+			[2]testCertState{testCertIssued, testCertExpired},
+		)
+		require.Equal(t, testCertIssued, fsm.Current())
+
+		require.NoError(t, fsm.Transition(testCertRevoked))
+		require.Equal(t, testCertRevoked, fsm.Current())
+
+		err := fsm.Transition(testCertExpired)
+		var invalidErr *ErrInvalidTransition[testCertState]
+		require.ErrorAs(t, err, &invalidErr)
+		require.Equal(t, testCertRevoked, invalidErr.From)
+		require.Equal(t, testCertExpired, invalidErr.To)
+		require.EqualError(t, err, "invalid transition from revoked to expired")
+		require.Equal(t, testCertRevoked, fsm.Current())
+
+		// self transition is not allowed unless registered
+		require.Error(t, fsm.Transition(testCertRevoked))
+	})
+
+	t.Run("duplicated transition", func(t *testing.T) {
+		fsm := NewFSM(1)
+		require.NoError(t, fsm.AddTransition(1, 2, nil))
+		require.ErrorContains(t, fsm.AddTransition(1, 2, nil), "already exists")
+		require.Panics(t, func() { fsm.MustAddTransitions([2]int{1, 2}) })
+	})
+
+	t.Run("guard", func(t *testing.T) {
+		fsm := NewFSM(testCertIssued)
+		var allow bool
+		require.NoError(t, fsm.AddTransition(testCertIssued, testCertRevoked, func() error {
+			if !allow {
+				return errors.New("not allowed")
+			}
+
+			return nil
+		}))
+
+		require.ErrorContains(t, fsm.Transition(testCertRevoked), "not allowed")
+		require.Equal(t, testCertIssued, fsm.Current())
+
+		allow = true
+		require.NoError(t, fsm.Transition(testCertRevoked))
+		require.Equal(t, testCertRevoked, fsm.Current())
+	})
+
+	t.Run("guard outside lock", func(t *testing.T) {
+		fsm := NewFSM(testCertIssued, WithFSMThreadSafe())
+		fsm.MustAddTransitions([2]testCertState{testCertIssued, testCertExpired})
+		require.NoError(t, fsm.AddTransition(testCertIssued, testCertRevoked, func() error {
+			// guard can read state without deadlock
+			require.Equal(t, testCertIssued, fsm.Current())
+
+			// state changed by others during guard
+			require.NoError(t, fsm.Transition(testCertExpired))
+			return nil
+		}))
+
+		err := fsm.Transition(testCertRevoked)
+		require.ErrorIs(t, err, ErrFSMStateChanged)
+		require.Equal(t, testCertExpired, fsm.Current())
+	})
+
+	t.Run("hooks", func(t *testing.T) {
+		fsm := NewFSM(testCertIssued, WithFSMThreadSafe())
+		fsm.MustAddTransitions([2]testCertState{testCertIssued, testCertRevoked})
+
+		var calls []string
+		fsm.OnEnter(testCertRevoked, func(from testCertState) {
+			calls = append(calls, "first from "+string(from))
+			// hooks run outside the lock
+			require.Equal(t, testCertRevoked, fsm.Current())
+		})
+		fsm.OnEnter(testCertRevoked, func(from testCertState) {
+			calls = append(calls, "second from "+string(from))
+		})
+		fsm.OnEnter(testCertExpired, func(from testCertState) {
+			calls = append(calls, "should not be called")
+		})
+
+		require.NoError(t, fsm.Transition(testCertRevoked))
+		require.Equal(t, []string{"first from issued", "second from issued"}, calls)
+
+		// failed transition does not call hooks
+		require.Error(t, fsm.Transition(testCertExpired))
+		require.Len(t, calls, 2)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		fsm := NewFSM(testCertIssued, WithFSMThreadSafe())
+		fsm.MustAddTransitions([2]testCertState{testCertIssued, testCertRevoked})
+
+		var (
+			wg        sync.WaitGroup
+			succeeded atomic.Int32
+			entered   atomic.Int32
+		)
+		fsm.OnEnter(testCertRevoked, func(testCertState) { entered.Add(1) })
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if fsm.Transition(testCertRevoked) == nil {
+					succeeded.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		require.EqualValues(t, 1, succeeded.Load())
+		require.EqualValues(t, 1, entered.Load())
+		require.Equal(t, testCertRevoked, fsm.Current())
+	})
+}
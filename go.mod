@@ -16,6 +16,7 @@ require (
 	github.com/cespare/xxhash v1.1.0
 	github.com/corvus-ch/shamir v1.0.1
 	github.com/deckarep/golang-set/v2 v2.1.0
+	github.com/emmansun/gmsm v0.29.7
 	github.com/fsnotify/fsnotify v1.7.0
 	github.com/gammazero/deque v0.2.1
 	github.com/go-json-experiment/json v0.0.0-20231011163920-8aa127fd5801
@@ -33,9 +34,9 @@ require (
 	github.com/xlzd/gotp v0.1.0
 	go.dedis.ch/kyber/v3 v3.1.0
 	go.uber.org/automaxprocs v1.6.0
-	golang.org/x/crypto v0.28.0
+	golang.org/x/crypto v0.32.0
 	golang.org/x/sync v0.8.0
-	golang.org/x/term v0.25.0
+	golang.org/x/term v0.28.0
 	golang.org/x/time v0.3.0
 	gopkg.in/gomail.v2 v2.0.0-20160411212932-81ebce5c23df
 )
@@ -55,7 +56,7 @@ require (
 	go.dedis.ch/fixbuf v1.0.3 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	golang.org/x/lint v0.0.0-20210508222113-6edffad5e616 // indirect
-	golang.org/x/sys v0.26.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
 	golang.org/x/tools v0.26.0 // indirect
 	gopkg.in/alexcesaro/quotedprintable.v3 v3.0.0-20150716171945-2caba252f4dc // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
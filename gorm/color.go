@@ -1,13 +1,17 @@
 package gorm
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Laisky/errors/v2"
 	"github.com/Laisky/zap"
 	"github.com/Laisky/zap/zapcore"
+	"golang.org/x/time/rate"
 
 	gutils "github.com/Laisky/go-utils/v4"
 )
@@ -18,18 +22,83 @@ type loggerItf interface {
 	Error(string, ...zap.Field)
 }
 
+// warnLoggerItf optional Warn method of loggerItf
+type warnLoggerItf interface {
+	Warn(string, ...zap.Field)
+}
+
+const (
+	// disableLogMarker sql contains this marker will not be logged
+	disableLogMarker = "/*disable_log*/"
+
+	defaultExplainTimeout = 5 * time.Second
+)
+
 // Logger colored logger for gorm
 type Logger struct {
 	logger    loggerItf
 	formatter func(...any) []any
+	dialect   string
+	explain   *explainOption
+}
+
+type explainOption struct {
+	db        *sql.DB
+	threshold time.Duration
+	limiter   *rate.Limiter
+	timeout   time.Duration
+}
+
+// LoggerOption option for NewLogger
+type LoggerOption func(*Logger)
+
+// WithDialect set the sql dialect name of database,
+// like "mysql", "postgres", "sqlite3".
+//
+// dialect decides the syntax of EXPLAIN in WithExplainOnSlow.
+func WithDialect(dialect string) LoggerOption {
+	return func(l *Logger) {
+		l.dialect = strings.ToLower(strings.TrimSpace(dialect))
+	}
+}
+
+// WithExplainOnSlow run `EXPLAIN <query>` by db when the query's cost
+// exceeds threshold, and log the plan at warn level.
+//
+// at most maxPerMinute EXPLAIN will be executed per minute,
+// maxPerMinute <= 0 means disable EXPLAIN.
+// only SELECT statement will be explained,
+// statements contain `/*disable_log*/` will be ignored.
+//
+// EXPLAIN runs in background, any error will only be logged
+// and never affect the original query.
+func WithExplainOnSlow(db *sql.DB, threshold time.Duration, maxPerMinute int) LoggerOption {
+	return func(l *Logger) {
+		if db == nil || maxPerMinute <= 0 {
+			l.explain = nil
+			return
+		}
+
+		l.explain = &explainOption{
+			db:        db,
+			threshold: threshold,
+			limiter:   rate.NewLimiter(rate.Every(time.Minute/time.Duration(maxPerMinute)), maxPerMinute),
+			timeout:   defaultExplainTimeout,
+		}
+	}
 }
 
 // NewLogger new gorm sql logger
-func NewLogger(formatter func(...any) []any, logger loggerItf) *Logger {
-	return &Logger{
+func NewLogger(formatter func(...any) []any, logger loggerItf, opts ...LoggerOption) *Logger {
+	l := &Logger{
 		logger:    logger,
 		formatter: formatter,
 	}
+	for _, optf := range opts {
+		optf(l)
+	}
+
+	return l
 }
 
 // Print print sql logger
@@ -78,10 +147,12 @@ func (l *Logger) Print(vs ...any) {
 	}
 
 	// ignore some logs
-	if strings.Contains(msg, "/*disable_log*/") {
+	if strings.Contains(msg, disableLogMarker) {
 		return
 	}
 
+	l.explainIfSlow(vs...)
+
 	switch strings.TrimSpace(strings.ToLower(strings.SplitN(msg, " ", 2)[0])) {
 	case "drop", "delete":
 		l.logger.Info(gutils.Color(gutils.ANSIColorFgMagenta, msg), fields...)
@@ -97,3 +168,117 @@ func (l *Logger) Print(vs ...any) {
 		l.logger.Info(gutils.Color(gutils.ANSIColorFgBlue, msg), fields...)
 	}
 }
+
+// explainIfSlow run EXPLAIN for slow select query in background.
+//
+// vs is the raw values passed to Print,
+// like ["sql", caller, cost, sql, args, affected].
+func (l *Logger) explainIfSlow(vs ...any) {
+	if l.explain == nil || len(vs) < 4 {
+		return
+	}
+
+	cost, ok := vs[2].(time.Duration)
+	if !ok || cost < l.explain.threshold {
+		return
+	}
+
+	query, ok := vs[3].(string)
+	if !ok || !isSelectStmt(query) {
+		return
+	}
+
+	var args []any
+	if len(vs) > 4 {
+		args, _ = vs[4].([]any)
+	}
+
+	if !l.explain.limiter.Allow() {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), l.explain.timeout)
+		defer cancel()
+
+		plan, err := l.runExplain(ctx, query, args...)
+		if err != nil {
+			l.logger.Debug("explain slow query", zap.String("sql", query), zap.Error(err))
+			return
+		}
+
+		l.warn(gutils.Color(gutils.ANSIColorFgHiYellow, "slow query"),
+			zap.String("sql", query),
+			zap.Int("ms", int(cost/time.Millisecond)),
+			zap.String("plan", plan),
+		)
+	}()
+}
+
+// warn log by logger's Warn if implemented, otherwise fallback to Info
+func (l *Logger) warn(msg string, fields ...zap.Field) {
+	if w, ok := l.logger.(warnLoggerItf); ok {
+		w.Warn(msg, fields...)
+		return
+	}
+
+	l.logger.Info(msg, fields...)
+}
+
+// explainPrefix return EXPLAIN syntax for dialect
+func (l *Logger) explainPrefix() string {
+	switch l.dialect {
+	case "sqlite", "sqlite3":
+		return "EXPLAIN QUERY PLAN "
+	default: // mysql, postgres
+		return "EXPLAIN "
+	}
+}
+
+// runExplain run EXPLAIN and join all rows into plan text
+func (l *Logger) runExplain(ctx context.Context, query string, args ...any) (plan string, err error) {
+	rows, err := l.explain.db.QueryContext(ctx, l.explainPrefix()+query, args...)
+	if err != nil {
+		return "", errors.Wrap(err, "query explain")
+	}
+	defer gutils.SilentClose(rows)
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return "", errors.Wrap(err, "get columns")
+	}
+
+	var lines []string
+	for rows.Next() {
+		vals := make([]sql.NullString, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+
+		if err = rows.Scan(ptrs...); err != nil {
+			return "", errors.Wrap(err, "scan explain row")
+		}
+
+		var line []string
+		for _, v := range vals {
+			line = append(line, v.String)
+		}
+		lines = append(lines, strings.Join(line, " | "))
+	}
+	if err = rows.Err(); err != nil {
+		return "", errors.Wrap(err, "iterate explain rows")
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// isSelectStmt check whether query is a select statement
+func isSelectStmt(query string) bool {
+	if strings.Contains(query, disableLogMarker) {
+		return false
+	}
+
+	fields := strings.Fields(strings.TrimLeft(query, " \t\r\n("))
+	return len(fields) != 0 && strings.EqualFold(fields[0], "select")
+}
@@ -1,9 +1,17 @@
 package gorm
 
 import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/Laisky/errors/v2"
+	"github.com/Laisky/zap"
+	"github.com/Laisky/zap/zapcore"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
@@ -69,3 +77,172 @@ func TestGormLogger_Print(t *testing.T) {
 		)
 	})
 }
+
+type captureLogger struct {
+	mu   sync.Mutex
+	warn []map[string]any
+}
+
+func (l *captureLogger) Debug(string, ...zap.Field) {}
+func (l *captureLogger) Info(string, ...zap.Field)  {}
+func (l *captureLogger) Error(string, ...zap.Field) {}
+func (l *captureLogger) Warn(_ string, fields ...zap.Field) {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warn = append(l.warn, enc.Fields)
+}
+
+func (l *captureLogger) warns() []map[string]any {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]map[string]any{}, l.warn...)
+}
+
+// explainDriver fake sql driver that only answers EXPLAIN
+type explainDriver struct {
+	mu      sync.Mutex
+	queries []string
+}
+
+func (d *explainDriver) Open(string) (driver.Conn, error) { return &explainConn{d: d}, nil }
+
+func (d *explainDriver) executed() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string{}, d.queries...)
+}
+
+type explainConn struct{ d *explainDriver }
+
+func (c *explainConn) Prepare(query string) (driver.Stmt, error) {
+	return &explainStmt{d: c.d, query: query}, nil
+}
+func (c *explainConn) Close() error              { return nil }
+func (c *explainConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+type explainStmt struct {
+	d     *explainDriver
+	query string
+}
+
+func (s *explainStmt) Close() error  { return nil }
+func (s *explainStmt) NumInput() int { return -1 }
+func (s *explainStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s *explainStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	s.d.queries = append(s.d.queries, s.query)
+	s.d.mu.Unlock()
+
+	if !strings.HasPrefix(s.query, "EXPLAIN QUERY PLAN ") {
+		return nil, errors.Errorf("unknown query %q", s.query)
+	}
+
+	return &explainRows{rows: [][]driver.Value{{int64(2), int64(0), int64(0), "SCAN users"}}}, nil
+}
+
+type explainRows struct {
+	rows [][]driver.Value
+}
+
+func (r *explainRows) Columns() []string { return []string{"id", "parent", "notused", "detail"} }
+func (r *explainRows) Close() error      { return nil }
+func (r *explainRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestLogger_WithExplainOnSlow(t *testing.T) {
+	drv := new(explainDriver)
+	sql.Register("gutils-explain-test", drv)
+	db, err := sql.Open("gutils-explain-test", "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	formatter := func(vs ...any) []any { return vs }
+	logger := new(captureLogger)
+	gl := NewLogger(formatter, logger,
+		WithDialect("sqlite3"),
+		WithExplainOnSlow(db, time.Millisecond, 2),
+	)
+
+	t.Run("fast query", func(t *testing.T) {
+		gl.Print("sql", "caller", time.Microsecond, "SELECT * FROM users", []any{}, int64(1))
+		time.Sleep(100 * time.Millisecond)
+		require.Empty(t, drv.executed())
+	})
+
+	t.Run("not select", func(t *testing.T) {
+		gl.Print("sql", "caller", time.Second, "DELETE FROM users", []any{}, int64(1))
+		gl.Print("sql", "caller", time.Second, "SELECT * FROM users /*disable_log*/", []any{}, int64(1))
+		time.Sleep(100 * time.Millisecond)
+		require.Empty(t, drv.executed())
+	})
+
+	t.Run("slow select", func(t *testing.T) {
+		gl.Print("sql", "caller", time.Second, "SELECT * FROM users WHERE id = ?", []any{1}, int64(1))
+		require.Eventually(t, func() bool {
+			return len(logger.warns()) == 1
+		}, time.Second, 10*time.Millisecond)
+
+		fields := logger.warns()[0]
+		require.Equal(t, "SELECT * FROM users WHERE id = ?", fields["sql"])
+		require.Contains(t, fields["plan"], "SCAN users")
+		require.Equal(t, []string{"EXPLAIN QUERY PLAN SELECT * FROM users WHERE id = ?"}, drv.executed())
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			gl.Print("sql", "caller", time.Second, "SELECT * FROM users", []any{}, int64(1))
+		}
+
+		time.Sleep(200 * time.Millisecond)
+		require.Len(t, drv.executed(), 2)
+		require.Len(t, logger.warns(), 2)
+	})
+}
+
+func Test_isSelectStmt(t *testing.T) {
+	require.True(t, isSelectStmt("SELECT 1"))
+	require.True(t, isSelectStmt("  select * from a"))
+	require.True(t, isSelectStmt("(SELECT 1) UNION (SELECT 2)"))
+	require.False(t, isSelectStmt("UPDATE a SET b = 1"))
+	require.False(t, isSelectStmt("SELECT 1 /*disable_log*/"))
+	require.True(t, isSelectStmt("\tSELECT 1"))
+	require.True(t, isSelectStmt("\nSELECT\n*\nFROM a"))
+	require.True(t, isSelectStmt("SELECT\t1"))
+	require.False(t, isSelectStmt("SELECTED"))
+	require.False(t, isSelectStmt(""))
+}
+
+// infoLogger logger without Warn
+type infoLogger struct {
+	info []string
+}
+
+func (l *infoLogger) Debug(string, ...zap.Field)      {}
+func (l *infoLogger) Error(string, ...zap.Field)      {}
+func (l *infoLogger) Info(msg string, _ ...zap.Field) { l.info = append(l.info, msg) }
+
+func TestLogger_warn(t *testing.T) {
+	logger := new(infoLogger)
+	gl := NewLogger(func(vs ...any) []any { return vs }, logger)
+	gl.warn("slow query")
+	require.Equal(t, []string{"slow query"}, logger.info)
+
+	wlogger := new(captureLogger)
+	gl = NewLogger(func(vs ...any) []any { return vs }, wlogger)
+	gl.warn("slow query", zap.String("sql", "SELECT 1"))
+	require.Len(t, wlogger.warns(), 1)
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/Laisky/errors/v2"
 	"github.com/cespare/xxhash"
 
+	"github.com/Laisky/go-utils/v4/json"
 	"github.com/Laisky/go-utils/v4/log"
 )
 
@@ -129,3 +130,21 @@ func HashVerify(hashType HashTypeInterface, content io.Reader, signature []byte)
 
 	return nil
 }
+
+// HashJSONCanonical calculate hex encoded hash of v's canonical json,
+// semantically equal values always have the same hash.
+//
+// more details at json.MarshalCanonical
+func HashJSONCanonical(hashType HashTypeInterface, v any) (string, error) {
+	data, err := json.MarshalCanonical(v)
+	if err != nil {
+		return "", errors.Wrap(err, "marshal canonical json")
+	}
+
+	signature, err := Hash(hashType, bytes.NewReader(data))
+	if err != nil {
+		return "", errors.Wrap(err, "hash")
+	}
+
+	return hex.EncodeToString(signature), nil
+}
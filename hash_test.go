@@ -2,6 +2,7 @@ package utils
 
 import (
 	"crypto/sha256"
+	"encoding/hex"
 	"testing"
 
 	"github.com/Laisky/zap"
@@ -69,3 +70,38 @@ func ExampleHashXxhashString() {
 	got := HashXxhashString(val)
 	log.Shared.Info("hash", zap.String("got", got))
 }
+
+func TestHashJSONCanonical(t *testing.T) {
+	t.Parallel()
+
+	a := map[string]any{
+		"name": "laisky",
+		"tags": []string{"a", "b"},
+		"nested": map[string]any{
+			"z": 1.0,
+			"a": "<&>",
+		},
+	}
+	b := map[string]any{
+		"nested": map[string]any{
+			"a": "<&>",
+			"z": 1,
+		},
+		"tags": []string{"a", "b"},
+		"name": "laisky",
+	}
+
+	ha, err := HashJSONCanonical(HashTypeSha256, a)
+	require.NoError(t, err)
+	hb, err := HashJSONCanonical(HashTypeSha256, b)
+	require.NoError(t, err)
+	require.Equal(t, ha, hb)
+
+	expect := sha256.Sum256([]byte(`{"name":"laisky","nested":{"a":"<&>","z":1},"tags":["a","b"]}`))
+	require.Equal(t, hex.EncodeToString(expect[:]), ha)
+
+	b["name"] = "another"
+	hb, err = HashJSONCanonical(HashTypeSha256, b)
+	require.NoError(t, err)
+	require.NotEqual(t, ha, hb)
+}
@@ -0,0 +1,300 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Laisky/errors/v2"
+
+	"github.com/Laisky/go-utils/v4/json"
+)
+
+// HealthStatus status of health check
+type HealthStatus string
+
+const (
+	// HealthStatusOK check passed
+	HealthStatusOK HealthStatus = "ok"
+	// HealthStatusWarn non-critical check failed
+	HealthStatusWarn HealthStatus = "warn"
+	// HealthStatusFail critical check failed
+	HealthStatusFail HealthStatus = "fail"
+)
+
+// HealthCheckResult result of one check
+type HealthCheckResult struct {
+	Name     string       `json:"name"`
+	Status   HealthStatus `json:"status"`
+	Critical bool         `json:"critical"`
+	// Latency time cost of the last run
+	Latency time.Duration `json:"latency"`
+	// Error of the last run, empty if passed
+	Error string `json:"error,omitempty"`
+	// CheckedAt time of the last run
+	CheckedAt time.Time `json:"checked_at"`
+	// Cached whether the result is reused from the last run
+	Cached bool `json:"cached"`
+}
+
+// HealthReport aggregated result of all checks
+type HealthReport struct {
+	// Status fail if any critical check failed,
+	// warn if any non-critical check failed, otherwise ok
+	Status HealthStatus        `json:"status"`
+	Checks []HealthCheckResult `json:"checks"`
+}
+
+type healthOption struct {
+	timeout     time.Duration
+	interval    time.Duration
+	nonCritical bool
+}
+
+// HealthOption options for HealthRegistry.Register
+type HealthOption func(*healthOption) error
+
+// WithHealthTimeout set timeout of each run, default is 5s
+func WithHealthTimeout(timeout time.Duration) HealthOption {
+	return func(o *healthOption) error {
+		if timeout <= 0 {
+			return errors.Errorf("timeout should be positive, got %s", timeout)
+		}
+
+		o.timeout = timeout
+		return nil
+	}
+}
+
+// WithHealthInterval reuse the last result within interval,
+// so the expensive check will not run more than once per interval.
+// default is 0, means run on every report.
+func WithHealthInterval(interval time.Duration) HealthOption {
+	return func(o *healthOption) error {
+		if interval < 0 {
+			return errors.Errorf("interval should not be negative, got %s", interval)
+		}
+
+		o.interval = interval
+		return nil
+	}
+}
+
+// WithHealthNonCritical failure of the check only warns,
+// does not fail the whole report. default is critical.
+func WithHealthNonCritical() HealthOption {
+	return func(o *healthOption) error {
+		o.nonCritical = true
+		return nil
+	}
+}
+
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error
+	opt   *healthOption
+
+	// mu serializes runs, so concurrent reports share the cached result
+	mu   sync.Mutex
+	last *HealthCheckResult
+}
+
+type healthRegistryOption struct {
+	concurrency int
+	clock       SchedClock
+}
+
+// HealthRegistryOption options for NewHealthRegistry
+type HealthRegistryOption func(*healthRegistryOption) error
+
+// WithHealthRegistryConcurrency set max number of checks running
+// at the same time, default is 8
+func WithHealthRegistryConcurrency(n int) HealthRegistryOption {
+	return func(o *healthRegistryOption) error {
+		if n <= 0 {
+			return errors.Errorf("concurrency should be positive, got %d", n)
+		}
+
+		o.concurrency = n
+		return nil
+	}
+}
+
+// withHealthRegistryClock replace the clock used by interval caching, for tests
+func withHealthRegistryClock(clock SchedClock) HealthRegistryOption {
+	return func(o *healthRegistryOption) error {
+		o.clock = clock
+		return nil
+	}
+}
+
+// HealthRegistry registry of health checks, create by NewHealthRegistry
+type HealthRegistry struct {
+	opt *healthRegistryOption
+
+	mu     sync.RWMutex
+	checks []*healthCheck
+}
+
+// NewHealthRegistry new health registry
+func NewHealthRegistry(opts ...HealthRegistryOption) (*HealthRegistry, error) {
+	opt := &healthRegistryOption{
+		concurrency: 8,
+		clock:       realSchedClock{},
+	}
+	for _, f := range opts {
+		if err := f(opt); err != nil {
+			return nil, errors.Wrap(err, "apply option")
+		}
+	}
+
+	return &HealthRegistry{opt: opt}, nil
+}
+
+// Register add check by unique name
+func (r *HealthRegistry) Register(name string,
+	check func(ctx context.Context) error, opts ...HealthOption) error {
+	if name == "" {
+		return errors.New("name should not be empty")
+	}
+	if check == nil {
+		return errors.New("check should not be nil")
+	}
+
+	opt := &healthOption{timeout: 5 * time.Second}
+	for _, f := range opts {
+		if err := f(opt); err != nil {
+			return errors.Wrap(err, "apply option")
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range r.checks {
+		if c.name == name {
+			return errors.Errorf("check %q already registered", name)
+		}
+	}
+
+	r.checks = append(r.checks, &healthCheck{
+		name:  name,
+		check: check,
+		opt:   opt,
+	})
+
+	return nil
+}
+
+// Report run all checks concurrently, results are in the order of registration.
+func (r *HealthRegistry) Report(ctx context.Context) HealthReport {
+	r.mu.RLock()
+	checks := append([]*healthCheck(nil), r.checks...)
+	r.mu.RUnlock()
+
+	report := HealthReport{
+		Status: HealthStatusOK,
+		Checks: make([]HealthCheckResult, len(checks)),
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, r.opt.concurrency)
+	for i, c := range checks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, c *healthCheck) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			report.Checks[i] = r.run(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		switch result.Status {
+		case HealthStatusFail:
+			report.Status = HealthStatusFail
+		case HealthStatusWarn:
+			if report.Status == HealthStatusOK {
+				report.Status = HealthStatusWarn
+			}
+		}
+	}
+
+	return report
+}
+
+// run check or reuse the cached result
+func (r *HealthRegistry) run(ctx context.Context, c *healthCheck) HealthCheckResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	startAt := r.opt.clock.Now()
+	if c.last != nil && c.opt.interval > 0 && startAt.Sub(c.last.CheckedAt) < c.opt.interval {
+		result := *c.last
+		result.Cached = true
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.opt.timeout)
+	defer cancel()
+
+	// run in goroutine, so the check that ignores ctx still times out
+	errCh := make(chan error, 1)
+	go func() {
+		var err error
+		if perr := IsPanic2(func() { err = c.check(ctx) }); perr != nil {
+			err = perr
+		}
+
+		errCh <- err
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = errors.Wrapf(ctx.Err(), "check %q timeout after %s", c.name, c.opt.timeout)
+	}
+
+	result := HealthCheckResult{
+		Name:      c.name,
+		Status:    HealthStatusOK,
+		Critical:  !c.opt.nonCritical,
+		Latency:   r.opt.clock.Now().Sub(startAt),
+		CheckedAt: startAt,
+	}
+	if err != nil {
+		result.Error = err.Error()
+		result.Status = HealthStatusFail
+		if c.opt.nonCritical {
+			result.Status = HealthStatusWarn
+		}
+	}
+
+	c.last = &result
+	return result
+}
+
+// Handler http handler that responds the report in JSON,
+// status code is 503 if the report is failed, otherwise 200.
+func (r *HealthRegistry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := r.Report(req.Context())
+		body, err := json.Marshal(report)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		code := http.StatusOK
+		if report.Status == HealthStatusFail {
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_, _ = w.Write(body)
+	})
+}
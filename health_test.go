@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Laisky/errors/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Laisky/go-utils/v4/json"
+)
+
+func TestHealthRegistry_Interval(t *testing.T) {
+	clock := NewFakeSchedClock()
+	r, err := NewHealthRegistry(withHealthRegistryClock(clock))
+	require.NoError(t, err)
+
+	var called atomic.Int32
+	err = r.Register("db", func(ctx context.Context) error {
+		called.Add(1)
+		return nil
+	}, WithHealthInterval(10*time.Second))
+	require.NoError(t, err)
+
+	report := r.Report(context.Background())
+	require.Equal(t, HealthStatusOK, report.Status)
+	require.False(t, report.Checks[0].Cached)
+	require.EqualValues(t, 1, called.Load())
+
+	clock.Advance(t, 9*time.Second)
+	report = r.Report(context.Background())
+	require.True(t, report.Checks[0].Cached)
+	require.EqualValues(t, 1, called.Load())
+
+	clock.Advance(t, time.Second)
+	report = r.Report(context.Background())
+	require.False(t, report.Checks[0].Cached)
+	require.EqualValues(t, 2, called.Load())
+	require.Equal(t, clock.Now(), report.Checks[0].CheckedAt)
+
+	err = r.Register("db", func(ctx context.Context) error { return nil })
+	require.ErrorContains(t, err, "already registered")
+}
+
+func TestHealthRegistry_Timeout(t *testing.T) {
+	r, err := NewHealthRegistry()
+	require.NoError(t, err)
+
+	// ignores ctx
+	blocker := make(chan struct{})
+	defer close(blocker)
+	err = r.Register("slow", func(ctx context.Context) error {
+		<-blocker
+		return nil
+	}, WithHealthTimeout(50*time.Millisecond))
+	require.NoError(t, err)
+
+	startAt := time.Now()
+	report := r.Report(context.Background())
+	require.Less(t, time.Since(startAt), time.Second)
+	require.Equal(t, HealthStatusFail, report.Status)
+	require.Contains(t, report.Checks[0].Error, "timeout")
+}
+
+func TestHealthRegistry_Aggregation(t *testing.T) {
+	newRegistry := func(t *testing.T, criticalErr, nonCriticalErr error) *HealthRegistry {
+		r, err := NewHealthRegistry(WithHealthRegistryConcurrency(2))
+		require.NoError(t, err)
+
+		require.NoError(t, r.Register("ok", func(ctx context.Context) error { return nil }))
+		require.NoError(t, r.Register("critical", func(ctx context.Context) error { return criticalErr }))
+		require.NoError(t, r.Register("cache", func(ctx context.Context) error {
+			return nonCriticalErr
+		}, WithHealthNonCritical()))
+		return r
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		report := newRegistry(t, nil, nil).Report(context.Background())
+		require.Equal(t, HealthStatusOK, report.Status)
+		require.Len(t, report.Checks, 3)
+		require.Equal(t, "ok", report.Checks[0].Name)
+		require.Equal(t, "critical", report.Checks[1].Name)
+		require.Equal(t, "cache", report.Checks[2].Name)
+	})
+
+	t.Run("warn", func(t *testing.T) {
+		report := newRegistry(t, nil, errors.New("cache down")).Report(context.Background())
+		require.Equal(t, HealthStatusWarn, report.Status)
+		require.Equal(t, HealthStatusWarn, report.Checks[2].Status)
+		require.Equal(t, "cache down", report.Checks[2].Error)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		report := newRegistry(t, errors.New("db down"), errors.New("cache down")).
+			Report(context.Background())
+		require.Equal(t, HealthStatusFail, report.Status)
+		require.Equal(t, HealthStatusFail, report.Checks[1].Status)
+		require.Equal(t, HealthStatusWarn, report.Checks[2].Status)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		r, err := NewHealthRegistry()
+		require.NoError(t, err)
+		require.NoError(t, r.Register("panic", func(ctx context.Context) error { panic("boom") }))
+
+		report := r.Report(context.Background())
+		require.Equal(t, HealthStatusFail, report.Status)
+		require.Contains(t, report.Checks[0].Error, "boom")
+	})
+}
+
+func TestHealthRegistry_Handler(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		code int
+	}{
+		{nil, http.StatusOK},
+		{errors.New("down"), http.StatusServiceUnavailable},
+	} {
+		r, err := NewHealthRegistry()
+		require.NoError(t, err)
+		require.NoError(t, r.Register("db", func(ctx context.Context) error { return c.err }))
+
+		w := httptest.NewRecorder()
+		r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		require.Equal(t, c.code, w.Code)
+		require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var report HealthReport
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		require.Len(t, report.Checks, 1)
+		require.Equal(t, "db", report.Checks[0].Name)
+	}
+}
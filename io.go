@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"hash"
+	"io"
+	"sync/atomic"
+
+	"github.com/Laisky/errors/v2"
+)
+
+// CopyWithLimit copy at most limit bytes from src to dst.
+//
+// different from io.CopyN, it is not an error that src has more data than limit,
+// truncated will be true and the rest of src is left unread,
+// except for one byte consumed to detect truncation.
+func CopyWithLimit(dst io.Writer, src io.Reader, limit int64) (written int64, truncated bool, err error) {
+	if limit < 0 {
+		return 0, false, errors.Errorf("limit should not be negative, got %d", limit)
+	}
+
+	written, err = io.CopyN(dst, src, limit)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return written, false, nil
+		}
+
+		return written, false, errors.Wrap(err, "copy")
+	}
+
+	// detect whether there is more data
+	var probe [1]byte
+	for {
+		n, err := src.Read(probe[:])
+		if n > 0 {
+			return written, true, nil
+		}
+
+		switch {
+		case errors.Is(err, io.EOF):
+			return written, false, nil
+		case err != nil:
+			return written, false, errors.Wrap(err, "read src")
+		}
+	}
+}
+
+// CountingReader count bytes read from the underlying reader
+type CountingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+// NewCountingReader create new CountingReader
+func NewCountingReader(r io.Reader) *CountingReader {
+	return &CountingReader{r: r}
+}
+
+// Read read from the underlying reader
+func (r *CountingReader) Read(p []byte) (n int, err error) {
+	n, err = r.r.Read(p)
+	r.n.Add(int64(n))
+	return n, err
+}
+
+// Count return the number of bytes read so far,
+// it is safe to be called concurrently with Read.
+func (r *CountingReader) Count() int64 {
+	return r.n.Load()
+}
+
+// CountingWriter count bytes written to the underlying writer
+type CountingWriter struct {
+	w io.Writer
+	n atomic.Int64
+}
+
+// NewCountingWriter create new CountingWriter
+func NewCountingWriter(w io.Writer) *CountingWriter {
+	return &CountingWriter{w: w}
+}
+
+// Write write to the underlying writer
+func (w *CountingWriter) Write(p []byte) (n int, err error) {
+	n, err = w.w.Write(p)
+	w.n.Add(int64(n))
+	return n, err
+}
+
+// Count return the number of bytes written so far,
+// it is safe to be called concurrently with Write.
+func (w *CountingWriter) Count() int64 {
+	return w.n.Load()
+}
+
+// NewTeeHasher return a reader that hash all data read from r,
+// call digest to get the signature after reader is consumed.
+//
+// it is useful to verify the hash while streaming data to disk in one pass:
+//
+//	reader, digest, err := NewTeeHasher(resp.Body, HashTypeSha256)
+//	_, err = io.Copy(fp, reader)
+//	sig := digest()
+func NewTeeHasher(r io.Reader, hashType HashTypeInterface) (reader io.Reader, digest func() []byte, err error) {
+	var hasher hash.Hash
+	if hasher, err = hashType.Hasher(); err != nil {
+		return nil, nil, errors.Wrap(err, "get hasher")
+	}
+
+	return io.TeeReader(r, hasher), func() []byte {
+		return hasher.Sum(nil)
+	}, nil
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyWithLimit(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []struct {
+		src       string
+		limit     int64
+		expect    string
+		truncated bool
+	}{
+		{"", 0, "", false},
+		{"a", 0, "", true},
+		{"abc", 2, "ab", true},
+		{"abc", 3, "abc", false},
+		{"abc", 4, "abc", false},
+	} {
+		dst := new(bytes.Buffer)
+		written, truncated, err := CopyWithLimit(dst, strings.NewReader(c.src), c.limit)
+		require.NoError(t, err)
+		require.Equal(t, c.expect, dst.String(), c)
+		require.Equal(t, int64(len(c.expect)), written, c)
+		require.Equal(t, c.truncated, truncated, c)
+	}
+
+	_, _, err := CopyWithLimit(io.Discard, strings.NewReader("abc"), -1)
+	require.Error(t, err)
+}
+
+func TestCountingReaderWriter(t *testing.T) {
+	t.Parallel()
+
+	const size = 1024 * 1024
+	data := make([]byte, size)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+
+	r := NewCountingReader(bytes.NewReader(data))
+	w := NewCountingWriter(io.Discard)
+
+	var (
+		pool     sync.WaitGroup
+		done     = make(chan struct{})
+		violated atomic.Bool
+	)
+	pool.Add(1)
+	go func() {
+		defer pool.Done()
+		var last int64
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			n := r.Count()
+			if n < last || w.Count() > n {
+				violated.Store(true)
+			}
+			last = n
+		}
+	}()
+
+	n, err := io.CopyBuffer(w, r, make([]byte, 1024))
+	close(done)
+	pool.Wait()
+
+	require.NoError(t, err)
+	require.False(t, violated.Load())
+	require.Equal(t, int64(size), n)
+	require.Equal(t, int64(size), r.Count())
+	require.Equal(t, int64(size), w.Count())
+}
+
+func TestNewTeeHasher(t *testing.T) {
+	t.Parallel()
+
+	data := make([]byte, 100*1024)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+
+	for _, ht := range []HashType{HashTypeSha1, HashTypeSha256, HashTypeSha512, HashTypeXxhash} {
+		reader, digest, err := NewTeeHasher(bytes.NewReader(data), ht)
+		require.NoError(t, err)
+
+		dst := new(bytes.Buffer)
+		_, err = io.Copy(dst, reader)
+		require.NoError(t, err)
+		require.Equal(t, data, dst.Bytes())
+
+		expect, err := Hash(ht, bytes.NewReader(data))
+		require.NoError(t, err)
+		require.Equal(t, expect, digest(), ht)
+	}
+
+	_, _, err = NewTeeHasher(bytes.NewReader(data), HashType("unknown"))
+	require.Error(t, err)
+}
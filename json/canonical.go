@@ -0,0 +1,218 @@
+package json
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/Laisky/errors/v2"
+)
+
+// MarshalCanonical marshal v to canonical json defined by RFC 8785 (JCS),
+// the output is deterministic and suitable for hashing or signing.
+//
+//   - object keys are sorted by UTF-16 code units at every level
+//   - no insignificant whitespace
+//   - no HTML escaping, only `"`, `\` and control characters are escaped
+//   - numbers are formatted as ECMAScript's Number.prototype.toString
+//
+// v is first marshaled by encoding/json, so struct tags are respected.
+// strings are kept as-is without unicode normalization, as required by RFC 8785.
+func MarshalCanonical(v any) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var val any
+	if err = dec.Decode(&val); err != nil {
+		return nil, errors.Wrap(err, "decode")
+	}
+
+	buf := new(bytes.Buffer)
+	if err = writeCanonical(buf, val); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeCanonical(buf *bytes.Buffer, val any) error {
+	switch val := val.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(val))
+	case string:
+		writeCanonicalString(buf, val)
+	case json.Number:
+		f, err := val.Float64()
+		if err != nil {
+			return errors.Wrapf(err, "parse number %q", val.String())
+		}
+
+		num, err := formatCanonicalNumber(f)
+		if err != nil {
+			return err
+		}
+
+		buf.WriteString(num)
+	case []any:
+		buf.WriteByte('[')
+		for i, v := range val {
+			if i != 0 {
+				buf.WriteByte(',')
+			}
+
+			if err := writeCanonical(buf, v); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]any:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sortUTF16(keys)
+
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i != 0 {
+				buf.WriteByte(',')
+			}
+
+			writeCanonicalString(buf, k)
+			buf.WriteByte(':')
+			if err := writeCanonical(buf, val[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return errors.Errorf("unsupported type %T", val)
+	}
+
+	return nil
+}
+
+// sortUTF16 sort strings by their UTF-16 code units
+func sortUTF16(keys []string) {
+	encoded := make(map[string][]uint16, len(keys))
+	for _, k := range keys {
+		encoded[k] = utf16.Encode([]rune(k))
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := encoded[keys[i]], encoded[keys[j]]
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+
+		return len(a) < len(b)
+	})
+}
+
+const hexDigits = "0123456789abcdef"
+
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			buf.WriteRune(r)
+			i += size
+			continue
+		}
+
+		switch c {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if c < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hexDigits[c>>4])
+				buf.WriteByte(hexDigits[c&0xf])
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+
+		i++
+	}
+	buf.WriteByte('"')
+}
+
+// formatCanonicalNumber format f as ECMAScript's Number.prototype.toString
+func formatCanonicalNumber(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", errors.Errorf("invalid number %v", f)
+	}
+
+	if f == 0 { // also covers -0
+		return "0", nil
+	}
+
+	var sign string
+	if f < 0 {
+		sign = "-"
+		f = -f
+	}
+
+	// shortest representation like "d.ddddde±dd"
+	sci := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, expStr, _ := strings.Cut(sci, "e")
+	exp, err := strconv.Atoi(expStr)
+	if err != nil {
+		return "", errors.Wrapf(err, "parse exponent of %q", sci)
+	}
+
+	digits := strings.Replace(mantissa, ".", "", 1)
+	k := len(digits)
+	n := exp + 1 // f = 0.digits * 10^n
+
+	var out string
+	switch {
+	case k <= n && n <= 21:
+		out = digits + strings.Repeat("0", n-k)
+	case 0 < n && n <= 21:
+		out = digits[:n] + "." + digits[n:]
+	case -6 < n && n <= 0:
+		out = "0." + strings.Repeat("0", -n) + digits
+	default:
+		expSign := "+"
+		if n-1 < 0 {
+			expSign = "-"
+		}
+
+		out = digits[:1]
+		if k > 1 {
+			out += "." + digits[1:]
+		}
+		out += "e" + expSign + strconv.Itoa(int(math.Abs(float64(n-1))))
+	}
+
+	return sign + out, nil
+}
@@ -0,0 +1,138 @@
+package json
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalCanonical(t *testing.T) {
+	t.Parallel()
+
+	t.Run("rfc8785 sample", func(t *testing.T) {
+		var v any
+		err := Unmarshal([]byte(`{
+			"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+			"string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+			"literals": [null, true, false]
+		}`), &v)
+		require.NoError(t, err)
+
+		got, err := MarshalCanonical(v)
+		require.NoError(t, err)
+		require.Equal(t,
+			`{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],`+
+				`"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+			string(got))
+	})
+
+	t.Run("rfc8785 key sorting", func(t *testing.T) {
+		var v any
+		err := Unmarshal([]byte(`{
+			"\u20ac": "Euro Sign",
+			"\r": "Carriage Return",
+			"\ufb33": "Hebrew Letter Dalet With Dagesh",
+			"1": "One",
+			"\ud83d\ude00": "Emoji: Grinning Face",
+			"\u0080": "Control",
+			"\u00f6": "Latin Small Letter O With Diaeresis"
+		}`), &v)
+		require.NoError(t, err)
+
+		got, err := MarshalCanonical(v)
+		require.NoError(t, err)
+		require.Equal(t,
+			"{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\","+
+				"\"ö\":\"Latin Small Letter O With Diaeresis\",\"€\":\"Euro Sign\","+
+				"\"😀\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}",
+			string(got))
+	})
+
+	t.Run("no html escape", func(t *testing.T) {
+		got, err := MarshalCanonical(map[string]any{"b": "<a&b>", "a": []int{1, 2}})
+		require.NoError(t, err)
+		require.Equal(t, `{"a":[1,2],"b":"<a&b>"}`, string(got))
+	})
+
+	t.Run("struct", func(t *testing.T) {
+		type inner struct {
+			Z int    `json:"z"`
+			A string `json:"a"`
+			X string `json:"-"`
+		}
+		type outer struct {
+			Inner inner  `json:"inner"`
+			Name  string `json:"name"`
+		}
+
+		got, err := MarshalCanonical(outer{Inner: inner{Z: 1, A: "a", X: "x"}, Name: "n"})
+		require.NoError(t, err)
+		require.Equal(t, `{"inner":{"a":"a","z":1},"name":"n"}`, string(got))
+	})
+
+	t.Run("insertion order", func(t *testing.T) {
+		m1 := map[string]any{}
+		m1["b"] = 2
+		m1["a"] = map[string]any{"y": 1.50, "x": []any{"s", 1e21}}
+		m1["c"] = nil
+
+		m2 := map[string]any{}
+		m2["c"] = nil
+		m2["a"] = map[string]any{"x": []any{"s", 1e21}, "y": 1.5}
+		m2["b"] = 2.0
+
+		got1, err := MarshalCanonical(m1)
+		require.NoError(t, err)
+		got2, err := MarshalCanonical(m2)
+		require.NoError(t, err)
+		require.Equal(t, string(got1), string(got2))
+		require.Equal(t, `{"a":{"x":["s",1e+21],"y":1.5},"b":2,"c":null}`, string(got1))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := MarshalCanonical(math.NaN())
+		require.Error(t, err)
+	})
+}
+
+func TestFormatCanonicalNumber(t *testing.T) {
+	t.Parallel()
+
+	// test vectors from RFC 8785 appendix B
+	for bits, expect := range map[uint64]string{
+		0x0000000000000000: "0",
+		0x8000000000000000: "0",
+		0x0000000000000001: "5e-324",
+		0x8000000000000001: "-5e-324",
+		0x7fefffffffffffff: "1.7976931348623157e+308",
+		0xffefffffffffffff: "-1.7976931348623157e+308",
+		0x4340000000000000: "9007199254740992",
+		0xc340000000000000: "-9007199254740992",
+		0x4430000000000000: "295147905179352830000",
+		0x44b52d02c7e14af5: "9.999999999999997e+22",
+		0x44b52d02c7e14af6: "1e+23",
+		0x44b52d02c7e14af7: "1.0000000000000001e+23",
+		0x444b1ae4d6e2ef4e: "999999999999999700000",
+		0x444b1ae4d6e2ef4f: "999999999999999900000",
+		0x444b1ae4d6e2ef50: "1e+21",
+		0x3eb0c6f7a0b5ed8c: "9.999999999999997e-7",
+		0x3eb0c6f7a0b5ed8d: "0.000001",
+		0x41b3de4355555553: "333333333.3333332",
+		0x41b3de4355555554: "333333333.33333325",
+		0x41b3de4355555555: "333333333.3333333",
+		0x41b3de4355555556: "333333333.3333334",
+		0x41b3de4355555557: "333333333.33333343",
+		0xbecbf647612f3696: "-0.0000033333333333333333",
+		0x43143ff3c1cb0959: "1424953923781206.2",
+	} {
+		got, err := formatCanonicalNumber(math.Float64frombits(bits))
+		require.NoError(t, err)
+		require.Equal(t, expect, got, "%x", bits)
+	}
+
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		_, err := formatCanonicalNumber(f)
+		require.Error(t, err)
+	}
+}
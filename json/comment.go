@@ -1,6 +1,7 @@
 package json
 
 import (
+	"bytes"
 	"encoding/json"
 
 	"github.com/Laisky/go-utils/v4/common"
@@ -13,6 +14,8 @@ import (
 var (
 	// Unmarshal unmarshal json, do not support comment
 	Unmarshal = json.Unmarshal
+	// Valid reports whether data is a valid JSON encoding, do not support comment
+	Valid = json.Valid
 )
 
 // UnmarshalFromString unmarshal json from string, do not support comment
@@ -43,6 +46,25 @@ func UnmarshalCommentFromString(str string, v interface{}) (err error) {
 	return UnmarshalComment(common.Str2Bytes(str), v)
 }
 
+// ValidComment reports whether data is a valid JSON encoding
+// with comments and trailing commas, like JSONC.
+//
+// unlike UnmarshalComment, data will not be changed.
+func ValidComment(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+
+	// hujson standardizes in place
+	ast, err := hujson.Parse(bytes.Clone(data))
+	if err != nil {
+		return false
+	}
+
+	ast.Standardize()
+	return Valid(ast.Pack())
+}
+
 func standardizeJSON(b []byte) ([]byte, error) {
 	ast, err := hujson.Parse(b)
 	if err != nil {
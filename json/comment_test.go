@@ -41,3 +41,37 @@ func TestUnmarshalCommentFromString(t *testing.T) {
 		require.Empty(t, m)
 	})
 }
+
+func TestUnmarshalComment_URLInString(t *testing.T) {
+	raw := []byte(`{
+		/* block
+		   comment */
+		"url": "http://example.com/a//b", // trailing comment
+		"glob": "/*.json",
+	}`)
+
+	m := map[string]string{}
+	err := UnmarshalComment(raw, &m)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"url":  "http://example.com/a//b",
+		"glob": "/*.json",
+	}, m)
+}
+
+func TestValidComment(t *testing.T) {
+	raw := []byte(`{
+		// comment
+		"url": "http://example.com", /* comment */
+	}`)
+	require.True(t, ValidComment(raw))
+	require.False(t, Valid(raw))
+	require.Contains(t, string(raw), "// comment", "should not change raw")
+
+	require.True(t, Valid([]byte(`{"a": 1}`)))
+	require.True(t, ValidComment([]byte(`{"a": 1}`)))
+
+	for _, raw := range []string{"", "{", `{"a": }`, `{"a": 1} /`, "/* unclosed {}"} {
+		require.False(t, ValidComment([]byte(raw)), raw)
+	}
+}
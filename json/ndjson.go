@@ -0,0 +1,189 @@
+package json
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/Laisky/errors/v2"
+)
+
+// NDJSONReader read newline delimited json records one by one
+type NDJSONReader struct {
+	reader *bufio.Reader
+	lineNo int
+}
+
+// NewNDJSONReader create new NDJSONReader
+//
+// blank lines and trailing newlines are ignored.
+func NewNDJSONReader(r io.Reader) *NDJSONReader {
+	return &NDJSONReader{
+		reader: bufio.NewReader(r),
+	}
+}
+
+// Next decode next record into v,
+// return io.EOF if there is no more records.
+func (r *NDJSONReader) Next(v any) error {
+	for {
+		line, err := r.reader.ReadBytes('\n')
+		if len(line) != 0 {
+			r.lineNo++
+			if line = bytes.TrimSpace(line); len(line) != 0 {
+				if err := json.Unmarshal(line, v); err != nil {
+					return errors.Wrapf(err, "decode line %d", r.lineNo)
+				}
+
+				return nil
+			}
+		}
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return io.EOF
+			}
+
+			return errors.Wrapf(err, "read line %d", r.lineNo+1)
+		}
+	}
+}
+
+// LineNo return the line number of last read record
+func (r *NDJSONReader) LineNo() int {
+	return r.lineNo
+}
+
+type ndjsonWriterOption struct {
+	flushEvery int
+}
+
+// NDJSONWriterOption option for NewNDJSONWriter
+type NDJSONWriterOption func(*ndjsonWriterOption) error
+
+// WithNDJSONFlushEvery flush buffered records to underlying writer every n records,
+// default is 1.
+//
+// records are buffered until Flush is called if n is 0.
+func WithNDJSONFlushEvery(n int) NDJSONWriterOption {
+	return func(o *ndjsonWriterOption) error {
+		if n < 0 {
+			return errors.Errorf("flush every should not be negative, got %d", n)
+		}
+
+		o.flushEvery = n
+		return nil
+	}
+}
+
+// NDJSONWriter write records as newline delimited json
+type NDJSONWriter struct {
+	opt     *ndjsonWriterOption
+	writer  *bufio.Writer
+	encoder *json.Encoder
+	nBuffed int
+}
+
+// NewNDJSONWriter create new NDJSONWriter
+func NewNDJSONWriter(w io.Writer, opts ...NDJSONWriterOption) (*NDJSONWriter, error) {
+	opt := &ndjsonWriterOption{
+		flushEvery: 1,
+	}
+	for _, optf := range opts {
+		if err := optf(opt); err != nil {
+			return nil, errors.Wrap(err, "apply option")
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	return &NDJSONWriter{
+		opt:     opt,
+		writer:  bw,
+		encoder: json.NewEncoder(bw),
+	}, nil
+}
+
+// Write encode v as one line
+func (w *NDJSONWriter) Write(v any) error {
+	// json.Encoder will append a newline after each record
+	if err := w.encoder.Encode(v); err != nil {
+		return errors.Wrap(err, "encode record")
+	}
+
+	w.nBuffed++
+	if w.opt.flushEvery > 0 && w.nBuffed >= w.opt.flushEvery {
+		return w.Flush()
+	}
+
+	return nil
+}
+
+// Flush write all buffered records to underlying writer
+func (w *NDJSONWriter) Flush() error {
+	w.nBuffed = 0
+	if err := w.writer.Flush(); err != nil {
+		return errors.Wrap(err, "flush")
+	}
+
+	return nil
+}
+
+// UnmarshalWithLimit unmarshal data into v,
+// reject data that larger than maxBytes or nested deeper than maxDepth
+// before decoding.
+//
+// maxBytes or maxDepth <= 0 means no limit.
+func UnmarshalWithLimit(data []byte, v any, maxBytes int, maxDepth int) error {
+	if maxBytes > 0 && len(data) > maxBytes {
+		return errors.Errorf("json size %d exceeds limit %d", len(data), maxBytes)
+	}
+
+	if maxDepth > 0 {
+		if depth := jsonDepth(data, maxDepth); depth > maxDepth {
+			return errors.Errorf("json depth exceeds limit %d", maxDepth)
+		}
+	}
+
+	return Unmarshal(data, v)
+}
+
+// jsonDepth return the max nesting depth of data,
+// stop scanning once depth exceeds limit.
+func jsonDepth(data []byte, limit int) (maxDepth int) {
+	var (
+		depth             int
+		inString, escaped bool
+	)
+	for _, c := range data {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if depth > maxDepth {
+				maxDepth = depth
+				if maxDepth > limit {
+					return maxDepth
+				}
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+
+	return maxDepth
+}
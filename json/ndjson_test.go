@@ -0,0 +1,137 @@
+package json
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNDJSONReader(t *testing.T) {
+	t.Parallel()
+
+	type record struct {
+		ID int `json:"id"`
+	}
+
+	t.Run("blank lines", func(t *testing.T) {
+		r := NewNDJSONReader(strings.NewReader("\n{\"id\":1}\n   \n{\"id\":2}\n\n"))
+
+		var got []int
+		for {
+			var rec record
+			err := r.Next(&rec)
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			got = append(got, rec.ID)
+		}
+
+		require.Equal(t, []int{1, 2}, got)
+	})
+
+	t.Run("no trailing newline", func(t *testing.T) {
+		r := NewNDJSONReader(strings.NewReader(`{"id":1}`))
+
+		var rec record
+		require.NoError(t, r.Next(&rec))
+		require.Equal(t, 1, rec.ID)
+		require.ErrorIs(t, r.Next(&rec), io.EOF)
+	})
+
+	t.Run("corrupt line", func(t *testing.T) {
+		r := NewNDJSONReader(strings.NewReader("{\"id\":1}\n{\"id\":2}\n{\"id\":\n{\"id\":4}\n"))
+
+		var rec record
+		require.NoError(t, r.Next(&rec))
+		require.NoError(t, r.Next(&rec))
+		err := r.Next(&rec)
+		require.ErrorContains(t, err, "decode line 3")
+
+		// continue with the next line
+		require.NoError(t, r.Next(&rec))
+		require.Equal(t, 4, rec.ID)
+	})
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	t.Parallel()
+
+	type record struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		const n = 100000
+		buf := new(bytes.Buffer)
+		w, err := NewNDJSONWriter(buf, WithNDJSONFlushEvery(1000))
+		require.NoError(t, err)
+
+		for i := 0; i < n; i++ {
+			require.NoError(t, w.Write(record{ID: i, Name: "laisky"}))
+		}
+		require.NoError(t, w.Flush())
+
+		r := NewNDJSONReader(buf)
+		var cnt int
+		for {
+			var rec record
+			err := r.Next(&rec)
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			require.Equal(t, cnt, rec.ID)
+			cnt++
+		}
+
+		require.Equal(t, n, cnt)
+		require.Equal(t, n, r.LineNo())
+	})
+
+	t.Run("flush every", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		w, err := NewNDJSONWriter(buf, WithNDJSONFlushEvery(2))
+		require.NoError(t, err)
+
+		require.NoError(t, w.Write(record{ID: 1}))
+		require.Zero(t, buf.Len())
+		require.NoError(t, w.Write(record{ID: 2}))
+		require.Equal(t, "{\"id\":1,\"name\":\"\"}\n{\"id\":2,\"name\":\"\"}\n", buf.String())
+	})
+
+	t.Run("invalid option", func(t *testing.T) {
+		_, err := NewNDJSONWriter(io.Discard, WithNDJSONFlushEvery(-1))
+		require.Error(t, err)
+	})
+}
+
+func TestUnmarshalWithLimit(t *testing.T) {
+	t.Parallel()
+
+	var v any
+	require.NoError(t, UnmarshalWithLimit([]byte(`{"a":[1,{"b":2}]}`), &v, 100, 3))
+
+	t.Run("exceed bytes", func(t *testing.T) {
+		err := UnmarshalWithLimit([]byte(`{"a":"123456789"}`), &v, 10, 0)
+		require.ErrorContains(t, err, "exceeds limit 10")
+	})
+
+	t.Run("exceed depth", func(t *testing.T) {
+		data := []byte(strings.Repeat("[", 1000) + strings.Repeat("]", 1000))
+		err := UnmarshalWithLimit(data, &v, 0, 32)
+		require.ErrorContains(t, err, "depth exceeds limit 32")
+
+		err = UnmarshalWithLimit([]byte(`{"a":[1,{"b":2}]}`), &v, 0, 2)
+		require.ErrorContains(t, err, "depth exceeds limit 2")
+	})
+
+	t.Run("brackets in string", func(t *testing.T) {
+		err := UnmarshalWithLimit([]byte(`{"a":"[[[[\"{{{{"}`), &v, 0, 1)
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,45 @@
+package json
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/Laisky/errors/v2"
+)
+
+// DecodeArrayStream decode JSON array from r element by element,
+// call fn for each element, so the whole array is never loaded into memory.
+//
+// stop and return the error of fn once fn failed,
+// data after the closing `]` other than whitespace is an error.
+func DecodeArrayStream[T any](r io.Reader, fn func(T) error) error {
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err != nil {
+		return errors.Wrap(err, "read opening bracket")
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return errors.Errorf("json should be an array, got %v", tok)
+	}
+
+	for i := 0; dec.More(); i++ {
+		var v T
+		if err = dec.Decode(&v); err != nil {
+			return errors.Wrapf(err, "decode element %d", i)
+		}
+
+		if err = fn(v); err != nil {
+			return errors.Wrapf(err, "handle element %d", i)
+		}
+	}
+
+	if _, err = dec.Token(); err != nil {
+		return errors.Wrap(err, "read closing bracket")
+	}
+
+	if _, err = dec.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("unexpected data after array")
+	}
+
+	return nil
+}
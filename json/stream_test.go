@@ -0,0 +1,87 @@
+package json
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Laisky/errors/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type testStreamItem struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// testArrayReader generate `[{"id":0,...},{"id":1,...}]` without holding it in memory
+func testArrayReader(n int) io.Reader {
+	readers := []io.Reader{strings.NewReader("[\n")}
+	for i := 0; i < n; i++ {
+		sep := ","
+		if i == n-1 {
+			sep = ""
+		}
+
+		readers = append(readers, strings.NewReader(
+			fmt.Sprintf(`  {"id": %d, "name": "item-%d"}%s`+"\n", i, i, sep)))
+	}
+	readers = append(readers, strings.NewReader("]\n"))
+
+	return io.MultiReader(readers...)
+}
+
+func TestDecodeArrayStream(t *testing.T) {
+	t.Run("large array", func(t *testing.T) {
+		const n = 100000
+		var (
+			count int
+			sum   int
+		)
+		err := DecodeArrayStream(testArrayReader(n), func(item testStreamItem) error {
+			require.Equal(t, fmt.Sprintf("item-%d", item.ID), item.Name)
+			count++
+			sum += item.ID
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, n, count)
+		require.Equal(t, n*(n-1)/2, sum)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		err := DecodeArrayStream(strings.NewReader(" [ ] "), func(int) error {
+			return errors.New("should not be called")
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("stop on fn error", func(t *testing.T) {
+		errStop := errors.New("stop")
+		var got []int
+		err := DecodeArrayStream(strings.NewReader("[1, 2, 3, 4]"), func(v int) error {
+			if v == 3 {
+				return errStop
+			}
+
+			got = append(got, v)
+			return nil
+		})
+		require.ErrorIs(t, err, errStop)
+		require.Equal(t, []int{1, 2}, got)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, raw := range []string{
+			"",
+			`{"a": 1}`,
+			`[1, "a"]`,
+			`[1, 2`,
+			`[1, 2] 3`,
+		} {
+			err := DecodeArrayStream(strings.NewReader(raw), func(int) error { return nil })
+			require.Error(t, err, raw)
+		}
+	})
+}
@@ -0,0 +1,276 @@
+package utils
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/Laisky/errors/v2"
+)
+
+// pathSegment one segment of path, either a map key or a slice index
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parseGetPath parse path like `data.items[0].name`.
+//
+// `\` escapes the next char, so `a\.b` is the key "a.b".
+func parseGetPath(path string) ([]pathSegment, error) {
+	var (
+		segs    []pathSegment
+		key     strings.Builder
+		hasKey  bool
+		afterIx bool // just closed an index, expect `.` or `[`
+	)
+	flushKey := func() {
+		if hasKey {
+			segs = append(segs, pathSegment{key: key.String()})
+		}
+
+		key.Reset()
+		hasKey = false
+	}
+
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if afterIx && c != '.' && c != '[' {
+			return nil, errors.Errorf("unexpected %q after index at %d", c, i)
+		}
+
+		switch c {
+		case '\\':
+			if i+1 >= len(path) {
+				return nil, errors.Errorf("dangling escape at %d", i)
+			}
+
+			i++
+			key.WriteByte(path[i])
+			hasKey = true
+		case '.':
+			if !hasKey && !afterIx {
+				return nil, errors.Errorf("empty key at %d", i)
+			}
+
+			flushKey()
+			afterIx = false
+			if i == len(path)-1 {
+				return nil, errors.Errorf("empty key at %d", i+1)
+			}
+		case '[':
+			flushKey()
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, errors.Errorf("unclosed `[` at %d", i)
+			}
+
+			idx, err := strconv.Atoi(path[i+1 : i+end])
+			if err != nil || idx < 0 {
+				return nil, errors.Errorf("invalid index %q at %d", path[i+1:i+end], i)
+			}
+
+			segs = append(segs, pathSegment{index: idx, isIndex: true})
+			i += end
+			afterIx = true
+		default:
+			key.WriteByte(c)
+			hasKey = true
+		}
+	}
+	flushKey()
+
+	return segs, nil
+}
+
+// GetByPath get value from nested maps and slices by path,
+// return false if path is invalid or not found.
+//
+// path is dotted keys with bracketed indices, like `data.items[0].name`.
+// use `\` to escape `.`, `[` or `\` in keys, like `a\.b`.
+// empty path returns data itself.
+//
+// it is designed for payloads decoded from json, like map[string]any and []any,
+// other maps with string keys and slices are supported by reflection.
+func GetByPath(data any, path string) (any, bool) {
+	segs, err := parseGetPath(path)
+	if err != nil {
+		return nil, false
+	}
+
+	cur := data
+	for _, seg := range segs {
+		var ok bool
+		if seg.isIndex {
+			cur, ok = getByIndex(cur, seg.index)
+		} else {
+			cur, ok = getByKey(cur, seg.key)
+		}
+
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return cur, true
+}
+
+func getByKey(data any, key string) (any, bool) {
+	switch data := data.(type) {
+	case map[string]any:
+		v, ok := data[key]
+		return v, ok
+	case map[string]string:
+		v, ok := data[key]
+		return v, ok
+	case nil:
+		return nil, false
+	}
+
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+
+	item := v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key()))
+	if !item.IsValid() {
+		return nil, false
+	}
+
+	return item.Interface(), true
+}
+
+func getByIndex(data any, idx int) (any, bool) {
+	switch data := data.(type) {
+	case []any:
+		if idx >= len(data) {
+			return nil, false
+		}
+
+		return data[idx], true
+	case nil:
+		return nil, false
+	}
+
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, false
+	}
+	if idx >= v.Len() {
+		return nil, false
+	}
+
+	return v.Index(idx).Interface(), true
+}
+
+// GetStringByPath get string by path, see GetByPath for path syntax.
+//
+// string, []byte and json.Number are accepted, otherwise return ("", false).
+func GetStringByPath(data any, path string) (string, bool) {
+	v, ok := GetByPath(data, path)
+	if !ok {
+		return "", false
+	}
+
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	case json.Number:
+		return v.String(), true
+	default:
+		return "", false
+	}
+}
+
+// GetIntByPath get int by path, see GetByPath for path syntax.
+//
+// all integer types are accepted, floats (like json numbers decoded as float64)
+// and json.Number are accepted only if they are integral and fit in int,
+// otherwise return (0, false).
+func GetIntByPath(data any, path string) (int, bool) {
+	v, ok := GetByPath(data, path)
+	if !ok {
+		return 0, false
+	}
+
+	switch v := v.(type) {
+	case int:
+		return v, true
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return int64ToInt(i)
+		}
+
+		f, err := v.Float64()
+		if err != nil {
+			return 0, false
+		}
+
+		return float64ToInt(f)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int64ToInt(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > math.MaxInt {
+			return 0, false
+		}
+
+		return int(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return float64ToInt(rv.Float())
+	default:
+		return 0, false
+	}
+}
+
+func int64ToInt(v int64) (int, bool) {
+	if v > math.MaxInt || v < math.MinInt {
+		return 0, false
+	}
+
+	return int(v), true
+}
+
+func float64ToInt(f float64) (int, bool) {
+	// float64(math.MaxInt) rounds up to 2^63, so use `>=`
+	if f != math.Trunc(f) || f >= float64(math.MaxInt) || f < float64(math.MinInt) {
+		return 0, false
+	}
+
+	return int(f), true
+}
+
+// GetSliceByPath get slice by path, see GetByPath for path syntax.
+//
+// []any is returned as is, other slices are copied to []any,
+// otherwise return (nil, false).
+func GetSliceByPath(data any, path string) ([]any, bool) {
+	v, ok := GetByPath(data, path)
+	if !ok {
+		return nil, false
+	}
+
+	if s, ok := v.([]any); ok {
+		return s, true
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return nil, false
+	}
+
+	s := make([]any, rv.Len())
+	for i := range s {
+		s[i] = rv.Index(i).Interface()
+	}
+
+	return s, true
+}
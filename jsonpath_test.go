@@ -0,0 +1,208 @@
+package utils
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testGetByPathPayload = `{
+	"data": {
+		"total": 2,
+		"ratio": 0.5,
+		"big": 1e300,
+		"items": [
+			{"name": "laisky", "tags": ["a", "b"], "matrix": [[1, 2], [3, 4]]},
+			{"name": "yo", "tags": [], "score": -3}
+		],
+		"a.b": {"c": "escaped"},
+		"x[0]": "bracket",
+		"back\\slash": "backslash",
+		"null": null
+	}
+}`
+
+func testGetByPathData(t *testing.T) map[string]any {
+	t.Helper()
+
+	var data map[string]any
+	require.NoError(t, json.Unmarshal([]byte(testGetByPathPayload), &data))
+	return data
+}
+
+func TestGetByPath(t *testing.T) {
+	t.Parallel()
+	data := testGetByPathData(t)
+
+	for _, tt := range []struct {
+		path string
+		want any
+		ok   bool
+	}{
+		{"data.items[0].name", "laisky", true},
+		{"data.items[1].name", "yo", true},
+		{"data.items[0].tags[1]", "b", true},
+		{"data.items[0].matrix[1][0]", float64(3), true},
+		{`data.a\.b.c`, "escaped", true},
+		{`data.x\[0\]`, "bracket", true},
+		{`data.back\\slash`, "backslash", true},
+		{"data.null", nil, true},
+		{"data.total", float64(2), true},
+
+		// missing
+		{"data.items[2].name", nil, false},
+		{"data.items[0].tags[2]", nil, false},
+		{"data.notexists", nil, false},
+		{"data.a.b.c", nil, false},
+		{"data.null.x", nil, false},
+
+		// wrong type
+		{"data.items.name", nil, false},
+		{"data[0]", nil, false},
+		{"data.total.x", nil, false},
+		{"data.items[0].name[0]", nil, false},
+
+		// invalid path
+		{"data..items", nil, false},
+		{".data", nil, false},
+		{"data.", nil, false},
+		{"data.items[", nil, false},
+		{"data.items[-1]", nil, false},
+		{"data.items[a]", nil, false},
+		{"data.items[0]name", nil, false},
+		{`data\`, nil, false},
+	} {
+		got, ok := GetByPath(data, tt.path)
+		require.Equal(t, tt.ok, ok, tt.path)
+		require.Equal(t, tt.want, got, tt.path)
+	}
+
+	t.Run("empty path", func(t *testing.T) {
+		got, ok := GetByPath(data, "")
+		require.True(t, ok)
+		require.Equal(t, data, got)
+	})
+
+	t.Run("leading index", func(t *testing.T) {
+		got, ok := GetByPath([]any{map[string]any{"a": 1}}, "[0].a")
+		require.True(t, ok)
+		require.Equal(t, 1, got)
+	})
+
+	t.Run("reflect", func(t *testing.T) {
+		type key string
+		v := map[key][]map[string]int{"a": {{"b": 1}}}
+		got, ok := GetByPath(v, "a[0].b")
+		require.True(t, ok)
+		require.Equal(t, 1, got)
+
+		got, ok = GetByPath([2]string{"x", "y"}, "[1]")
+		require.True(t, ok)
+		require.Equal(t, "y", got)
+
+		_, ok = GetByPath(map[int]any{1: "x"}, "1")
+		require.False(t, ok)
+	})
+}
+
+func TestGetTypedByPath(t *testing.T) {
+	t.Parallel()
+	data := testGetByPathData(t)
+
+	t.Run("string", func(t *testing.T) {
+		s, ok := GetStringByPath(data, "data.items[0].name")
+		require.True(t, ok)
+		require.Equal(t, "laisky", s)
+
+		s, ok = GetStringByPath(map[string]any{"n": json.Number("12")}, "n")
+		require.True(t, ok)
+		require.Equal(t, "12", s)
+
+		for _, path := range []string{"data.total", "data.items", "data.null", "data.notexists"} {
+			s, ok = GetStringByPath(data, path)
+			require.False(t, ok, path)
+			require.Empty(t, s, path)
+		}
+	})
+
+	t.Run("int", func(t *testing.T) {
+		n, ok := GetIntByPath(data, "data.total")
+		require.True(t, ok)
+		require.Equal(t, 2, n)
+
+		n, ok = GetIntByPath(data, "data.items[1].score")
+		require.True(t, ok)
+		require.Equal(t, -3, n)
+
+		n, ok = GetIntByPath(map[string]any{"nf": json.Number("12.0")}, "nf")
+		require.True(t, ok)
+		require.Equal(t, 12, n)
+
+		for _, v := range []any{int8(-8), uint16(8), json.Number("12")} {
+			n, ok = GetIntByPath(map[string]any{"v": v}, "v")
+			require.True(t, ok, v)
+			require.NotZero(t, n, v)
+		}
+
+		for _, v := range []any{
+			uint64(math.MaxUint64), float64(1 << 63), math.NaN(), math.Inf(1),
+			json.Number("1.5"), json.Number("1e300"), "12", true,
+		} {
+			n, ok = GetIntByPath(map[string]any{"v": v}, "v")
+			require.False(t, ok, v)
+			require.Zero(t, n, v)
+		}
+
+		for _, path := range []string{"data.ratio", "data.big", "data.items[0].name", "data.null", "data.notexists"} {
+			n, ok = GetIntByPath(data, path)
+			require.False(t, ok, path)
+			require.Zero(t, n, path)
+		}
+	})
+
+	t.Run("slice", func(t *testing.T) {
+		s, ok := GetSliceByPath(data, "data.items[0].tags")
+		require.True(t, ok)
+		require.Equal(t, []any{"a", "b"}, s)
+
+		s, ok = GetSliceByPath(data, "data.items[1].tags")
+		require.True(t, ok)
+		require.Empty(t, s)
+
+		s, ok = GetSliceByPath(map[string]any{"v": []int{1, 2}}, "v")
+		require.True(t, ok)
+		require.Equal(t, []any{1, 2}, s)
+
+		for _, path := range []string{"data.total", "data.items[0]", "data.null", "data.notexists"} {
+			s, ok = GetSliceByPath(data, path)
+			require.False(t, ok, path)
+			require.Nil(t, s, path)
+		}
+	})
+}
+
+func BenchmarkGetByPath(b *testing.B) {
+	var data map[string]any
+	if err := json.Unmarshal([]byte(testGetByPathPayload), &data); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("GetStringByPath", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if v, ok := GetStringByPath(data, "data.items[0].name"); !ok || v != "laisky" {
+				b.Fatal("unexpected")
+			}
+		}
+	})
+
+	b.Run("manual", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			v := data["data"].(map[string]any)["items"].([]any)[0].(map[string]any)["name"].(string) //nolint:forcetypeassert
+			if v != "laisky" {
+				b.Fatal("unexpected")
+			}
+		}
+	})
+}
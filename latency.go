@@ -0,0 +1,215 @@
+package utils
+
+import (
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/Laisky/errors/v2"
+)
+
+type latencyTrackerOption struct {
+	min, max time.Duration
+	growth   float64
+}
+
+// LatencyTrackerOption options for NewLatencyTracker
+type LatencyTrackerOption func(*latencyTrackerOption) error
+
+// WithLatencyTrackerBounds set the range covered by exponential buckets,
+// default is [100µs, 1min].
+//
+// durations below min or above max are still counted,
+// but their percentiles are only bounded by the observed min/max.
+func WithLatencyTrackerBounds(min, max time.Duration) LatencyTrackerOption {
+	return func(opt *latencyTrackerOption) error {
+		if min <= 0 || max <= min {
+			return errors.Errorf("bounds should satisfy 0 < min < max, got [%s, %s]", min, max)
+		}
+
+		opt.min, opt.max = min, max
+		return nil
+	}
+}
+
+// minLatencyTrackerGrowth lower limit of growth,
+// keeps the number of buckets below about 4400 even for [1ns, MaxInt64]
+const minLatencyTrackerGrowth = 1.01
+
+// WithLatencyTrackerGrowth set the ratio between adjacent bucket bounds,
+// default is 1.1, means the relative error of percentile is within 10%.
+//
+// growth should not be less than 1.01.
+func WithLatencyTrackerGrowth(growth float64) LatencyTrackerOption {
+	return func(opt *latencyTrackerOption) error {
+		if !(growth >= minLatencyTrackerGrowth) || math.IsInf(growth, 0) {
+			return errors.Errorf("growth should not be less than %v, got %v",
+				minLatencyTrackerGrowth, growth)
+		}
+
+		opt.growth = growth
+		return nil
+	}
+}
+
+// LatencyTracker track latency distribution by fixed exponential buckets,
+// memory is constant and Observe only does atomic operations.
+//
+// it is safe for concurrent use.
+type LatencyTracker struct {
+	// bounds upper bounds of buckets, the last bucket has no upper bound
+	bounds []time.Duration
+	counts []atomic.Int64
+
+	count    atomic.Int64
+	sum      atomic.Int64
+	min, max atomic.Int64
+}
+
+// LatencySnapshot statistics of LatencyTracker
+type LatencySnapshot struct {
+	Count                         int64
+	Min, Max, Mean, P50, P95, P99 time.Duration
+}
+
+// NewLatencyTracker new latency tracker
+func NewLatencyTracker(opts ...LatencyTrackerOption) (*LatencyTracker, error) {
+	opt := &latencyTrackerOption{
+		min:    100 * time.Microsecond,
+		max:    time.Minute,
+		growth: 1.1,
+	}
+	for _, f := range opts {
+		if err := f(opt); err != nil {
+			return nil, err
+		}
+	}
+
+	t := new(LatencyTracker)
+	for b := float64(opt.min); ; b *= opt.growth {
+		if b >= float64(opt.max) {
+			t.bounds = append(t.bounds, opt.max)
+			break
+		}
+
+		t.bounds = append(t.bounds, time.Duration(b))
+	}
+
+	t.counts = make([]atomic.Int64, len(t.bounds)+1)
+	t.Reset()
+	return t, nil
+}
+
+// Observe record one duration
+func (t *LatencyTracker) Observe(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+
+	// first bucket whose upper bound >= d
+	idx := sort.Search(len(t.bounds), func(i int) bool { return t.bounds[i] >= d })
+	t.counts[idx].Add(1)
+	t.sum.Add(int64(d))
+	for {
+		cur := t.min.Load()
+		if int64(d) >= cur || t.min.CompareAndSwap(cur, int64(d)) {
+			break
+		}
+	}
+	for {
+		cur := t.max.Load()
+		if int64(d) <= cur || t.max.CompareAndSwap(cur, int64(d)) {
+			break
+		}
+	}
+
+	// update count at last, so readers that see count > 0 also see min/max
+	t.count.Add(1)
+}
+
+// Percentile return the approximate p-th percentile, p in [0, 100].
+//
+// the result is the upper bound of the bucket that contains the percentile,
+// clamped to the observed min/max. p <= 0 returns min, p >= 100 returns max,
+// return 0 if nothing observed.
+func (t *LatencyTracker) Percentile(p float64) time.Duration {
+	total := t.count.Load()
+	if total == 0 {
+		return 0
+	}
+
+	minV, maxV := time.Duration(t.min.Load()), time.Duration(t.max.Load())
+	switch {
+	case p <= 0 || math.IsNaN(p):
+		return minV
+	case p >= 100:
+		return maxV
+	}
+
+	rank := int64(math.Ceil(p / 100 * float64(total)))
+	var cum int64
+	for i := range t.counts {
+		cum += t.counts[i].Load()
+		if cum < rank {
+			continue
+		}
+
+		switch {
+		case i == len(t.bounds) || t.bounds[i] > maxV:
+			return maxV
+		case t.bounds[i] < minV:
+			return minV
+		default:
+			return t.bounds[i]
+		}
+	}
+
+	// counts are updated concurrently and not yet reached rank
+	return maxV
+}
+
+// Snapshot return statistics of observed durations,
+// fields may be slightly inconsistent if Observe runs concurrently.
+func (t *LatencyTracker) Snapshot() LatencySnapshot {
+	count := t.count.Load()
+	if count == 0 {
+		return LatencySnapshot{}
+	}
+
+	return LatencySnapshot{
+		Count: count,
+		Min:   time.Duration(t.min.Load()),
+		Max:   time.Duration(t.max.Load()),
+		Mean:  time.Duration(t.sum.Load() / count),
+		P50:   t.Percentile(50),
+		P95:   t.Percentile(95),
+		P99:   t.Percentile(99),
+	}
+}
+
+// Reset clear all observed durations
+func (t *LatencyTracker) Reset() {
+	for i := range t.counts {
+		t.counts[i].Store(0)
+	}
+
+	t.count.Store(0)
+	t.sum.Store(0)
+	t.min.Store(math.MaxInt64)
+	t.max.Store(0)
+}
+
+// TimeIt return the time cost of f
+func TimeIt(f func()) time.Duration {
+	start := time.Now()
+	f()
+	return time.Since(start)
+}
+
+// TimeItErr return the time cost and error of f
+func TimeItErr(f func() error) (time.Duration, error) {
+	start := time.Now()
+	err := f()
+	return time.Since(start), err
+}
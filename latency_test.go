@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Laisky/errors/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// requireWithinBucket check got is within one bucket width of want
+func requireWithinBucket(t *testing.T, tracker *LatencyTracker, want, got time.Duration, msg string) {
+	t.Helper()
+
+	idx := sort.Search(len(tracker.bounds), func(i int) bool { return tracker.bounds[i] >= want })
+	require.Less(t, idx, len(tracker.bounds), msg)
+	width := tracker.bounds[idx]
+	if idx > 0 {
+		width -= tracker.bounds[idx-1]
+	}
+
+	diff := got - want
+	if diff < 0 {
+		diff = -diff
+	}
+	require.LessOrEqual(t, diff, width, "%s: want %s, got %s", msg, want, got)
+}
+
+func TestLatencyTracker(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty", func(t *testing.T) {
+		tracker, err := NewLatencyTracker()
+		require.NoError(t, err)
+		require.Zero(t, tracker.Percentile(50))
+		require.Equal(t, LatencySnapshot{}, tracker.Snapshot())
+	})
+
+	t.Run("uniform", func(t *testing.T) {
+		tracker, err := NewLatencyTracker()
+		require.NoError(t, err)
+
+		var samples []time.Duration
+		for i := 1; i <= 1000; i++ {
+			samples = append(samples, time.Duration(i)*time.Millisecond)
+		}
+		rand.Shuffle(len(samples), func(i, j int) { samples[i], samples[j] = samples[j], samples[i] })
+		for _, d := range samples {
+			tracker.Observe(d)
+		}
+
+		for _, p := range []float64{1, 10, 50, 90, 95, 99} {
+			want := time.Duration(p*10) * time.Millisecond
+			requireWithinBucket(t, tracker, want, tracker.Percentile(p), "uniform")
+		}
+
+		require.Equal(t, time.Millisecond, tracker.Percentile(0))
+		require.Equal(t, time.Second, tracker.Percentile(100))
+		require.Equal(t, time.Second, tracker.Percentile(200))
+
+		snap := tracker.Snapshot()
+		require.EqualValues(t, 1000, snap.Count)
+		require.Equal(t, time.Millisecond, snap.Min)
+		require.Equal(t, time.Second, snap.Max)
+		require.Equal(t, 500500*time.Microsecond, snap.Mean)
+		requireWithinBucket(t, tracker, 500*time.Millisecond, snap.P50, "p50")
+		requireWithinBucket(t, tracker, 950*time.Millisecond, snap.P95, "p95")
+		requireWithinBucket(t, tracker, 990*time.Millisecond, snap.P99, "p99")
+
+		tracker.Reset()
+		require.Equal(t, LatencySnapshot{}, tracker.Snapshot())
+	})
+
+	t.Run("exponential", func(t *testing.T) {
+		tracker, err := NewLatencyTracker(WithLatencyTrackerGrowth(1.05))
+		require.NoError(t, err)
+
+		rnd := rand.New(rand.NewSource(1)) //nolint:gosec
+		samples := make([]time.Duration, 100000)
+		for i := range samples {
+			samples[i] = time.Duration(rnd.ExpFloat64() * float64(10*time.Millisecond))
+			tracker.Observe(samples[i])
+		}
+
+		sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+		for _, p := range []float64{50, 95, 99} {
+			want := samples[int(p/100*float64(len(samples)))-1]
+			requireWithinBucket(t, tracker, want, tracker.Percentile(p), "exponential")
+		}
+	})
+
+	t.Run("out of bounds", func(t *testing.T) {
+		tracker, err := NewLatencyTracker(WithLatencyTrackerBounds(time.Millisecond, time.Second))
+		require.NoError(t, err)
+
+		tracker.Observe(-time.Second)
+		tracker.Observe(10 * time.Microsecond)
+		tracker.Observe(time.Hour)
+
+		require.Equal(t, time.Duration(0), tracker.Percentile(0))
+		require.Equal(t, time.Millisecond, tracker.Percentile(50))
+		require.Equal(t, time.Hour, tracker.Percentile(99))
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		for _, opt := range []LatencyTrackerOption{
+			WithLatencyTrackerBounds(0, time.Second),
+			WithLatencyTrackerBounds(time.Second, time.Second),
+			WithLatencyTrackerGrowth(1),
+			WithLatencyTrackerGrowth(0.5),
+			WithLatencyTrackerGrowth(1 + 1e-15),
+			WithLatencyTrackerGrowth(1.009),
+		} {
+			_, err := NewLatencyTracker(opt)
+			require.Error(t, err)
+		}
+
+		tracker, err := NewLatencyTracker(
+			WithLatencyTrackerBounds(1, math.MaxInt64),
+			WithLatencyTrackerGrowth(1.01),
+		)
+		require.NoError(t, err)
+		require.Less(t, len(tracker.bounds), 4500)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		tracker, err := NewLatencyTracker()
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 1; j <= 1000; j++ {
+					tracker.Observe(time.Duration(j) * time.Millisecond)
+					_ = tracker.Snapshot()
+				}
+			}()
+		}
+		wg.Wait()
+
+		snap := tracker.Snapshot()
+		require.EqualValues(t, 10000, snap.Count)
+		require.Equal(t, 500500*time.Microsecond, snap.Mean)
+	})
+}
+
+func TestTimeIt(t *testing.T) {
+	t.Parallel()
+
+	cost := TimeIt(func() { time.Sleep(10 * time.Millisecond) })
+	require.GreaterOrEqual(t, cost, 10*time.Millisecond)
+
+	cost, err := TimeItErr(func() error {
+		time.Sleep(10 * time.Millisecond)
+		return errors.New("yo")
+	})
+	require.ErrorContains(t, err, "yo")
+	require.GreaterOrEqual(t, cost, 10*time.Millisecond)
+}
+
+func BenchmarkLatencyTracker_Observe(b *testing.B) {
+	tracker, err := NewLatencyTracker()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.RunParallel(func(pb *testing.PB) {
+		d := time.Millisecond
+		for pb.Next() {
+			tracker.Observe(d)
+			d = (d + 37*time.Microsecond) % time.Second
+		}
+	})
+}
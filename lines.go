@@ -0,0 +1,254 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/Laisky/errors/v2"
+
+	"github.com/Laisky/go-utils/v4/log"
+)
+
+// grepContextSeparator separator between non-adjacent groups of lines, same as grep
+const grepContextSeparator = "--"
+
+// tailBlockSize size of block read backwards by TailFileLines
+const tailBlockSize = 64 * 1024
+
+// headLinesPrealloc max lines preallocated by HeadReaderLines,
+// n could be very large like math.MaxInt to read all lines
+const headLinesPrealloc = 1024
+
+// readLine read one line without trailing `\n` or `\r\n`,
+// long lines are never truncated. return io.EOF if there is no more lines.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			return "", errors.Wrap(err, "read line")
+		}
+		if line == "" {
+			return "", io.EOF
+		}
+	}
+
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
+
+type grepOption struct {
+	maxMatches    int
+	invert        bool
+	before, after int
+}
+
+// GrepOption options for GrepReader
+type GrepOption func(*grepOption) error
+
+// WithGrepMaxMatches stop after n matched lines, like `grep -m`,
+// the context lines after the last match are still returned.
+// default is 0, means no limit.
+func WithGrepMaxMatches(n int) GrepOption {
+	return func(o *grepOption) error {
+		if n < 0 {
+			return errors.Errorf("max matches should not be negative, got %d", n)
+		}
+
+		o.maxMatches = n
+		return nil
+	}
+}
+
+// WithGrepInvert select non-matching lines, like `grep -v`
+func WithGrepInvert() GrepOption {
+	return func(o *grepOption) error {
+		o.invert = true
+		return nil
+	}
+}
+
+// WithGrepContext return before lines before and after lines after
+// each matched line, like `grep -B before -A after`
+func WithGrepContext(before, after int) GrepOption {
+	return func(o *grepOption) error {
+		if before < 0 || after < 0 {
+			return errors.Errorf("context should not be negative, got %d and %d", before, after)
+		}
+
+		o.before, o.after = before, after
+		return nil
+	}
+}
+
+// GrepReader return lines in r that match pattern, without line endings.
+//
+// if context lines are enabled by WithGrepContext,
+// non-adjacent groups of lines are separated by `--`, same as grep.
+func GrepReader(r io.Reader, pattern *regexp.Regexp, opts ...GrepOption) ([]string, error) {
+	if pattern == nil {
+		return nil, errors.New("pattern should not be nil")
+	}
+
+	opt := new(grepOption)
+	for _, f := range opts {
+		if err := f(opt); err != nil {
+			return nil, errors.Wrap(err, "apply option")
+		}
+	}
+
+	var (
+		reader         = bufio.NewReader(r)
+		result         []string
+		beforeBuf      []string
+		nMatched       int
+		afterRemaining int
+		lastPrinted    = -1
+	)
+	for lineNo := 0; ; lineNo++ {
+		if opt.maxMatches > 0 && nMatched >= opt.maxMatches && afterRemaining == 0 {
+			break
+		}
+
+		line, err := readLine(reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return nil, errors.Wrapf(err, "read line %d", lineNo+1)
+		}
+
+		matched := pattern.MatchString(line) != opt.invert
+		switch {
+		case matched && (opt.maxMatches == 0 || nMatched < opt.maxMatches):
+			nMatched++
+			groupStart := lineNo - len(beforeBuf)
+			if lastPrinted >= 0 && groupStart > lastPrinted+1 && (opt.before > 0 || opt.after > 0) {
+				result = append(result, grepContextSeparator)
+			}
+
+			result = append(result, beforeBuf...)
+			result = append(result, line)
+			beforeBuf = beforeBuf[:0]
+			lastPrinted = lineNo
+			afterRemaining = opt.after
+		case afterRemaining > 0:
+			result = append(result, line)
+			lastPrinted = lineNo
+			afterRemaining--
+		case opt.before > 0:
+			if len(beforeBuf) == opt.before {
+				beforeBuf = append(beforeBuf[:0], beforeBuf[1:]...)
+			}
+
+			beforeBuf = append(beforeBuf, line)
+		}
+	}
+
+	return result, nil
+}
+
+// HeadReaderLines return the first n lines of r without line endings,
+// return all lines if r has less than n lines.
+func HeadReaderLines(r io.Reader, n int) ([]string, error) {
+	if n < 0 {
+		return nil, errors.Errorf("n should not be negative, got %d", n)
+	}
+
+	reader := bufio.NewReader(r)
+	lines := make([]string, 0, min(n, headLinesPrealloc))
+	for len(lines) < n {
+		line, err := readLine(reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return nil, errors.Wrapf(err, "read line %d", len(lines)+1)
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines, nil
+}
+
+// TailFileLines return the last n lines of file without line endings,
+// return all lines if file has less than n lines.
+//
+// the file is read backwards block by block until n lines are found,
+// so only the tail of file is loaded into memory.
+func TailFileLines(path string, n int) (lines []string, err error) {
+	if n < 0 {
+		return nil, errors.Errorf("n should not be negative, got %d", n)
+	}
+	if n == 0 {
+		return []string{}, nil
+	}
+
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open file %q", path)
+	}
+	defer LogErr(fp.Close, log.Shared)
+
+	stat, err := fp.Stat()
+	if err != nil {
+		return nil, errors.Wrapf(err, "stat file %q", path)
+	}
+
+	var (
+		pos      = stat.Size()
+		blocks   [][]byte
+		newlines int
+		// the newline at the end of file does not start a new line
+		trailingNewline = -1
+	)
+	for pos > 0 {
+		size := min(int64(tailBlockSize), pos)
+		pos -= size
+
+		block := make([]byte, size)
+		if _, err = fp.ReadAt(block, pos); err != nil {
+			return nil, errors.Wrapf(err, "read file %q at %d", path, pos)
+		}
+
+		if trailingNewline < 0 {
+			trailingNewline = 0
+			if block[len(block)-1] == '\n' {
+				trailingNewline = 1
+			}
+		}
+
+		blocks = append(blocks, block)
+		newlines += bytes.Count(block, []byte{'\n'})
+		if newlines-trailingNewline >= n {
+			break
+		}
+	}
+
+	// blocks are read backwards
+	var data []byte
+	for i := len(blocks) - 1; i >= 0; i-- {
+		data = append(data, blocks[i]...)
+	}
+	if len(data) == 0 {
+		return []string{}, nil
+	}
+
+	data = bytes.TrimSuffix(data, []byte{'\n'})
+	parts := bytes.Split(data, []byte{'\n'})
+	if len(parts) > n {
+		parts = parts[len(parts)-n:]
+	}
+
+	lines = make([]string, 0, len(parts))
+	for _, part := range parts {
+		lines = append(lines, string(bytes.TrimSuffix(part, []byte{'\r'})))
+	}
+
+	return lines, nil
+}
@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGrepReader(t *testing.T) {
+	raw := "INFO start\r\n" +
+		"DEBUG a\r\n" +
+		"ERROR first\r\n" +
+		"DEBUG b\r\n" +
+		"DEBUG c\r\n" +
+		"DEBUG d\r\n" +
+		"ERROR second\r\n" +
+		"INFO done"
+	re := regexp.MustCompile(`^ERROR`)
+
+	t.Run("basic", func(t *testing.T) {
+		got, err := GrepReader(strings.NewReader(raw), re)
+		require.NoError(t, err)
+		require.Equal(t, []string{"ERROR first", "ERROR second"}, got)
+	})
+
+	t.Run("invert", func(t *testing.T) {
+		got, err := GrepReader(strings.NewReader(raw), regexp.MustCompile(`^(DEBUG|ERROR)`), WithGrepInvert())
+		require.NoError(t, err)
+		require.Equal(t, []string{"INFO start", "INFO done"}, got)
+	})
+
+	t.Run("max matches", func(t *testing.T) {
+		got, err := GrepReader(strings.NewReader(raw), re, WithGrepMaxMatches(1))
+		require.NoError(t, err)
+		require.Equal(t, []string{"ERROR first"}, got)
+
+		got, err = GrepReader(strings.NewReader(raw), re, WithGrepMaxMatches(1), WithGrepContext(0, 1))
+		require.NoError(t, err)
+		require.Equal(t, []string{"ERROR first", "DEBUG b"}, got)
+	})
+
+	t.Run("context", func(t *testing.T) {
+		got, err := GrepReader(strings.NewReader(raw), re, WithGrepContext(1, 1))
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			"DEBUG a", "ERROR first", "DEBUG b",
+			"--",
+			"DEBUG d", "ERROR second", "INFO done",
+		}, got)
+
+		// overlapped groups are merged
+		got, err = GrepReader(strings.NewReader(raw), re, WithGrepContext(2, 2))
+		require.NoError(t, err)
+		require.Equal(t, strings.Split(strings.ReplaceAll(raw, "\r", ""), "\n"), got)
+	})
+
+	t.Run("long line", func(t *testing.T) {
+		long := "ERROR " + strings.Repeat("x", 3*1024*1024)
+		got, err := GrepReader(strings.NewReader("a\n"+long+"\nb\n"), re)
+		require.NoError(t, err)
+		require.Equal(t, []string{long}, got)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := GrepReader(strings.NewReader(raw), nil)
+		require.Error(t, err)
+		_, err = GrepReader(strings.NewReader(raw), re, WithGrepContext(-1, 0))
+		require.Error(t, err)
+	})
+}
+
+func TestHeadReaderLines(t *testing.T) {
+	got, err := HeadReaderLines(strings.NewReader("a\r\nb\r\nc\r\n"), 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, got)
+
+	got, err = HeadReaderLines(strings.NewReader("a\nb"), 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, got)
+
+	got, err = HeadReaderLines(strings.NewReader(""), 10)
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	long := strings.Repeat("y", 2*1024*1024)
+	got, err = HeadReaderLines(strings.NewReader(long+"\nb"), 1)
+	require.NoError(t, err)
+	require.Equal(t, []string{long}, got)
+
+	_, err = HeadReaderLines(strings.NewReader(""), -1)
+	require.Error(t, err)
+
+	// read all lines
+	got, err = HeadReaderLines(strings.NewReader("a\nb\nc"), math.MaxInt)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "c"}, got)
+}
+
+func TestTailFileLines(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(t *testing.T, content string) string {
+		t.Helper()
+		fpath := filepath.Join(dir, RandomStringWithLength(8))
+		require.NoError(t, os.WriteFile(fpath, []byte(content), 0600))
+		return fpath
+	}
+
+	t.Run("crlf", func(t *testing.T) {
+		fpath := writeFile(t, "a\r\nb\r\nc\r\n")
+		got, err := TailFileLines(fpath, 2)
+		require.NoError(t, err)
+		require.Equal(t, []string{"b", "c"}, got)
+
+		got, err = TailFileLines(fpath, 10)
+		require.NoError(t, err)
+		require.Equal(t, []string{"a", "b", "c"}, got)
+	})
+
+	t.Run("no trailing newline", func(t *testing.T) {
+		fpath := writeFile(t, "a\nb\n\nc")
+		got, err := TailFileLines(fpath, 3)
+		require.NoError(t, err)
+		require.Equal(t, []string{"b", "", "c"}, got)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		got, err := TailFileLines(writeFile(t, ""), 3)
+		require.NoError(t, err)
+		require.Empty(t, got)
+
+		got, err = TailFileLines(writeFile(t, "a"), 0)
+		require.NoError(t, err)
+		require.Empty(t, got)
+	})
+
+	t.Run("long lines across blocks", func(t *testing.T) {
+		long := strings.Repeat("z", 3*tailBlockSize+17)
+		fpath := writeFile(t, "head\n"+long+"\r\nshort\n")
+		got, err := TailFileLines(fpath, 2)
+		require.NoError(t, err)
+		require.Equal(t, []string{long, "short"}, got)
+	})
+
+	t.Run("huge sparse file", func(t *testing.T) {
+		fpath := filepath.Join(dir, "huge.log")
+		fp, err := os.Create(fpath)
+		require.NoError(t, err)
+
+		// 300MB of holes, then repeated blocks of lines
+		const holeSize = 300 * 1024 * 1024
+		require.NoError(t, fp.Truncate(holeSize))
+		var sb strings.Builder
+		for i := 0; i < 10000; i++ {
+			fmt.Fprintf(&sb, "line %d\r\n", i)
+		}
+		_, err = fp.WriteAt([]byte(sb.String()), holeSize)
+		require.NoError(t, err)
+		require.NoError(t, fp.Close())
+
+		got, err := TailFileLines(fpath, 3)
+		require.NoError(t, err)
+		require.Equal(t, []string{"line 9997", "line 9998", "line 9999"}, got)
+
+		got, err = TailFileLines(fpath, 5000)
+		require.NoError(t, err)
+		require.Len(t, got, 5000)
+		require.Equal(t, "line 5000", got[0])
+	})
+
+	t.Run("not exists", func(t *testing.T) {
+		_, err := TailFileLines(filepath.Join(dir, "not-exists"), 1)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,52 @@
+package log
+
+import (
+	"context"
+
+	"github.com/GoWebProd/uuid7"
+	zap "github.com/Laisky/zap"
+)
+
+type ctxKey string
+
+const (
+	ctxKeyLogger  ctxKey = "logger"
+	ctxKeyTraceID ctxKey = "trace_id"
+
+	// TraceIDKey field name of trace id in logs
+	TraceIDKey = "trace_id"
+)
+
+var traceIDGen = uuid7.New()
+
+// ToCtx attach logger to ctx
+func ToCtx(ctx context.Context, logger Logger) context.Context {
+	return context.WithValue(ctx, ctxKeyLogger, logger)
+}
+
+// FromCtx get logger from ctx, return Shared if not found
+func FromCtx(ctx context.Context) Logger {
+	if logger, ok := ctx.Value(ctxKeyLogger).(Logger); ok && logger != nil {
+		return logger
+	}
+
+	return Shared
+}
+
+// WithTraceID generate a new uuid7 trace id,
+// attach it to ctx and to the logger in ctx as field `trace_id`.
+//
+// all logs emitted by FromCtx(ctx) afterwards will carry the trace id.
+func WithTraceID(ctx context.Context) (context.Context, string) {
+	traceID := traceIDGen.Next().String()
+	logger := FromCtx(ctx).With(zap.String(TraceIDKey, traceID))
+
+	ctx = context.WithValue(ctx, ctxKeyTraceID, traceID)
+	return ToCtx(ctx, logger), traceID
+}
+
+// TraceIDFromCtx get trace id set by WithTraceID, return empty string if not found
+func TraceIDFromCtx(ctx context.Context) string {
+	traceID, _ := ctx.Value(ctxKeyTraceID).(string)
+	return traceID
+}
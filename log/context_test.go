@@ -0,0 +1,68 @@
+package log
+
+import (
+	"context"
+	"testing"
+
+	zap "github.com/Laisky/zap"
+	"github.com/Laisky/zap/zaptest/observer"
+	"github.com/stretchr/testify/require"
+)
+
+func newObservedLogger() (*LoggerT, *observer.ObservedLogs) {
+	level := zap.NewAtomicLevelAt(zap.DebugLevel)
+	core, logs := observer.New(level)
+	return &LoggerT{
+		Logger: zap.New(core),
+		level:  level,
+	}, logs
+}
+
+func TestFromCtx(t *testing.T) {
+	t.Run("bare context", func(t *testing.T) {
+		logger := FromCtx(context.Background())
+		require.NotNil(t, logger)
+		require.Equal(t, Shared, logger)
+		logger.Info("usable")
+	})
+
+	t.Run("with chain", func(t *testing.T) {
+		logger, logs := newObservedLogger()
+		ctx := ToCtx(context.Background(), logger.With(zap.String("a", "1")))
+
+		child := FromCtx(ctx).With(zap.String("b", "2"))
+		ctx = ToCtx(ctx, child)
+		FromCtx(ctx).Named("sub").With(zap.String("c", "3")).Info("hello")
+
+		entries := logs.All()
+		require.Len(t, entries, 1)
+		require.Equal(t, map[string]any{
+			"a": "1",
+			"b": "2",
+			"c": "3",
+		}, entries[0].ContextMap())
+	})
+}
+
+func TestWithTraceID(t *testing.T) {
+	logger, logs := newObservedLogger()
+	ctx := ToCtx(context.Background(), logger)
+
+	ctx, traceID := WithTraceID(ctx)
+	require.NotEmpty(t, traceID)
+	require.Equal(t, traceID, TraceIDFromCtx(ctx))
+	require.Empty(t, TraceIDFromCtx(context.Background()))
+
+	FromCtx(ctx).Info("first")
+	FromCtx(ctx).With(zap.String("k", "v")).Warn("second")
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+	for _, ent := range entries {
+		require.Equal(t, traceID, ent.ContextMap()[TraceIDKey])
+	}
+	require.Equal(t, "v", entries[1].ContextMap()["k"])
+
+	_, anotherID := WithTraceID(ctx)
+	require.NotEqual(t, traceID, anotherID)
+}
@@ -0,0 +1,598 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Laisky/errors/v2"
+	zap "github.com/Laisky/zap"
+	"github.com/Laisky/zap/zapcore"
+)
+
+const (
+	defaultFileLogMaxSizeMB = 100
+	rotateFileTimeFormat    = "2006-01-02T15-04-05.000000000"
+	compressSuffix          = ".gz"
+)
+
+type fileLogOption struct {
+	maxSize    int64
+	maxBackups int
+	maxAge     time.Duration
+	compress   bool
+	level      Level
+	name       string
+}
+
+func (o *fileLogOption) fillDefault() *fileLogOption {
+	o.maxSize = defaultFileLogMaxSizeMB * 1024 * 1024
+	o.level = LevelInfo
+	o.name = "app"
+	return o
+}
+
+func (o *fileLogOption) applyOpts(opts ...FileLogOption) (*fileLogOption, error) {
+	for _, optf := range opts {
+		if err := optf(o); err != nil {
+			return nil, errors.Wrap(err, "apply opts")
+		}
+	}
+
+	return o, nil
+}
+
+// FileLogOption options for NewFileLogger and NewRotateFile
+type FileLogOption func(*fileLogOption) error
+
+// WithMaxSizeMB rotate log file when its size exceeds maxSizeMB, default is 100
+func WithMaxSizeMB(maxSizeMB int) FileLogOption {
+	return func(o *fileLogOption) error {
+		if maxSizeMB <= 0 {
+			return errors.Errorf("max size should greater than 0, got %d", maxSizeMB)
+		}
+
+		o.maxSize = int64(maxSizeMB) * 1024 * 1024
+		return nil
+	}
+}
+
+// WithMaxBackups set the maximum number of rotated files to retain,
+// default is 0 means retain all
+func WithMaxBackups(maxBackups int) FileLogOption {
+	return func(o *fileLogOption) error {
+		if maxBackups < 0 {
+			return errors.Errorf("max backups should not be negative, got %d", maxBackups)
+		}
+
+		o.maxBackups = maxBackups
+		return nil
+	}
+}
+
+// WithMaxAgeDays set the maximum days to retain rotated files,
+// default is 0 means never remove files by age
+func WithMaxAgeDays(days int) FileLogOption {
+	return func(o *fileLogOption) error {
+		if days < 0 {
+			return errors.Errorf("max age should not be negative, got %d", days)
+		}
+
+		o.maxAge = time.Duration(days) * 24 * time.Hour
+		return nil
+	}
+}
+
+// WithCompressRotated compress rotated files by gzip
+func WithCompressRotated() FileLogOption {
+	return func(o *fileLogOption) error {
+		o.compress = true
+		return nil
+	}
+}
+
+// WithFileLogLevel set level of NewFileLogger, default is info
+func WithFileLogLevel(level Level) FileLogOption {
+	return func(o *fileLogOption) error {
+		if _, err := LevelToZap(level); err != nil {
+			return err
+		}
+
+		o.level = level
+		return nil
+	}
+}
+
+// WithFileLogName set name of NewFileLogger, default is "app"
+func WithFileLogName(name string) FileLogOption {
+	return func(o *fileLogOption) error {
+		o.name = name
+		return nil
+	}
+}
+
+// RotateFile is a zapcore.WriteSyncer that write to file,
+// and rotate file when its size exceeds the limit.
+//
+// rotated files are named like `<name>-<time>.<ext>`
+// in the same directory of the log file.
+type RotateFile struct {
+	mu     sync.Mutex
+	opt    *fileLogOption
+	path   string
+	file   *os.File
+	size   int64
+	millCh chan struct{}
+	millWg sync.WaitGroup
+}
+
+var _ zapcore.WriteSyncer = new(RotateFile)
+
+// NewRotateFile create new RotateFile
+func NewRotateFile(path string, opts ...FileLogOption) (*RotateFile, error) {
+	opt, err := new(fileLogOption).fillDefault().applyOpts(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &RotateFile{
+		opt:    opt,
+		path:   path,
+		millCh: make(chan struct{}, 1),
+	}
+	if err = f.open(); err != nil {
+		return nil, err
+	}
+
+	f.millWg.Add(1)
+	go f.runMill(f.millCh)
+	return f, nil
+}
+
+// open open or create the log file, should be called with lock
+func (f *RotateFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return errors.Wrapf(err, "create dir for %q", f.path)
+	}
+
+	fp, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return errors.Wrapf(err, "open file %q", f.path)
+	}
+
+	st, err := fp.Stat()
+	if err != nil {
+		_ = fp.Close()
+		return errors.Wrapf(err, "stat file %q", f.path)
+	}
+
+	f.file = fp
+	f.size = st.Size()
+	return nil
+}
+
+// closeFile close current file, should be called with lock
+func (f *RotateFile) closeFile() error {
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// Write write p to file, rotate file if needed
+func (f *RotateFile) Write(p []byte) (n int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, errors.Errorf("file %q already closed", f.path)
+	}
+
+	if f.size > 0 && f.size+int64(len(p)) > f.opt.maxSize {
+		if err = f.rotate(); err != nil {
+			return 0, errors.Wrap(err, "rotate")
+		}
+	}
+
+	n, err = f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Sync commit the current contents of the file to disk
+func (f *RotateFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+
+	return f.file.Sync()
+}
+
+// Reopen close and reopen the log file.
+//
+// call it after the file has been truncated or moved by external tools,
+// like logrotate's postrotate with SIGHUP.
+func (f *RotateFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.closeFile(); err != nil {
+		return errors.Wrap(err, "close file")
+	}
+
+	return f.open()
+}
+
+// Rotate close current file, rename it to backup and open a new file
+func (f *RotateFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.rotate()
+}
+
+// Close close the file and stop background cleaning
+func (f *RotateFile) Close() error {
+	f.mu.Lock()
+	err := f.closeFile()
+	if f.millCh != nil {
+		close(f.millCh)
+		f.millCh = nil
+	}
+	f.mu.Unlock()
+
+	f.millWg.Wait()
+	return err
+}
+
+// rotate should be called with lock
+func (f *RotateFile) rotate() error {
+	if err := f.closeFile(); err != nil {
+		return errors.Wrap(err, "close file")
+	}
+
+	if err := os.Rename(f.path, f.backupName(time.Now())); err != nil &&
+		!errors.Is(err, os.ErrNotExist) {
+		return errors.Wrap(err, "rename file")
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	select {
+	case f.millCh <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// backupName return an unused backup file name
+func (f *RotateFile) backupName(t time.Time) string {
+	dir := filepath.Dir(f.path)
+	ext := filepath.Ext(f.path)
+	prefix := strings.TrimSuffix(filepath.Base(f.path), ext) + "-"
+	for {
+		name := filepath.Join(dir, prefix+t.Format(rotateFileTimeFormat)+ext)
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			if _, err := os.Stat(name + compressSuffix); os.IsNotExist(err) {
+				return name
+			}
+		}
+
+		t = t.Add(time.Nanosecond)
+	}
+}
+
+// runMill compress and remove rotated files in background
+func (f *RotateFile) runMill(millCh <-chan struct{}) {
+	defer f.millWg.Done()
+
+	for range millCh {
+		if err := f.mill(); err != nil {
+			Shared.Error("mill rotated log files", zap.String("path", f.path), zap.Error(err))
+		}
+	}
+}
+
+type rotatedFile struct {
+	path string
+	t    time.Time
+}
+
+// backups list all rotated files, newest first
+func (f *RotateFile) backups() ([]rotatedFile, error) {
+	dir := filepath.Dir(f.path)
+	ext := filepath.Ext(f.path)
+	prefix := strings.TrimSuffix(filepath.Base(f.path), ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read dir %q", dir)
+	}
+
+	var files []rotatedFile
+	for _, ent := range entries {
+		if ent.IsDir() {
+			continue
+		}
+
+		name := strings.TrimSuffix(ent.Name(), compressSuffix)
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+
+		ts := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+		t, err := time.ParseInLocation(rotateFileTimeFormat, ts, time.Local)
+		if err != nil {
+			continue
+		}
+
+		files = append(files, rotatedFile{path: filepath.Join(dir, ent.Name()), t: t})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].t.After(files[j].t)
+	})
+
+	return files, nil
+}
+
+func (f *RotateFile) mill() error {
+	files, err := f.backups()
+	if err != nil {
+		return err
+	}
+
+	var remains []rotatedFile
+	for i, file := range files {
+		if (f.opt.maxBackups > 0 && i >= f.opt.maxBackups) ||
+			(f.opt.maxAge > 0 && time.Since(file.t) > f.opt.maxAge) {
+			if err = os.Remove(file.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return errors.Wrapf(err, "remove %q", file.path)
+			}
+
+			continue
+		}
+
+		remains = append(remains, file)
+	}
+
+	if !f.opt.compress {
+		return nil
+	}
+
+	for _, file := range remains {
+		if strings.HasSuffix(file.path, compressSuffix) {
+			continue
+		}
+
+		if err = gzipFile(file.path); err != nil {
+			return errors.Wrapf(err, "compress %q", file.path)
+		}
+	}
+
+	return nil
+}
+
+// gzipFile compress src to src.gz and remove src
+func gzipFile(src string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return errors.Wrap(err, "open src")
+	}
+	defer in.Close() //nolint:errcheck
+
+	out, err := os.OpenFile(src+compressSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return errors.Wrap(err, "open dst")
+	}
+
+	gz := gzip.NewWriter(out)
+	if _, err = io.Copy(gz, in); err != nil {
+		_ = out.Close()
+		return errors.Wrap(err, "compress")
+	}
+	if err = gz.Close(); err != nil {
+		_ = out.Close()
+		return errors.Wrap(err, "close gzip writer")
+	}
+	if err = out.Close(); err != nil {
+		return errors.Wrap(err, "close dst")
+	}
+
+	return os.Remove(src)
+}
+
+// NewFileLogger create new json logger that write to file with size-based rotation.
+//
+// the returned logger is *LoggerT, its RotateFile should be closed after use,
+// and could be reopened after moved by external tools.
+func NewFileLogger(path string, opts ...FileLogOption) (Logger, error) {
+	opt, err := new(fileLogOption).fillDefault().applyOpts(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := NewRotateFile(path, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "new rotate file")
+	}
+
+	level := zap.NewAtomicLevelAt(opt.level.Zap())
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(defaultEncoderConfig()), file, level)
+	return &LoggerT{
+		Logger: zap.New(newSinkCore(core), zap.AddCaller()).Named(opt.name),
+		level:  level,
+		file:   file,
+	}, nil
+}
+
+// RotateFile get rotate file of logger created by NewFileLogger,
+// return nil if logger does not write to rotate file.
+//
+// children of the logger share the same file.
+func (l *LoggerT) RotateFile() *RotateFile {
+	return l.file
+}
+
+// AddSink tee logs to ws with level,
+// level is independent of the logger's level.
+//
+// logs are encoded as json lines. only affect current logger
+// and the children created after this call.
+// it's safe to call concurrently with logging.
+func (l *LoggerT) AddSink(ws zapcore.WriteSyncer, level Level) error {
+	lvl, err := LevelToZap(level)
+	if err != nil {
+		return err
+	}
+
+	core, ok := l.Logger.Core().(*sinkCore)
+	if !ok {
+		return errors.Errorf("logger does not support sinks, core is %T", l.Logger.Core())
+	}
+
+	core.add(zapcore.NewCore(zapcore.NewJSONEncoder(defaultEncoderConfig()), ws, lvl))
+	return nil
+}
+
+// sinkCore tee logs to base core and sinks added by AddSink,
+// sinks are swapped atomically, so they could be added during logging.
+type sinkCore struct {
+	zapcore.Core
+	// fields added by With, applied to sinks added later
+	fields []zapcore.Field
+	sinks  *atomic.Pointer[[]zapcore.Core]
+}
+
+func newSinkCore(base zapcore.Core) *sinkCore {
+	return &sinkCore{
+		Core:  base,
+		sinks: new(atomic.Pointer[[]zapcore.Core]),
+	}
+}
+
+func (c *sinkCore) loadSinks() []zapcore.Core {
+	if sinks := c.sinks.Load(); sinks != nil {
+		return *sinks
+	}
+
+	return nil
+}
+
+// add append sink by copy-on-write
+func (c *sinkCore) add(sink zapcore.Core) {
+	if len(c.fields) != 0 {
+		sink = sink.With(c.fields)
+	}
+
+	for {
+		old := c.sinks.Load()
+		var sinks []zapcore.Core
+		if old != nil {
+			sinks = append(sinks, *old...)
+		}
+		sinks = append(sinks, sink)
+
+		if c.sinks.CompareAndSwap(old, &sinks) {
+			return
+		}
+	}
+}
+
+// fork return core with the same sinks,
+// sinks added to one of them later do not affect the other
+func (c *sinkCore) fork(base zapcore.Core, fields []zapcore.Field) *sinkCore {
+	forked := &sinkCore{
+		Core:   base,
+		fields: append(append([]zapcore.Field(nil), c.fields...), fields...),
+		sinks:  new(atomic.Pointer[[]zapcore.Core]),
+	}
+
+	if sinks := c.loadSinks(); len(sinks) != 0 {
+		cloned := make([]zapcore.Core, len(sinks))
+		for i, sink := range sinks {
+			cloned[i] = sink
+			if len(fields) != 0 {
+				cloned[i] = sink.With(fields)
+			}
+		}
+		forked.sinks.Store(&cloned)
+	}
+
+	return forked
+}
+
+// forkLogger make logger's sinks independent of its parent
+func forkLogger(logger *zap.Logger) *zap.Logger {
+	if _, ok := logger.Core().(*sinkCore); !ok {
+		return logger
+	}
+
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		if c, ok := core.(*sinkCore); ok {
+			return c.fork(c.Core, nil)
+		}
+
+		return core
+	}))
+}
+
+func (c *sinkCore) Enabled(lvl zapcore.Level) bool {
+	if c.Core.Enabled(lvl) {
+		return true
+	}
+
+	for _, sink := range c.loadSinks() {
+		if sink.Enabled(lvl) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *sinkCore) With(fields []zapcore.Field) zapcore.Core {
+	return c.fork(c.Core.With(fields), fields)
+}
+
+func (c *sinkCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	ce = c.Core.Check(ent, ce)
+	for _, sink := range c.loadSinks() {
+		ce = sink.Check(ent, ce)
+	}
+
+	return ce
+}
+
+func (c *sinkCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	errs := []error{c.Core.Write(ent, fields)}
+	for _, sink := range c.loadSinks() {
+		errs = append(errs, sink.Write(ent, fields))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *sinkCore) Sync() error {
+	errs := []error{c.Core.Sync()}
+	for _, sink := range c.loadSinks() {
+		errs = append(errs, sink.Sync())
+	}
+
+	return errors.Join(errs...)
+}
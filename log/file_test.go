@@ -0,0 +1,227 @@
+package log
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	zap "github.com/Laisky/zap"
+	"github.com/stretchr/testify/require"
+)
+
+func assertJSONLines(t *testing.T, path string) (n int) {
+	t.Helper()
+
+	fp, err := os.Open(path)
+	require.NoError(t, err)
+	defer fp.Close()
+
+	var reader = bufio.NewReader(fp)
+	if strings.HasSuffix(path, compressSuffix) {
+		gz, err := gzip.NewReader(fp)
+		require.NoError(t, err)
+		reader = bufio.NewReader(gz)
+	}
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		var m map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &m), scanner.Text())
+		n++
+	}
+	require.NoError(t, scanner.Err())
+
+	return n
+}
+
+func TestNewFileLogger(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	logger, err := NewFileLogger(path,
+		WithMaxSizeMB(1),
+		WithMaxBackups(2),
+		WithFileLogLevel(LevelDebug),
+	)
+	require.NoError(t, err)
+	file := logger.(*LoggerT).RotateFile()
+	require.NotNil(t, file)
+	require.Same(t, file, logger.Named("child").RotateFile())
+	require.Nil(t, Shared.RotateFile())
+	defer file.Close()
+
+	var pool sync.WaitGroup
+	payload := strings.Repeat("x", 1000)
+	for i := 0; i < 8; i++ {
+		pool.Add(1)
+		go func(i int) {
+			defer pool.Done()
+			for j := 0; j < 500; j++ {
+				logger.Debug("hello", zap.Int("goroutine", i), zap.String("payload", payload))
+			}
+		}(i)
+	}
+	pool.Wait()
+	require.NoError(t, logger.Sync())
+
+	st, err := os.Stat(path)
+	require.NoError(t, err)
+	require.LessOrEqual(t, st.Size(), int64(1024*1024))
+
+	var backups []string
+	require.Eventually(t, func() bool {
+		backups, err = filepath.Glob(filepath.Join(dir, "app-*.log"))
+		require.NoError(t, err)
+		return len(backups) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	for _, fpath := range append(backups, path) {
+		require.Greater(t, assertJSONLines(t, fpath), 0)
+	}
+}
+
+func TestRotateFile(t *testing.T) {
+	t.Run("compress", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "app.log")
+
+		f, err := NewRotateFile(path, WithCompressRotated())
+		require.NoError(t, err)
+		defer f.Close()
+
+		_, err = f.Write([]byte(`{"msg":"hello"}` + "\n"))
+		require.NoError(t, err)
+		require.NoError(t, f.Rotate())
+
+		var files []string
+		require.Eventually(t, func() bool {
+			files, err = filepath.Glob(filepath.Join(dir, "app-*.log*"))
+			require.NoError(t, err)
+			return len(files) == 1 && strings.HasSuffix(files[0], compressSuffix)
+		}, 5*time.Second, 10*time.Millisecond)
+
+		require.Equal(t, 1, assertJSONLines(t, files[0]))
+	})
+
+	t.Run("reopen", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "app.log")
+
+		f, err := NewRotateFile(path)
+		require.NoError(t, err)
+		defer f.Close()
+
+		_, err = f.Write([]byte("line1\n"))
+		require.NoError(t, err)
+
+		// moved by external tool
+		require.NoError(t, os.Rename(path, path+".1"))
+		require.NoError(t, f.Reopen())
+
+		_, err = f.Write([]byte("line2\n"))
+		require.NoError(t, err)
+
+		cnt, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "line2\n", string(cnt))
+		require.Equal(t, int64(len("line2\n")), f.size)
+
+		// truncated by external tool
+		require.NoError(t, os.Truncate(path, 0))
+		require.NoError(t, f.Reopen())
+		require.Zero(t, f.size)
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		f, err := NewRotateFile(filepath.Join(t.TempDir(), "app.log"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		_, err = f.Write([]byte("yo"))
+		require.ErrorContains(t, err, "already closed")
+	})
+
+	t.Run("invalid option", func(t *testing.T) {
+		_, err := NewRotateFile(filepath.Join(t.TempDir(), "app.log"), WithMaxSizeMB(0))
+		require.Error(t, err)
+	})
+}
+
+func TestLoggerT_AddSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := NewRotateFile(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	logger, err := New(WithLevel(LevelInfo))
+	require.NoError(t, err)
+	require.NoError(t, logger.AddSink(f, LevelDebug))
+	require.Error(t, logger.AddSink(f, "invalid"))
+
+	logger.Debug("debug msg")
+	logger.Named("child").Info("info msg")
+	require.NoError(t, f.Sync())
+
+	cnt, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(cnt), "debug msg")
+	require.Contains(t, string(cnt), "info msg")
+	require.Equal(t, 2, assertJSONLines(t, path))
+
+	// console level is not changed
+	require.Equal(t, LevelInfo, logger.Level())
+}
+
+func TestLoggerT_AddSinkConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := New(WithLevel(LevelInfo))
+	require.NoError(t, err)
+	child := logger.Named("child").With(zap.String("k", "v"))
+
+	var pool sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		pool.Add(1)
+		go func() {
+			defer pool.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				logger.Debug("parent")
+				child.Debug("child")
+			}
+		}()
+	}
+
+	var files []*RotateFile
+	for i := 0; i < 10; i++ {
+		f, err := NewRotateFile(filepath.Join(dir, "child.log"))
+		require.NoError(t, err)
+		files = append(files, f)
+		require.NoError(t, child.AddSink(f, LevelDebug))
+	}
+	close(stop)
+	pool.Wait()
+
+	// sink of child does not receive logs of parent
+	child.Debug("done")
+	for _, f := range files {
+		require.NoError(t, f.Close())
+	}
+
+	cnt, err := os.ReadFile(filepath.Join(dir, "child.log"))
+	require.NoError(t, err)
+	require.NotContains(t, string(cnt), `"parent"`)
+	require.Contains(t, string(cnt), `"done"`)
+	require.Contains(t, string(cnt), `"k":"v"`)
+}
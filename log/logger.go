@@ -123,6 +123,8 @@ type LoggerT struct {
 	// zap logger do not expose api to change log's level,
 	// so we have to save the pointer of zap.AtomicLevel.
 	level zap.AtomicLevel
+	// file rotate file of logger created by NewFileLogger
+	file *RotateFile
 }
 
 // NewWithName create new logger with name
@@ -157,17 +159,22 @@ func (o *option) fillDefault() *option {
 		Level:            zap.NewAtomicLevel(),
 		Development:      false,
 		Encoding:         string(EncodingConsole),
-		EncoderConfig:    zap.NewProductionEncoderConfig(),
+		EncoderConfig:    defaultEncoderConfig(),
 		OutputPaths:      []string{"stdout"},
 		ErrorOutputPaths: []string{"stderr"},
 	}
-	o.EncoderConfig.EncodeCaller = zapcore.ShortCallerEncoder
-	o.EncoderConfig.MessageKey = "message"
-	o.EncoderConfig.EncodeTime = zapcore.RFC3339TimeEncoder
-	o.EncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
 	return o
 }
 
+func defaultEncoderConfig() zapcore.EncoderConfig {
+	cfg := zap.NewProductionEncoderConfig()
+	cfg.EncodeCaller = zapcore.ShortCallerEncoder
+	cfg.MessageKey = "message"
+	cfg.EncodeTime = zapcore.RFC3339TimeEncoder
+	cfg.EncodeLevel = zapcore.CapitalLevelEncoder
+	return cfg
+}
+
 func (o *option) applyOpts(optfs ...Option) (*option, error) {
 	for _, optf := range optfs {
 		if err := optf(o); err != nil {
@@ -312,7 +319,9 @@ func New(optfs ...Option) (l *LoggerT, err error) {
 	if err != nil {
 		return nil, errors.Errorf("build zap logger: %+v", err)
 	}
-	zapLogger = zapLogger.Named(opt.Name)
+	zapLogger = zapLogger.Named(opt.Name).WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return newSinkCore(core)
+	}))
 
 	l = &LoggerT{
 		Logger: zapLogger,
@@ -383,27 +392,32 @@ func (l *LoggerT) WarnSample(sample int, msg string, fields ...zapcore.Field) {
 // Named adds a new path segment to the logger's name. Segments are joined by
 // periods. By default, Loggers are unnamed.
 func (l *LoggerT) Named(s string) *LoggerT {
-	return &LoggerT{
-		Logger: l.Logger.Named(s),
-		level:  l.level,
-	}
+	return l.child(l.Logger.Named(s))
 }
 
 // With creates a child logger and adds structured context to it. Fields added
 // to the child don't affect the parent, and vice versa.
 func (l *LoggerT) With(fields ...zapcore.Field) *LoggerT {
-	return &LoggerT{
-		Logger: l.Logger.With(fields...),
-		level:  l.level,
-	}
+	return l.child(l.Logger.With(fields...))
 }
 
 // WithOptions clones the current Logger, applies the supplied Options, and
 // returns the resulting Logger. It's safe to use concurrently.
 func (l *LoggerT) WithOptions(opts ...zap.Option) *LoggerT {
+	return l.child(l.Logger.WithOptions(opts...))
+}
+
+// child wrap logger derived from l, sinks added to child later
+// do not affect l, and vice versa
+func (l *LoggerT) child(logger *zap.Logger) *LoggerT {
+	if logger.Core() == l.Logger.Core() {
+		logger = forkLogger(logger)
+	}
+
 	return &LoggerT{
-		Logger: l.Logger.WithOptions(opts...),
+		Logger: logger,
 		level:  l.level,
+		file:   l.file,
 	}
 }
 
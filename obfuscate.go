@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"hash"
+
+	"github.com/Laisky/errors/v2"
+)
+
+const (
+	idObfuscatorMinKeySize  = 16
+	idObfuscatorRounds      = 8
+	idObfuscatorChecksumLen = 4
+	idObfuscatorTokenLen    = 8 + idObfuscatorChecksumLen
+)
+
+// IDObfuscatorEncoding text encoding of token
+type IDObfuscatorEncoding uint8
+
+const (
+	// IDObfuscatorBase58 encode token by base58 with bitcoin alphabet
+	IDObfuscatorBase58 IDObfuscatorEncoding = iota
+	// IDObfuscatorBase32 encode token by Crockford's base32
+	IDObfuscatorBase32
+)
+
+type idObfuscatorOption struct {
+	encoding IDObfuscatorEncoding
+}
+
+// IDObfuscatorOption options for NewIDObfuscator
+type IDObfuscatorOption func(*idObfuscatorOption) error
+
+// WithIDObfuscatorEncoding set encoding of token, default is IDObfuscatorBase58
+func WithIDObfuscatorEncoding(encoding IDObfuscatorEncoding) IDObfuscatorOption {
+	return func(o *idObfuscatorOption) error {
+		switch encoding {
+		case IDObfuscatorBase58, IDObfuscatorBase32:
+		default:
+			return errors.Errorf("unknown encoding %d", encoding)
+		}
+
+		o.encoding = encoding
+		return nil
+	}
+}
+
+// IDObfuscator map integer ids to non-enumerable tokens reversibly,
+// create by NewIDObfuscator
+type IDObfuscator struct {
+	opt         *idObfuscatorOption
+	feistelKey  []byte
+	checksumKey []byte
+}
+
+// NewIDObfuscator new obfuscator by key, key should be at least 16 bytes.
+//
+// id is permuted by a 64-bit Feistel network with HMAC-SHA256 as round function,
+// and appended with a keyed checksum, so the same id always maps to
+// the same token by the same key, and tokens from other keys are rejected.
+//
+// it hides the order and density of ids, but is not an encryption
+// for confidential data.
+func NewIDObfuscator(key []byte, opts ...IDObfuscatorOption) (*IDObfuscator, error) {
+	if len(key) < idObfuscatorMinKeySize {
+		return nil, errors.Errorf("key should be at least %d bytes, got %d",
+			idObfuscatorMinKeySize, len(key))
+	}
+
+	opt := new(idObfuscatorOption)
+	for _, f := range opts {
+		if err := f(opt); err != nil {
+			return nil, errors.Wrap(err, "apply option")
+		}
+	}
+
+	deriveKey := func(label string) []byte {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(label))
+		return mac.Sum(nil)
+	}
+
+	return &IDObfuscator{
+		opt:         opt,
+		feistelKey:  deriveKey("gutils id obfuscator feistel"),
+		checksumKey: deriveKey("gutils id obfuscator checksum"),
+	}, nil
+}
+
+// round feistel round function
+func (o *IDObfuscator) round(mac hash.Hash, round byte, half uint32) uint32 {
+	var buf [5]byte
+	buf[0] = round
+	binary.BigEndian.PutUint32(buf[1:], half)
+
+	mac.Reset()
+	mac.Write(buf[:])
+	return binary.BigEndian.Uint32(mac.Sum(nil))
+}
+
+// permute encrypt or decrypt id by feistel network
+func (o *IDObfuscator) permute(id uint64, inverse bool) uint64 {
+	mac := hmac.New(sha256.New, o.feistelKey)
+	left, right := uint32(id>>32), uint32(id)
+	for i := 0; i < idObfuscatorRounds; i++ {
+		if inverse {
+			round := byte(idObfuscatorRounds - 1 - i)
+			left, right = right^o.round(mac, round, left), left
+		} else {
+			left, right = right, left^o.round(mac, byte(i), right)
+		}
+	}
+
+	return uint64(left)<<32 | uint64(right)
+}
+
+func (o *IDObfuscator) checksum(permuted []byte) []byte {
+	mac := hmac.New(sha256.New, o.checksumKey)
+	mac.Write(permuted)
+	return mac.Sum(nil)[:idObfuscatorChecksumLen]
+}
+
+// Encode map id to token
+func (o *IDObfuscator) Encode(id uint64) string {
+	raw := make([]byte, 8, idObfuscatorTokenLen)
+	binary.BigEndian.PutUint64(raw, o.permute(id, false))
+	raw = append(raw, o.checksum(raw)...)
+
+	if o.opt.encoding == IDObfuscatorBase32 {
+		return EncodeByBase32Crockford(raw)
+	}
+
+	return EncodeByBase58(raw)
+}
+
+// Decode map token back to id, return error if token is invalid
+// or not encoded by the same key
+func (o *IDObfuscator) Decode(token string) (uint64, error) {
+	var (
+		raw []byte
+		err error
+	)
+	if o.opt.encoding == IDObfuscatorBase32 {
+		raw, err = DecodeByBase32Crockford(token)
+	} else {
+		raw, err = DecodeByBase58(token)
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "decode token")
+	}
+
+	if len(raw) != idObfuscatorTokenLen {
+		return 0, errors.Errorf("invalid token length %d", len(raw))
+	}
+
+	if subtle.ConstantTimeCompare(o.checksum(raw[:8]), raw[8:]) != 1 {
+		return 0, errors.New("token checksum mismatch")
+	}
+
+	return o.permute(binary.BigEndian.Uint64(raw[:8]), true), nil
+}
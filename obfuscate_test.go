@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIDObfuscator(t *testing.T) {
+	key := []byte("0123456789abcdef")
+
+	_, err := NewIDObfuscator([]byte("short"))
+	require.ErrorContains(t, err, "at least 16 bytes")
+	_, err = NewIDObfuscator(key, WithIDObfuscatorEncoding(100))
+	require.Error(t, err)
+
+	for _, encoding := range []IDObfuscatorEncoding{IDObfuscatorBase58, IDObfuscatorBase32} {
+		o, err := NewIDObfuscator(key, WithIDObfuscatorEncoding(encoding))
+		require.NoError(t, err)
+
+		t.Run("boundaries", func(t *testing.T) {
+			for _, id := range []uint64{0, 1, 2, math.MaxUint32, math.MaxUint64 - 1, math.MaxUint64} {
+				token := o.Encode(id)
+				require.Equal(t, token, o.Encode(id), "should be deterministic")
+
+				got, err := o.Decode(token)
+				require.NoError(t, err)
+				require.Equal(t, id, got)
+			}
+		})
+
+		t.Run("bijective", func(t *testing.T) {
+			rnd := rand.New(rand.NewSource(1))
+			tokens := make(map[string]uint64, 100000)
+			for i := 0; i < 100000; i++ {
+				id := rnd.Uint64()
+				if i < 1000 {
+					// sequential ids
+					id = uint64(i)
+				}
+
+				token := o.Encode(id)
+				if prev, ok := tokens[token]; ok {
+					require.Equal(t, prev, id, "collision")
+				}
+				tokens[token] = id
+
+				got, err := o.Decode(token)
+				require.NoError(t, err)
+				require.Equal(t, id, got)
+			}
+		})
+
+		t.Run("wrong key", func(t *testing.T) {
+			other, err := NewIDObfuscator([]byte("fedcba9876543210"), WithIDObfuscatorEncoding(encoding))
+			require.NoError(t, err)
+
+			for id := uint64(0); id < 1000; id++ {
+				require.NotEqual(t, o.Encode(id), other.Encode(id))
+
+				_, err = other.Decode(o.Encode(id))
+				require.ErrorContains(t, err, "checksum mismatch")
+			}
+		})
+
+		t.Run("invalid token", func(t *testing.T) {
+			for _, token := range []string{"", "0OIl", "abc", o.Encode(1) + "2"} {
+				_, err := o.Decode(token)
+				require.Error(t, err, token)
+			}
+		})
+	}
+}
+
+func BenchmarkIDObfuscator(b *testing.B) {
+	o, err := NewIDObfuscator([]byte("0123456789abcdef"))
+	require.NoError(b, err)
+	token := o.Encode(123456789)
+
+	b.Run("encode", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = o.Encode(uint64(i))
+		}
+	})
+
+	b.Run("decode", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := o.Decode(token); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -2,10 +2,16 @@ package utils
 
 import (
 	crand "crypto/rand"
+	"encoding/binary"
+	"math"
 	"math/big"
 	"math/rand"
+	"slices"
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/Laisky/errors/v2"
 )
 
 var (
@@ -102,3 +108,229 @@ func RandomChoice[T any](arr []T, n int) (got []T) {
 
 	return got
 }
+
+type randOption struct {
+	src rand.Source
+}
+
+// RandOption options for random utils like Shuffle and WeightedChoice
+type RandOption func(*randOption)
+
+// WithRandSource use src to generate random numbers,
+// set a seeded source to make results deterministic in tests.
+//
+// src is protected by a mutex, so it can be shared by goroutines.
+func WithRandSource(src rand.Source) RandOption {
+	return func(o *randOption) {
+		o.src = src
+	}
+}
+
+// WithCryptoRand use crypto/rand to generate random numbers
+func WithCryptoRand() RandOption {
+	return WithRandSource(cryptoSource{})
+}
+
+// sharedRand shared by random utils without RandOption
+var sharedRand = rand.New(globalSource{})
+
+// newRandFromOpts return a thread-safe random generator,
+// default to sharedRand, only create new one if source is set by option.
+func newRandFromOpts(opts ...RandOption) *rand.Rand {
+	opt := new(randOption)
+	for _, f := range opts {
+		f(opt)
+	}
+
+	if opt.src == nil {
+		return sharedRand
+	}
+
+	return rand.New(&lockedSource{src: opt.src})
+}
+
+// globalSource rand.Source backed by top-level functions of math/rand,
+// which are randomly seeded and safe for concurrent use
+type globalSource struct{}
+
+func (globalSource) Seed(int64) {}
+
+func (globalSource) Int63() int64 {
+	return rand.Int63()
+}
+
+func (globalSource) Uint64() uint64 {
+	return rand.Uint64()
+}
+
+// cryptoSource rand.Source backed by crypto/rand
+type cryptoSource struct{}
+
+func (cryptoSource) Seed(int64) {}
+
+func (s cryptoSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+func (cryptoSource) Uint64() uint64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		panic(errors.Wrap(err, "read crypto rand"))
+	}
+
+	return binary.BigEndian.Uint64(b[:])
+}
+
+// lockedSource make rand.Source safe for concurrent use
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	s.src.Seed(seed)
+	s.mu.Unlock()
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+// WeightedChooser choose item randomly by weights,
+// precompute prefix sums for repeated draws.
+//
+// it's safe for concurrent use.
+type WeightedChooser[T any] struct {
+	items  []T
+	prefix []float64
+	// last index of item with positive weight
+	last  int
+	randr *rand.Rand
+}
+
+// NewWeightedChooser create new WeightedChooser
+//
+// items and weights must have the same length,
+// weights must be non-negative and finite, and the total must be positive.
+func NewWeightedChooser[T any, W Number](items []T, weights []W, opts ...RandOption) (*WeightedChooser[T], error) {
+	if len(items) != len(weights) {
+		return nil, errors.Errorf("length of items (%d) and weights (%d) not match",
+			len(items), len(weights))
+	}
+
+	prefix := make([]float64, len(weights))
+	var (
+		total float64
+		last  int
+	)
+	for i, w := range weights {
+		fw := float64(w)
+		switch {
+		case math.IsNaN(fw) || math.IsInf(fw, 0):
+			return nil, errors.Errorf("weight should be finite, got %v at %d", w, i)
+		case fw < 0:
+			return nil, errors.Errorf("weight should not be negative, got %v at %d", w, i)
+		case fw > 0:
+			last = i
+		}
+
+		total += fw
+		prefix[i] = total
+	}
+
+	if !(total > 0) || math.IsInf(total, 0) {
+		return nil, errors.New("total weight should be positive and finite")
+	}
+
+	return &WeightedChooser[T]{
+		items:  items,
+		prefix: prefix,
+		last:   last,
+		randr:  newRandFromOpts(opts...),
+	}, nil
+}
+
+// Choose choose an item randomly,
+// the probability of each item is proportional to its weight.
+func (c *WeightedChooser[T]) Choose() T {
+	target := c.randr.Float64() * c.prefix[len(c.prefix)-1]
+	idx := sort.Search(len(c.prefix), func(i int) bool {
+		return c.prefix[i] > target
+	})
+
+	// target may be rounded up to total
+	return c.items[min(idx, c.last)]
+}
+
+// WeightedChoice choose an item randomly by weights
+//
+// use NewWeightedChooser for repeated draws.
+func WeightedChoice[T any, W Number](items []T, weights []W, opts ...RandOption) (T, error) {
+	chooser, err := NewWeightedChooser(items, weights, opts...)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return chooser.Choose(), nil
+}
+
+// ReservoirSampler sample k items uniformly from a stream
+// of unknown length with O(k) memory, by Algorithm R.
+//
+// not thread-safe.
+type ReservoirSampler[T any] struct {
+	k       int
+	seen    int64
+	samples []T
+	randr   *rand.Rand
+}
+
+// NewReservoirSampler create new ReservoirSampler that keeps k samples,
+// k less than 1 will be set to 1.
+func NewReservoirSampler[T any](k int, opts ...RandOption) *ReservoirSampler[T] {
+	if k < 1 {
+		k = 1
+	}
+
+	return &ReservoirSampler[T]{
+		k:       k,
+		samples: make([]T, 0, k),
+		randr:   newRandFromOpts(opts...),
+	}
+}
+
+// Add add item from stream
+func (s *ReservoirSampler[T]) Add(item T) {
+	s.seen++
+	if len(s.samples) < s.k {
+		s.samples = append(s.samples, item)
+		return
+	}
+
+	if j := s.randr.Int63n(s.seen); j < int64(s.k) {
+		s.samples[j] = item
+	}
+}
+
+// Seen return the number of items added
+func (s *ReservoirSampler[T]) Seen() int64 {
+	return s.seen
+}
+
+// Samples return a copy of current samples,
+// all items are returned if less than k items added.
+func (s *ReservoirSampler[T]) Samples() []T {
+	return slices.Clone(s.samples)
+}
+
+// Shuffle shuffle s in place, by math/rand default,
+// use WithCryptoRand or WithRandSource to change the random source.
+func Shuffle[T any](s []T, opts ...RandOption) {
+	newRandFromOpts(opts...).Shuffle(len(s), func(i, j int) {
+		s[i], s[j] = s[j], s[i]
+	})
+}
@@ -1,6 +1,10 @@
 package utils
 
 import (
+	"math"
+	"math/rand"
+	"slices"
+	"sync"
 	"testing"
 	"time"
 
@@ -54,3 +58,167 @@ func BenchmarkRandomChoice(b *testing.B) {
 		}
 	})
 }
+
+func TestWeightedChoice(t *testing.T) {
+	t.Run("invalid", func(t *testing.T) {
+		_, err := WeightedChoice([]string{"a", "b"}, []int{1})
+		require.ErrorContains(t, err, "not match")
+		_, err = WeightedChoice([]string{"a", "b"}, []int{1, -1})
+		require.ErrorContains(t, err, "negative")
+		_, err = WeightedChoice([]string{"a", "b"}, []int{0, 0})
+		require.ErrorContains(t, err, "positive")
+		_, err = WeightedChoice([]string{}, []int{})
+		require.ErrorContains(t, err, "positive")
+	})
+
+	t.Run("zero weight never chosen", func(t *testing.T) {
+		for i := 0; i < 1000; i++ {
+			got, err := WeightedChoice([]string{"a", "b", "c"}, []int{0, 1, 0})
+			require.NoError(t, err)
+			require.Equal(t, "b", got)
+		}
+	})
+
+	t.Run("distribution", func(t *testing.T) {
+		chooser, err := NewWeightedChooser([]string{"a", "b", "c"}, []int{1, 2, 7})
+		require.NoError(t, err)
+
+		const n = 100000
+		cnt := map[string]int{}
+		for i := 0; i < n; i++ {
+			cnt[chooser.Choose()]++
+		}
+
+		require.InDelta(t, 0.1, float64(cnt["a"])/n, 0.01)
+		require.InDelta(t, 0.2, float64(cnt["b"])/n, 0.01)
+		require.InDelta(t, 0.7, float64(cnt["c"])/n, 0.01)
+	})
+
+	t.Run("float weights", func(t *testing.T) {
+		_, err := WeightedChoice([]string{"a", "b"}, []float64{1, math.NaN()})
+		require.ErrorContains(t, err, "finite")
+		_, err = WeightedChoice([]string{"a", "b"}, []float64{1, math.Inf(1)})
+		require.ErrorContains(t, err, "finite")
+		_, err = WeightedChoice([]string{"a", "b"}, []float64{0.5, -0.1})
+		require.ErrorContains(t, err, "negative")
+
+		chooser, err := NewWeightedChooser([]string{"a", "b", "c"}, []float64{0.25, 0, 0.75})
+		require.NoError(t, err)
+
+		const n = 100000
+		cnt := map[string]int{}
+		for i := 0; i < n; i++ {
+			cnt[chooser.Choose()]++
+		}
+
+		require.InDelta(t, 0.25, float64(cnt["a"])/n, 0.01)
+		require.Zero(t, cnt["b"])
+		require.InDelta(t, 0.75, float64(cnt["c"])/n, 0.01)
+	})
+
+	t.Run("deterministic", func(t *testing.T) {
+		items := []int{1, 2, 3, 4, 5}
+		weights := []float64{1, 2, 3, 4, 5}
+		draw := func() (got []int) {
+			chooser, err := NewWeightedChooser(items, weights, WithRandSource(rand.NewSource(42)))
+			require.NoError(t, err)
+			for i := 0; i < 100; i++ {
+				got = append(got, chooser.Choose())
+			}
+
+			return got
+		}
+
+		require.Equal(t, draw(), draw())
+	})
+
+	t.Run("crypto rand", func(t *testing.T) {
+		got, err := WeightedChoice([]string{"a", "b"}, []int{0, 1}, WithCryptoRand())
+		require.NoError(t, err)
+		require.Equal(t, "b", got)
+	})
+}
+
+func TestReservoirSampler(t *testing.T) {
+	t.Run("less than k", func(t *testing.T) {
+		s := NewReservoirSampler[int](5)
+		require.Empty(t, s.Samples())
+		for i := 0; i < 3; i++ {
+			s.Add(i)
+		}
+
+		require.Equal(t, []int{0, 1, 2}, s.Samples())
+		require.EqualValues(t, 3, s.Seen())
+	})
+
+	t.Run("samples is a copy", func(t *testing.T) {
+		s := NewReservoirSampler[int](1)
+		s.Add(1)
+		s.Samples()[0] = 2
+		require.Equal(t, []int{1}, s.Samples())
+	})
+
+	t.Run("uniform", func(t *testing.T) {
+		const (
+			streamLen = 20
+			k         = 5
+			rounds    = 20000
+		)
+
+		src := rand.NewSource(1)
+		cnt := make([]int, streamLen)
+		for r := 0; r < rounds; r++ {
+			s := NewReservoirSampler[int](k, WithRandSource(src))
+			for i := 0; i < streamLen; i++ {
+				s.Add(i)
+			}
+
+			samples := s.Samples()
+			require.Len(t, samples, k)
+			for _, v := range samples {
+				cnt[v]++
+			}
+		}
+
+		// each position should be sampled with probability k/n
+		for i, c := range cnt {
+			require.InDelta(t, float64(k)/streamLen, float64(c)/rounds, 0.02, "position %d", i)
+		}
+	})
+}
+
+func TestShuffle(t *testing.T) {
+	origin := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	s1 := slices.Clone(origin)
+	Shuffle(s1, WithRandSource(rand.NewSource(7)))
+	s2 := slices.Clone(origin)
+	Shuffle(s2, WithRandSource(rand.NewSource(7)))
+	require.Equal(t, s1, s2)
+	require.ElementsMatch(t, origin, s1)
+
+	s3 := slices.Clone(origin)
+	Shuffle(s3, WithCryptoRand())
+	require.ElementsMatch(t, origin, s3)
+
+	Shuffle([]int{})
+	Shuffle([]int(nil))
+}
+
+func Test_newRandFromOpts(t *testing.T) {
+	// shared by default, avoid allocating and seeding on every call
+	require.Same(t, sharedRand, newRandFromOpts())
+	require.NotSame(t, sharedRand, newRandFromOpts(WithRandSource(rand.NewSource(1))))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				require.Less(t, newRandFromOpts().Float64(), 1.0)
+			}
+		}()
+	}
+	wg.Wait()
+}
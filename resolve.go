@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ValueSource source of config values, used by ResolveValue
+type ValueSource interface {
+	// Name name of source, like `env`, returned as origin by ResolveValueOrigin
+	Name() string
+	// Lookup return value of key, ok is false if key not exists
+	Lookup(key string) (val string, ok bool)
+}
+
+// ResolveValue return the value of key from the first source that has key,
+// so sources should be ordered by precedence, like env, flags, file, config server.
+func ResolveValue(key string, sources ...ValueSource) (string, bool) {
+	val, _, ok := ResolveValueOrigin(key, sources...)
+	return val, ok
+}
+
+// ResolveValueOrigin same as ResolveValue, also return the name of the source
+func ResolveValueOrigin(key string, sources ...ValueSource) (val, origin string, ok bool) {
+	for _, src := range sources {
+		if src == nil {
+			continue
+		}
+
+		if val, ok = src.Lookup(key); ok {
+			return val, src.Name(), true
+		}
+	}
+
+	return "", "", false
+}
+
+type valueSourceFunc struct {
+	name   string
+	lookup func(key string) (string, bool)
+}
+
+// Name return name of source
+func (s valueSourceFunc) Name() string {
+	return s.name
+}
+
+// Lookup return value of key
+func (s valueSourceFunc) Lookup(key string) (string, bool) {
+	return s.lookup(key)
+}
+
+// NewValueSourceFunc wrap lookup func as ValueSource,
+// like flags, viper or config server clients.
+func NewValueSourceFunc(name string, lookup func(key string) (string, bool)) ValueSource {
+	return valueSourceFunc{name: name, lookup: lookup}
+}
+
+// NewEnvValueSource ValueSource named `env` that reads environments by os.LookupEnv,
+// key like `db.max-conns` is mapped to `<PREFIX>DB_MAX_CONNS`.
+//
+// set but empty environments are treated as existing.
+func NewEnvValueSource(prefix string) ValueSource {
+	replacer := strings.NewReplacer(".", "_", "-", "_")
+	return NewValueSourceFunc("env", func(key string) (string, bool) {
+		return os.LookupEnv(prefix + strings.ToUpper(replacer.Replace(key)))
+	})
+}
+
+// NewMapValueSource ValueSource that reads nested map by dotted key like `db.host`,
+// as FlattenMap does. values are formatted by fmt.Sprint,
+// nested maps are not values.
+func NewMapValueSource(name string, m map[string]any) ValueSource {
+	return NewValueSourceFunc(name, func(key string) (string, bool) {
+		var cur any = m
+		for _, part := range strings.Split(key, ".") {
+			sub, ok := cur.(map[string]any)
+			if !ok {
+				return "", false
+			}
+
+			if cur, ok = sub[part]; !ok {
+				return "", false
+			}
+		}
+
+		if _, ok := cur.(map[string]any); ok {
+			return "", false
+		}
+
+		return fmt.Sprint(cur), true
+	})
+}
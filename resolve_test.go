@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveValue(t *testing.T) {
+	t.Setenv("APP_DB_HOST", "env-host")
+	t.Setenv("APP_DB_MAX_CONNS", "")
+
+	file := NewMapValueSource("file", map[string]any{
+		"db": map[string]any{
+			"host":      "file-host",
+			"port":      5432,
+			"max-conns": 10,
+		},
+		"name": "svc",
+	})
+	flags := NewValueSourceFunc("flags", func(key string) (string, bool) {
+		if key == "name" {
+			return "flag-name", true
+		}
+
+		return "", false
+	})
+	sources := []ValueSource{NewEnvValueSource("APP_"), flags, file}
+
+	for _, c := range []struct {
+		key, val, origin string
+		ok               bool
+	}{
+		{"db.host", "env-host", "env", true},
+		{"db.max-conns", "", "env", true},
+		{"db.port", "5432", "file", true},
+		{"name", "flag-name", "flags", true},
+		{"db", "", "", false},
+		{"db.host.x", "", "", false},
+		{"missing", "", "", false},
+	} {
+		val, origin, ok := ResolveValueOrigin(c.key, sources...)
+		require.Equal(t, c.ok, ok, c.key)
+		require.Equal(t, c.val, val, c.key)
+		require.Equal(t, c.origin, origin, c.key)
+
+		val, ok = ResolveValue(c.key, sources...)
+		require.Equal(t, c.ok, ok, c.key)
+		require.Equal(t, c.val, val, c.key)
+	}
+
+	val, ok := ResolveValue("db.host", nil, file)
+	require.True(t, ok)
+	require.Equal(t, "file-host", val)
+
+	_, ok = ResolveValue("db.host")
+	require.False(t, ok)
+}
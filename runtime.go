@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Laisky/errors/v2"
+)
+
+// RuntimeStats snapshot of runtime state, returned by RuntimeSnapshot
+type RuntimeStats struct {
+	Time       time.Time `json:"time"`
+	Goroutines int       `json:"goroutines"`
+	GOMAXPROCS int       `json:"gomaxprocs"`
+	NumCPU     int       `json:"num_cpu"`
+
+	HeapAlloc     uint64    `json:"heap_alloc"`
+	HeapInuse     uint64    `json:"heap_inuse"`
+	HeapObjects   uint64    `json:"heap_objects"`
+	TotalAlloc    uint64    `json:"total_alloc"`
+	Sys           uint64    `json:"sys"`
+	Mallocs       uint64    `json:"mallocs"`
+	Frees         uint64    `json:"frees"`
+	NumGC         uint32    `json:"num_gc"`
+	LastGC        time.Time `json:"last_gc"`
+	GCCPUFraction float64   `json:"gc_cpu_fraction"`
+
+	// GCPause percentiles of recent gc pauses, at most 256
+	GCPause GCPauseStats `json:"gc_pause"`
+
+	// BuildInfo build info without deps, nil if not available
+	BuildInfo *debug.BuildInfo `json:"build_info,omitempty"`
+}
+
+// GCPauseStats percentiles of gc pauses
+type GCPauseStats struct {
+	P50 time.Duration `json:"p50"`
+	P95 time.Duration `json:"p95"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+var runtimeBuildInfo = sync.OnceValue(func() *debug.BuildInfo {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+
+	cp := *info
+	cp.Deps = nil
+	return &cp
+})
+
+// RuntimeSnapshot return current runtime stats,
+// it calls runtime.ReadMemStats, which stops the world briefly.
+func RuntimeSnapshot() RuntimeStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	stats := RuntimeStats{
+		Time:          time.Now().UTC(),
+		Goroutines:    runtime.NumGoroutine(),
+		GOMAXPROCS:    runtime.GOMAXPROCS(0),
+		NumCPU:        runtime.NumCPU(),
+		HeapAlloc:     mem.HeapAlloc,
+		HeapInuse:     mem.HeapInuse,
+		HeapObjects:   mem.HeapObjects,
+		TotalAlloc:    mem.TotalAlloc,
+		Sys:           mem.Sys,
+		Mallocs:       mem.Mallocs,
+		Frees:         mem.Frees,
+		NumGC:         mem.NumGC,
+		GCCPUFraction: mem.GCCPUFraction,
+		GCPause:       gcPauseStats(&mem),
+		BuildInfo:     runtimeBuildInfo(),
+	}
+	if mem.LastGC != 0 {
+		stats.LastGC = time.Unix(0, int64(mem.LastGC)).UTC()
+	}
+
+	return stats
+}
+
+// gcPauseStats calculate percentiles from the circular buffer of recent pauses
+func gcPauseStats(mem *runtime.MemStats) GCPauseStats {
+	n := min(int(mem.NumGC), len(mem.PauseNs))
+	if n == 0 {
+		return GCPauseStats{}
+	}
+
+	pauses := slices.Clone(mem.PauseNs[:])
+	if n < len(pauses) {
+		// buffer is filled from the beginning before wrapping around
+		pauses = pauses[:n]
+	}
+	slices.Sort(pauses)
+
+	percentile := func(p float64) time.Duration {
+		return time.Duration(pauses[int(p*float64(n-1))])
+	}
+
+	return GCPauseStats{
+		P50: percentile(0.5),
+		P95: percentile(0.95),
+		P99: percentile(0.99),
+		Max: time.Duration(pauses[n-1]),
+	}
+}
+
+// limitedWriter write at most remain bytes to w, discard the rest
+type limitedWriter struct {
+	w      io.Writer
+	remain int
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if l.remain <= 0 {
+		return len(p), nil
+	}
+
+	n := min(len(p), l.remain)
+	written, err := l.w.Write(p[:n])
+	l.remain -= written
+	if err != nil {
+		return written, err
+	}
+
+	return len(p), nil
+}
+
+// DumpGoroutines write stacks of all goroutines to w,
+// output is truncated to maxBytes.
+func DumpGoroutines(w io.Writer, maxBytes int) error {
+	if maxBytes <= 0 {
+		return errors.Errorf("maxBytes should greater than 0, got %d", maxBytes)
+	}
+
+	err := pprof.Lookup("goroutine").WriteTo(&limitedWriter{w: w, remain: maxBytes}, 2)
+	return errors.Wrap(err, "write goroutine profile")
+}
+
+// StartRuntimeReporter call report with RuntimeSnapshot on every interval
+// in background, until ctx is done.
+//
+// a tick is skipped if the previous report is still running.
+func StartRuntimeReporter(ctx context.Context, interval time.Duration,
+	report func(RuntimeStats)) error {
+	return startRuntimeReporter(ctx, realSchedClock{}, interval, report)
+}
+
+func startRuntimeReporter(ctx context.Context, clock SchedClock,
+	interval time.Duration, report func(RuntimeStats)) error {
+	if report == nil {
+		return errors.New("report should not be nil")
+	}
+
+	return Every(ctx, interval, func(context.Context) error {
+		report(RuntimeSnapshot())
+		return nil
+	},
+		WithEverySkipIfRunning(),
+		WithEveryClock(clock),
+	)
+}
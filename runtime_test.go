@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Laisky/go-utils/v4/json"
+)
+
+func TestRuntimeSnapshot(t *testing.T) {
+	runtime.GC()
+
+	stats := RuntimeSnapshot()
+	require.Positive(t, stats.Goroutines)
+	require.Equal(t, runtime.GOMAXPROCS(0), stats.GOMAXPROCS)
+	require.Positive(t, stats.NumCPU)
+	require.Positive(t, stats.HeapAlloc)
+	require.Positive(t, stats.Sys)
+	require.Positive(t, stats.NumGC)
+	require.False(t, stats.LastGC.IsZero())
+	require.False(t, stats.Time.IsZero())
+	require.LessOrEqual(t, stats.GCPause.P50, stats.GCPause.P99)
+	require.LessOrEqual(t, stats.GCPause.P99, stats.GCPause.Max)
+	if stats.BuildInfo != nil {
+		require.Empty(t, stats.BuildInfo.Deps)
+	}
+
+	data, err := json.Marshal(stats)
+	require.NoError(t, err)
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(data, &got))
+	require.Contains(t, got, "goroutines")
+	require.Contains(t, got, "heap_alloc")
+	require.Contains(t, got, "gc_pause")
+}
+
+func TestDumpGoroutines(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, DumpGoroutines(&buf, 1<<20))
+	require.Contains(t, buf.String(), "TestDumpGoroutines")
+
+	buf.Reset()
+	require.NoError(t, DumpGoroutines(&buf, 10))
+	require.Equal(t, 10, buf.Len())
+	require.True(t, strings.HasPrefix(buf.String(), "goroutine"))
+
+	require.Error(t, DumpGoroutines(&buf, 0))
+}
+
+func TestStartRuntimeReporter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.Error(t, StartRuntimeReporter(ctx, 0, func(RuntimeStats) {}))
+	require.Error(t, StartRuntimeReporter(ctx, time.Second, nil))
+
+	var (
+		clock = NewFakeSchedClock()
+		cnt   atomic.Int64
+	)
+	err := startRuntimeReporter(ctx, clock, time.Minute, func(stats RuntimeStats) {
+		require.Positive(t, stats.Goroutines)
+		cnt.Add(1)
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		clock.Advance(t, time.Minute)
+		require.Eventually(t, func() bool { return cnt.Load() == int64(i+1) },
+			time.Second, time.Millisecond)
+	}
+
+	clock.Advance(t, 30*time.Second)
+	time.Sleep(10 * time.Millisecond)
+	require.EqualValues(t, 3, cnt.Load())
+
+	cancel()
+	require.Eventually(t, func() bool {
+		clock.mu.Lock()
+		defer clock.mu.Unlock()
+		return clock.tickers[0].stopped
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"strings"
+)
+
+// ConstantTimeEqual compare a and b in constant time,
+// use it to compare tokens or signatures instead of `bytes.Equal`.
+//
+// both inputs are hashed by sha256 before comparison,
+// so it will not return early for inputs with different lengths.
+// the time still grows with the length of inputs,
+// which only leaks the magnitude of the lengths.
+func ConstantTimeEqual(a, b []byte) bool {
+	ha := sha256.Sum256(a)
+	hb := sha256.Sum256(b)
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+// ConstantTimeEqualString compare a and b in constant time,
+// more details in ConstantTimeEqual
+func ConstantTimeEqualString(a, b string) bool {
+	return ConstantTimeEqual([]byte(a), []byte(b))
+}
+
+const (
+	redactEllipsis = "…"
+	// RedactedPlaceholder placeholder for redacted values
+	RedactedPlaceholder = "[REDACTED]"
+)
+
+// RedactString keep keepPrefix runes at the beginning and keepSuffix runes at the end,
+// replace the rest with "…", like `sk-ab…yz`.
+//
+// return "…" if s is too short to hide anything.
+func RedactString(s string, keepPrefix, keepSuffix int) string {
+	keepPrefix = max(keepPrefix, 0)
+	keepSuffix = max(keepSuffix, 0)
+
+	runes := []rune(s)
+	if keepPrefix+keepSuffix >= len(runes) {
+		return redactEllipsis
+	}
+
+	return string(runes[:keepPrefix]) + redactEllipsis + string(runes[len(runes)-keepSuffix:])
+}
+
+// RedactMapKeys return a deep copy of m, with values of matched keys
+// replaced by RedactedPlaceholder. m is not modified.
+//
+// keys are matched case-insensitively, either by the key itself like `password`,
+// or by the dotted path like `user.password` as FlattenMap does.
+// maps inside slices are also redacted, indices are not part of the path.
+//
+// it is designed to be called right before structured logging.
+func RedactMapKeys(m map[string]any, keys []string) map[string]any {
+	if m == nil {
+		return nil
+	}
+
+	m = DeepClone(m)
+	redactMap(m, "", keys)
+	return m
+}
+
+func redactMatch(key, path string, keys []string) bool {
+	for _, k := range keys {
+		if strings.EqualFold(k, key) || strings.EqualFold(k, path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func redactMap(m map[string]any, prefix string, keys []string) {
+	for k, v := range m {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+
+		if redactMatch(k, path, keys) {
+			m[k] = RedactedPlaceholder
+			continue
+		}
+
+		redactValue(v, path, keys)
+	}
+}
+
+func redactValue(v any, path string, keys []string) {
+	switch v := v.(type) {
+	case map[string]any:
+		redactMap(v, path, keys)
+	case []any:
+		for _, item := range v {
+			redactValue(item, path, keys)
+		}
+	case []map[string]any:
+		for _, item := range v {
+			redactMap(item, path, keys)
+		}
+	}
+}
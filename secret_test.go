@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstantTimeEqual(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, ConstantTimeEqual([]byte("token"), []byte("token")))
+	require.True(t, ConstantTimeEqual(nil, []byte{}))
+	require.False(t, ConstantTimeEqual([]byte("token"), []byte("tokem")))
+	require.False(t, ConstantTimeEqual([]byte("token"), []byte("token1")))
+	require.False(t, ConstantTimeEqual([]byte("token"), nil))
+
+	require.True(t, ConstantTimeEqualString("token", "token"))
+	require.False(t, ConstantTimeEqualString("token", "Token"))
+}
+
+func TestRedactString(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []struct {
+		s              string
+		prefix, suffix int
+		expect         string
+	}{
+		{"sk-abcdefghxyz", 5, 2, "sk-ab…yz"},
+		{"sk-abcdefghxyz", 0, 0, "…"},
+		{"sk-abcdefghxyz", 3, 0, "sk-…"},
+		{"sk-abcdefghxyz", -1, 3, "…xyz"},
+		{"abc", 2, 1, "…"},
+		{"abcd", 2, 1, "ab…d"},
+		{"", 2, 2, "…"},
+		{"密码是一二三四", 2, 1, "密码…四"},
+	} {
+		require.Equal(t, c.expect, RedactString(c.s, c.prefix, c.suffix), c.s)
+	}
+}
+
+func TestRedactMapKeys(t *testing.T) {
+	t.Parallel()
+
+	m := map[string]any{
+		"user":     "laisky",
+		"Password": "123",
+		"auth": map[string]any{
+			"token": "abc",
+			"type":  "bearer",
+		},
+		"db": map[string]any{
+			"dsn":  "postgres://",
+			"name": "app",
+		},
+		"accounts": []any{
+			map[string]any{"name": "a", "password": "1"},
+			map[string]any{"name": "b", "password": "2"},
+			"plain",
+		},
+		"children": []map[string]any{
+			{"token": "x"},
+		},
+	}
+
+	got := RedactMapKeys(m, []string{"password", "TOKEN", "db.DSN"})
+	require.Equal(t, map[string]any{
+		"user":     "laisky",
+		"Password": RedactedPlaceholder,
+		"auth": map[string]any{
+			"token": RedactedPlaceholder,
+			"type":  "bearer",
+		},
+		"db": map[string]any{
+			"dsn":  RedactedPlaceholder,
+			"name": "app",
+		},
+		"accounts": []any{
+			map[string]any{"name": "a", "password": RedactedPlaceholder},
+			map[string]any{"name": "b", "password": RedactedPlaceholder},
+			"plain",
+		},
+		"children": []map[string]any{
+			{"token": RedactedPlaceholder},
+		},
+	}, got)
+
+	// original map is not mutated
+	require.Equal(t, "123", m["Password"])
+	require.Equal(t, "abc", m["auth"].(map[string]any)["token"])
+	require.Equal(t, "postgres://", m["db"].(map[string]any)["dsn"])
+	require.Equal(t, "1", m["accounts"].([]any)[0].(map[string]any)["password"])
+	require.Equal(t, "x", m["children"].([]map[string]any)[0]["token"])
+
+	t.Run("dotted path", func(t *testing.T) {
+		got := RedactMapKeys(m, []string{"accounts.password"})
+		require.Equal(t, RedactedPlaceholder, got["accounts"].([]any)[1].(map[string]any)["password"])
+		require.Equal(t, "123", got["Password"])
+
+		// whole section
+		got = RedactMapKeys(m, []string{"auth"})
+		require.Equal(t, RedactedPlaceholder, got["auth"])
+	})
+
+	require.Nil(t, RedactMapKeys(nil, []string{"password"}))
+}
@@ -0,0 +1,363 @@
+package utils
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Laisky/errors/v2"
+)
+
+// Semver semantic version, see https://semver.org/spec/v2.0.0.html
+type Semver struct {
+	Major, Minor, Patch uint64
+	// Prerelease dot-separated identifiers after `-`, like `alpha.1`
+	Prerelease string
+	// Build dot-separated build metadata after `+`, ignored by Compare
+	Build string
+}
+
+// ParseSemver parse version like `v1.2.3-alpha.1+build.5`, leading `v` is optional
+func ParseSemver(s string) (Semver, error) {
+	var v Semver
+	raw := strings.TrimPrefix(s, "v")
+
+	raw, build, hasBuild := strings.Cut(raw, "+")
+	if hasBuild {
+		if err := validSemverIdentifiers(build, false); err != nil {
+			return v, errors.Wrapf(err, "invalid build metadata of %q", s)
+		}
+
+		v.Build = build
+	}
+
+	raw, pre, hasPre := strings.Cut(raw, "-")
+	if hasPre {
+		if err := validSemverIdentifiers(pre, true); err != nil {
+			return v, errors.Wrapf(err, "invalid pre-release of %q", s)
+		}
+
+		v.Prerelease = pre
+	}
+
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return v, errors.Errorf("version %q should be like MAJOR.MINOR.PATCH", s)
+	}
+
+	for i, dst := range []*uint64{&v.Major, &v.Minor, &v.Patch} {
+		n, err := parseSemverNumber(parts[i])
+		if err != nil {
+			return v, errors.Wrapf(err, "invalid version %q", s)
+		}
+
+		*dst = n
+	}
+
+	return v, nil
+}
+
+// parseSemverNumber parse numeric identifier without leading zeros
+func parseSemverNumber(s string) (uint64, error) {
+	if !isSemverNumeric(s) {
+		return 0, errors.Errorf("%q is not a number", s)
+	}
+	if len(s) > 1 && s[0] == '0' {
+		return 0, errors.Errorf("%q should not have leading zeros", s)
+	}
+
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "parse %q", s)
+	}
+
+	return n, nil
+}
+
+func isSemverNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validSemverIdentifiers check dot-separated identifiers are non-empty [0-9A-Za-z-],
+// numeric pre-release identifiers should not have leading zeros.
+func validSemverIdentifiers(s string, prerelease bool) error {
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return errors.New("identifier should not be empty")
+		}
+
+		for i := 0; i < len(id); i++ {
+			c := id[i]
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '-') {
+				return errors.Errorf("invalid character %q in %q", c, id)
+			}
+		}
+
+		if prerelease && len(id) > 1 && id[0] == '0' && isSemverNumeric(id) {
+			return errors.Errorf("numeric identifier %q should not have leading zeros", id)
+		}
+	}
+
+	return nil
+}
+
+// String return version without leading `v`
+func (v Semver) String() string {
+	s := strconv.FormatUint(v.Major, 10) + "." +
+		strconv.FormatUint(v.Minor, 10) + "." +
+		strconv.FormatUint(v.Patch, 10)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+
+	return s
+}
+
+func compareUint64(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Compare return -1 if v < other, 1 if v > other, 0 if equal in precedence.
+//
+// build metadata is ignored, pre-release version has lower precedence
+// than the associated normal version.
+func (v Semver) Compare(other Semver) int {
+	if c := compareUint64(v.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := compareUint64(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := compareUint64(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+
+	switch {
+	case v.Prerelease == other.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case other.Prerelease == "":
+		return -1
+	}
+
+	ids, otherIDs := strings.Split(v.Prerelease, "."), strings.Split(other.Prerelease, ".")
+	for i := 0; i < len(ids) && i < len(otherIDs); i++ {
+		if c := compareSemverIdentifier(ids[i], otherIDs[i]); c != 0 {
+			return c
+		}
+	}
+
+	return compareUint64(uint64(len(ids)), uint64(len(otherIDs)))
+}
+
+// compareSemverIdentifier numeric identifiers are compared numerically
+// and have lower precedence than alphanumeric identifiers
+func compareSemverIdentifier(a, b string) int {
+	aNum, bNum := isSemverNumeric(a), isSemverNumeric(b)
+	switch {
+	case aNum && bNum:
+		// no leading zeros, so longer is bigger
+		if c := compareUint64(uint64(len(a)), uint64(len(b))); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a, b)
+	case aNum:
+		return -1
+	case bNum:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+type semverComparator struct {
+	op      string
+	version Semver
+}
+
+func (c semverComparator) match(v Semver) bool {
+	r := v.Compare(c.version)
+	switch c.op {
+	case ">":
+		return r > 0
+	case ">=":
+		return r >= 0
+	case "<":
+		return r < 0
+	case "<=":
+		return r <= 0
+	default:
+		return r == 0
+	}
+}
+
+// Satisfies check whether v satisfies constraint.
+//
+// constraint is space-separated comparators that all must match,
+// like `>=1.2.0 <2.0.0`. supported comparators:
+//
+//   - `=`, `>`, `>=`, `<`, `<=`, missing minor/patch are treated as 0
+//   - `~1.2.3` means `>=1.2.3 <1.3.0`, `~1` means `>=1.0.0 <2.0.0`
+//   - `^1.2.3` means `>=1.2.3 <2.0.0`, `^0.2.3` means `>=0.2.3 <0.3.0`
+//
+// a pre-release version only satisfies the constraint if some comparator
+// has the same MAJOR.MINOR.PATCH with pre-release, so `^1.2.3` will not
+// match `1.3.0-beta`, but `>=1.3.0-alpha` does.
+func (v Semver) Satisfies(constraint string) (bool, error) {
+	comparators, err := parseSemverConstraint(constraint)
+	if err != nil {
+		return false, err
+	}
+
+	for _, c := range comparators {
+		if !c.match(v) {
+			return false, nil
+		}
+	}
+
+	if v.Prerelease == "" {
+		return true, nil
+	}
+
+	for _, c := range comparators {
+		if c.version.Prerelease != "" &&
+			c.version.Major == v.Major &&
+			c.version.Minor == v.Minor &&
+			c.version.Patch == v.Patch {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func parseSemverConstraint(constraint string) (comparators []semverComparator, err error) {
+	fields := strings.Fields(constraint)
+	if len(fields) == 0 {
+		return nil, errors.New("empty constraint")
+	}
+
+	for _, field := range fields {
+		var op string
+		for _, prefix := range []string{">=", "<=", ">", "<", "=", "~", "^"} {
+			if strings.HasPrefix(field, prefix) {
+				op = prefix
+				break
+			}
+		}
+
+		v, n, err := parsePartialSemver(strings.TrimPrefix(field, op))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid comparator %q", field)
+		}
+
+		// upper bound of ~ and ^, `-0` is the lowest pre-release
+		upper := Semver{Prerelease: "0"}
+		switch op {
+		case "~":
+			if n == 1 {
+				upper.Major = v.Major + 1
+			} else {
+				upper.Major, upper.Minor = v.Major, v.Minor+1
+			}
+		case "^":
+			switch {
+			case v.Major > 0 || n == 1:
+				upper.Major = v.Major + 1
+			case v.Minor > 0 || n == 2:
+				upper.Minor = v.Minor + 1
+			default:
+				upper.Minor, upper.Patch = v.Minor, v.Patch+1
+			}
+		default:
+			if op == "" {
+				op = "="
+			}
+
+			comparators = append(comparators, semverComparator{op: op, version: v})
+			continue
+		}
+
+		comparators = append(comparators,
+			semverComparator{op: ">=", version: v},
+			semverComparator{op: "<", version: upper},
+		)
+	}
+
+	return comparators, nil
+}
+
+// parsePartialSemver parse version that minor and patch may be omitted,
+// return the number of parsed version parts
+func parsePartialSemver(s string) (v Semver, n int, err error) {
+	core, suffix := s, ""
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		core, suffix = s[:i], s[i:]
+	}
+
+	n = strings.Count(core, ".") + 1
+	if n < 3 {
+		if suffix != "" {
+			return v, n, errors.Errorf("partial version %q should not have pre-release or build", s)
+		}
+
+		core += strings.Repeat(".0", 3-n)
+	}
+
+	v, err = ParseSemver(core + suffix)
+	return v, n, err
+}
+
+// SortVersions sort versions in ascending order of precedence,
+// versions with equal precedence keep their original order.
+//
+// return error if any version is invalid.
+func SortVersions(versions []string) ([]string, error) {
+	type item struct {
+		raw     string
+		version Semver
+	}
+
+	items := make([]item, 0, len(versions))
+	for _, raw := range versions {
+		v, err := ParseSemver(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item{raw: raw, version: v})
+	}
+
+	slices.SortStableFunc(items, func(a, b item) int {
+		return a.version.Compare(b.version)
+	})
+
+	sorted := make([]string, 0, len(items))
+	for _, it := range items {
+		sorted = append(sorted, it.raw)
+	}
+
+	return sorted, nil
+}
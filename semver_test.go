@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSemver(t *testing.T) {
+	t.Parallel()
+
+	v, err := ParseSemver("v1.2.3-alpha.1+build.05")
+	require.NoError(t, err)
+	require.Equal(t, Semver{Major: 1, Minor: 2, Patch: 3, Prerelease: "alpha.1", Build: "build.05"}, v)
+	require.Equal(t, "1.2.3-alpha.1+build.05", v.String())
+
+	v, err = ParseSemver("1.0.0-x-y-z.--")
+	require.NoError(t, err)
+	require.Equal(t, "x-y-z.--", v.Prerelease)
+
+	v, err = ParseSemver("1.0.0+21AF26D3----117B344092BD")
+	require.NoError(t, err)
+	require.Equal(t, "21AF26D3----117B344092BD", v.Build)
+	require.Empty(t, v.Prerelease)
+
+	for _, invalid := range []string{
+		"",
+		"v",
+		"1",
+		"1.2",
+		"1.2.3.4",
+		"01.2.3",
+		"1.02.3",
+		"1.2.-3",
+		"1.2.3-",
+		"1.2.3-01",
+		"1.2.3-alpha..1",
+		"1.2.3+",
+		"1.2.3-alpha_1",
+		"1.2.3+build!",
+		"vv1.2.3",
+		"a.b.c",
+		"99999999999999999999.0.0",
+	} {
+		_, err := ParseSemver(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestSemver_Compare(t *testing.T) {
+	t.Parallel()
+
+	// precedence examples from SemVer 2.0.0
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"2.0.0",
+		"2.1.0",
+		"2.1.1",
+		"10.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			a, err := ParseSemver(ordered[i])
+			require.NoError(t, err)
+			b, err := ParseSemver(ordered[j])
+			require.NoError(t, err)
+
+			expect := compareUint64(uint64(i), uint64(j))
+			require.Equal(t, expect, a.Compare(b), "%s vs %s", ordered[i], ordered[j])
+		}
+	}
+
+	a, _ := ParseSemver("1.0.0+build.1")
+	b, _ := ParseSemver("v1.0.0+build.2")
+	require.Zero(t, a.Compare(b))
+}
+
+func TestSemver_Satisfies(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		version, constraint string
+		expect              bool
+	}{
+		{"1.5.0", ">=1.2.0 <2.0.0", true},
+		{"2.0.0", ">=1.2.0 <2.0.0", false},
+		{"1.2.0", ">=1.2.0 <2.0.0", true},
+		{"1.1.9", ">=1.2.0 <2.0.0", false},
+		{"1.2.0", "1.2.0", true},
+		{"1.2.0", "=1.2", true},
+		{"1.2.1", "<=1.2.0", false},
+		{"1.2.1", ">1.2", true},
+		{"4.1.0", "<v4.2.0", true},
+
+		{"1.2.9", "~1.2", true},
+		{"1.3.0", "~1.2", false},
+		{"1.2.2", "~1.2.3", false},
+		{"1.9.0", "~1", true},
+		{"2.0.0", "~1", false},
+
+		{"1.9.9", "^1.2.3", true},
+		{"2.0.0", "^1.2.3", false},
+		{"1.2.2", "^1.2.3", false},
+		{"0.2.9", "^0.2.3", true},
+		{"0.3.0", "^0.2.3", false},
+		{"0.0.3", "^0.0.3", true},
+		{"0.0.4", "^0.0.3", false},
+		{"0.0.9", "^0.0", true},
+		{"0.1.0", "^0.0", false},
+		{"0.9.0", "^0", true},
+
+		// pre-releases only match comparators on the same version with pre-release
+		{"2.0.0-alpha", "<2.0.0", false},
+		{"2.0.0-alpha", "^1.2.3", false},
+		{"1.3.0-beta", "^1.2.3", false},
+		{"1.3.0-beta", ">=1.3.0-alpha", true},
+		{"1.3.0-alpha", ">=1.3.0-beta", false},
+		{"1.3.1-beta", ">=1.3.0-alpha", false},
+		{"1.2.3-beta.3", "^1.2.3-beta.2", true},
+		{"1.2.3-beta.1", "^1.2.3-beta.2", false},
+		{"1.2.4", "^1.2.3-beta.2", true},
+		{"1.2.3-rc.1", "~1.2.3-rc.0", true},
+	} {
+		v, err := ParseSemver(tc.version)
+		require.NoError(t, err)
+
+		ok, err := v.Satisfies(tc.constraint)
+		require.NoError(t, err, tc.constraint)
+		require.Equal(t, tc.expect, ok, "%s satisfies %s", tc.version, tc.constraint)
+	}
+
+	v, _ := ParseSemver("1.0.0")
+	for _, invalid := range []string{"", ">=", ">=1.x", "~1.2-beta", "=>1.0.0", ">=1.0.0 <"} {
+		_, err := v.Satisfies(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestSortVersions(t *testing.T) {
+	t.Parallel()
+
+	sorted, err := SortVersions([]string{
+		"v1.10.0", "1.2.0", "v1.2.0-rc.1", "1.2.0-beta.11", "1.2.0-beta.2", "0.9.0",
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"0.9.0", "1.2.0-beta.2", "1.2.0-beta.11", "v1.2.0-rc.1", "1.2.0", "v1.10.0",
+	}, sorted)
+
+	// equal precedence keeps input order
+	sorted, err = SortVersions([]string{"1.0.0+b", "1.0.0+a"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"1.0.0+b", "1.0.0+a"}, sorted)
+
+	_, err = SortVersions([]string{"1.0.0", "latest"})
+	require.Error(t, err)
+
+	sorted, err = SortVersions(nil)
+	require.NoError(t, err)
+	require.Empty(t, sorted)
+}
@@ -104,6 +104,138 @@ func WaitComplete(ctx context.Context, goros ...func(ctx context.Context) error)
 	}
 }
 
+// WaitWithTimeout wait wg done or timeout,
+// return true if wg done before timeout.
+func WaitWithTimeout(wg *sync.WaitGroup, d time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+
+	return WaitWithContext(wg, ctx) == nil
+}
+
+// WaitWithContext wait wg done or ctx canceled,
+// return ctx.Err() if ctx canceled before wg done.
+//
+//nolint:revive // keep the same argument order as WaitWithTimeout
+func WaitWithContext(wg *sync.WaitGroup, ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		// double check if wg is done at the same time
+		select {
+		case <-done:
+			return nil
+		default:
+			return ctx.Err()
+		}
+	}
+}
+
+// GoSafe run f in group, the panic in f will be converted to error
+// instead of crashing the whole process.
+func GoSafe(group *errgroup.Group, f func() error) {
+	group.Go(func() (err error) {
+		if perr := IsPanic2(func() { err = f() }); perr != nil {
+			return perr
+		}
+
+		return err
+	})
+}
+
+// BoundedGroup errgroup that limits the number of running goroutines,
+// create by NewBoundedGroup
+type BoundedGroup struct {
+	group *errgroup.Group
+	ctx   context.Context
+}
+
+// NewBoundedGroup new group that runs at most maxConcurrency goroutines,
+// maxConcurrency less than 1 will be set to 1.
+//
+// the ctx passed to fns is canceled when any fn returns error or ctx is done.
+func NewBoundedGroup(ctx context.Context, maxConcurrency int) *BoundedGroup {
+	if maxConcurrency < 1 {
+		maxConcurrency = 1
+	}
+
+	group, gctx := errgroup.WithContext(ctx)
+	group.SetLimit(maxConcurrency)
+	return &BoundedGroup{
+		group: group,
+		ctx:   gctx,
+	}
+}
+
+// Go run f in a new goroutine, blocks until there is a free slot.
+//
+// the panic in f will be converted to error, same as GoSafe.
+func (g *BoundedGroup) Go(f func(ctx context.Context) error) {
+	g.group.Go(func() (err error) {
+		if perr := IsPanic2(func() { err = f(g.ctx) }); perr != nil {
+			return perr
+		}
+
+		return err
+	})
+}
+
+// Wait wait all goroutines done, return the first non-nil error
+func (g *BoundedGroup) Wait() error {
+	return g.group.Wait()
+}
+
+// FirstErr run all fns concurrently, return the first non-nil error
+// as soon as any fn failed, without waiting for the rest fns.
+//
+// return nil if all fns succeed. use FirstErrCtx if fns should be canceled.
+func FirstErr(fns ...func() error) error {
+	ctxFns := make([]func(ctx context.Context) error, 0, len(fns))
+	for _, f := range fns {
+		f := f
+		ctxFns = append(ctxFns, func(context.Context) error { return f() })
+	}
+
+	return FirstErrCtx(context.Background(), ctxFns...)
+}
+
+// FirstErrCtx run all fns concurrently, return the first non-nil error
+// as soon as any fn failed, and cancel the rest fns by ctx.
+//
+// return nil if all fns succeed.
+func FirstErrCtx(ctx context.Context, fns ...func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errCh := make(chan error, len(fns))
+	for _, f := range fns {
+		f := f
+		go func() {
+			var err error
+			if perr := IsPanic2(func() { err = f(ctx) }); perr != nil {
+				err = perr
+			}
+
+			errCh <- err
+		}()
+	}
+
+	for range fns {
+		if err := <-errCh; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // const (
 // 	defaultLaiskyRemoteLockTokenUserKey    = "uid"
 // 	defaultLaiskyRemoteLockAuthCookieName  = "general"
@@ -407,3 +539,316 @@ func (m *RWManager) Unlock(name string) {
 
 	mu.(*sync.RWMutex).Unlock() //nolint:forcetypeassert
 }
+
+type lazyOption struct {
+	retryOnError bool
+}
+
+// LazyOption options for NewLazy
+type LazyOption func(*lazyOption)
+
+// WithLazyRetryOnError do not memoize the error returned by init,
+// the failed init will be retried on the next Get.
+func WithLazyRetryOnError() LazyOption {
+	return func(o *lazyOption) {
+		o.retryOnError = true
+	}
+}
+
+type lazyResult[T any] struct {
+	val T
+	err error
+}
+
+// Lazy initialize value on the first Get, and memoize both value and error
+type Lazy[T any] struct {
+	mu     sync.Mutex
+	opt    *lazyOption
+	init   func() (T, error)
+	result atomic.Pointer[lazyResult[T]]
+}
+
+// NewLazy create new Lazy, init will be called at most once
+// (unless WithLazyRetryOnError is set or Reset is called).
+func NewLazy[T any](init func() (T, error), opts ...LazyOption) *Lazy[T] {
+	opt := new(lazyOption)
+	for _, optf := range opts {
+		optf(opt)
+	}
+
+	return &Lazy[T]{
+		opt:  opt,
+		init: init,
+	}
+}
+
+// Get get value, call init if not initialized
+func (l *Lazy[T]) Get() (T, error) {
+	if r := l.result.Load(); r != nil {
+		return r.val, r.err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if r := l.result.Load(); r != nil {
+		return r.val, r.err
+	}
+
+	val, err := l.init()
+	if err != nil && l.opt.retryOnError {
+		return val, err
+	}
+
+	l.result.Store(&lazyResult[T]{val: val, err: err})
+	return val, err
+}
+
+// Reset drop the memoized value, next Get will call init again.
+//
+// mostly used in tests, it's safe to be called while Get is in flight.
+func (l *Lazy[T]) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.result.Store(nil)
+}
+
+// PoolStats statistics of Pool
+type PoolStats struct {
+	// Gets number of Get calls
+	Gets uint64
+	// News number of objects created by newFn,
+	// Gets - News is the number of reused objects
+	News uint64
+	// Puts number of Put calls
+	Puts uint64
+}
+
+// Pool typed wrapper of sync.Pool with statistics
+type Pool[T any] struct {
+	pool             sync.Pool
+	resetFn          func(T)
+	gets, news, puts atomic.Uint64
+}
+
+// NewPool create new Pool
+//
+// # Args
+//   - newFn: create new object when pool is empty
+//   - resetFn: reset object before putting back to pool, can be nil
+//
+// T should be a pointer-like type, otherwise boxing T into any
+// in sync.Pool allocates on every Put.
+func NewPool[T any](newFn func() T, resetFn func(T)) *Pool[T] {
+	p := &Pool[T]{resetFn: resetFn}
+	p.pool.New = func() any {
+		p.news.Add(1)
+		return newFn()
+	}
+
+	return p
+}
+
+// Get get object from pool, or create a new one by newFn
+func (p *Pool[T]) Get() T {
+	p.gets.Add(1)
+	return p.pool.Get().(T) //nolint:forcetypeassert // only T is put into pool
+}
+
+// Put reset object by resetFn and put it back to pool
+func (p *Pool[T]) Put(v T) {
+	p.puts.Add(1)
+	if p.resetFn != nil {
+		p.resetFn(v)
+	}
+
+	p.pool.Put(v)
+}
+
+// Stats return statistics of pool
+func (p *Pool[T]) Stats() PoolStats {
+	return PoolStats{
+		Gets: p.gets.Load(),
+		News: p.news.Load(),
+		Puts: p.puts.Load(),
+	}
+}
+
+type goOption struct {
+	ctx         context.Context
+	maxRestarts int
+	backoff     time.Duration
+	logger      interface{ Error(string, ...zap.Field) }
+	clock       SchedClock
+}
+
+// GoOption options for GoWithRecover
+type GoOption func(*goOption) error
+
+// WithGoContext set parent context of the goroutine,
+// cancel it will stop the goroutine and all pending restarts.
+func WithGoContext(ctx context.Context) GoOption {
+	return func(o *goOption) error {
+		if ctx == nil {
+			return errors.New("ctx should not be nil")
+		}
+
+		o.ctx = ctx
+		return nil
+	}
+}
+
+// WithRestartPolicy restart the panicked goroutine at most maxRestarts times,
+// wait backoff before each restart.
+//
+// default is 0 means never restart, negative maxRestarts means restart forever.
+func WithRestartPolicy(maxRestarts int, backoff time.Duration) GoOption {
+	return func(o *goOption) error {
+		if backoff < 0 {
+			return errors.Errorf("backoff should not be negative, got %s", backoff)
+		}
+
+		o.maxRestarts = maxRestarts
+		o.backoff = backoff
+		return nil
+	}
+}
+
+// WithGoLogger set logger to log panics, default is log.Shared
+func WithGoLogger(logger interface{ Error(string, ...zap.Field) }) GoOption {
+	return func(o *goOption) error {
+		if logger == nil {
+			return errors.New("logger should not be nil")
+		}
+
+		o.logger = logger
+		return nil
+	}
+}
+
+// withGoClock replace the clock used by backoff of GoWithRecover, for tests
+func withGoClock(clock SchedClock) GoOption {
+	return func(o *goOption) error {
+		o.clock = clock
+		return nil
+	}
+}
+
+// GoHandle handle of goroutine started by GoWithRecover
+type GoHandle struct {
+	name     string
+	cancel   context.CancelFunc
+	done     chan struct{}
+	err      error
+	restarts atomic.Int64
+}
+
+// Stop cancel the goroutine's context and stop pending restarts,
+// wait Done to make sure the goroutine has exited.
+func (h *GoHandle) Stop() {
+	h.cancel()
+}
+
+// Done closed when the goroutine exits and will not be restarted
+func (h *GoHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Err return the last panic as *PanicError if the goroutine exited by panic,
+// or the error of invalid options, should be called after Done is closed.
+func (h *GoHandle) Err() error {
+	select {
+	case <-h.done:
+		return h.err
+	default:
+		return nil
+	}
+}
+
+// Restarts return how many times the goroutine has been restarted
+func (h *GoHandle) Restarts() int {
+	return int(h.restarts.Load())
+}
+
+// GoWithRecover run f in a new goroutine, recover and log panics with stack,
+// and restart f according to WithRestartPolicy.
+//
+// Done is closed when f returns normally, ctx is done,
+// or panics exceed the restart budget.
+// if any option is invalid, f will not be run,
+// the returned handle is done with Err set.
+func GoWithRecover(name string, f func(ctx context.Context), opts ...GoOption) *GoHandle {
+	h := &GoHandle{
+		name: name,
+		done: make(chan struct{}),
+	}
+
+	opt := &goOption{
+		ctx:    context.Background(),
+		logger: log.Shared,
+		clock:  realSchedClock{},
+	}
+	for _, optf := range opts {
+		if err := optf(opt); err != nil {
+			h.cancel = func() {}
+			h.err = errors.Wrap(err, "apply option")
+			close(h.done)
+			return h
+		}
+	}
+
+	ctx, cancel := context.WithCancel(opt.ctx)
+	h.cancel = cancel
+
+	go func() {
+		defer close(h.done)
+		defer cancel()
+
+		for {
+			err := h.run(ctx, f, opt)
+			if err == nil || ctx.Err() != nil {
+				h.err = err
+				return
+			}
+
+			if opt.maxRestarts >= 0 && h.Restarts() >= opt.maxRestarts {
+				h.err = err
+				return
+			}
+
+			// wait backoff
+			backoffCh := make(chan struct{})
+			timer := opt.clock.AfterFunc(opt.backoff, func() { close(backoffCh) })
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				h.err = err
+				return
+			case <-backoffCh:
+			}
+
+			h.restarts.Add(1)
+		}
+	}()
+
+	return h
+}
+
+// run invoke f once, return panic as *PanicError
+func (h *GoHandle) run(ctx context.Context, f func(ctx context.Context), opt *goOption) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			perr := newPanicError(r)
+			opt.logger.Error("goroutine panic",
+				zap.String("name", h.name),
+				zap.Any("panic", r),
+				zap.ByteString("stack", perr.Stack),
+			)
+			err = perr
+		}
+	}()
+
+	f(ctx)
+	return nil
+}
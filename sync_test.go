@@ -4,9 +4,8 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
-	"os"
-	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -248,37 +247,6 @@ func TestRaceWithCtx(t *testing.T) {
 	})
 }
 
-func TestNewFlock(t *testing.T) {
-	dir, err := os.MkdirTemp("", "fs*")
-	require.NoError(t, err)
-	t.Logf("create directory: %v", dir)
-	defer os.RemoveAll(dir)
-
-	lockfile := filepath.Join(dir, "test.lock")
-
-	t.Run("file not exist", func(t *testing.T) {
-		f := NewFlock("/123/" + lockfile)
-		require.NoError(t, err)
-		require.Error(t, f.Lock())
-		require.Error(t, f.Unlock())
-	})
-
-	t.Run("same process", func(t *testing.T) {
-		flock1 := NewFlock(lockfile)
-		require.NoError(t, err)
-		flock2 := NewFlock(lockfile)
-		require.NoError(t, err)
-
-		err = flock1.Lock()
-		require.NoError(t, err)
-		err = flock2.Lock()
-		require.NoError(t, err)
-
-		require.NoError(t, flock1.Unlock())
-		require.NoError(t, flock2.Unlock())
-	})
-}
-
 func TestRaceErrWithCtx(t *testing.T) {
 	var gs []func(context.Context) error
 	for i := 0; i < 1000; i++ {
@@ -423,3 +391,470 @@ func TestWaitComplete(t *testing.T) {
 		require.Less(t, cost, time.Second)
 	})
 }
+
+func TestWaitWithTimeout(t *testing.T) {
+	t.Run("timeout", func(t *testing.T) {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		defer wg.Done()
+
+		require.False(t, WaitWithTimeout(&wg, 10*time.Millisecond))
+	})
+
+	t.Run("done", func(t *testing.T) {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			time.Sleep(10 * time.Millisecond)
+		}()
+
+		require.True(t, WaitWithTimeout(&wg, time.Second))
+	})
+}
+
+func TestWaitWithContext(t *testing.T) {
+	t.Run("canceled", func(t *testing.T) {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		defer wg.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err := WaitWithContext(&wg, ctx)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("done", func(t *testing.T) {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			time.Sleep(10 * time.Millisecond)
+		}()
+
+		require.NoError(t, WaitWithContext(&wg, context.Background()))
+	})
+}
+
+func TestGoSafe(t *testing.T) {
+	var pool errgroup.Group
+	GoSafe(&pool, func() error {
+		return nil
+	})
+	GoSafe(&pool, func() error {
+		panic("yo")
+	})
+
+	err := pool.Wait()
+	require.ErrorContains(t, err, "panic: yo")
+
+	pool = errgroup.Group{}
+	GoSafe(&pool, func() error {
+		return errors.New("normal error")
+	})
+	require.ErrorContains(t, pool.Wait(), "normal error")
+}
+
+func TestBoundedGroup(t *testing.T) {
+	t.Run("limit", func(t *testing.T) {
+		const limit = 3
+		g := NewBoundedGroup(context.Background(), limit)
+
+		var running, maxRunning, done atomic.Int32
+		for i := 0; i < 20; i++ {
+			g.Go(func(ctx context.Context) error {
+				n := running.Add(1)
+				defer running.Add(-1)
+				for {
+					cur := maxRunning.Load()
+					if n <= cur || maxRunning.CompareAndSwap(cur, n) {
+						break
+					}
+				}
+
+				time.Sleep(5 * time.Millisecond)
+				done.Add(1)
+				return nil
+			})
+
+			require.LessOrEqual(t, running.Load(), int32(limit))
+		}
+
+		require.NoError(t, g.Wait())
+		require.EqualValues(t, 20, done.Load())
+		require.EqualValues(t, limit, maxRunning.Load())
+	})
+
+	t.Run("error cancels ctx", func(t *testing.T) {
+		g := NewBoundedGroup(context.Background(), 0)
+		g.Go(func(ctx context.Context) error {
+			return errors.New("yo")
+		})
+		g.Go(func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		require.ErrorContains(t, g.Wait(), "yo")
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		g := NewBoundedGroup(context.Background(), 2)
+		g.Go(func(ctx context.Context) error {
+			panic("yo")
+		})
+
+		require.ErrorContains(t, g.Wait(), "panic: yo")
+	})
+}
+
+func TestFirstErr(t *testing.T) {
+	t.Run("all succeed", func(t *testing.T) {
+		require.NoError(t, FirstErr(
+			func() error { return nil },
+			func() error { return nil },
+		))
+		require.NoError(t, FirstErr())
+	})
+
+	t.Run("return first error without waiting", func(t *testing.T) {
+		startAt := time.Now()
+		err := FirstErr(
+			func() error {
+				time.Sleep(time.Second)
+				return nil
+			},
+			func() error {
+				time.Sleep(10 * time.Millisecond)
+				return errors.New("failed")
+			},
+		)
+		require.ErrorContains(t, err, "failed")
+		require.Less(t, time.Since(startAt), 500*time.Millisecond)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		err := FirstErr(func() error { panic("boom") })
+		require.ErrorContains(t, err, "panic: boom")
+	})
+}
+
+func TestFirstErrCtx(t *testing.T) {
+	t.Run("all succeed", func(t *testing.T) {
+		err := FirstErrCtx(context.Background(),
+			func(ctx context.Context) error { return nil },
+			func(ctx context.Context) error { return nil },
+		)
+		require.NoError(t, err)
+	})
+
+	t.Run("return first error and cancel others", func(t *testing.T) {
+		canceled := make(chan struct{})
+		startAt := time.Now()
+		err := FirstErrCtx(context.Background(),
+			func(ctx context.Context) error {
+				<-ctx.Done()
+				close(canceled)
+				time.Sleep(time.Second)
+				return ctx.Err()
+			},
+			func(ctx context.Context) error {
+				time.Sleep(10 * time.Millisecond)
+				return errors.New("failed")
+			},
+		)
+		require.ErrorContains(t, err, "failed")
+		require.Less(t, time.Since(startAt), 500*time.Millisecond)
+
+		select {
+		case <-canceled:
+		case <-time.After(time.Second):
+			t.Fatal("ctx should be canceled")
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		err := FirstErrCtx(context.Background(),
+			func(ctx context.Context) error { panic("boom") },
+		)
+		require.ErrorContains(t, err, "panic: boom")
+	})
+}
+
+func TestLazy(t *testing.T) {
+	t.Run("stampede", func(t *testing.T) {
+		var called int32
+		lazy := NewLazy(func() (int, error) {
+			atomic.AddInt32(&called, 1)
+			time.Sleep(10 * time.Millisecond)
+			return 100, nil
+		})
+
+		var pool errgroup.Group
+		for i := 0; i < 1000; i++ {
+			pool.Go(func() error {
+				v, err := lazy.Get()
+				if err != nil {
+					return err
+				}
+				if v != 100 {
+					return errors.Errorf("unexpected value %d", v)
+				}
+
+				return nil
+			})
+		}
+
+		require.NoError(t, pool.Wait())
+		require.Equal(t, int32(1), atomic.LoadInt32(&called))
+	})
+
+	t.Run("memoize error", func(t *testing.T) {
+		var called int
+		lazy := NewLazy(func() (int, error) {
+			called++
+			return 0, errors.New("failed")
+		})
+
+		for i := 0; i < 3; i++ {
+			_, err := lazy.Get()
+			require.ErrorContains(t, err, "failed")
+		}
+		require.Equal(t, 1, called)
+	})
+
+	t.Run("retry on error", func(t *testing.T) {
+		var called int
+		lazy := NewLazy(func() (int, error) {
+			called++
+			if called < 3 {
+				return 0, errors.New("failed")
+			}
+
+			return called, nil
+		}, WithLazyRetryOnError())
+
+		_, err := lazy.Get()
+		require.Error(t, err)
+		_, err = lazy.Get()
+		require.Error(t, err)
+
+		v, err := lazy.Get()
+		require.NoError(t, err)
+		require.Equal(t, 3, v)
+
+		v, err = lazy.Get()
+		require.NoError(t, err)
+		require.Equal(t, 3, v)
+		require.Equal(t, 3, called)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		var called int32
+		lazy := NewLazy(func() (int32, error) {
+			return atomic.AddInt32(&called, 1), nil
+		})
+
+		v, err := lazy.Get()
+		require.NoError(t, err)
+		require.Equal(t, int32(1), v)
+
+		var pool errgroup.Group
+		for i := 0; i < 100; i++ {
+			pool.Go(func() error {
+				_, err := lazy.Get()
+				return err
+			})
+			pool.Go(func() error {
+				lazy.Reset()
+				return nil
+			})
+		}
+		require.NoError(t, pool.Wait())
+
+		lazy.Reset()
+		v, err = lazy.Get()
+		require.NoError(t, err)
+		require.Equal(t, atomic.LoadInt32(&called), v)
+		require.Greater(t, v, int32(1))
+	})
+}
+
+func TestGoWithRecover(t *testing.T) {
+	t.Parallel()
+
+	t.Run("panic twice then succeed", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			clock  = NewFakeSchedClock()
+			logger = new(everyTestLogger)
+			runs   atomic.Int64
+		)
+		h := GoWithRecover("worker", func(ctx context.Context) {
+			if runs.Add(1) <= 2 {
+				panic("boom")
+			}
+		}, withGoClock(clock), WithGoLogger(logger), WithRestartPolicy(3, time.Second))
+
+		for i := 1; i <= 2; i++ {
+			require.Eventually(t, func() bool { return clock.ActiveTimers() == 1 },
+				time.Second, time.Millisecond)
+			require.EqualValues(t, i, runs.Load())
+
+			// not restarted before backoff
+			clock.Advance(t, time.Second-time.Millisecond)
+			require.EqualValues(t, i, runs.Load())
+			clock.Advance(t, time.Millisecond)
+		}
+
+		<-h.Done()
+		require.NoError(t, h.Err())
+		require.EqualValues(t, 3, runs.Load())
+		require.Equal(t, 2, h.Restarts())
+		require.Equal(t, 2, logger.len())
+	})
+
+	t.Run("exceed restart budget", func(t *testing.T) {
+		t.Parallel()
+
+		clock := NewFakeSchedClock()
+		h := GoWithRecover("worker", func(ctx context.Context) {
+			panic("boom")
+		}, withGoClock(clock), WithGoLogger(new(everyTestLogger)), WithRestartPolicy(1, time.Second))
+		require.NoError(t, h.Err())
+
+		require.Eventually(t, func() bool { return clock.ActiveTimers() == 1 },
+			time.Second, time.Millisecond)
+		clock.Advance(t, time.Second)
+
+		<-h.Done()
+		require.EqualError(t, h.Err(), "panic: boom")
+		require.Equal(t, 1, h.Restarts())
+	})
+
+	t.Run("no restart by default", func(t *testing.T) {
+		t.Parallel()
+
+		h := GoWithRecover("worker", func(ctx context.Context) {
+			panic("boom")
+		}, WithGoLogger(new(everyTestLogger)))
+
+		<-h.Done()
+		require.ErrorContains(t, h.Err(), "boom")
+		require.Zero(t, h.Restarts())
+
+		var perr *PanicError
+		require.ErrorAs(t, h.Err(), &perr)
+		require.Equal(t, "boom", perr.Value)
+		require.Contains(t, string(perr.Stack), "sync_test.go")
+	})
+
+	t.Run("stop during backoff", func(t *testing.T) {
+		t.Parallel()
+
+		clock := NewFakeSchedClock()
+		h := GoWithRecover("worker", func(ctx context.Context) {
+			panic("boom")
+		}, withGoClock(clock), WithGoLogger(new(everyTestLogger)), WithRestartPolicy(-1, time.Hour))
+
+		require.Eventually(t, func() bool { return clock.ActiveTimers() == 1 },
+			time.Second, time.Millisecond)
+		h.Stop()
+
+		<-h.Done()
+		require.Zero(t, h.Restarts())
+		require.Zero(t, clock.ActiveTimers())
+	})
+
+	t.Run("ctx done", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		h := GoWithRecover("worker", func(ctx context.Context) {
+			<-ctx.Done()
+		}, WithGoContext(ctx), WithRestartPolicy(-1, 0))
+
+		cancel()
+		<-h.Done()
+		require.NoError(t, h.Err())
+	})
+
+	t.Run("invalid option", func(t *testing.T) {
+		t.Parallel()
+
+		var called atomic.Bool
+		h := GoWithRecover("worker", func(ctx context.Context) { called.Store(true) },
+			WithRestartPolicy(1, -time.Second))
+
+		<-h.Done()
+		require.ErrorContains(t, h.Err(), "apply option")
+		require.False(t, called.Load())
+		h.Stop()
+	})
+}
+
+func TestPool(t *testing.T) {
+	t.Parallel()
+
+	type obj struct{ n int }
+	pool := NewPool(func() *obj { return new(obj) }, func(o *obj) { o.n = 0 })
+
+	o := pool.Get()
+	require.Zero(t, o.n)
+	o.n = 10
+	pool.Put(o)
+
+	// sync.Pool may drop objects at any time, only check what is guaranteed
+	o = pool.Get()
+	require.Zero(t, o.n)
+
+	stats := pool.Stats()
+	require.EqualValues(t, 2, stats.Gets)
+	require.EqualValues(t, 1, stats.Puts)
+	require.GreaterOrEqual(t, stats.News, uint64(1))
+	require.LessOrEqual(t, stats.News, stats.Gets)
+
+	t.Run("nil reset", func(t *testing.T) {
+		pool := NewPool(func() []byte { return make([]byte, 0, 8) }, nil)
+		pool.Put(pool.Get())
+		require.EqualValues(t, 1, pool.Stats().Puts)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					o := pool.Get()
+					o.n++
+					pool.Put(o)
+				}
+			}()
+		}
+		wg.Wait()
+
+		stats := pool.Stats()
+		require.EqualValues(t, 1002, stats.Gets)
+		require.EqualValues(t, 1001, stats.Puts)
+	})
+}
+
+func BenchmarkPool(b *testing.B) {
+	type obj struct{ buf [64]byte }
+	pool := NewPool(func() *obj { return new(obj) }, func(o *obj) { o.buf = [64]byte{} })
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			o := pool.Get()
+			o.buf[0] = 1
+			pool.Put(o)
+		}
+	})
+	b.ReportMetric(float64(pool.Stats().News), "news")
+}
@@ -0,0 +1,43 @@
+//go:build !windows
+// +build !windows
+
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFlock(t *testing.T) {
+	dir, err := os.MkdirTemp("", "fs*")
+	require.NoError(t, err)
+	t.Logf("create directory: %v", dir)
+	defer os.RemoveAll(dir)
+
+	lockfile := filepath.Join(dir, "test.lock")
+
+	t.Run("file not exist", func(t *testing.T) {
+		f := NewFlock("/123/" + lockfile)
+		require.NoError(t, err)
+		require.Error(t, f.Lock())
+		require.Error(t, f.Unlock())
+	})
+
+	t.Run("same process", func(t *testing.T) {
+		flock1 := NewFlock(lockfile)
+		require.NoError(t, err)
+		flock2 := NewFlock(lockfile)
+		require.NoError(t, err)
+
+		err = flock1.Lock()
+		require.NoError(t, err)
+		err = flock2.Lock()
+		require.NoError(t, err)
+
+		require.NoError(t, flock1.Unlock())
+		require.NoError(t, flock2.Unlock())
+	})
+}
@@ -0,0 +1,301 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// tableEllipsis suffix of truncated cell
+const tableEllipsis = "…"
+
+type tableOption struct {
+	maxWidth    int
+	headerColor int
+	indent      int
+}
+
+// TableOption options for RenderTable and RenderKV
+type TableOption func(*tableOption)
+
+// WithTableMaxWidth truncate cells wider than n with ellipsis,
+// default is 0, means no limit. n less than 1 is ignored.
+func WithTableMaxWidth(n int) TableOption {
+	return func(o *tableOption) {
+		if n > 0 {
+			o.maxWidth = n
+		}
+	}
+}
+
+// WithTableHeaderColor render headers or keys with ANSI color,
+// like ANSIColorFgCyan, default is no color.
+func WithTableHeaderColor(color int) TableOption {
+	return func(o *tableOption) {
+		o.headerColor = color
+	}
+}
+
+// WithTableIndent indent of nested maps in RenderKV, default is 2.
+// negative n is ignored.
+func WithTableIndent(n int) TableOption {
+	return func(o *tableOption) {
+		if n >= 0 {
+			o.indent = n
+		}
+	}
+}
+
+func applyTableOptions(opts []TableOption) *tableOption {
+	opt := &tableOption{indent: 2}
+	for _, f := range opts {
+		f(opt)
+	}
+
+	return opt
+}
+
+// colorize wrap s with header color if set
+func (o *tableOption) colorize(s string) string {
+	if o.headerColor == 0 {
+		return s
+	}
+
+	return Color(o.headerColor, s)
+}
+
+// truncate cut s to max width with ellipsis
+func (o *tableOption) truncate(s string) string {
+	if o.maxWidth == 0 || StringWidth(s) <= o.maxWidth {
+		return s
+	}
+
+	var (
+		sb    strings.Builder
+		width int
+	)
+	for _, r := range s {
+		w := runeWidth(r)
+		if width+w > o.maxWidth-StringWidth(tableEllipsis) {
+			break
+		}
+
+		width += w
+		sb.WriteRune(r)
+	}
+
+	return sb.String() + tableEllipsis
+}
+
+// wideRuneRanges east asian wide and fullwidth ranges
+var wideRuneRanges = [][2]rune{
+	{0x1100, 0x115F},   // Hangul Jamo
+	{0x2E80, 0x303E},   // CJK Radicals, Kangxi, CJK Symbols and Punctuation
+	{0x3041, 0x33FF},   // Hiragana, Katakana, Bopomofo, CJK Compatibility
+	{0x3400, 0x4DBF},   // CJK Unified Ideographs Extension A
+	{0x4E00, 0x9FFF},   // CJK Unified Ideographs
+	{0xA000, 0xA4CF},   // Yi
+	{0xAC00, 0xD7A3},   // Hangul Syllables
+	{0xF900, 0xFAFF},   // CJK Compatibility Ideographs
+	{0xFE30, 0xFE4F},   // CJK Compatibility Forms
+	{0xFF00, 0xFF60},   // Fullwidth Forms
+	{0xFFE0, 0xFFE6},   // Fullwidth Signs
+	{0x1F300, 0x1F64F}, // Misc Symbols and Pictographs, Emoticons
+	{0x1F900, 0x1F9FF}, // Supplemental Symbols and Pictographs
+	{0x20000, 0x3FFFD}, // CJK Unified Ideographs Extension B and later
+}
+
+// runeWidth return the number of terminal columns of r
+func runeWidth(r rune) int {
+	switch {
+	case r == 0, unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	case r < 0x1100:
+		return 1
+	}
+
+	for _, rg := range wideRuneRanges {
+		if r >= rg[0] && r <= rg[1] {
+			return 2
+		}
+	}
+
+	return 1
+}
+
+// StringWidth return the number of terminal columns of s,
+// east asian wide characters take 2 columns, combining marks take 0.
+//
+// ANSI escape sequences are not excluded.
+func StringWidth(s string) int {
+	var width int
+	for _, r := range s {
+		width += runeWidth(r)
+	}
+
+	return width
+}
+
+// padRight pad s with spaces to width
+func padRight(s string, width int) string {
+	if n := width - StringWidth(s); n > 0 {
+		return s + strings.Repeat(" ", n)
+	}
+
+	return s
+}
+
+// RenderTable render rows as aligned columns with headers, like:
+//
+//	NAME   AGE
+//	-----  ---
+//	alice  18
+//
+// the width of column is the widest cell in unicode width,
+// rows shorter than others are filled by empty cells.
+// return empty string if there is neither header nor row.
+func RenderTable(headers []string, rows [][]string, opts ...TableOption) string {
+	opt := applyTableOptions(opts)
+
+	ncol := len(headers)
+	for _, row := range rows {
+		ncol = max(ncol, len(row))
+	}
+	if ncol == 0 {
+		return ""
+	}
+
+	cleanCell := func(row []string, i int) string {
+		if i >= len(row) {
+			return ""
+		}
+
+		return opt.truncate(strings.NewReplacer("\r", "", "\n", " ", "\t", " ").Replace(row[i]))
+	}
+
+	cells := make([][]string, 0, len(rows))
+	widths := make([]int, ncol)
+	for _, row := range append([][]string{headers}, rows...) {
+		cleaned := make([]string, ncol)
+		for i := range cleaned {
+			cleaned[i] = cleanCell(row, i)
+			widths[i] = max(widths[i], StringWidth(cleaned[i]))
+		}
+
+		cells = append(cells, cleaned)
+	}
+
+	var sb strings.Builder
+	writeRow := func(row []string, colorize bool) {
+		line := make([]string, 0, len(row))
+		for i, cell := range row {
+			padded := padRight(cell, widths[i])
+			if colorize {
+				padded = opt.colorize(cell) + padded[len(cell):]
+			}
+
+			line = append(line, padded)
+		}
+
+		sb.WriteString(strings.TrimRight(strings.Join(line, "  "), " ") + "\n")
+	}
+
+	if len(headers) != 0 {
+		writeRow(cells[0], true)
+
+		separators := make([]string, ncol)
+		for i, w := range widths {
+			separators[i] = strings.Repeat("-", w)
+		}
+		writeRow(separators, false)
+	}
+
+	for _, row := range cells[1:] {
+		writeRow(row, false)
+	}
+
+	return sb.String()
+}
+
+// RenderKV render map as sorted `key: value` lines, values are aligned,
+// nested maps are indented, slices are joined by `, `,
+// multi-line values are aligned to the value column, like:
+//
+//	issuer:
+//	  cn: root
+//	is_ca:  true
+func RenderKV(m map[string]any, opts ...TableOption) string {
+	var sb strings.Builder
+	renderKV(&sb, applyTableOptions(opts), reflect.ValueOf(m), 0)
+	return sb.String()
+}
+
+func renderKV(sb *strings.Builder, opt *tableOption, m reflect.Value, depth int) {
+	keys := make([]string, 0, m.Len())
+	values := make(map[string]reflect.Value, m.Len())
+	for _, k := range m.MapKeys() {
+		key := fmt.Sprint(k.Interface())
+		keys = append(keys, key)
+		values[key] = m.MapIndex(k)
+	}
+	sort.Strings(keys)
+
+	var keyWidth int
+	for _, k := range keys {
+		keyWidth = max(keyWidth, StringWidth(k)+1)
+	}
+
+	prefix := strings.Repeat(" ", depth*opt.indent)
+	for _, k := range keys {
+		v := values[k]
+		for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				break
+			}
+
+			v = v.Elem()
+		}
+
+		sb.WriteString(prefix + opt.colorize(k) + ":")
+		if v.Kind() == reflect.Map {
+			sb.WriteString("\n")
+			renderKV(sb, opt, v, depth+1)
+			continue
+		}
+
+		lines := strings.Split(strings.TrimRight(renderKVValue(v), "\n"), "\n")
+		for i, line := range lines {
+			if i == 0 {
+				sb.WriteString(strings.Repeat(" ", keyWidth-StringWidth(k)))
+			} else {
+				sb.WriteString(prefix + strings.Repeat(" ", keyWidth+1))
+			}
+
+			sb.WriteString(opt.truncate(line) + "\n")
+		}
+	}
+}
+
+func renderKVValue(v reflect.Value) string {
+	if !v.IsValid() {
+		return "<nil>"
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+
+		items := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, fmt.Sprint(v.Index(i).Interface()))
+		}
+
+		return strings.Join(items, ", ")
+	}
+
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStringWidth(t *testing.T) {
+	for s, want := range map[string]int{
+		"":     0,
+		"abc":  3,
+		"中文":   4,
+		"한국어":  6,
+		"ｆｕｌｌ": 8,
+		"é":   1,
+		"a中b":  4,
+	} {
+		require.Equal(t, want, StringWidth(s), s)
+	}
+}
+
+func TestRenderTable(t *testing.T) {
+	t.Run("basic", func(t *testing.T) {
+		got := RenderTable(
+			[]string{"NAME", "CITY", "AGE"},
+			[][]string{
+				{"alice", "上海", "18"},
+				{"bob", "NYC"},
+			})
+		require.Equal(t, ""+
+			"NAME   CITY  AGE\n"+
+			"-----  ----  ---\n"+
+			"alice  上海  18\n"+
+			"bob    NYC\n", got)
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		got := RenderTable(
+			[]string{"K", "V"},
+			[][]string{
+				{"a", strings.Repeat("x", 100)},
+				{"b", "中文中文中文"},
+				{"c", "line1\nline2"},
+			},
+			WithTableMaxWidth(6))
+		require.Equal(t, ""+
+			"K  V\n"+
+			"-  ------\n"+
+			"a  xxxxx…\n"+
+			"b  中文…\n"+
+			"c  line1…\n", got)
+	})
+
+	t.Run("color", func(t *testing.T) {
+		got := RenderTable([]string{"A", "BB"}, [][]string{{"xxx", "y"}},
+			WithTableHeaderColor(ANSIColorFgCyan))
+		require.Equal(t, ""+
+			Color(ANSIColorFgCyan, "A")+"    "+Color(ANSIColorFgCyan, "BB")+"\n"+
+			"---  --\n"+
+			"xxx  y\n", got)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		require.Equal(t, "", RenderTable(nil, nil))
+		require.Equal(t, "A  B\n-  -\n", RenderTable([]string{"A", "B"}, nil))
+		require.Equal(t, "x  y\n", RenderTable(nil, [][]string{{"x", "y"}}))
+	})
+}
+
+func TestRenderKV(t *testing.T) {
+	got := RenderKV(map[string]any{
+		"is_ca": "true",
+		"issuer": map[string]any{
+			"cn": "root",
+			"o":  []string{"org1", "org2"},
+		},
+		"public_key": "-----BEGIN-----\nabc\n-----END-----\n",
+		"名字":         "值",
+		"nil":        nil,
+	})
+	require.Equal(t, ""+
+		"is_ca:      true\n"+
+		"issuer:\n"+
+		"  cn: root\n"+
+		"  o:  org1, org2\n"+
+		"nil:        <nil>\n"+
+		"public_key: -----BEGIN-----\n"+
+		"            abc\n"+
+		"            -----END-----\n"+
+		"名字:       值\n", got)
+
+	require.Equal(t, "", RenderKV(nil))
+	require.Equal(t, "k: abc…\n", RenderKV(map[string]any{"k": "abcdefg"}, WithTableMaxWidth(4)))
+}
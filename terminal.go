@@ -3,20 +3,25 @@ package utils
 import (
 	"fmt"
 	"io"
+	"os"
 	"strings"
-	"syscall"
 
 	"github.com/Laisky/errors/v2"
 	"golang.org/x/term"
 )
 
+// IsTerminal check whether f is a terminal, works on unix and windows
+func IsTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
 // InputPassword reads password from stdin input
 // and returns it as a string.
 func InputPassword(hint string, validator func(string) error) (passwd string, err error) {
 	fmt.Printf("%s: \n", hint)
 
 	for {
-		bytepw, err := term.ReadPassword(syscall.Stdin)
+		bytepw, err := term.ReadPassword(stdinFd())
 		if err != nil {
 			return "", errors.Wrap(err, "read input password")
 		}
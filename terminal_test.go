@@ -44,3 +44,17 @@ func TestInputYes(t *testing.T) {
 		})
 	}
 }
+
+func TestIsTerminal(t *testing.T) {
+	fp, err := NewTmpFile(bytes.NewReader([]byte("hello")))
+	require.NoError(t, err)
+	defer fp.Close()
+	require.False(t, IsTerminal(fp))
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	defer w.Close()
+	require.False(t, IsTerminal(r))
+	require.False(t, IsTerminal(w))
+}
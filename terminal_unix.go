@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package utils
+
+import "syscall"
+
+// stdinFd return the fd of stdin for term.ReadPassword
+func stdinFd() int {
+	return syscall.Stdin
+}
@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package utils
+
+import "syscall"
+
+// stdinFd return the console handle of stdin for term.ReadPassword
+func stdinFd() int {
+	return int(syscall.Stdin)
+}
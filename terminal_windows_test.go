@@ -0,0 +1,30 @@
+//go:build windows
+// +build windows
+
+package utils
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStdinFd(t *testing.T) {
+	require.Equal(t, int(syscall.Stdin), stdinFd())
+	require.Equal(t, int(os.Stdin.Fd()), stdinFd())
+}
+
+func TestStopSignal(t *testing.T) {
+	stopCh := StopSignal()
+	select {
+	case <-stopCh:
+		t.Fatal("should not be closed")
+	default:
+	}
+
+	require.True(t, IsPanic(func() {
+		_ = StopSignal(WithStopSignalCloseSignals())
+	}))
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"regexp"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -224,3 +226,289 @@ func MockStdout() (recoverFn func(), stdout *os.File, err error) {
 		os.Stdout = old
 	}, fp, nil
 }
+
+// FakeCMDCall one command invocation recorded by FakeCMDRunner
+type FakeCMDCall struct {
+	App   string
+	Args  []string
+	Envs  []string
+	Stdin []byte
+}
+
+// FakeCMDResult canned result of FakeCMDRunner
+type FakeCMDResult struct {
+	Stdout, Stderr []byte
+	// ExitCode non-zero means the command failed
+	ExitCode int
+	// Err returned as is if not nil, like binary not found
+	Err error
+}
+
+type fakeCMDRule struct {
+	pattern *regexp.Regexp
+	result  FakeCMDResult
+}
+
+// FakeCMDRunner CMDRunner that returns canned results without running anything,
+// create by NewFakeCMDRunner
+type FakeCMDRunner struct {
+	mu    sync.Mutex
+	rules []fakeCMDRule
+	calls []FakeCMDCall
+}
+
+// NewFakeCMDRunner new fake runner without any registered result
+func NewFakeCMDRunner() *FakeCMDRunner {
+	return new(FakeCMDRunner)
+}
+
+// On register result for commands matched by pattern.
+//
+// pattern is regexp matched against the command line like `app arg1 arg2`,
+// rules are checked in the order of registration, the first matched wins.
+func (r *FakeCMDRunner) On(pattern string, result FakeCMDResult) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return errors.Wrapf(err, "compile pattern %q", pattern)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rules = append(r.rules, fakeCMDRule{pattern: re, result: result})
+	return nil
+}
+
+// Calls return all recorded invocations in order
+func (r *FakeCMDRunner) Calls() []FakeCMDCall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]FakeCMDCall(nil), r.calls...)
+}
+
+// match record the call and return the matched result
+func (r *FakeCMDRunner) match(app string,
+	args []string, envs []string, opts ...RunCMDOption) (FakeCMDResult, error) {
+	opt, err := newRunCMDOption(opts...)
+	if err != nil {
+		return FakeCMDResult{}, errors.Wrap(err, "apply option")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = append(r.calls, FakeCMDCall{
+		App:   app,
+		Args:  append([]string(nil), args...),
+		Envs:  append([]string(nil), envs...),
+		Stdin: append([]byte(nil), opt.stdin...),
+	})
+
+	cmdline := strings.Join(append([]string{app}, args...), " ")
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(cmdline) {
+			continue
+		}
+
+		if rule.result.Err != nil {
+			return rule.result, rule.result.Err
+		}
+		if rule.result.ExitCode != 0 {
+			return rule.result, errors.Errorf("run %q: exit status %d", cmdline, rule.result.ExitCode)
+		}
+
+		return rule.result, nil
+	}
+
+	return FakeCMDResult{}, errors.Errorf("no fake result matches %q", cmdline)
+}
+
+// Run return stdout and stderr of the matched result
+func (r *FakeCMDRunner) Run(ctx context.Context, app string, args ...string) ([]byte, error) {
+	return r.RunWithEnv(ctx, app, args, nil)
+}
+
+// RunWithEnv return stdout and stderr of the matched result
+func (r *FakeCMDRunner) RunWithEnv(_ context.Context, app string,
+	args []string, envs []string, opts ...RunCMDOption) ([]byte, error) {
+	result, err := r.match(app, args, envs, opts...)
+	output := append(append([]byte(nil), result.Stdout...), result.Stderr...)
+	if err != nil {
+		return output, errors.Wrapf(err, "got %q", output)
+	}
+
+	return output, nil
+}
+
+// Run2 feed stdout and stderr of the matched result to handlers line by line
+func (r *FakeCMDRunner) Run2(_ context.Context, app string,
+	args []string, envs []string,
+	stdoutHandler, stderrHandler func(string),
+	opts ...RunCMDOption) error {
+	result, err := r.match(app, args, envs, opts...)
+	if err != nil && result.ExitCode == 0 {
+		// not matched or Err
+		return err
+	}
+
+	for _, h := range []struct {
+		out     []byte
+		handler func(string)
+	}{
+		{result.Stdout, stdoutHandler},
+		{result.Stderr, stderrHandler},
+	} {
+		if h.handler == nil || len(h.out) == 0 {
+			continue
+		}
+
+		for _, line := range strings.Split(strings.TrimSuffix(string(h.out), "\n"), "\n") {
+			h.handler(line)
+		}
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "wait cmd")
+	}
+
+	return nil
+}
+
+// FakeSchedClock fake SchedClock for tests, only moves forward by Advance
+type FakeSchedClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []*fakeSchedTimer
+	tickers []*fakeSchedTicker
+}
+
+type fakeSchedTimer struct {
+	clock         *FakeSchedClock
+	when          time.Time
+	f             func()
+	stopped, done bool
+}
+
+func (t *fakeSchedTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	active := !t.stopped && !t.done
+	t.stopped = true
+	return active
+}
+
+type fakeSchedTicker struct {
+	clock   *FakeSchedClock
+	period  time.Duration
+	next    time.Time
+	ch      chan time.Time
+	stopped bool
+}
+
+func (t *fakeSchedTicker) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeSchedTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	t.stopped = true
+}
+
+// NewFakeSchedClock new fake clock starts at 2023-11-14T22:13:20Z
+func NewFakeSchedClock() *FakeSchedClock {
+	return &FakeSchedClock{now: time.Unix(1700000000, 0)}
+}
+
+func (c *FakeSchedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *FakeSchedClock) AfterFunc(d time.Duration, f func()) SchedTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeSchedTimer{clock: c, when: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (c *FakeSchedClock) NewTicker(d time.Duration) SchedTicker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeSchedTicker{clock: c, period: d, next: c.now.Add(d), ch: make(chan time.Time)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// ActiveTimers return the number of timers not fired or stopped
+func (c *FakeSchedClock) ActiveTimers() (n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, t := range c.timers {
+		if !t.stopped && !t.done {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Advance move clock forward, fire timers and tickers in order.
+//
+// timers are invoked synchronously, ticks are delivered blocking.
+func (c *FakeSchedClock) Advance(t testing.TB, d time.Duration) {
+	t.Helper()
+
+	c.mu.Lock()
+	target := c.now.Add(d)
+	for {
+		var (
+			timer  *fakeSchedTimer
+			ticker *fakeSchedTicker
+			when   = target.Add(time.Nanosecond)
+		)
+		for _, tm := range c.timers {
+			if !tm.stopped && !tm.done && tm.when.Before(when) {
+				timer, when = tm, tm.when
+			}
+		}
+		for _, tk := range c.tickers {
+			if !tk.stopped && tk.next.Before(when) {
+				timer, ticker, when = nil, tk, tk.next
+			}
+		}
+
+		switch {
+		case timer != nil:
+			c.now = when
+			timer.done = true
+			c.mu.Unlock()
+			timer.f()
+			c.mu.Lock()
+		case ticker != nil:
+			c.now = when
+			ticker.next = ticker.next.Add(ticker.period)
+			c.mu.Unlock()
+			select {
+			case ticker.ch <- when:
+			case <-time.After(5 * time.Second):
+				t.Error("tick not consumed")
+				return
+			}
+			c.mu.Lock()
+		default:
+			c.now = target
+			c.mu.Unlock()
+			return
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Laisky/errors/v2"
 	"github.com/stretchr/testify/require"
 )
 
@@ -128,3 +129,50 @@ func TestNewGoroutineTest(t *testing.T) {
 	})
 	require.True(t, ok)
 }
+
+func TestFakeCMDRunner(t *testing.T) {
+	ctx := context.Background()
+	runner := NewFakeCMDRunner()
+	var _ CMDRunner = runner
+
+	require.Error(t, runner.On(`(`, FakeCMDResult{}))
+	require.NoError(t, runner.On(`^git status`, FakeCMDResult{Stdout: []byte("clean\n")}))
+	require.NoError(t, runner.On(`^git`, FakeCMDResult{
+		Stdout:   []byte("out1\nout2\n"),
+		Stderr:   []byte("fatal: bad\n"),
+		ExitCode: 128,
+	}))
+	require.NoError(t, runner.On(`^missing`, FakeCMDResult{Err: errors.New("not found")}))
+
+	out, err := runner.Run(ctx, "git", "status", "-s")
+	require.NoError(t, err)
+	require.Equal(t, "clean\n", string(out))
+
+	out, err = runner.RunWithEnv(ctx, "git", []string{"push"}, []string{"A=1"}, WithCMDStdin([]byte("in")))
+	require.ErrorContains(t, err, "exit status 128")
+	require.Equal(t, "out1\nout2\nfatal: bad\n", string(out))
+
+	var stdout, stderr []string
+	err = runner.Run2(ctx, "git", []string{"pull"}, nil,
+		func(s string) { stdout = append(stdout, s) },
+		func(s string) { stderr = append(stderr, s) })
+	require.ErrorContains(t, err, "exit status 128")
+	require.Equal(t, []string{"out1", "out2"}, stdout)
+	require.Equal(t, []string{"fatal: bad"}, stderr)
+
+	_, err = runner.Run(ctx, "missing")
+	require.ErrorContains(t, err, "not found")
+
+	_, err = runner.Run(ctx, "ls")
+	require.ErrorContains(t, err, "no fake result matches")
+
+	calls := runner.Calls()
+	require.Len(t, calls, 5)
+	require.Equal(t, FakeCMDCall{
+		App:   "git",
+		Args:  []string{"push"},
+		Envs:  []string{"A=1"},
+		Stdin: []byte("in"),
+	}, calls[1])
+	require.Equal(t, "ls", calls[4].App)
+}
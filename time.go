@@ -2,12 +2,16 @@ package utils
 
 import (
 	"context"
+	"math/rand"
 	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/Laisky/errors/v2"
+	"github.com/Laisky/zap"
+
+	"github.com/Laisky/go-utils/v4/log"
 )
 
 const (
@@ -23,9 +27,42 @@ const (
 
 // SleepWithContext sleep duration with context, if context is done, return
 func SleepWithContext(ctx context.Context, duration time.Duration) {
-	ctx, cancel := context.WithTimeout(ctx, duration)
-	defer cancel()
-	<-ctx.Done()
+	_ = SleepWithContext2(ctx, duration)
+}
+
+// SleepWithContext2 sleep duration with context,
+// return ctx.Err() if context is done before duration elapsed.
+//
+// return nil immediately if duration <= 0.
+func SleepWithContext2(ctx context.Context, duration time.Duration) error {
+	if duration <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// JitteredDuration return a random duration in [base*(1-fraction), base*(1+fraction)],
+// used to spread periodic work.
+//
+// fraction is clamped to [0, 1], return base as is if base <= 0.
+func JitteredDuration(base time.Duration, fraction float64) time.Duration {
+	if base <= 0 || !(fraction > 0) {
+		return base
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+
+	return base + time.Duration(float64(base)*fraction*(2*rand.Float64()-1))
 }
 
 // UTCNow get current time in utc
@@ -235,3 +272,270 @@ func init() {
 	TimeZoneShanghai, err = time.LoadLocation("Asia/Shanghai")
 	PanicIfErr(err)
 }
+
+// ---------------------------------------
+// Schedule
+// ---------------------------------------
+
+// SchedTimer timer that can be stopped
+type SchedTimer interface {
+	Stop() bool
+}
+
+// SchedTicker ticker that can be stopped
+type SchedTicker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// SchedClock clock used by Debounce, ThrottleFunc, Every and others,
+// can be replaced by FakeSchedClock in tests
+type SchedClock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) SchedTimer
+	NewTicker(d time.Duration) SchedTicker
+}
+
+type realSchedTicker struct {
+	*time.Ticker
+}
+
+func (t realSchedTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+type realSchedClock struct{}
+
+func (realSchedClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realSchedClock) AfterFunc(d time.Duration, f func()) SchedTimer {
+	return time.AfterFunc(d, f)
+}
+
+func (realSchedClock) NewTicker(d time.Duration) SchedTicker {
+	return realSchedTicker{time.NewTicker(d)}
+}
+
+// SystemSchedClock return SchedClock backed by the system time
+func SystemSchedClock() SchedClock {
+	return realSchedClock{}
+}
+
+type debounceOption struct {
+	clock SchedClock
+}
+
+// DebounceOption option for Debounce
+type DebounceOption func(*debounceOption)
+
+// WithDebounceClock replace the clock of Debounce, default is the system clock
+func WithDebounceClock(clock SchedClock) DebounceOption {
+	return func(o *debounceOption) {
+		o.clock = clock
+	}
+}
+
+// Debounce collapse rapid calls into one invocation of f,
+// f will be invoked in another goroutine d after the last call.
+//
+// stop cancel the pending invocation, calls after stop are ignored.
+func Debounce(d time.Duration, f func(), opts ...DebounceOption) (call func(), stop func()) {
+	opt := &debounceOption{clock: realSchedClock{}}
+	for _, optf := range opts {
+		optf(opt)
+	}
+
+	var (
+		mu      sync.Mutex
+		timer   SchedTimer
+		stopped bool
+	)
+
+	call = func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if stopped {
+			return
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+
+		timer = opt.clock.AfterFunc(d, f)
+	}
+
+	stop = func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		stopped = true
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+
+	return call, stop
+}
+
+type throttleFuncOption struct {
+	trailing bool
+	clock    SchedClock
+}
+
+// ThrottleFuncOption option for ThrottleFunc
+type ThrottleFuncOption func(*throttleFuncOption)
+
+// WithThrottleFuncTrailing invoke f once more at the end of period
+// if there are calls dropped during the period
+func WithThrottleFuncTrailing() ThrottleFuncOption {
+	return func(o *throttleFuncOption) {
+		o.trailing = true
+	}
+}
+
+// WithThrottleFuncClock replace the clock of ThrottleFunc, default is the system clock
+func WithThrottleFuncClock(clock SchedClock) ThrottleFuncOption {
+	return func(o *throttleFuncOption) {
+		o.clock = clock
+	}
+}
+
+// ThrottleFunc invoke f at most once per d.
+//
+// f is invoked synchronously on the leading edge,
+// calls during the period are dropped, unless WithThrottleFuncTrailing is set.
+func ThrottleFunc(d time.Duration, f func(), opts ...ThrottleFuncOption) func() {
+	opt := &throttleFuncOption{clock: realSchedClock{}}
+	for _, optf := range opts {
+		optf(opt)
+	}
+	clock := opt.clock
+
+	var (
+		mu      sync.Mutex
+		last    time.Time
+		pending bool
+	)
+
+	return func() {
+		mu.Lock()
+		now := clock.Now()
+		if last.IsZero() || now.Sub(last) >= d {
+			last = now
+			mu.Unlock()
+			f()
+			return
+		}
+
+		if opt.trailing && !pending {
+			pending = true
+			clock.AfterFunc(last.Add(d).Sub(now), func() {
+				mu.Lock()
+				pending = false
+				last = clock.Now()
+				mu.Unlock()
+				f()
+			})
+		}
+		mu.Unlock()
+	}
+}
+
+type everyOption struct {
+	logger        interface{ Error(string, ...zap.Field) }
+	skipIfRunning bool
+	clock         SchedClock
+}
+
+// EveryOption option for Every
+type EveryOption func(*everyOption) error
+
+// WithEveryLogger set logger to log errors returned by f,
+// default is log.Shared
+func WithEveryLogger(logger interface{ Error(string, ...zap.Field) }) EveryOption {
+	return func(o *everyOption) error {
+		if logger == nil {
+			return errors.New("logger should not be nil")
+		}
+
+		o.logger = logger
+		return nil
+	}
+}
+
+// WithEverySkipIfRunning skip the tick if the previous run is still executing
+func WithEverySkipIfRunning() EveryOption {
+	return func(o *everyOption) error {
+		o.skipIfRunning = true
+		return nil
+	}
+}
+
+// WithEveryClock replace the clock of Every, default is the system clock
+func WithEveryClock(clock SchedClock) EveryOption {
+	return func(o *everyOption) error {
+		if clock == nil {
+			return errors.New("clock should not be nil")
+		}
+
+		o.clock = clock
+		return nil
+	}
+}
+
+// Every run f in background on every tick of d, until ctx is done.
+//
+// each run is started in a new goroutine, so runs may overlap
+// if f takes longer than d, unless WithEverySkipIfRunning is set.
+// errors returned by f are logged.
+func Every(ctx context.Context, d time.Duration,
+	f func(ctx context.Context) error, opts ...EveryOption) error {
+	if d <= 0 {
+		return errors.Errorf("interval should greater than 0, got %s", d)
+	}
+
+	opt := &everyOption{
+		logger: log.Shared,
+		clock:  realSchedClock{},
+	}
+	for _, optf := range opts {
+		if err := optf(opt); err != nil {
+			return errors.Wrap(err, "apply option")
+		}
+	}
+
+	ticker := opt.clock.NewTicker(d)
+	go func() {
+		defer ticker.Stop()
+
+		var running atomic.Bool
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+			}
+
+			if opt.skipIfRunning {
+				if !running.CompareAndSwap(false, true) {
+					continue
+				}
+			}
+
+			go func() {
+				if opt.skipIfRunning {
+					defer running.Store(false)
+				}
+
+				if err := f(ctx); err != nil {
+					opt.logger.Error("run every", zap.Error(err))
+				}
+			}()
+		}
+	}()
+
+	return nil
+}
@@ -2,10 +2,14 @@ package utils
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/Laisky/errors/v2"
+	"github.com/Laisky/zap"
 	"github.com/stretchr/testify/require"
 
 	"github.com/Laisky/go-utils/v4/log"
@@ -397,6 +401,71 @@ func TestSleepWithContext(t *testing.T) {
 	})
 }
 
+func TestSleepWithContext2(t *testing.T) {
+	t.Run("normal sleep", func(t *testing.T) {
+		startAt := time.Now()
+		require.NoError(t, SleepWithContext2(context.Background(), time.Millisecond*10))
+		require.Greater(t, time.Since(startAt), 10*time.Millisecond)
+	})
+
+	t.Run("sleep break by context", func(t *testing.T) {
+		startAt := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		defer cancel()
+		err := SleepWithContext2(ctx, time.Hour)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Less(t, time.Since(startAt), time.Second*2)
+		require.Greater(t, time.Since(startAt), time.Millisecond*10)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+		}()
+
+		startAt := time.Now()
+		require.ErrorIs(t, SleepWithContext2(ctx, time.Second), context.Canceled)
+		require.Less(t, time.Since(startAt), 500*time.Millisecond)
+	})
+
+	t.Run("non-positive duration", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		startAt := time.Now()
+		require.NoError(t, SleepWithContext2(ctx, 0))
+		require.NoError(t, SleepWithContext2(context.Background(), -time.Hour))
+		require.Less(t, time.Since(startAt), 10*time.Millisecond)
+	})
+}
+
+func TestJitteredDuration(t *testing.T) {
+	t.Parallel()
+
+	base := time.Second
+	var below, above bool
+	for i := 0; i < 10000; i++ {
+		d := JitteredDuration(base, 0.2)
+		require.GreaterOrEqual(t, d, 800*time.Millisecond)
+		require.LessOrEqual(t, d, 1200*time.Millisecond)
+		below = below || d < 900*time.Millisecond
+		above = above || d > 1100*time.Millisecond
+	}
+	require.True(t, below && above, "jitter should spread over the whole range")
+
+	require.Equal(t, base, JitteredDuration(base, 0))
+	require.Equal(t, base, JitteredDuration(base, -1))
+	require.Equal(t, time.Duration(0), JitteredDuration(0, 0.5))
+	require.Equal(t, -time.Second, JitteredDuration(-time.Second, 0.5))
+	for i := 0; i < 1000; i++ {
+		d := JitteredDuration(base, 5)
+		require.GreaterOrEqual(t, d, time.Duration(0))
+		require.LessOrEqual(t, d, 2*base)
+	}
+}
+
 func TestTimeEqual(t *testing.T) {
 	t.Parallel()
 
@@ -443,3 +512,159 @@ func TestTimeEqual(t *testing.T) {
 		require.True(t, TimeEqual(t1, t2, time.Second))
 	})
 }
+
+func TestDebounce(t *testing.T) {
+	t.Parallel()
+
+	clock := NewFakeSchedClock()
+	var cnt int
+	call, stop := Debounce(100*time.Millisecond, func() { cnt++ }, WithDebounceClock(clock))
+
+	for round := 1; round <= 3; round++ {
+		for i := 0; i < 10; i++ {
+			call()
+			clock.Advance(t, 10*time.Millisecond)
+		}
+		require.Equal(t, round-1, cnt)
+
+		clock.Advance(t, 100*time.Millisecond)
+		require.Equal(t, round, cnt)
+	}
+
+	call()
+	stop()
+	call()
+	clock.Advance(t, time.Second)
+	require.Equal(t, 3, cnt)
+}
+
+func TestThrottleFunc(t *testing.T) {
+	t.Parallel()
+
+	t.Run("leading", func(t *testing.T) {
+		clock := NewFakeSchedClock()
+		var cnt int
+		call := ThrottleFunc(100*time.Millisecond, func() { cnt++ },
+			WithThrottleFuncClock(clock))
+
+		// 50 calls in 500ms
+		for i := 0; i < 50; i++ {
+			call()
+			clock.Advance(t, 10*time.Millisecond)
+		}
+		require.Equal(t, 5, cnt)
+
+		clock.Advance(t, time.Second)
+		require.Equal(t, 5, cnt)
+	})
+
+	t.Run("trailing", func(t *testing.T) {
+		clock := NewFakeSchedClock()
+		var cnt int
+		call := ThrottleFunc(100*time.Millisecond, func() { cnt++ },
+			WithThrottleFuncClock(clock), WithThrottleFuncTrailing())
+
+		call()
+		require.Equal(t, 1, cnt)
+		clock.Advance(t, 10*time.Millisecond)
+		call()
+		call()
+		require.Equal(t, 1, cnt)
+
+		clock.Advance(t, 90*time.Millisecond)
+		require.Equal(t, 2, cnt)
+
+		// trailing call also starts a new period
+		clock.Advance(t, 50*time.Millisecond)
+		call()
+		require.Equal(t, 2, cnt)
+		clock.Advance(t, 50*time.Millisecond)
+		require.Equal(t, 3, cnt)
+
+		clock.Advance(t, time.Second)
+		require.Equal(t, 3, cnt)
+	})
+}
+
+type everyTestLogger struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *everyTestLogger) Error(msg string, fields ...zap.Field) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.msgs = append(l.msgs, msg)
+}
+
+func (l *everyTestLogger) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.msgs)
+}
+
+func TestEvery(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid", func(t *testing.T) {
+		err := Every(context.Background(), 0, func(ctx context.Context) error { return nil })
+		require.Error(t, err)
+		err = Every(context.Background(), time.Second,
+			func(ctx context.Context) error { return nil }, WithEveryLogger(nil))
+		require.Error(t, err)
+	})
+
+	for _, skip := range []bool{false, true} {
+		skip := skip
+		t.Run(fmt.Sprintf("skip if running %v", skip), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var (
+				started atomic.Int64
+				release = make(chan struct{})
+				logger  = new(everyTestLogger)
+				clock   = NewFakeSchedClock()
+			)
+			opts := []EveryOption{WithEveryClock(clock), WithEveryLogger(logger)}
+			if skip {
+				opts = append(opts, WithEverySkipIfRunning())
+			}
+
+			err := Every(ctx, time.Second, func(ctx context.Context) error {
+				started.Add(1)
+				<-release
+				return errors.New("yo")
+			}, opts...)
+			require.NoError(t, err)
+
+			clock.Advance(t, 3*time.Second)
+			expect := int64(3)
+			if skip {
+				expect = 1
+			}
+			require.Eventually(t, func() bool { return started.Load() == expect },
+				time.Second, time.Millisecond)
+
+			close(release)
+			require.Eventually(t, func() bool { return logger.len() == int(expect) },
+				time.Second, time.Millisecond)
+
+			// previous run finished, next tick should run
+			require.Eventually(t, func() bool {
+				clock.Advance(t, time.Second)
+				return started.Load() > expect
+			}, time.Second, time.Millisecond)
+
+			// ticker is stopped after ctx done
+			cancel()
+			require.Eventually(t, func() bool {
+				clock.mu.Lock()
+				defer clock.mu.Unlock()
+				return clock.tickers[0].stopped
+			}, time.Second, time.Millisecond)
+		})
+	}
+}
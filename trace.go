@@ -0,0 +1,251 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Laisky/errors/v2"
+)
+
+const (
+	// TraceparentEnv env name of W3C traceparent
+	TraceparentEnv = "TRACEPARENT"
+	// TracestateEnv env name of W3C tracestate
+	TracestateEnv = "TRACESTATE"
+	// TraceparentHeader http header of W3C traceparent
+	TraceparentHeader = "traceparent"
+	// TracestateHeader http header of W3C tracestate
+	TracestateHeader = "tracestate"
+
+	// traceparentLen length of traceparent of version 00
+	traceparentLen = 55
+)
+
+type traceCtxKey struct{}
+
+// TraceContext W3C trace context, see https://www.w3.org/TR/trace-context/
+type TraceContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	// Flags trace flags, 0x01 means sampled
+	Flags byte
+	// State raw tracestate, optional
+	State string
+}
+
+// NewTraceContext new sampled trace context with random trace id and span id
+func NewTraceContext() (TraceContext, error) {
+	var tc TraceContext
+	if err := randNonZero(tc.TraceID[:]); err != nil {
+		return tc, errors.Wrap(err, "generate trace id")
+	}
+	if err := randNonZero(tc.SpanID[:]); err != nil {
+		return tc, errors.Wrap(err, "generate span id")
+	}
+
+	tc.Flags = 0x01
+	return tc, nil
+}
+
+// NewSpan return a child trace context with the same trace id and a new span id
+func (tc TraceContext) NewSpan() (TraceContext, error) {
+	if err := randNonZero(tc.SpanID[:]); err != nil {
+		return tc, errors.Wrap(err, "generate span id")
+	}
+
+	return tc, nil
+}
+
+// randNonZero fill b by crypto/rand, all-zero is invalid in W3C trace context
+func randNonZero(b []byte) error {
+	for {
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+
+		if !isAllZero(b) {
+			return nil
+		}
+	}
+}
+
+func isAllZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsValid trace id and span id should not be all zero
+func (tc TraceContext) IsValid() bool {
+	return !isAllZero(tc.TraceID[:]) && !isAllZero(tc.SpanID[:])
+}
+
+// Traceparent format to traceparent like `00-<trace id>-<span id>-<flags>`
+func (tc TraceContext) Traceparent() string {
+	return "00-" + hex.EncodeToString(tc.TraceID[:]) +
+		"-" + hex.EncodeToString(tc.SpanID[:]) +
+		"-" + hex.EncodeToString([]byte{tc.Flags})
+}
+
+// ParseTraceparent parse traceparent like `00-<trace id>-<span id>-<flags>`,
+// State of the returned TraceContext is empty.
+//
+// versions greater than 00 are accepted as the spec requires,
+// and only the fields defined by version 00 are parsed.
+func ParseTraceparent(traceparent string) (tc TraceContext, err error) {
+	if len(traceparent) < traceparentLen {
+		return tc, errors.Errorf("traceparent should be at least %d chars", traceparentLen)
+	}
+
+	version := traceparent[:2]
+	switch {
+	case !isLowerHex(version):
+		return tc, errors.Errorf("invalid version %q", version)
+	case version == "ff":
+		return tc, errors.New("version ff is forbidden")
+	case version == "00" && len(traceparent) != traceparentLen:
+		return tc, errors.Errorf("traceparent of version 00 should be %d chars", traceparentLen)
+	case len(traceparent) > traceparentLen && traceparent[traceparentLen] != '-':
+		return tc, errors.New("invalid traceparent")
+	}
+
+	parts := strings.Split(traceparent[:traceparentLen], "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return tc, errors.New("invalid traceparent")
+	}
+
+	for i, dst := range [][]byte{tc.TraceID[:], tc.SpanID[:], {0}} {
+		if !isLowerHex(parts[i+1]) {
+			return tc, errors.Errorf("invalid hex %q", parts[i+1])
+		}
+
+		if _, err = hex.Decode(dst, []byte(parts[i+1])); err != nil {
+			return tc, errors.Wrapf(err, "decode %q", parts[i+1])
+		}
+
+		if i == 2 {
+			tc.Flags = dst[0]
+		}
+	}
+
+	if !tc.IsValid() {
+		return tc, errors.New("trace id and span id should not be all zero")
+	}
+
+	return tc, nil
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ToCtx attach trace context to ctx
+func (tc TraceContext) ToCtx(ctx context.Context) context.Context {
+	return context.WithValue(ctx, traceCtxKey{}, tc)
+}
+
+// TraceContextFromCtx get trace context attached by TraceContext.ToCtx
+func TraceContextFromCtx(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceCtxKey{}).(TraceContext)
+	return tc, ok
+}
+
+// InjectTraceEnv set TRACEPARENT/TRACESTATE in envs by trace context in ctx,
+// existing keys are replaced, TRACESTATE is removed if trace context has no state.
+// return envs as is if there is no trace context.
+//
+// like BuildEnv, envs is os.Environ() if nil, so the result can be
+// passed to RunCMDWithEnv without losing current process's environments.
+func InjectTraceEnv(ctx context.Context, envs []string) []string {
+	tc, ok := TraceContextFromCtx(ctx)
+	if !ok {
+		return envs
+	}
+
+	overrides := map[string]string{TraceparentEnv: tc.Traceparent()}
+	if tc.State != "" {
+		overrides[TracestateEnv] = tc.State
+	}
+
+	injected, err := BuildEnv(envs, overrides)
+	if err != nil { // keys are valid, should not happen
+		return envs
+	}
+
+	if tc.State == "" {
+		// drop stale tracestate, like InjectTraceHeader
+		injected = slices.DeleteFunc(injected, func(env string) bool {
+			return strings.HasPrefix(env, TracestateEnv+"=")
+		})
+	}
+
+	return injected
+}
+
+// ExtractTraceEnv parse trace context from TRACEPARENT/TRACESTATE in envs
+func ExtractTraceEnv(envs []string) (TraceContext, bool) {
+	var traceparent, tracestate string
+	for _, env := range envs {
+		k, v, _ := strings.Cut(env, "=")
+		switch k {
+		case TraceparentEnv:
+			traceparent = v
+		case TracestateEnv:
+			tracestate = v
+		}
+	}
+
+	tc, err := ParseTraceparent(traceparent)
+	if err != nil {
+		return tc, false
+	}
+
+	tc.State = tracestate
+	return tc, true
+}
+
+// InjectTraceHeader set traceparent/tracestate in header by trace context in ctx
+func InjectTraceHeader(ctx context.Context, header http.Header) {
+	tc, ok := TraceContextFromCtx(ctx)
+	if !ok {
+		return
+	}
+
+	header.Set(TraceparentHeader, tc.Traceparent())
+	if tc.State != "" {
+		header.Set(TracestateHeader, tc.State)
+	} else {
+		header.Del(TracestateHeader)
+	}
+}
+
+// ExtractTraceHeader parse trace context from traceparent/tracestate in header,
+// multiple tracestate headers are combined by `,`.
+func ExtractTraceHeader(header http.Header) (TraceContext, bool) {
+	parents := header.Values(TraceparentHeader)
+	if len(parents) != 1 {
+		return TraceContext{}, false
+	}
+
+	tc, err := ParseTraceparent(parents[0])
+	if err != nil {
+		return tc, false
+	}
+
+	tc.State = strings.Join(header.Values(TracestateHeader), ",")
+	return tc, true
+}
@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceContext(t *testing.T) {
+	t.Parallel()
+
+	tc, err := NewTraceContext()
+	require.NoError(t, err)
+	require.True(t, tc.IsValid())
+	require.EqualValues(t, 0x01, tc.Flags)
+
+	traceparent := tc.Traceparent()
+	require.Len(t, traceparent, 55)
+	require.Regexp(t, `^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`, traceparent)
+
+	got, err := ParseTraceparent(traceparent)
+	require.NoError(t, err)
+	require.Equal(t, tc, got)
+
+	child, err := tc.NewSpan()
+	require.NoError(t, err)
+	require.Equal(t, tc.TraceID, child.TraceID)
+	require.NotEqual(t, tc.SpanID, child.SpanID)
+
+	t.Run("spec example", func(t *testing.T) {
+		tc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		require.NoError(t, err)
+		require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", EncodeByHex(tc.TraceID[:]))
+		require.Equal(t, "00f067aa0ba902b7", EncodeByHex(tc.SpanID[:]))
+		require.EqualValues(t, 1, tc.Flags)
+
+		// future version with extra fields
+		_, err = ParseTraceparent("cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-what-the-future-will-be-like")
+		require.NoError(t, err)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		for _, traceparent := range []string{
+			"",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-",
+			"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			"0g-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+			"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0x",
+			"00_4bf92f3577b34da6a3ce929d0e0e4736_00f067aa0ba902b7_01",
+			"00-4bf92f3577b34da6a3ce929d0e0e473-600f067aa0ba902b7-01",
+			"cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.",
+		} {
+			_, err := ParseTraceparent(traceparent)
+			require.Error(t, err, traceparent)
+		}
+	})
+}
+
+func TestTraceContextPropagation(t *testing.T) {
+	t.Parallel()
+
+	tc, err := NewTraceContext()
+	require.NoError(t, err)
+	tc.State = "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7"
+	ctx := tc.ToCtx(context.Background())
+
+	got, ok := TraceContextFromCtx(ctx)
+	require.True(t, ok)
+	require.Equal(t, tc, got)
+
+	t.Run("env", func(t *testing.T) {
+		envs := InjectTraceEnv(ctx, []string{"FOO=BAR", "TRACEPARENT=stale"})
+		require.Equal(t, []string{
+			"FOO=BAR",
+			"TRACEPARENT=" + tc.Traceparent(),
+			"TRACESTATE=" + tc.State,
+		}, envs)
+
+		got, ok := ExtractTraceEnv(envs)
+		require.True(t, ok)
+		require.Equal(t, tc, got)
+
+		// stale tracestate is removed if trace context has no state
+		noState := tc
+		noState.State = ""
+		stale := []string{"FOO=BAR", "TRACESTATE=stale=1"}
+		envs = InjectTraceEnv(noState.ToCtx(context.Background()), stale)
+		require.Equal(t, []string{
+			"FOO=BAR",
+			"TRACEPARENT=" + tc.Traceparent(),
+		}, envs)
+		require.Equal(t, []string{"FOO=BAR", "TRACESTATE=stale=1"}, stale)
+
+		// inherit current process's environments
+		envs = InjectTraceEnv(ctx, nil)
+		require.Greater(t, len(envs), 2)
+
+		envApp, err := exec.LookPath("env")
+		if err != nil {
+			t.Skip("env not found")
+		}
+		stdout, err := RunCMDWithEnv(ctx, envApp, nil, envs)
+		require.NoError(t, err)
+		got, ok = ExtractTraceEnv(strings.Split(string(stdout), "\n"))
+		require.True(t, ok)
+		require.Equal(t, tc, got)
+	})
+
+	t.Run("header", func(t *testing.T) {
+		header := http.Header{}
+		header.Set(TracestateHeader, "stale=1")
+		InjectTraceHeader(ctx, header)
+		require.Equal(t, tc.Traceparent(), header.Get("Traceparent"))
+
+		got, ok := ExtractTraceHeader(header)
+		require.True(t, ok)
+		require.Equal(t, tc, got)
+
+		header.Add(TracestateHeader, "foo=bar")
+		got, ok = ExtractTraceHeader(header)
+		require.True(t, ok)
+		require.Equal(t, tc.State+",foo=bar", got.State)
+
+		header.Add(TraceparentHeader, tc.Traceparent())
+		_, ok = ExtractTraceHeader(header)
+		require.False(t, ok, "multiple traceparent")
+	})
+
+	t.Run("no trace context", func(t *testing.T) {
+		ctx := context.Background()
+		_, ok := TraceContextFromCtx(ctx)
+		require.False(t, ok)
+
+		envs := []string{"FOO=BAR"}
+		require.Equal(t, envs, InjectTraceEnv(ctx, envs))
+		_, ok = ExtractTraceEnv(envs)
+		require.False(t, ok)
+
+		header := http.Header{}
+		InjectTraceHeader(ctx, header)
+		require.Empty(t, header)
+		_, ok = ExtractTraceHeader(header)
+		require.False(t, ok)
+
+		_, ok = ExtractTraceHeader(http.Header{"Traceparent": {"00-yo"}})
+		require.False(t, ok)
+	})
+}
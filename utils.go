@@ -252,8 +252,13 @@ func HasMethod(st any, methodName string) bool {
 }
 
 // MD5JSON calculate md5(jsonify(data))
+//
+// the output of json.Marshal is not guaranteed to be deterministic
+// across languages, use HashJSONCanonical for signing or verification.
 func MD5JSON(data any) (string, error) {
-	if NilInterface(data) {
+	// only nil pointer is rejected, nil slice and map are marshaled to `null`
+	if data == nil || (reflect.TypeOf(data).Kind() == reflect.Pointer &&
+		reflect.ValueOf(data).IsNil()) {
 		return "", errors.New("data is nil")
 	}
 
@@ -265,7 +270,10 @@ func MD5JSON(data any) (string, error) {
 	return fmt.Sprintf("%x", md5.Sum(b)), nil
 }
 
-// NilInterface make sure data is nil interface or another type with nil value
+// NilInterface make sure data is nil interface or another type with nil value,
+// include nil pointer, slice, map, channel, func and unsafe pointer.
+//
+// empty but non-nil slices and maps are not nil.
 //
 // Example:
 //
@@ -275,17 +283,21 @@ func MD5JSON(data any) (string, error) {
 //	v = f
 //	v == nil // false
 //	NilInterface(v) // true
+//
+//	var s []int
+//	NilInterface(s) // true
 func NilInterface(data any) bool {
 	if data == nil {
 		return true
 	}
 
-	if reflect.TypeOf(data).Kind() == reflect.Ptr &&
-		reflect.ValueOf(data).IsNil() {
-		return true
+	switch v := reflect.ValueOf(data); v.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map,
+		reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return v.IsNil()
+	default:
+		return false
 	}
-
-	return false
 }
 
 // GetStructFieldByName get struct field by name
@@ -363,6 +375,150 @@ func GetFuncName(f any) string {
 	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
 }
 
+// GetFuncNameShort return the name of func without package path,
+// return empty string if f is not a func.
+//
+//   - package function: `testFoo`
+//   - method: `(*Type).Method` or `Type.Method`
+//   - closure: `testFoo.func1`
+func GetFuncNameShort(f any) string {
+	v := reflect.ValueOf(f)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return ""
+	}
+
+	fn := runtime.FuncForPC(v.Pointer())
+	if fn == nil {
+		return ""
+	}
+
+	return shortFuncName(fn.Name())
+}
+
+// trimFuncPkgPath trim package path to its last element,
+// like `github.com/Laisky/go-utils/v4/log.New` to `log.New`
+func trimFuncPkgPath(name string) string {
+	if idx := strings.LastIndexByte(name, '/'); idx >= 0 {
+		name = name[idx+1:]
+	}
+
+	return name
+}
+
+// shortFuncName remove package from func name in runtime
+func shortFuncName(name string) string {
+	// dots in the last element of package path are escaped to `%2e` by runtime,
+	// so the first dot after the last slash is the end of package name
+	name = trimFuncPkgPath(name)
+	if idx := strings.IndexByte(name, '.'); idx >= 0 {
+		name = name[idx+1:]
+	}
+
+	// bound method value
+	return strings.TrimSuffix(name, "-fm")
+}
+
+// trimFilePath keep the last directory and file name,
+// like `/root/go-utils/log/logger.go` to `log/logger.go`
+func trimFilePath(file string) string {
+	idx := strings.LastIndexByte(file, '/')
+	if idx <= 0 {
+		return file
+	}
+	if idx = strings.LastIndexByte(file[:idx], '/'); idx < 0 {
+		return file
+	}
+
+	return file[idx+1:]
+}
+
+// CallerName return the func name of the caller,
+// package path is trimmed to its last element, like `log.New`.
+//
+// skip 0 means the caller of CallerName.
+func CallerName(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return ""
+	}
+
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return ""
+	}
+
+	return trimFuncPkgPath(fn.Name())
+}
+
+// CallerFileLine return the file and line of the caller,
+// file is trimmed to the last directory and file name, like `log/logger.go`.
+//
+// skip 0 means the caller of CallerFileLine.
+func CallerFileLine(skip int) (file string, line int) {
+	_, file, line, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return "", 0
+	}
+
+	return trimFilePath(file), line
+}
+
+// Caller return the file, line and func name of the caller,
+// trimmed as CallerFileLine and CallerName do.
+//
+// skip 0 means the caller of Caller.
+func Caller(skip int) (file string, line int, funcName string) {
+	pc, file, line, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return "", 0, ""
+	}
+
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		funcName = trimFuncPkgPath(fn.Name())
+	}
+
+	return trimFilePath(file), line, funcName
+}
+
+// CallerString return the caller like `log/logger.go:12 log.New`,
+// same as the frame of StackTrace, return empty string if not found.
+//
+// skip 0 means the caller of CallerString.
+func CallerString(skip int) string {
+	file, line, funcName := Caller(skip + 1)
+	if file == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%s:%d %s", file, line, funcName)
+}
+
+// StackTrace return at most maxFrames frames of the caller's stack,
+// each frame is like `log/logger.go:12 log.New`.
+//
+// it is lighter than debug.Stack, without goroutine header and arguments.
+func StackTrace(maxFrames int) []string {
+	if maxFrames <= 0 {
+		return nil
+	}
+
+	pcs := make([]uintptr, maxFrames)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	stack := make([]string, 0, n)
+	for {
+		frame, more := frames.Next()
+		stack = append(stack, fmt.Sprintf("%s:%d %s",
+			trimFilePath(frame.File), frame.Line, trimFuncPkgPath(frame.Function)))
+		if !more || len(stack) >= maxFrames {
+			break
+		}
+	}
+
+	return stack
+}
+
 // FallBack return the fallback when orig got error
 // utils.FallBack(func() any { return getIOStatMetric(fs) }, &IOStat{}).(*IOStat)
 func FallBack(orig func() any, fallback any) (ret any) {
@@ -376,6 +532,22 @@ func FallBack(orig func() any, fallback any) (ret any) {
 	return
 }
 
+// FallBack2 return the fallback with error when orig panics or returns error,
+// the recovered panic is returned as *PanicError, as IsPanic2 does.
+func FallBack2(orig func() (any, error), fallback any) (ret any, err error) {
+	defer func() {
+		if panicRet := recover(); panicRet != nil {
+			ret, err = fallback, newPanicError(panicRet)
+		}
+	}()
+
+	if ret, err = orig(); err != nil {
+		return fallback, err
+	}
+
+	return ret, nil
+}
+
 // RegexNamedSubMatch extract key:val map from string by group match
 //
 // Deprecated: use RegexNamedSubMatch2 instead
@@ -523,17 +695,13 @@ func AutoGC(ctx context.Context, opts ...GcOptFunc) (err error) {
 	log.Shared.Info("enable auto gc", zap.Uint64("ratio", opt.memRatio), zap.Uint64("limit", memLimit))
 
 	go func(ctx context.Context) {
-		ticker := time.NewTicker(1 * time.Second)
-		defer ticker.Stop()
-
 		var (
 			m     runtime.MemStats
 			ratio uint64
 		)
 		for {
-			select {
-			case <-ticker.C:
-			case <-ctx.Done():
+			// jitter to avoid multiple processes checking memory in lockstep
+			if SleepWithContext2(ctx, JitteredDuration(time.Second, 0.1)) != nil {
 				return
 			}
 			runtime.ReadMemStats(&m)
@@ -654,6 +822,8 @@ func SetStructFieldsBySlice(structs, vals any) (err error) {
 }
 
 // UniqueStrings remove duplicate string in slice
+//
+// vs is modified inplace, use UniqueStringsCopy if you still need the original slice.
 func UniqueStrings(vs []string) []string {
 	seen := make(map[string]struct{})
 	j := 0
@@ -669,6 +839,23 @@ func UniqueStrings(vs []string) []string {
 	return vs[:j:j]
 }
 
+// UniqueStringsCopy return a new slice without duplicate string,
+// vs is left untouched.
+//
+// it is the allocating alternative of UniqueStrings.
+func UniqueStringsCopy(vs []string) []string {
+	seen := make(map[string]struct{}, len(vs))
+	r := make([]string, 0, len(vs))
+	for _, v := range vs {
+		if _, ok := seen[v]; !ok {
+			seen[v] = struct{}{}
+			r = append(r, v)
+		}
+	}
+
+	return r
+}
+
 // RemoveEmpty remove duplicate string in slice
 func RemoveEmpty(vs []string) (r []string) {
 	for _, v := range vs {
@@ -697,6 +884,16 @@ func Contains[V comparable](collection []V, ele V) bool {
 	return slices.Contains(collection, ele)
 }
 
+// ContainsBy if any element in collection satisfies pred
+func ContainsBy[V any](collection []V, pred func(V) bool) bool {
+	return slices.ContainsFunc(collection, pred)
+}
+
+// IndexOf return the index of the first ele in collection, -1 if absent
+func IndexOf[V comparable](collection []V, ele V) int {
+	return slices.Index(collection, ele)
+}
+
 // IsPtr check if t is pointer
 func IsPtr(t any) bool {
 	return reflect.TypeOf(t).Kind() == reflect.Ptr
@@ -729,16 +926,163 @@ func RunCMD(ctx context.Context, app string, args ...string) (stdout []byte, err
 	return RunCMDWithEnv(ctx, app, args, nil)
 }
 
+var reEnvKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// BuildEnv build environments like `[]string{"FOO=BAR"}` from base and overrides.
+//
+// base is os.Environ() if nil. keys in overrides replace the existing keys in base
+// case-sensitively instead of appending duplicates, new keys are appended in sorted order.
+// keys of overrides should match `[A-Za-z_][A-Za-z0-9_]*`.
+func BuildEnv(base []string, overrides map[string]string) ([]string, error) {
+	var invalid []string
+	for k := range overrides {
+		if !reEnvKey.MatchString(k) {
+			invalid = append(invalid, k)
+		}
+	}
+	if len(invalid) != 0 {
+		slices.Sort(invalid)
+		return nil, errors.Errorf("invalid env keys %q", invalid)
+	}
+
+	if base == nil {
+		base = os.Environ()
+	}
+
+	envs := make([]string, 0, len(base)+len(overrides))
+	replaced := make(map[string]bool, len(overrides))
+	for _, env := range base {
+		k, _, _ := strings.Cut(env, "=")
+		v, ok := overrides[k]
+		switch {
+		case !ok:
+			envs = append(envs, env)
+		case !replaced[k]:
+			envs = append(envs, k+"="+v)
+			replaced[k] = true
+		}
+	}
+
+	keys := make([]string, 0, len(overrides))
+	for k := range overrides {
+		if !replaced[k] {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		envs = append(envs, k+"="+overrides[k])
+	}
+
+	return envs, nil
+}
+
+// ParseEnvList parse environments like `[]string{"FOO=BAR"}` to map,
+// the last one wins if key is duplicated.
+func ParseEnvList(envs []string) (map[string]string, error) {
+	m := make(map[string]string, len(envs))
+	for _, env := range envs {
+		k, v, ok := strings.Cut(env, "=")
+		if !ok {
+			return nil, errors.Errorf("env %q should be like `KEY=VALUE`", env)
+		}
+		if !reEnvKey.MatchString(k) {
+			return nil, errors.Errorf("invalid env key %q", k)
+		}
+
+		m[k] = v
+	}
+
+	return m, nil
+}
+
+type runCMDOption struct {
+	envMap map[string]string
+	stdin  []byte
+}
+
+func newRunCMDOption(opts ...RunCMDOption) (*runCMDOption, error) {
+	opt := new(runCMDOption)
+	for _, f := range opts {
+		if err := f(opt); err != nil {
+			return nil, err
+		}
+	}
+
+	return opt, nil
+}
+
+// RunCMDOption options for RunCMDWithEnv and RunCMD2
+type RunCMDOption func(*runCMDOption) error
+
+// WithCMDEnvMap set environments by map, built by BuildEnv.
+//
+// the base is envs passed to RunCMDWithEnv/RunCMD2,
+// or os.Environ() if envs is empty.
+func WithCMDEnvMap(envs map[string]string) RunCMDOption {
+	return func(opt *runCMDOption) error {
+		opt.envMap = envs
+		return nil
+	}
+}
+
+// WithCMDStdin feed stdin to the command
+func WithCMDStdin(stdin []byte) RunCMDOption {
+	return func(opt *runCMDOption) error {
+		opt.stdin = stdin
+		return nil
+	}
+}
+
+// newCMD new exec.Cmd with environments and stdin by opts
+func newCMD(ctx context.Context, app string,
+	args []string, envs []string, opts ...RunCMDOption) (*exec.Cmd, error) {
+	opt, err := newRunCMDOption(opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "apply option")
+	}
+
+	cmd := exec.CommandContext(ctx, app, args...)
+	if cmd.Env, err = cmdEnvs(envs, opt); err != nil {
+		return nil, errors.Wrap(err, "build envs")
+	}
+
+	if len(opt.stdin) != 0 {
+		cmd.Stdin = bytes.NewReader(opt.stdin)
+	}
+
+	return cmd, nil
+}
+
+// cmdEnvs return environments for exec.Cmd, nil means inherit current process's
+func cmdEnvs(envs []string, opt *runCMDOption) ([]string, error) {
+	if len(opt.envMap) == 0 {
+		if len(envs) == 0 {
+			return nil, nil
+		}
+
+		return envs, nil
+	}
+
+	if len(envs) == 0 {
+		envs = nil
+	}
+
+	return BuildEnv(envs, opt.envMap)
+}
+
 // RunCMDWithEnv run command with environments
 //
 // # Args
-//   - envs: []string{"FOO=BAR"}
+//   - envs: []string{"FOO=BAR"}, if not empty, the command
+//     will not inherit current process's environments
+//   - opts: use WithCMDEnvMap to override environments by map,
+//     WithCMDStdin to feed stdin
 func RunCMDWithEnv(ctx context.Context, app string,
-	args []string, envs []string) (stdout []byte, err error) {
-	cmd := exec.CommandContext(ctx, app, args...)
-
-	if len(envs) != 0 {
-		cmd.Env = append(cmd.Env, envs...)
+	args []string, envs []string, opts ...RunCMDOption) (stdout []byte, err error) {
+	cmd, err := newCMD(ctx, app, args, envs, opts...)
+	if err != nil {
+		return nil, err
 	}
 
 	stdout, err = cmd.CombinedOutput()
@@ -754,9 +1098,12 @@ func RunCMDWithEnv(ctx context.Context, app string,
 func RunCMD2(ctx context.Context, app string,
 	args []string, envs []string,
 	stdoutHandler, stderrHandler func(string),
+	opts ...RunCMDOption,
 ) (err error) {
-	cmd := exec.CommandContext(ctx, app, args...)
-	cmd.Env = append(cmd.Env, envs...)
+	cmd, err := newCMD(ctx, app, args, envs, opts...)
+	if err != nil {
+		return err
+	}
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -870,11 +1217,12 @@ func IsPanic(f func()) (isPanic bool) {
 	return false
 }
 
-// IsPanic2 check is `f()` throw panic, and return panic as error
+// IsPanic2 check is `f()` throw panic, and return panic as *PanicError,
+// which carries the recovered value and the stack where panic happened.
 func IsPanic2(f func()) (err error) {
 	defer func() {
 		if panicRet := recover(); panicRet != nil {
-			err = errors.Errorf("panic: %v", panicRet)
+			err = newPanicError(panicRet)
 		}
 	}()
 
@@ -918,6 +1266,9 @@ func WithStopSignalCloseSignals(signals ...os.Signal) StopSignalOptFunc {
 // which is closed on one of these signals. If a second signal is caught, the program
 // is terminated with exit code 1.
 //
+// On windows, os.Interrupt is CTRL_C/CTRL_BREAK, and CTRL_CLOSE/CTRL_LOGOFF/CTRL_SHUTDOWN
+// are delivered as SIGTERM by os/signal, so closing the console also closes stopCh.
+//
 // Copied from https://github.com/kubernetes/sample-controller
 func StopSignal(optfs ...StopSignalOptFunc) (stopCh <-chan struct{}) {
 	opt := &stopSignalOpt{
@@ -1019,7 +1370,39 @@ func PrettyBuildInfo(opts ...PrettyBuildInfoOption) string {
 	return string(ver)
 }
 
-// IsEmpty is empty
+// BuildVCSInfo return vcs revision, whether the working tree is modified,
+// and commit time from build settings, ok is false if vcs info not stamped,
+// like built by `go build -buildvcs=false` or `go test`.
+func BuildVCSInfo() (revision string, dirty bool, buildTime time.Time, ok bool) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "", false, time.Time{}, false
+	}
+
+	return buildVCSInfo(info.Settings)
+}
+
+func buildVCSInfo(settings []debug.BuildSetting) (
+	revision string, dirty bool, buildTime time.Time, ok bool) {
+	for _, s := range settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision, ok = s.Value, true
+		case "vcs.modified":
+			dirty = s.Value == "true"
+		case "vcs.time":
+			buildTime, _ = time.Parse(time.RFC3339, s.Value)
+		}
+	}
+
+	return revision, dirty, buildTime, ok
+}
+
+// IsEmpty is empty, means val is zero value, or pointer to zero value.
+//
+// zero value is checked by reflect.Value.IsZero, so `""` is empty,
+// but `"  "` and non-nil slice/map with zero length are not,
+// use IsBlank for them.
 func IsEmpty(val any) bool {
 	t := reflect.TypeOf(val)
 	v := reflect.ValueOf(val)
@@ -1040,6 +1423,39 @@ func IsEmpty(val any) bool {
 	return false
 }
 
+// IsBlank is blank, means val is nil, zero value, whitespace-only string,
+// or slice/map/chan/array with zero length.
+// pointers and interfaces are recursed into.
+//
+// used for validating forms or configs, where `"  "` or `[]string{}`
+// is as meaningless as missing.
+func IsBlank(val any) bool {
+	return isBlankValue(reflect.ValueOf(val))
+}
+
+func isBlankValue(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return true
+		}
+
+		return isBlankValue(v.Elem())
+	case reflect.String:
+		return strings.TrimSpace(v.String()) == ""
+	case reflect.Slice, reflect.Map, reflect.Chan:
+		return v.Len() == 0
+	case reflect.Array:
+		return v.Len() == 0 || v.IsZero()
+	default:
+		return v.IsZero()
+	}
+}
+
 // NotEmpty val should not be empty, with pretty error msg
 func NotEmpty(val any, name string) error {
 	t := reflect.TypeOf(val)
@@ -1070,6 +1486,30 @@ func OptionalVal[T any](ptr *T, optionalVal T) T {
 	return *ptr
 }
 
+// FirstNonEmpty return the first val that is not empty by IsEmpty,
+// ok is false if all vals are empty
+func FirstNonEmpty[T any](vals ...T) (val T, ok bool) {
+	for i := range vals {
+		if !IsEmpty(&vals[i]) {
+			return vals[i], true
+		}
+	}
+
+	return val, false
+}
+
+// CoalescePtr return the first ptr that is neither nil nor point to empty value,
+// return nil if all ptrs are empty
+func CoalescePtr[T any](ptrs ...*T) *T {
+	for _, ptr := range ptrs {
+		if !IsEmpty(ptr) {
+			return ptr
+		}
+	}
+
+	return nil
+}
+
 // CostSecs convert duration to string like `0.25s`
 func CostSecs(cost time.Duration) string {
 	return fmt.Sprintf("%.2fs", float64(cost)/float64(time.Second))
@@ -1124,6 +1564,7 @@ func ParseUUID7(val string) (UUID7Itf, error) {
 //
 // do not use this type directly.
 type Delayer struct {
+	ctx     context.Context
 	startAt time.Time
 	d       time.Duration
 }
@@ -1132,7 +1573,15 @@ type Delayer struct {
 //
 //	defer NewDelay(time.Second).Wait()
 func NewDelay(d time.Duration) *Delayer {
+	return NewDelayWithContext(context.Background(), d)
+}
+
+// NewDelayWithContext like NewDelay, but Wait returns early when ctx is done
+//
+//	defer NewDelayWithContext(ctx, time.Second).Wait()
+func NewDelayWithContext(ctx context.Context, d time.Duration) *Delayer {
 	return &Delayer{
+		ctx:     ctx,
 		startAt: time.Now(),
 		d:       d,
 	}
@@ -1140,7 +1589,7 @@ func NewDelay(d time.Duration) *Delayer {
 
 // Wait wait in defer
 func (d *Delayer) Wait() {
-	time.Sleep(d.d - time.Since(d.startAt))
+	SleepWithContext(d.ctx, d.d-time.Since(d.startAt))
 }
 
 // FileHashSharding get file hash sharding path
@@ -1162,6 +1611,18 @@ func ReverseSlice[T any](s []T) {
 	}
 }
 
+// ReversedSlice return a reversed copy of s, s is left untouched.
+//
+// it is the allocating alternative of ReverseSlice.
+func ReversedSlice[T any](s []T) []T {
+	r := make([]T, len(s))
+	for i, v := range s {
+		r[len(s)-1-i] = v
+	}
+
+	return r
+}
+
 // RemoveEmptyVal remove empty value in map
 func RemoveEmptyVal(m map[string]any) map[string]any {
 	for k, v := range m {
@@ -1252,7 +1713,7 @@ func CombineSortedChain[T Sortable](sortOrder common.SortOrder, chans ...chan T)
 
 				// there is no active chans
 				if len(activeChans) == 0 {
-					for i := 0; i < heap.Len(); i++ {
+					for heap.Len() > 0 {
 						it := heap.Pop()
 						result <- it.GetVal()
 					}
@@ -1273,7 +1734,88 @@ func CombineSortedChain[T Sortable](sortOrder common.SortOrder, chans ...chan T)
 	return result, nil
 }
 
+// SortedChanFromSlice sort a copy of s by order, and stream it by chan.
+//
+// the returned chan is buffered with all items and already closed,
+// so no goroutine is involved and it is safe to abandon it early.
+// it could be used as the input of CombineSortedChain.
+func SortedChanFromSlice[T Sortable](s []T, order common.SortOrder) chan T {
+	sorted := slices.Clone(s)
+	slices.Sort(sorted)
+	if order == common.SortOrderDesc {
+		slices.Reverse(sorted)
+	}
+
+	ch := make(chan T, len(sorted))
+	for _, v := range sorted {
+		ch <- v
+	}
+	close(ch)
+
+	return ch
+}
+
+// DeduplicateSortedChan drop consecutive duplicates in sorted chan in,
+// like the result of CombineSortedChain with overlapping chans.
+//
+// the returned chan will be closed after in is closed or ctx is done.
+// cancel ctx if you abandon the returned chan early, otherwise
+// the inner goroutine will be blocked forever.
+func DeduplicateSortedChan[T comparable](ctx context.Context, in <-chan T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+
+		var (
+			last    T
+			hasLast bool
+		)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case v, ok := <-in:
+				if !ok {
+					return
+				}
+				if hasLast && v == last {
+					continue
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case out <- v:
+				}
+
+				last, hasLast = v, true
+			}
+		}
+	}()
+
+	return out
+}
+
+// TakeN read at most n items from in, return early if in is closed.
+//
+// it runs in the caller's goroutine, so no goroutine will be leaked.
+func TakeN[T any](in <-chan T, n int) []T {
+	var result []T
+	for i := 0; i < n; i++ {
+		v, ok := <-in
+		if !ok {
+			break
+		}
+
+		result = append(result, v)
+	}
+
+	return result
+}
+
 // FilterSlice filters a slice inplace
+//
+// s is modified and truncated, use FilterSliceCopy if you still need the original slice.
 func FilterSlice[T any](s []T, f func(v T) bool) []T {
 	var j int
 	for _, v := range s {
@@ -1287,6 +1829,21 @@ func FilterSlice[T any](s []T, f func(v T) bool) []T {
 	return s[:j:j]
 }
 
+// FilterSliceCopy return a new slice that contains elements satisfy f,
+// s is left untouched.
+//
+// it is the allocating alternative of FilterSlice.
+func FilterSliceCopy[T any](s []T, f func(v T) bool) []T {
+	r := make([]T, 0, len(s))
+	for _, v := range s {
+		if f(v) {
+			r = append(r, v)
+		}
+	}
+
+	return r
+}
+
 // GetEnvInsensitive get env case insensitive
 func GetEnvInsensitive(key string) (values []string) {
 	for _, e := range os.Environ() {
@@ -9,14 +9,19 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"runtime"
+	"runtime/debug"
+	"slices"
 	"strings"
 	"sync"
-	"syscall"
+	"sync/atomic"
 	"testing"
 	"time"
+	"unsafe"
 
 	"github.com/Laisky/errors/v2"
 	"github.com/Laisky/zap"
@@ -227,6 +232,102 @@ func ExampleGetFuncName() {
 	GetFuncName(testFoo) // "github.com/Laisky/go-utils.testFoo"
 }
 
+type testFuncNameT struct{}
+
+func (testFuncNameT) Value()    {}
+func (*testFuncNameT) Pointer() {}
+
+func TestGetFuncNameShort(t *testing.T) {
+	t.Parallel()
+
+	var ins testFuncNameT
+	closure := func() {}
+	for _, c := range []struct {
+		f      any
+		expect string
+	}{
+		{testFoo, "testFoo"},
+		{GetFuncNameShort, "GetFuncNameShort"},
+		{log.New, "New"},
+		{testFuncNameT.Value, "testFuncNameT.Value"},
+		{(*testFuncNameT).Pointer, "(*testFuncNameT).Pointer"},
+		{ins.Value, "testFuncNameT.Value"},
+		{ins.Pointer, "(*testFuncNameT).Pointer"},
+		{closure, "TestGetFuncNameShort.func1"},
+		{func() {}, "TestGetFuncNameShort.func2"},
+		{nil, ""},
+		{123, ""},
+		{(func())(nil), ""},
+	} {
+		require.Equal(t, c.expect, GetFuncNameShort(c.f), c.expect)
+	}
+}
+
+func TestCallerName(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "v4.TestCallerName", CallerName(0))
+	func() {
+		require.Equal(t, "v4.TestCallerName.func1", CallerName(0))
+		require.Equal(t, "v4.TestCallerName", CallerName(1))
+	}()
+	require.Empty(t, CallerName(1000))
+}
+
+func TestCallerFileLine(t *testing.T) {
+	t.Parallel()
+
+	file, line := CallerFileLine(0)
+	require.True(t, strings.HasSuffix(file, "/utils_test.go"), file)
+	require.Equal(t, 1, strings.Count(file, "/"), file)
+	require.Positive(t, line)
+
+	file, line = CallerFileLine(1000)
+	require.Empty(t, file)
+	require.Zero(t, line)
+}
+
+func TestCaller(t *testing.T) {
+	t.Parallel()
+
+	_, _, wantLine, _ := runtime.Caller(0)
+	file, line, funcName := Caller(0)
+	require.True(t, strings.HasSuffix(file, "/utils_test.go"), file)
+	require.Equal(t, wantLine+1, line)
+	require.Equal(t, "v4.TestCaller", funcName)
+
+	func() {
+		file, line, funcName := Caller(1)
+		require.True(t, strings.HasSuffix(file, "/utils_test.go"), file)
+		require.Equal(t, wantLine+11, line)
+		require.Equal(t, "v4.TestCaller", funcName)
+	}()
+
+	require.Equal(t, fmt.Sprintf("%s:%d v4.TestCaller", file, wantLine+13), CallerString(0))
+	func() {
+		require.Equal(t, fmt.Sprintf("%s:%d v4.TestCaller.func2", file, wantLine+15), CallerString(0))
+	}()
+
+	file, line, funcName = Caller(1000)
+	require.Empty(t, file)
+	require.Zero(t, line)
+	require.Empty(t, funcName)
+	require.Empty(t, CallerString(1000))
+}
+
+func TestStackTrace(t *testing.T) {
+	t.Parallel()
+
+	stack := StackTrace(2)
+	require.Len(t, stack, 2)
+	require.Contains(t, stack[0], "/utils_test.go:")
+	require.True(t, strings.HasSuffix(stack[0], " v4.TestStackTrace"), stack[0])
+	require.True(t, strings.HasSuffix(stack[1], " testing.tRunner"), stack[1])
+
+	require.Nil(t, StackTrace(0))
+	require.Greater(t, len(StackTrace(100)), 2)
+}
+
 func TestFallBack(t *testing.T) {
 	t.Parallel()
 
@@ -240,6 +341,28 @@ func TestFallBack(t *testing.T) {
 	}
 }
 
+func TestFallBack2(t *testing.T) {
+	t.Parallel()
+
+	got, err := FallBack2(func() (any, error) {
+		panic("got error")
+	}, 10)
+	require.EqualError(t, err, "panic: got error")
+	require.Equal(t, 10, got)
+
+	got, err = FallBack2(func() (any, error) {
+		return nil, errors.New("yo")
+	}, 10)
+	require.EqualError(t, err, "yo")
+	require.Equal(t, 10, got)
+
+	got, err = FallBack2(func() (any, error) {
+		return 1, nil
+	}, 10)
+	require.NoError(t, err)
+	require.Equal(t, 1, got)
+}
+
 func ExampleFallBack() {
 	targetFunc := func() any {
 		panic("someting wrong")
@@ -840,29 +963,6 @@ func TestConvert2Map(t *testing.T) {
 	}
 }
 
-func TestStopSignal(t *testing.T) {
-	stopCh := StopSignal(WithStopSignalCloseSignals(os.Interrupt, syscall.SIGTERM))
-	select {
-	case <-stopCh:
-		t.Fatal("should not be closed")
-	default:
-	}
-
-	err := syscall.Kill(syscall.Getpid(), syscall.SIGINT)
-	require.NoError(t, err)
-
-	_, ok := <-stopCh
-	require.False(t, ok)
-
-	// case: panic
-	{
-		ok := IsPanic(func() {
-			_ = StopSignal(WithStopSignalCloseSignals())
-		})
-		require.True(t, ok)
-	}
-}
-
 func TestBytes2Str(t *testing.T) {
 	rawStr := RandomStringWithLength(1024)
 	rawBytes := []byte(rawStr)
@@ -944,6 +1044,8 @@ func TestJSONMd5(t *testing.T) {
 		{"3", args{new(foo)}, "555dfa90763bd852d5dd9144887eed97", false},
 		{"4", args{foo{""}}, "555dfa90763bd852d5dd9144887eed97", false},
 		{"5", args{foo{Name: "a"}}, "88148e411b9b424a2e0ddf108cb02baa", false},
+		{"nil slice", args{[]foo(nil)}, "37a6259cc0c1dae299a7866489dff0bd", false},
+		{"nil map", args{map[string]foo(nil)}, "37a6259cc0c1dae299a7866489dff0bd", false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -971,6 +1073,116 @@ func TestNilInterface(t *testing.T) {
 	require.False(t, NilInterface(tf))
 	require.False(t, NilInterface(123))
 	require.True(t, NilInterface(nil))
+
+	var (
+		nilSlice   []int
+		nilMap     map[string]int
+		nilChan    chan int
+		nilFunc    func()
+		nilUnsafe  unsafe.Pointer
+		nilErr     error
+		nilPtr     *int
+		nilItfPtr  *any
+		nonNilItf  any = 1
+		nonNilItfP     = &nonNilItf
+	)
+	for _, c := range []struct {
+		name string
+		data any
+		want bool
+	}{
+		{"nil", nil, true},
+		{"nil error", nilErr, true},
+		{"nil pointer", nilPtr, true},
+		{"nil pointer to interface", nilItfPtr, true},
+		{"pointer", nonNilItfP, false},
+		{"nil slice", nilSlice, true},
+		{"empty slice", []int{}, false},
+		{"nil map", nilMap, true},
+		{"empty map", map[string]int{}, false},
+		{"nil chan", nilChan, true},
+		{"chan", make(chan int), false},
+		{"nil func", nilFunc, true},
+		{"func", func() {}, false},
+		{"nil unsafe pointer", nilUnsafe, true},
+		{"unsafe pointer", unsafe.Pointer(nonNilItfP), false},
+		{"bool", false, false},
+		{"int", 0, false},
+		{"float", 0.0, false},
+		{"complex", complex(0, 0), false},
+		{"string", "", false},
+		{"array", [0]int{}, false},
+		{"struct", struct{}{}, false},
+	} {
+		require.Equal(t, c.want, NilInterface(c.data), c.name)
+	}
+}
+
+func TestBuildVCSInfo(t *testing.T) {
+	_, _, _, _ = BuildVCSInfo()
+
+	revision, dirty, buildTime, ok := buildVCSInfo([]debug.BuildSetting{
+		{Key: "-compiler", Value: "gc"},
+		{Key: "vcs", Value: "git"},
+		{Key: "vcs.revision", Value: "2b10e57735f1"},
+		{Key: "vcs.time", Value: "2024-01-02T03:04:05Z"},
+		{Key: "vcs.modified", Value: "true"},
+	})
+	require.True(t, ok)
+	require.Equal(t, "2b10e57735f1", revision)
+	require.True(t, dirty)
+	require.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), buildTime)
+
+	revision, dirty, buildTime, ok = buildVCSInfo([]debug.BuildSetting{
+		{Key: "-compiler", Value: "gc"},
+	})
+	require.False(t, ok)
+	require.Empty(t, revision)
+	require.False(t, dirty)
+	require.True(t, buildTime.IsZero())
+}
+
+func TestIsBlank(t *testing.T) {
+	var (
+		nilPtr   *string
+		blank    = "  \t\n"
+		word     = " a "
+		nilSlice []int
+		nilErr   error
+		itf      any = "  "
+	)
+	for _, c := range []struct {
+		name         string
+		data         any
+		empty, blank bool
+	}{
+		{"nil", nil, true, true},
+		{"nil error", nilErr, true, true},
+		{"empty string", "", true, true},
+		{"blank string", blank, false, true},
+		{"string", word, false, false},
+		{"nil pointer", nilPtr, true, true},
+		{"pointer to blank string", &blank, false, true},
+		{"pointer to string", &word, false, false},
+		{"pointer to pointer", &nilPtr, true, true},
+		{"pointer to interface", &itf, false, true},
+		{"nil slice", nilSlice, true, true},
+		{"empty slice", []int{}, false, true},
+		{"slice", []int{0}, false, false},
+		{"empty map", map[string]int{}, false, true},
+		{"zero-length array", [0]int{}, true, true},
+		{"zero array", [2]int{}, true, true},
+		{"array", [2]int{0, 1}, false, false},
+		{"int", 0, true, true},
+		{"bool", true, false, false},
+		{"struct", struct{ A string }{}, true, true},
+		{"struct with blank field", struct{ A string }{A: " "}, false, false},
+	} {
+		if c.data != nil {
+			require.Equal(t, c.empty, IsEmpty(c.data), c.name)
+		}
+		require.Equal(t, c.blank, IsBlank(c.data), c.name)
+	}
 }
 
 func TestPanicIfErr(t *testing.T) {
@@ -1112,6 +1324,23 @@ func TestContains(t *testing.T) {
 	require.False(t, Contains([]int{1, 2, 3}, 4))
 }
 
+func TestContainsBy(t *testing.T) {
+	type user struct {
+		Name string
+	}
+	users := []user{{Name: "a"}, {Name: "b"}}
+
+	require.True(t, ContainsBy(users, func(u user) bool { return u.Name == "b" }))
+	require.False(t, ContainsBy(users, func(u user) bool { return u.Name == "c" }))
+	require.False(t, ContainsBy(nil, func(u user) bool { return true }))
+}
+
+func TestIndexOf(t *testing.T) {
+	require.Equal(t, 1, IndexOf([]string{"1", "2", "3", "2"}, "2"))
+	require.Equal(t, -1, IndexOf([]string{"1", "2", "3"}, "4"))
+	require.Equal(t, -1, IndexOf([]int(nil), 1))
+}
+
 func TestCtxKey(t *testing.T) {
 	// Warning: should not use empty type as context key
 	t.Run("empty type as key", func(t *testing.T) {
@@ -1216,6 +1445,42 @@ func TestOptionalVal(t *testing.T) {
 	require.Equal(t, v.BB, optFloat64)
 }
 
+func TestFirstNonEmpty(t *testing.T) {
+	val, ok := FirstNonEmpty("", "a", "b")
+	require.True(t, ok)
+	require.Equal(t, "a", val)
+
+	num, ok := FirstNonEmpty(0, 0, 3)
+	require.True(t, ok)
+	require.Equal(t, 3, num)
+
+	var nilErr error
+	err, ok := FirstNonEmpty(nilErr, errors.New("yo"))
+	require.True(t, ok)
+	require.EqualError(t, err, "yo")
+
+	val, ok = FirstNonEmpty("", "")
+	require.False(t, ok)
+	require.Empty(t, val)
+
+	_, ok = FirstNonEmpty[int]()
+	require.False(t, ok)
+}
+
+func TestCoalescePtr(t *testing.T) {
+	var (
+		nilPtr *string
+		empty  = ""
+		a      = "a"
+		b      = "b"
+	)
+
+	require.Equal(t, &a, CoalescePtr(nilPtr, &empty, &a, &b))
+	require.Equal(t, &b, CoalescePtr(&b, &a))
+	require.Nil(t, CoalescePtr(nilPtr, &empty))
+	require.Nil(t, CoalescePtr[string]())
+}
+
 func TestRunCMDWithEnv(t *testing.T) {
 	ctx := context.Background()
 
@@ -1248,6 +1513,78 @@ func TestRunCMDWithEnv(t *testing.T) {
 	}
 }
 
+func TestBuildEnv(t *testing.T) {
+	t.Parallel()
+
+	envs, err := BuildEnv([]string{"FOO=old", "BAR=1", "FOO=dup", "foo=lower"},
+		map[string]string{"FOO": "new", "NEW_B": "b", "NEW_A": "a=1"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"FOO=new", "BAR=1", "foo=lower", "NEW_A=a=1", "NEW_B=b"}, envs)
+
+	envs, err = BuildEnv(nil, map[string]string{"GO_UTILS_TEST_ENV": "yo"})
+	require.NoError(t, err)
+	require.Len(t, envs, len(os.Environ())+1)
+	require.Contains(t, envs, "GO_UTILS_TEST_ENV=yo")
+
+	envs, err = BuildEnv([]string{}, nil)
+	require.NoError(t, err)
+	require.Empty(t, envs)
+
+	_, err = BuildEnv(nil, map[string]string{"OK": "", "1BAD": "", "BAD-KEY": "", "": ""})
+	require.ErrorContains(t, err, `invalid env keys ["" "1BAD" "BAD-KEY"]`)
+
+	t.Run("parse", func(t *testing.T) {
+		m, err := ParseEnvList([]string{"FOO=BAR", "A=b=c", "EMPTY=", "FOO=BAZ"})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"FOO": "BAZ", "A": "b=c", "EMPTY": ""}, m)
+
+		_, err = ParseEnvList([]string{"FOO"})
+		require.ErrorContains(t, err, "should be like")
+		_, err = ParseEnvList([]string{"1FOO=BAR"})
+		require.ErrorContains(t, err, "invalid env key")
+
+		envs, err := BuildEnv([]string{}, m)
+		require.NoError(t, err)
+		got, err := ParseEnvList(envs)
+		require.NoError(t, err)
+		require.Equal(t, m, got)
+	})
+}
+
+func TestWithCMDEnvMap(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	envApp, err := exec.LookPath("env")
+	if err != nil {
+		t.Skip("env not found")
+	}
+
+	stdout, err := RunCMDWithEnv(ctx, envApp, nil, []string{"FOO=old", "BAR=1"},
+		WithCMDEnvMap(map[string]string{"FOO": "new"}))
+	require.NoError(t, err)
+	require.Equal(t, "FOO=new\nBAR=1\n", string(stdout))
+
+	// inherit current process's environments if envs is empty
+	stdout, err = RunCMDWithEnv(ctx, envApp, nil, nil,
+		WithCMDEnvMap(map[string]string{"GO_UTILS_TEST_ENV": "yo"}))
+	require.NoError(t, err)
+	got, err := ParseEnvList(strings.Split(strings.TrimSpace(string(stdout)), "\n"))
+	require.NoError(t, err)
+	require.Equal(t, "yo", got["GO_UTILS_TEST_ENV"])
+	require.Equal(t, os.Getenv("PATH"), got["PATH"])
+	require.Equal(t, 1, strings.Count(string(stdout), "GO_UTILS_TEST_ENV="))
+
+	_, err = RunCMDWithEnv(ctx, envApp, nil, nil, WithCMDEnvMap(map[string]string{"BAD-KEY": ""}))
+	require.ErrorContains(t, err, "invalid env keys")
+
+	var got2 atomic.Value
+	err = RunCMD2(ctx, envApp, nil, []string{"FOO=old"}, func(s string) { got2.Store(s) }, nil,
+		WithCMDEnvMap(map[string]string{"FOO": "new"}))
+	require.NoError(t, err)
+	require.Equal(t, "FOO=new", got2.Load())
+}
+
 func TestCostSecs(t *testing.T) {
 	d := time.Millisecond * 351
 	v := CostSecs(d)
@@ -1335,6 +1672,22 @@ func TestDelayer_Wait(t *testing.T) {
 	require.GreaterOrEqual(t, time.Since(startAt), delay)
 }
 
+func TestNewDelayWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	startAt := time.Now()
+	func() {
+		defer NewDelayWithContext(ctx, time.Second).Wait()
+		cancel()
+	}()
+	require.Less(t, time.Since(startAt), 500*time.Millisecond)
+
+	startAt = time.Now()
+	func() {
+		defer NewDelayWithContext(context.Background(), 10*time.Millisecond).Wait()
+	}()
+	require.GreaterOrEqual(t, time.Since(startAt), 10*time.Millisecond)
+}
+
 func ExampleNewDelay() {
 	startAt := time.Now()
 	delay := 10 * time.Millisecond
@@ -1482,6 +1835,11 @@ func TestRunCMD2(t *testing.T) {
 	stdoutMu.Unlock()
 }
 
+//go:noinline
+func panicForTest() {
+	panic("boom")
+}
+
 func TestIsPanic2(t *testing.T) {
 	t.Run("panic", func(t *testing.T) {
 		panicMsg := "test panic"
@@ -1497,6 +1855,25 @@ func TestIsPanic2(t *testing.T) {
 		}
 	})
 
+	t.Run("stack", func(t *testing.T) {
+		err := IsPanic2(panicForTest)
+
+		var perr *PanicError
+		require.ErrorAs(t, err, &perr)
+		require.Equal(t, "boom", perr.Value)
+		require.Contains(t, string(perr.Stack), "panicForTest")
+		require.Equal(t, "panic: boom", err.Error())
+		require.Contains(t, fmt.Sprintf("%+v", err), "panicForTest")
+		require.NotContains(t, fmt.Sprintf("%v", err), "panicForTest")
+	})
+
+	t.Run("error value", func(t *testing.T) {
+		cause := errors.New("yo")
+		err := IsPanic2(func() { panic(cause) })
+		require.ErrorIs(t, err, cause)
+		require.EqualError(t, err, "panic: yo")
+	})
+
 	t.Run("no panic", func(t *testing.T) {
 		f := func() {}
 		err := IsPanic2(f)
@@ -1633,6 +2010,22 @@ func TestReverseSlice(t *testing.T) {
 	}
 }
 
+func TestReversedSlice(t *testing.T) {
+	t.Parallel()
+
+	require.Empty(t, ReversedSlice([]int(nil)))
+	require.Equal(t, []int{1}, ReversedSlice([]int{1}))
+
+	input := []string{"a", "b", "c", "d"}
+	got := ReversedSlice(input)
+	require.Equal(t, []string{"d", "c", "b", "a"}, got)
+	require.Equal(t, []string{"a", "b", "c", "d"}, input)
+
+	// result does not share the backing array with input
+	got[0] = "x"
+	require.Equal(t, "d", input[3])
+}
+
 func TestUniqueStrings(t *testing.T) {
 	t.Parallel()
 
@@ -2140,6 +2533,96 @@ func Benchmark_CombineSortedChain(b *testing.B) {
 	})
 }
 
+func TestSortedChanUtils(t *testing.T) {
+	t.Parallel()
+
+	t.Run("chain", func(t *testing.T) {
+		t.Parallel()
+
+		for _, order := range []common.SortOrder{common.SortOrderAsc, common.SortOrderDesc} {
+			for round := 0; round < 20; round++ {
+				var (
+					shards [][]int
+					all    []int
+				)
+				for i := 0; i < 1+rand.Intn(5); i++ {
+					var shard []int
+					for j := 0; j < rand.Intn(50); j++ {
+						shard = append(shard, rand.Intn(30))
+					}
+
+					shards = append(shards, shard)
+					all = append(all, shard...)
+				}
+
+				expect := slices.Clone(all)
+				slices.Sort(expect)
+				expect = slices.Compact(expect)
+				if order == common.SortOrderDesc {
+					slices.Reverse(expect)
+				}
+
+				var chans []chan int
+				for _, shard := range shards {
+					chans = append(chans, SortedChanFromSlice(shard, order))
+				}
+
+				combined, err := CombineSortedChain(order, chans...)
+				require.NoError(t, err)
+
+				var got []int
+				for v := range DeduplicateSortedChan(context.Background(), combined) {
+					got = append(got, v)
+				}
+				require.Equal(t, expect, got, "order=%d, shards=%v", order, shards)
+			}
+		}
+	})
+
+	t.Run("from slice not modify input", func(t *testing.T) {
+		t.Parallel()
+
+		s := []string{"c", "a", "b"}
+		require.Equal(t, []string{"c", "b", "a"}, TakeN(SortedChanFromSlice(s, common.SortOrderDesc), 10))
+		require.Equal(t, []string{"c", "a", "b"}, s)
+		require.Empty(t, TakeN(SortedChanFromSlice([]string{}, common.SortOrderAsc), 10))
+	})
+
+	t.Run("take n", func(t *testing.T) {
+		t.Parallel()
+
+		ch := SortedChanFromSlice([]int{3, 1, 2, 5, 4}, common.SortOrderAsc)
+		require.Equal(t, []int{1, 2}, TakeN(ch, 2))
+		require.Equal(t, []int{3, 4, 5}, TakeN(ch, 10))
+		require.Empty(t, TakeN(ch, 10))
+		require.Empty(t, TakeN(make(chan int), 0))
+	})
+
+	t.Run("deduplicate abandoned", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan int)
+		go func() {
+			for i := 0; ; i++ {
+				select {
+				case in <- i:
+				case <-time.After(time.Second):
+					return
+				}
+			}
+		}()
+
+		out := DeduplicateSortedChan(ctx, in)
+		require.Equal(t, []int{0, 1, 2}, TakeN(out, 3))
+		cancel()
+
+		// out should be closed after ctx done
+		for range out {
+		}
+	})
+}
+
 func TestFilterSlice(t *testing.T) {
 	t.Parallel()
 
@@ -2189,6 +2672,30 @@ func TestFilterSlice(t *testing.T) {
 	}
 }
 
+func TestFilterSliceCopy(t *testing.T) {
+	t.Parallel()
+
+	s := []int{1, 2, 3, 4, 5, 6}
+	got := FilterSliceCopy(s, func(v int) bool { return v%2 == 0 })
+	require.Equal(t, []int{2, 4, 6}, got)
+	require.Equal(t, []int{1, 2, 3, 4, 5, 6}, s)
+
+	// result does not share memory with input
+	got[0] = 100
+	require.Equal(t, 2, s[1])
+
+	require.Empty(t, FilterSliceCopy([]int(nil), func(v int) bool { return true }))
+}
+
+func TestUniqueStringsCopy(t *testing.T) {
+	t.Parallel()
+
+	vs := []string{"a", "b", "a", "c", "b"}
+	require.Equal(t, []string{"a", "b", "c"}, UniqueStringsCopy(vs))
+	require.Equal(t, []string{"a", "b", "a", "c", "b"}, vs)
+	require.Empty(t, UniqueStringsCopy(nil))
+}
+
 func TestPrettyBuildInfo(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,58 @@
+//go:build !windows
+// +build !windows
+
+package utils
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStopSignal(t *testing.T) {
+	stopCh := StopSignal(WithStopSignalCloseSignals(os.Interrupt, syscall.SIGTERM))
+	select {
+	case <-stopCh:
+		t.Fatal("should not be closed")
+	default:
+	}
+
+	err := syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+	require.NoError(t, err)
+
+	_, ok := <-stopCh
+	require.False(t, ok)
+
+	// case: panic
+	{
+		ok := IsPanic(func() {
+			_ = StopSignal(WithStopSignalCloseSignals())
+		})
+		require.True(t, ok)
+	}
+}
+
+func TestRunCMDWithStdin(t *testing.T) {
+	ctx := context.Background()
+	out, err := RunCMDWithEnv(ctx, "cat", nil, nil, WithCMDStdin([]byte("hello")))
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(out))
+
+	out, err = DefaultCMDRunner.RunWithEnv(ctx, "cat", nil, nil, WithCMDStdin([]byte("world")))
+	require.NoError(t, err)
+	require.Equal(t, "world", string(out))
+}
+
+func TestRunCMD2Background(t *testing.T) {
+	t.Parallel()
+
+	// command exits normally and leaves a background child
+	startAt := time.Now()
+	err := RunCMD2(context.Background(), "sh", []string{"-c", "sleep 30 &"}, nil, nil, nil)
+	require.NoError(t, err)
+	require.Less(t, time.Since(startAt), 10*time.Second)
+}